@@ -15,6 +15,7 @@ import (
 	"goadmin-backend/internal/auth"
 	"goadmin-backend/internal/cmd/api"
 	"goadmin-backend/internal/platform/logging"
+	"goadmin-backend/internal/rebac"
 	"goadmin-backend/internal/repository/postgres"
 	"goadmin-backend/internal/user"
 )
@@ -57,7 +58,34 @@ func main() {
 
 	// repositories
 	userRepo := postgres.NewUserRepo(dbpool)
-	revokedTokenRepo := postgres.NewRevokedTokenRepo(dbpool)
+	revokedTokenRepo := auth.NewCachedRevokedTokenRepo(
+		postgres.NewRevokedTokenRepo(dbpool),
+		auth.DefaultRevocationCacheSize,
+		auth.DefaultRevocationCacheTTL,
+	)
+	refreshTokenRepo := postgres.NewRefreshTokenRepo(dbpool)
+	sessionRepo := postgres.NewSessionRepo(dbpool)
+	userTokenRepo := postgres.NewUserTokenRepo(dbpool)
+	mfaRepo := postgres.NewMFARepo(dbpool)
+	loginAttemptRepo := postgres.NewLoginAttemptRepo(dbpool)
+	roleRepo := postgres.NewRoleRepo(dbpool)
+	apiKeyRepo := postgres.NewAPIKeyRepo(dbpool)
+	serviceAccountRepo := postgres.NewServiceAccountRepo(dbpool)
+	oauthClientRepo := postgres.NewOAuthClientRepo(dbpool)
+	oauthGrantRepo := postgres.NewOAuthGrantRepo(dbpool)
+	userIdentityRepo := postgres.NewUserIdentityRepo(dbpool)
+	auditLogRepo := postgres.NewAuditLogRepo(dbpool)
+	relationDefinitionRepo := postgres.NewRelationDefinitionRepo(dbpool)
+
+	schema, err := api.LoadReBACSchema(apiCtx, cfg.ReBAC, relationDefinitionRepo)
+	if err != nil {
+		logger.Error("failed to load the authorization schema", slog.Any("err", err))
+
+		return
+	}
+
+	// tuples are checked against the schema on every write
+	relationTupleRepo := rebac.NewValidatingTupleRepo(postgres.NewRelationTupleRepo(dbpool), schema)
 
 	// services
 	idTknValidator, err := idtoken.NewValidator(apiCtx)
@@ -65,14 +93,111 @@ func main() {
 		logger.Error("failed to create id token validator", slog.Any("err", err))
 	}
 
+	mailer, err := api.NewMailer(cfg.Mail)
+	if err != nil {
+		logger.Error("failed to create mailer", slog.Any("err", err))
+
+		return
+	}
+
+	keyRing, err := api.NewKeyRing(cfg.API.Auth)
+	if err != nil {
+		logger.Error("failed to load signing keys", slog.Any("err", err))
+
+		return
+	}
+
+	passwordHasher, err := api.NewPasswordHasher(cfg.API.Auth.PasswordHash)
+	if err != nil {
+		logger.Error("failed to create password hasher", slog.Any("err", err))
+
+		return
+	}
+
+	passwordPolicy, err := api.NewPasswordPolicy(cfg.API.Auth.PasswordPolicy)
+	if err != nil {
+		logger.Error("failed to create password policy", slog.Any("err", err))
+
+		return
+	}
+
+	oauthServerConfig, err := api.NewOAuthServerConfig(cfg.API.Auth.OIDC, cfg.AppURL, keyRing)
+	if err != nil {
+		logger.Error("failed to configure the authorization server", slog.Any("err", err))
+
+		return
+	}
+
+	oidcProviders, err := api.NewOIDCProviders(cfg.OIDCProviders, cfg.Google, cfg.API.URL)
+	if err != nil {
+		logger.Error("failed to configure the OpenID Connect providers", slog.Any("err", err))
+
+		return
+	}
+
+	samlConnections, err := api.NewSAMLConnections(cfg.SAMLConnections, cfg.SAML, cfg.API.URL)
+	if err != nil {
+		logger.Error("failed to configure the SAML connections", slog.Any("err", err))
+
+		return
+	}
+
+	credentialVerifiers, err := api.NewCredentialVerifiers(cfg.API.Auth.LDAP)
+	if err != nil {
+		logger.Error("failed to configure the LDAP directories", slog.Any("err", err))
+
+		return
+	}
+
 	authService := auth.NewAuthService(
 		userRepo,
 		revokedTokenRepo,
-		[]byte(cfg.API.Auth.JWTSecret),
+		keyRing,
 		idTknValidator,
 		cfg.Google.ClientID,
+		auth.WithRefreshTokenRepo(refreshTokenRepo),
+		auth.WithSessionRepo(sessionRepo),
+		auth.WithUserTokenRepo(userTokenRepo),
+		auth.WithMailer(mailer, cfg.AppURL),
+		auth.WithMFARepo(mfaRepo, cfg.API.Auth.TOTPIssuer),
+		auth.WithRequireVerifiedEmail(cfg.API.Auth.RequireVerifiedEmail),
+		auth.WithLoginAttemptRepo(loginAttemptRepo, auth.LockoutPolicy{
+			MaxAttempts:     cfg.API.Auth.Lockout.MaxAttempts,
+			MaxIPAttempts:   cfg.API.Auth.Lockout.MaxIPAttempts,
+			BaseDelay:       cfg.API.Auth.Lockout.BaseDelay,
+			LockoutDuration: cfg.API.Auth.Lockout.Duration,
+		}),
+		auth.WithRoleRepo(roleRepo),
+		auth.WithAPIKeyRepo(apiKeyRepo),
+		auth.WithServiceAccountRepo(serviceAccountRepo),
+		auth.WithOAuthServer(oauthClientRepo, oauthGrantRepo, oauthServerConfig),
+		auth.WithOIDCProviders(oidcProviders...),
+		auth.WithSAMLConnections(samlConnections...),
+		auth.WithUserIdentityRepo(userIdentityRepo),
+		auth.WithAuditLogRepo(auditLogRepo),
+		auth.WithCredentialVerifiers(credentialVerifiers...),
+		auth.WithDirectoryUsernameLinking(api.UsernameLinkingDirectories(cfg.API.Auth.LDAP)...),
+		auth.WithLoginRedirect(api.NewLoginRedirectURL(cfg.AppURL)),
+		auth.WithPasswordHasher(passwordHasher),
+		auth.WithPasswordPolicy(passwordPolicy),
 	)
-	userService := user.NewUserService(userRepo)
+	userService := user.NewUserService(userRepo, authService, authService)
+
+	var rebacOpts []rebac.Option
+	if cfg.ReBAC.MaxConcurrency > 0 {
+		rebacOpts = append(rebacOpts, rebac.WithMaxConcurrency(cfg.ReBAC.MaxConcurrency))
+	}
+
+	rebacService := rebac.NewService(relationTupleRepo, relationDefinitionRepo, rebacOpts...)
+
+	go auth.SweepRevokedTokens(apiCtx, revokedTokenRepo, auth.DefaultSweepInterval, logger)
+
+	tokenTransport, err := api.NewTokenTransport(cfg.API.Auth)
+	if err != nil {
+		logger.Error("failed to configure token transport", slog.Any("err", err))
+
+		return
+	}
 
 	// openapi-validator
 	openapiValidator, err := api.NewOpenAPIValidator("", logger)
@@ -86,8 +211,9 @@ func main() {
 	apiHandler := api.NewRouter(
 		openapiValidator,
 		&api.Handlers{
-			AuthHandler:   auth.NewHandler(authService, logger),
+			AuthHandler:   auth.NewHandler(authService, logger, tokenTransport...),
 			UserHandler:   user.NewHandler(userService, logger),
+			ReBACHandler:  rebac.NewHandler(rebacService, logger),
 			HealthHandler: api.NewHealthHandler(logger),
 		},
 		logger,
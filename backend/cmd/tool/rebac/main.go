@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/joho/godotenv/autoload"
+
+	"goadmin-backend/internal/cmd/api"
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/rebac"
+	"goadmin-backend/internal/repository/postgres"
+)
+
+func usage() {
+	//nolint:forbidigo // This is a command line tool
+	fmt.Printf("usage: %s check [-explain] [-json] <user-id> <type>:<id> <permission>\n", path.Base(os.Args[0]))
+	os.Exit(1)
+}
+
+// rebac checks permissions against the relation tuples of the database of
+// the API config, explaining the decision on demand:
+//
+//	rebac check -explain 1 document:1 view
+func main() {
+	//nolint:gomnd // This is a command line tool
+	if len(os.Args) < 2 || os.Args[1] != "check" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	explain := flags.Bool("explain", false, "print the path the check evaluated")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("error parsing flags: %v", err)
+	}
+
+	//nolint:gomnd // This is a command line tool
+	if flags.NArg() != 3 {
+		usage()
+	}
+
+	entityType, entityID, ok := strings.Cut(flags.Arg(1), ":")
+	if !ok || entityType == "" || entityID == "" {
+		usage()
+	}
+
+	cfg, err := api.NewConfig()
+	if err != nil {
+		log.Fatalf("error parsing config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("error connecting to database: %v", err)
+	}
+	defer dbpool.Close()
+
+	service := rebac.NewService(postgres.NewRelationTupleRepo(dbpool), postgres.NewRelationDefinitionRepo(dbpool))
+
+	check := service.Check
+	if *explain {
+		check = service.Explain
+	}
+
+	result, err := check(ctx, &domain.User{ID: flags.Arg(0)}, &domain.Entity{EntityType: entityType, EntityID: entityID}, flags.Arg(2))
+	if err != nil {
+		// explanations fail with the trace up to the error
+		if result != nil && result.Trace != nil {
+			_ = rebac.WriteTrace(os.Stderr, result.Trace)
+		}
+
+		log.Fatalf("error checking: %v", err) //nolint:gocritic // nothing to clean up but the pool
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("error marshalling: %v", err) //nolint:gocritic // nothing to clean up but the pool
+		}
+
+		Printf("%s\n", b)
+
+		return
+	}
+
+	decision := "denied"
+	if result.Allowed {
+		decision = "allowed"
+	}
+
+	Printf("%s (depth %d)\n", decision, result.Depth)
+
+	if result.Trace != nil {
+		if err := rebac.WriteTrace(os.Stdout, result.Trace); err != nil {
+			log.Fatalf("error writing trace: %v", err) //nolint:gocritic // nothing to clean up but the pool
+		}
+	}
+}
+
+func Printf(format string, a ...any) {
+	//nolint:forbidigo // This is a command line tool
+	fmt.Printf(format, a...)
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS refresh_token (
+  id TEXT PRIMARY KEY,
+  family_id TEXT NOT NULL,
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  rotated_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
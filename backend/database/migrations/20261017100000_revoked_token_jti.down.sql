@@ -0,0 +1,8 @@
+-- The raw tokens cannot be restored; the revocations are dropped.
+DROP TABLE IF EXISTS revoked_token;
+
+CREATE TABLE IF NOT EXISTS revoked_token (
+  id BIGSERIAL PRIMARY KEY,
+  token TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,17 @@
+-- Revocations are keyed by the "jti" claim and only kept until the token
+-- expires. Entries of tokens issued without a "jti" are keyed by the SHA-256
+-- of the token; access tokens lived for an hour at most.
+ALTER TABLE revoked_token DROP CONSTRAINT IF EXISTS revoked_token_pkey;
+ALTER TABLE revoked_token DROP COLUMN IF EXISTS id;
+ALTER TABLE revoked_token ADD COLUMN expires_at TIMESTAMPTZ;
+
+UPDATE revoked_token
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    expires_at = COALESCE(created_at, CURRENT_TIMESTAMP) + INTERVAL '1 hour';
+
+ALTER TABLE revoked_token ALTER COLUMN expires_at SET NOT NULL;
+ALTER TABLE revoked_token DROP CONSTRAINT IF EXISTS revoked_token_token_key;
+ALTER TABLE revoked_token RENAME COLUMN token TO jti;
+ALTER TABLE revoked_token ADD PRIMARY KEY (jti);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_token_expires_at ON revoked_token (expires_at);
@@ -0,0 +1,13 @@
+-- Single-use secrets sent to users out of band (password reset, ...).
+-- Only the SHA-256 hash of a secret is stored.
+CREATE TABLE IF NOT EXISTS user_token (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  purpose TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_token_user_id_purpose_idx ON user_token (user_id, purpose);
@@ -0,0 +1,4 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- accounts created before e-mails were verified keep working
+UPDATE "user" SET email_verified_at = created_at WHERE email_verified_at IS NULL;
@@ -0,0 +1,20 @@
+-- TOTP (RFC 6238) second factor, one per user. It is only required at
+-- sign-in once confirmed; last_used_step keeps codes from being replayed.
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id BIGINT PRIMARY KEY REFERENCES "user"(id) ON DELETE CASCADE,
+  secret TEXT NOT NULL,
+  confirmed_at TIMESTAMPTZ,
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single-use recovery codes. Only the SHA-256 hash of a code is stored.
+CREATE TABLE IF NOT EXISTS mfa_recovery_code (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_code_user_id_idx ON mfa_recovery_code (user_id);
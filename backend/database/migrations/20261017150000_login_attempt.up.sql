@@ -0,0 +1,9 @@
+-- Recent failed sign-ins per username ("user:<name>") and per client IP
+-- address ("ip:<address>"), used to back off and lock out brute-force
+-- attempts.
+CREATE TABLE IF NOT EXISTS login_attempt (
+  key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until TIMESTAMPTZ
+);
@@ -0,0 +1,12 @@
+-- Machine clients signing in with the OAuth2 client credentials grant.
+-- Only the SHA-256 hash of a client secret is stored.
+CREATE TABLE IF NOT EXISTS service_account (
+  id BIGSERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  client_id TEXT NOT NULL UNIQUE,
+  client_secret_hash TEXT NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_by BIGINT REFERENCES "user"(id) ON DELETE SET NULL,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_authorization_code;
+DROP TABLE IF EXISTS oauth_consent;
+DROP TABLE IF EXISTS oauth_client;
@@ -0,0 +1,36 @@
+-- Applications signing their users in with goadmin as the OAuth2/OpenID
+-- Connect provider. Public clients have no secret.
+CREATE TABLE IF NOT EXISTS oauth_client (
+  id BIGSERIAL PRIMARY KEY,
+  client_id TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  client_secret_hash TEXT,
+  redirect_uris TEXT[] NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Scopes a user allowed a client to get.
+CREATE TABLE IF NOT EXISTS oauth_consent (
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  client_id TEXT NOT NULL REFERENCES oauth_client(client_id) ON DELETE CASCADE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, client_id)
+);
+
+-- Issued authorization codes, removed when they are exchanged. Only the
+-- SHA-256 hash of a code is stored.
+CREATE TABLE IF NOT EXISTS oauth_authorization_code (
+  code_hash TEXT PRIMARY KEY,
+  client_id TEXT NOT NULL REFERENCES oauth_client(client_id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  nonce TEXT NOT NULL DEFAULT '',
+  code_challenge TEXT NOT NULL,
+  auth_time TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,14 @@
+-- Accounts of users at external identity providers (Google, Okta, ...),
+-- known by the subject of the provider's ID tokens.
+CREATE TABLE IF NOT EXISTS user_identity (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '',
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_id_idx ON user_identity (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS relation_tuple;
+DROP TABLE IF EXISTS relation_definition;
@@ -0,0 +1,31 @@
+-- Relations of the ReBAC model: definitions say which relations and
+-- permissions entity types have and which subjects grant them, tuples
+-- relate entities to subjects, like document:1#viewer@group:1#member.
+-- Entities are referenced by type and ID only, so that any table can take
+-- part. An empty subject_relation stands for the subject itself.
+CREATE TABLE IF NOT EXISTS relation_definition (
+  id BIGSERIAL PRIMARY KEY,
+  entity_type TEXT NOT NULL,
+  relation_type TEXT NOT NULL,
+  subject_type TEXT NOT NULL,
+  subject_relation TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (entity_type, relation_type, subject_type, subject_relation)
+);
+
+CREATE TABLE IF NOT EXISTS relation_tuple (
+  id BIGSERIAL PRIMARY KEY,
+  entity_type TEXT NOT NULL,
+  entity_id TEXT NOT NULL,
+  relation TEXT NOT NULL,
+  subject_type TEXT NOT NULL,
+  subject_id TEXT NOT NULL,
+  subject_relation TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- also the index of the subjects of an entity's relation
+  UNIQUE (entity_type, entity_id, relation, subject_type, subject_id, subject_relation)
+);
+
+-- the entities a subject is related to, for reverse lookups
+CREATE INDEX IF NOT EXISTS relation_tuple_subject_idx
+  ON relation_tuple (subject_type, subject_id, subject_relation, entity_type, relation);
@@ -1 +1,143 @@
 package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+)
+
+var (
+	ErrRolesNotSupported = errors.New("roles are not enabled")
+	ErrInsufficientScope = errors.New("insufficient scope")
+	ErrAPIKeyNotAllowed  = errors.New("api keys are not allowed")
+)
+
+// WithRoleRepo enables role checks such as the RequireRole middleware.
+func WithRoleRepo(repo domain.RoleRepository) Option {
+	return func(a *authService) {
+		a.roleRepo = repo
+	}
+}
+
+// HasRole reports whether a user has any of the given roles
+func (a *authService) HasRole(ctx context.Context, userID string, roles ...string) (bool, error) {
+	if a.roleRepo == nil {
+		return false, ErrRolesNotSupported
+	}
+
+	userRoles, err := a.roleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("find roles error %w", err)
+	}
+
+	for _, role := range userRoles {
+		if slices.Contains(roles, role.Name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RequireRole only lets through users having any of the given roles. It has
+// to run after the Authenticator middleware.
+func (h *Handler) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			user, ok := UserFromContext(req.Context())
+			if !ok {
+				httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+				return
+			}
+
+			allowed, err := h.authService.HasRole(req.Context(), user.ID, roles...)
+			if err != nil {
+				h.Logger.Error("error checking roles", slog.Any("err", err))
+
+				httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+				return
+			}
+
+			if !allowed {
+				httperr.JSONError(res, errors.New("missing role"), http.StatusForbidden, req.URL.Path)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// RequireScope only lets through requests authenticated with an access token
+// of a user, or with an API key or service account token granted scope. It
+// has to run after the Authenticator middleware.
+func (h *Handler) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if scopes, ok := grantedScopes(req.Context()); ok && !slices.Contains(scopes, scope) {
+				httperr.JSONError(res, ErrInsufficientScope, http.StatusForbidden, req.URL.Path)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// RejectAPIKeys only lets through requests authenticated with an access
+// token, e.g. to manage the account itself. It has to run after the
+// Authenticator middleware.
+func (h *Handler) RejectAPIKeys() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if _, ok := APIKeyFromContext(req.Context()); ok {
+				httperr.JSONError(res, ErrAPIKeyNotAllowed, http.StatusForbidden, req.URL.Path)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// RejectImpersonation only lets through requests of users acting
+// themselves, e.g. to change credentials or to administer other users. It
+// has to run after the Authenticator middleware.
+func (h *Handler) RejectImpersonation() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if _, ok := ActorFromContext(req.Context()); ok {
+				httperr.JSONError(res, ErrImpersonationNotAllowed, http.StatusForbidden, req.URL.Path)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// grantedScopes returns the scopes a request is limited to; ok is false when
+// it is not limited, as for access tokens of users.
+func grantedScopes(ctx context.Context) ([]string, bool) {
+	if apiKey, ok := APIKeyFromContext(ctx); ok {
+		return apiKey.Scopes, true
+	}
+
+	if account, ok := ServiceAccountFromContext(ctx); ok {
+		return account.Scopes, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// APIKeyPrefix starts every API key, which tells them apart from JWTs
+	// and makes leaked keys easy to scan for.
+	APIKeyPrefix = "gak_"
+
+	// apiKeySize is the number of random bytes in an API key.
+	apiKeySize = 32
+
+	// apiKeyPrefixLength is how many characters of a key are kept in clear
+	// to tell keys apart.
+	apiKeyPrefixLength = len(APIKeyPrefix) + 8
+
+	// apiKeyTouchInterval limits how often the last use of a key is
+	// written.
+	apiKeyTouchInterval = time.Minute
+)
+
+// Scopes API keys can be granted. Access tokens of signed-in users are not
+// restricted by scopes.
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+)
+
+var (
+	ErrAPIKeysNotSupported = errors.New("api keys are not enabled")
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrUnknownScope        = errors.New("unknown scope")
+	ErrInvalidExpiry       = errors.New("expiry must be in the future")
+)
+
+// APIKeyScopes returns the scopes API keys can be granted.
+func APIKeyScopes() []string {
+	return []string{ScopeUsersRead, ScopeUsersWrite}
+}
+
+// WithAPIKeyRepo enables API keys.
+func WithAPIKeyRepo(repo domain.APIKeyRepository) Option {
+	return func(a *authService) {
+		a.apiKeyRepo = repo
+	}
+}
+
+// IsAPIKey reports whether a credential found by FindToken is an API key
+// rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// CreateAPIKey issues an API key for a user. The key itself is only returned
+// here, just its hash is stored.
+func (a *authService) CreateAPIKey(
+	ctx context.Context,
+	userID, name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (*domain.APIKey, string, error) {
+	if a.apiKeyRepo == nil {
+		return nil, "", ErrAPIKeysNotSupported
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(APIKeyScopes(), scope) {
+			return nil, "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiry
+	}
+
+	secret, err := random.Token(apiKeySize)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate api key error %w", err)
+	}
+
+	key := APIKeyPrefix + secret
+
+	scopes = append([]string{}, scopes...)
+	slices.Sort(scopes)
+
+	apiKey, err := a.apiKeyRepo.Create(ctx, &domain.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   hashToken(key),
+		Scopes:    slices.Compact(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("create api key error %w", err)
+	}
+
+	return apiKey, key, nil
+}
+
+// ListAPIKeys returns the API keys of a user
+func (a *authService) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	if a.apiKeyRepo == nil {
+		return nil, ErrAPIKeysNotSupported
+	}
+
+	keys, err := a.apiKeyRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find api keys error %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey deletes an API key of a user
+func (a *authService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	if a.apiKeyRepo == nil {
+		return ErrAPIKeysNotSupported
+	}
+
+	if err := a.apiKeyRepo.Delete(ctx, userID, id); err != nil {
+		return fmt.Errorf("delete api key error %w", err)
+	}
+
+	return nil
+}
+
+// VerifyAPIKey returns the owner of a valid API key, and the key.
+func (a *authService) VerifyAPIKey(
+	ctx context.Context,
+	key string,
+) (*domain.User, *domain.APIKey, error) {
+	if a.apiKeyRepo == nil || !IsAPIKey(key) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := a.apiKeyRepo.FindByHash(ctx, hashToken(key))
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+
+		return nil, nil, fmt.Errorf("find api key error %w", err)
+	}
+
+	now := time.Now()
+
+	if apiKey.Expired(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := a.userRepo.FindByID(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidAPIKey, err)
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := a.apiKeyRepo.Touch(ctx, apiKey.ID, now); err != nil {
+			return nil, nil, fmt.Errorf("touch api key error %w", err)
+		}
+
+		apiKey.LastUsedAt = &now
+	}
+
+	return user, apiKey, nil
+}
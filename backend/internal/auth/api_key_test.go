@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func Test_authService_CreateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		apiKeyRepo domain.APIKeyRepository
+		scopes     []string
+		expiresAt  *time.Time
+		wantScopes []string
+		wantErr    error
+	}{
+		{
+			name:       "Success",
+			apiKeyRepo: &APIKeyRepositoryMock{},
+			scopes:     []string{ScopeUsersWrite, ScopeUsersRead, ScopeUsersWrite},
+			expiresAt:  &future,
+			wantScopes: []string{ScopeUsersRead, ScopeUsersWrite},
+		},
+		{name: "No Scopes", apiKeyRepo: &APIKeyRepositoryMock{}, wantScopes: []string{}},
+		{
+			name:       "Unknown Scope",
+			apiKeyRepo: &APIKeyRepositoryMock{},
+			scopes:     []string{"users:delete"},
+			wantErr:    ErrUnknownScope,
+		},
+		{name: "Expired", apiKeyRepo: &APIKeyRepositoryMock{}, expiresAt: &past, wantErr: ErrInvalidExpiry},
+		{name: "Not Enabled", wantErr: ErrAPIKeysNotSupported},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &authService{apiKeyRepo: tt.apiKeyRepo}
+
+			apiKey, key, err := a.CreateAPIKey(context.Background(), "1", "ci", tt.scopes, tt.expiresAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.CreateAPIKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !IsAPIKey(key) || !strings.HasPrefix(key, apiKey.Prefix) {
+				t.Errorf("authService.CreateAPIKey() key = %v, prefix %v", key, apiKey.Prefix)
+			}
+
+			if apiKey.KeyHash != hashToken(key) {
+				t.Errorf("authService.CreateAPIKey() stored %v, want the hash of the key", apiKey.KeyHash)
+			}
+
+			if !reflect.DeepEqual(apiKey.Scopes, tt.wantScopes) {
+				t.Errorf("authService.CreateAPIKey() scopes = %v, want %v", apiKey.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func Test_authService_VerifyAPIKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo := &APIKeyRepositoryMock{}
+	a := &authService{userRepo: &UserRepositoryMock{}, apiKeyRepo: repo}
+
+	apiKey, key, err := a.CreateAPIKey(ctx, "1", "ci", []string{ScopeUsersRead}, nil)
+	if err != nil {
+		t.Fatalf("authService.CreateAPIKey() error = %v", err)
+	}
+
+	user, verified, err := a.VerifyAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("authService.VerifyAPIKey() error = %v", err)
+	}
+
+	if user.ID != "1" || verified.ID != apiKey.ID {
+		t.Errorf("authService.VerifyAPIKey() = %v, %v, want user 1 and key %v", user.ID, verified.ID, apiKey.ID)
+	}
+
+	lastUsedAt := repo.lastUsedAt(apiKey.ID)
+	if lastUsedAt == nil {
+		t.Fatalf("authService.VerifyAPIKey() did not record the use of the key")
+	}
+
+	// a second use within apiKeyTouchInterval is not written
+	if _, _, err := a.VerifyAPIKey(ctx, key); err != nil {
+		t.Fatalf("authService.VerifyAPIKey() error = %v", err)
+	}
+
+	if got := repo.lastUsedAt(apiKey.ID); !got.Equal(*lastUsedAt) {
+		t.Errorf("authService.VerifyAPIKey() touched the key again at %v", got)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	repo.setExpiresAt(apiKey.ID, &expired)
+
+	for _, key := range []string{key, APIKeyPrefix + "unknown", "not-an-api-key"} {
+		if _, _, err := a.VerifyAPIKey(ctx, key); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("authService.VerifyAPIKey(%q) error = %v, want %v", key, err, ErrInvalidAPIKey)
+		}
+	}
+}
+
+func Test_authService_RevokeAPIKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a := &authService{userRepo: &UserRepositoryMock{}, apiKeyRepo: &APIKeyRepositoryMock{}}
+
+	apiKey, key, err := a.CreateAPIKey(ctx, "1", "ci", nil, nil)
+	if err != nil {
+		t.Fatalf("authService.CreateAPIKey() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if err := a.RevokeAPIKey(ctx, "2", apiKey.ID); !errors.As(err, &notFoundErr) {
+		t.Errorf("authService.RevokeAPIKey() of another user error = %v, want not found", err)
+	}
+
+	keys, err := a.ListAPIKeys(ctx, "1")
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("authService.ListAPIKeys() = %v, %v, want 1 key", keys, err)
+	}
+
+	if err := a.RevokeAPIKey(ctx, "1", apiKey.ID); err != nil {
+		t.Fatalf("authService.RevokeAPIKey() error = %v", err)
+	}
+
+	if _, _, err := a.VerifyAPIKey(ctx, key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("authService.VerifyAPIKey() of a revoked key error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+
+	if keys, _ := a.ListAPIKeys(ctx, "1"); len(keys) != 0 {
+		t.Errorf("authService.ListAPIKeys() = %v, want none", keys)
+	}
+}
+
+var _ domain.APIKeyRepository = &APIKeyRepositoryMock{}
+
+type APIKeyRepositoryMock struct {
+	mu     sync.Mutex
+	nextID int
+	keys   map[string]*domain.APIKey
+}
+
+func (r *APIKeyRepositoryMock) lastUsedAt(id string) *time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.keys[id].LastUsedAt
+}
+
+func (r *APIKeyRepositoryMock) setExpiresAt(id string, expiresAt *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id].ExpiresAt = expiresAt
+}
+
+func (r *APIKeyRepositoryMock) Create(
+	_ context.Context,
+	apiKey *domain.APIKey,
+) (*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys == nil {
+		r.keys = make(map[string]*domain.APIKey)
+	}
+
+	r.nextID++
+
+	created := *apiKey
+	created.ID = strconv.Itoa(r.nextID)
+	created.CreatedAt = time.Now()
+	r.keys[created.ID] = &created
+
+	found := created
+
+	return &found, nil
+}
+
+func (r *APIKeyRepositoryMock) FindByHash(
+	_ context.Context,
+	keyHash string,
+) (*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, apiKey := range r.keys {
+		if apiKey.KeyHash == keyHash {
+			found := *apiKey
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("APIKey", "key_hash="+keyHash)
+}
+
+func (r *APIKeyRepositoryMock) FindByUserID(
+	_ context.Context,
+	userID string,
+) ([]*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []*domain.APIKey
+
+	for _, apiKey := range r.keys {
+		if apiKey.UserID == userID {
+			found := *apiKey
+			keys = append(keys, &found)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepositoryMock) Touch(
+	_ context.Context,
+	id string,
+	usedAt time.Time,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id].LastUsedAt = &usedAt
+
+	return nil
+}
+
+func (r *APIKeyRepositoryMock) Delete(
+	_ context.Context,
+	userID string,
+	id string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if apiKey, ok := r.keys[id]; !ok || apiKey.UserID != userID {
+		return domain.NewResourceNotFoundError("APIKey", "id="+id)
+	}
+
+	delete(r.keys, id)
+
+	return nil
+}
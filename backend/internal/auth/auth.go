@@ -2,25 +2,42 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 
 	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/cache"
+	"goadmin-backend/internal/platform/mail"
+	"goadmin-backend/internal/platform/random"
 )
 
 const (
 	DefaultTokenDuration        = 60 * time.Minute
 	DefaultRefreshTokenDuration = 24 * time.Hour
 	DefaultBCryptCost           = 15
+
+	// tokenIDSize is the number of random bytes in a token ID ("jti").
+	tokenIDSize = 16
+
+	// TokenIssuer is the "iss" claim of every token we sign.
+	TokenIssuer = "goadmin-backend"
+
+	// AccessTokenAudience and RefreshTokenAudience keep the two token
+	// types apart at the "aud" claim level as well.
+	AccessTokenAudience  = "goadmin-api"
+	RefreshTokenAudience = "goadmin-refresh"
 )
 
 var (
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrRefreshNotSupported = errors.New("refresh tokens are not enabled")
 )
 
 type Service interface {
@@ -32,127 +49,378 @@ type Service interface {
 		idToken, audience string,
 	) (*domain.JWTToken, error)
 	Logout(ctx context.Context, tokenString string) error
+	RefreshToken(ctx context.Context, refreshToken string) (*domain.JWTToken, error)
 	Profile(ctx context.Context, tokenString string) (*domain.User, error)
+	JWKS() *JWKSet
+	ListSessions(ctx context.Context, userID string) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	LogoutAll(ctx context.Context, userID string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	VerifyMFA(ctx context.Context, mfaToken, code string) (*domain.JWTToken, error)
+	EnrollTOTP(ctx context.Context, user *domain.User) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
+	UnlockUser(ctx context.Context, userID string) error
+	HasRole(ctx context.Context, userID string, roles ...string) (bool, error)
+	ChangePassword(ctx context.Context, userID, currentPassword, password string) error
+	SetPassword(ctx context.Context, userID, password string) error
+	CreateAPIKey(
+		ctx context.Context,
+		userID, name string,
+		scopes []string,
+		expiresAt *time.Time,
+	) (*domain.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	VerifyAPIKey(ctx context.Context, key string) (*domain.User, *domain.APIKey, error)
+	CreateServiceAccount(
+		ctx context.Context,
+		name string,
+		scopes []string,
+		createdBy string,
+	) (*domain.ServiceAccount, string, error)
+	ListServiceAccounts(ctx context.Context) ([]*domain.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, id string) error
+	ClientCredentials(
+		ctx context.Context,
+		clientID, clientSecret string,
+		scopes []string,
+	) (*domain.JWTToken, []string, error)
+	VerifyServiceAccountToken(ctx context.Context, tokenString string) (*domain.ServiceAccount, error)
+	CreateOAuthClient(
+		ctx context.Context,
+		name string,
+		redirectURIs []string,
+		scopes []string,
+		public bool,
+	) (*domain.OAuthClient, string, error)
+	ListOAuthClients(ctx context.Context) ([]*domain.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, id string) error
+	Authorize(
+		ctx context.Context,
+		tokenString string,
+		authReq AuthorizationRequest,
+	) (*AuthorizationResult, error)
+	Consent(
+		ctx context.Context,
+		tokenString string,
+		authReq AuthorizationRequest,
+		approve bool,
+	) (*AuthorizationResult, error)
+	ExchangeAuthorizationCode(
+		ctx context.Context,
+		clientID, clientSecret string,
+		code, redirectURI, codeVerifier string,
+	) (*domain.JWTToken, []string, error)
+	UserInfo(ctx context.Context, tokenString string) (*UserInfo, error)
+	OpenIDConfiguration() (*OpenIDConfiguration, error)
+	OIDCProviders() []*OIDCProvider
+	SignInWithOIDC(ctx context.Context, provider, idToken, nonce string) (*domain.JWTToken, error)
+	StartOIDCLogin(ctx context.Context, provider, redirectTo string) (*OIDCLogin, error)
+	FinishOIDCLogin(ctx context.Context, provider, flowToken string, callback OIDCCallback) (*OIDCLoginResult, error)
+	LoginRedirectURL() string
+	SAMLMetadata(connection string) ([]byte, error)
+	StartSAMLLogin(ctx context.Context, connection, redirectTo string) (*OIDCLogin, error)
+	FinishSAMLLogin(ctx context.Context, connection, flowToken, samlResponse string) (*OIDCLoginResult, error)
+	UserIdentities(ctx context.Context, userID string) ([]*domain.UserIdentity, error)
+	LinkIdentity(ctx context.Context, userID, provider, idToken, nonce string) (*domain.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, userID, identityID string) error
+	Impersonate(ctx context.Context, tokenString, userID, reason string) (*domain.JWTToken, error)
 }
 
 var _ Service = &authService{}
 
 type authService struct {
-	userRepo         domain.UserRepository
-	revokedTokenRepo domain.RevokedTokenRepository
-	jwtSecret        interface{}
+	userRepo            domain.UserRepository
+	revokedTokenRepo    domain.RevokedTokenRepository
+	refreshTokenRepo    domain.RefreshTokenRepository
+	sessionRepo         domain.SessionRepository
+	activeSessions      *cache.LRU[string, bool]
+	userTokenRepo       domain.UserTokenRepository
+	mailer              mail.Mailer
+	appURL              string
+	mfaRepo             domain.MFARepository
+	mfaAttempts         *cache.LRU[string, int]
+	totpIssuer          string
+	loginAttemptRepo    domain.LoginAttemptRepository
+	lockoutPolicy       LockoutPolicy
+	roleRepo            domain.RoleRepository
+	hasher              PasswordHasher
+	passwordPolicy      PasswordPolicy
+	apiKeyRepo          domain.APIKeyRepository
+	serviceAccountRepo  domain.ServiceAccountRepository
+	oauthClientRepo     domain.OAuthClientRepository
+	oauthGrantRepo      domain.OAuthGrantRepository
+	oauthServer         OAuthServerConfig
+	oidcProviders       map[string]*OIDCProvider
+	samlConnections     map[string]*SAMLConnection
+	loginRedirectURL    string
+	identityRepo        domain.UserIdentityRepository
+	auditLogRepo        domain.AuditLogRepository
+	credentialVerifiers []CredentialVerifier
+	// usernameLinking are the directories linking their users to existing
+	// users by username
+	usernameLinking  []string
+	keyRing          *KeyRing
 	idTokenValidator GoogleIDTokenValidator
 	audience         string
+
+	requireVerifiedEmail bool
+}
+
+// Option configures optional features of the auth service.
+type Option func(*authService)
+
+// WithRefreshTokenRepo enables refresh tokens. Without a repository to keep
+// track of rotations no refresh token is issued.
+func WithRefreshTokenRepo(repo domain.RefreshTokenRepository) Option {
+	return func(a *authService) {
+		a.refreshTokenRepo = repo
+	}
 }
 
 func NewAuthService(
 	userRepo domain.UserRepository,
 	revokedTokenRepo domain.RevokedTokenRepository,
-	jwtSecret []byte,
+	keyRing *KeyRing,
 	idTokenValidator GoogleIDTokenValidator,
 	audience string,
+	opts ...Option,
 ) Service {
-	return &authService{
+	svc := &authService{
 		userRepo:         userRepo,
 		revokedTokenRepo: revokedTokenRepo,
-		jwtSecret:        jwtSecret,
+		keyRing:          keyRing,
 		idTokenValidator: idTokenValidator,
 		audience:         audience,
 	}
+
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	return svc
 }
 
 func (a *authService) Login(
 	ctx context.Context,
 	credentials domain.Credentials,
 ) (*domain.JWTToken, error) {
-	user, err := a.userRepo.FindByUsername(ctx, credentials.Username)
+	if err := a.checkLoginThrottle(ctx, credentials.Username); err != nil {
+		return nil, err
+	}
+
+	user, err := a.verifyCredentials(ctx, credentials)
 	if err != nil {
-		return nil, fmt.Errorf("find user error %w", err)
+		// unknown usernames are throttled alike, not to tell them apart
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.Is(err, ErrInvalidCredentials) || errors.As(err, &notFoundErr) {
+			if err := a.recordLoginFailure(ctx, credentials.Username); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword(
-		[]byte(user.Password),
-		[]byte(credentials.Password),
-	)
+	if a.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	mfaRequired, err := a.mfaRequired(ctx, user)
 	if err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, err
 	}
 
-	return a.generateToken(user.Username)
+	// failures are only forgotten once the second factor is passed as well
+	if mfaRequired {
+		return a.issueMFAChallenge(user)
+	}
+
+	if err := a.resetLoginFailures(ctx, user.Username); err != nil {
+		return nil, err
+	}
+
+	return a.signIn(ctx, user)
 }
 
-func (a *authService) generateToken(username string) (*domain.JWTToken, error) {
-	expirationTime := time.Now().Add(DefaultTokenDuration)
+// generateToken issues an access token and, when refresh tokens are enabled,
+// a refresh token belonging to familyID. An empty familyID starts a new
+// family. Both tokens are bound to sessionID.
+func (a *authService) generateToken(
+	ctx context.Context,
+	user *domain.User,
+	familyID string,
+	sessionID string,
+) (*domain.JWTToken, error) {
+	token, refreshToken, err := a.signTokens(user, familyID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken != nil {
+		if err := a.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
+			return nil, fmt.Errorf("save refresh token error %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+// signTokens signs the tokens of generateToken, returning the refresh token
+// to store, nil when refresh tokens are disabled.
+func (a *authService) signTokens(
+	user *domain.User,
+	familyID string,
+	sessionID string,
+) (*domain.JWTToken, *domain.RefreshToken, error) {
+	tokenID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate token id error %w", err)
+	}
+
+	now := time.Now()
 	claims := &domain.JWTClaims{
-		Username: username,
+		Username:  user.Username,
+		TokenType: domain.TokenTypeAccess,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			Issuer:    "goadmin-backend",
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(DefaultTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := a.keyRing.Sign(claims)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	if a.refreshTokenRepo == nil {
+		return &domain.JWTToken{AccessToken: tokenString}, nil, nil
+	}
 
-	tokenString, err := token.SignedString(a.jwtSecret)
+	refreshTokenString, refreshToken, err := a.signRefreshToken(user, familyID, sessionID, now)
 	if err != nil {
-		return nil, fmt.Errorf("sign token error %w", err)
+		return nil, nil, err
+	}
+
+	return &domain.JWTToken{
+		AccessToken:  tokenString,
+		RefreshToken: refreshTokenString,
+	}, refreshToken, nil
+}
+
+func (a *authService) signRefreshToken(
+	user *domain.User,
+	familyID string,
+	sessionID string,
+	now time.Time,
+) (string, *domain.RefreshToken, error) {
+	tokenID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return "", nil, fmt.Errorf("generate refresh token id error %w", err)
+	}
+
+	if familyID == "" {
+		familyID = tokenID
 	}
 
 	// Create the refresh token with longer expiry
-	refreshTokenExpirationTime := time.Now().Add(DefaultRefreshTokenDuration)
+	expiresAt := now.Add(DefaultRefreshTokenDuration)
 	refreshClaims := &domain.JWTClaims{
-		Username: username,
+		Username:  user.Username,
+		TokenType: domain.TokenTypeRefresh,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(refreshTokenExpirationTime),
-			Issuer:    "goadmin-backend",
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{RefreshTokenAudience},
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-
-	refreshTokenString, err := refreshToken.SignedString(a.jwtSecret)
+	refreshTokenString, err := a.keyRing.Sign(refreshClaims)
 	if err != nil {
-		return nil, fmt.Errorf("sign refresh token error %w", err)
+		return "", nil, fmt.Errorf("sign refresh token error %w", err)
 	}
 
-	return &domain.JWTToken{
-		AccessToken:  tokenString,
-		RefreshToken: refreshTokenString,
+	return refreshTokenString, &domain.RefreshToken{
+		ID:        tokenID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
 	}, nil
 }
 
+// parseToken validates the signature and registered claims of tokenString
+// and makes sure it is of the expected token type.
+func (a *authService) parseToken(
+	tokenString string,
+	tokenType string,
+	audience string,
+) (*domain.JWTClaims, error) {
+	claims := &domain.JWTClaims{}
+
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		a.keyRing.Keyfunc,
+		jwt.WithValidMethods(a.keyRing.ValidMethods()),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(audience),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parse token error %w", err)
+	}
+
+	if !token.Valid || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
 // VerifyToken checks if the token is valid and not revoked
 func (a *authService) VerifyToken(
 	ctx context.Context,
 	tokenString string,
 ) (*domain.User, error) {
-	// check if token is in revoked_token list
-	isRevoked, err := a.revokedTokenRepo.IsRevoked(ctx, tokenString)
+	claims, err := a.parseToken(
+		tokenString,
+		domain.TokenTypeAccess,
+		AccessTokenAudience,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("check revoked token error %w", err)
+		return nil, err
 	}
 
-	if isRevoked {
+	// service accounts have their own verification
+	if claims.Principal() != domain.PrincipalTypeUser {
 		return nil, ErrInvalidToken
 	}
 
-	claims := &domain.JWTClaims{}
-
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		claims,
-		func(_ *jwt.Token) (any, error) {
-			return a.jwtSecret, nil
-		},
-	)
+	// check if token is in revoked_token list
+	isRevoked, err := a.revokedTokenRepo.IsRevoked(ctx, revocationID(claims, tokenString))
 	if err != nil {
-		return nil, fmt.Errorf("parse token error %w", err)
+		return nil, fmt.Errorf("check revoked token error %w", err)
 	}
 
-	if !token.Valid {
+	if isRevoked {
 		return nil, ErrInvalidToken
 	}
 
+	if err := a.checkSession(ctx, claims.SessionID); err != nil {
+		return nil, err
+	}
+
 	user, err := a.userRepo.FindByUsername(ctx, claims.Username)
 	if err != nil {
 		return nil, fmt.Errorf("find user error %w", err)
@@ -161,39 +429,192 @@ func (a *authService) VerifyToken(
 	return user, nil
 }
 
-// Logout invalidates the token by adding it to the revoked_token list
+// Logout invalidates the token by adding its ID to the revoked_token list
+// until the token expires
 func (a *authService) Logout(
 	ctx context.Context,
 	tokenString string,
 ) error {
-	err := a.revokedTokenRepo.AddRevokedToken(ctx, tokenString)
+	claims, err := a.parseToken(
+		tokenString,
+		domain.TokenTypeAccess,
+		AccessTokenAudience,
+	)
+	if err != nil {
+		return errors.Join(ErrInvalidToken, err)
+	}
+
+	expiresAt := time.Now().Add(DefaultTokenDuration)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	err = a.revokedTokenRepo.AddRevokedToken(
+		ctx,
+		revocationID(claims, tokenString),
+		expiresAt,
+	)
 	if err != nil {
 		return fmt.Errorf("revoke token error %w", err)
 	}
 
+	// an impersonation token shares the session of the admin, which lives on
+	if claims.Actor != nil {
+		if a.auditLogRepo == nil {
+			return nil
+		}
+
+		return a.audit(ctx, domain.AuditActionImpersonationEnd, claims.Actor.Subject, claims.Subject, "")
+	}
+
+	// signing out also ends the session, so its refresh token is void too
+	if a.sessionRepo != nil && claims.SessionID != "" {
+		return a.endSession(ctx, claims.SessionID)
+	}
+
 	return nil
 }
 
-// Register registers a new user
+// revocationID returns the ID a token is revoked by. Tokens issued before
+// access tokens carried a "jti" are identified by the hash of the token.
+func revocationID(claims *domain.JWTClaims, tokenString string) string {
+	if claims.ID != "" {
+		return claims.ID
+	}
+
+	return hashToken(tokenString)
+}
+
+// hashToken returns the hex encoded SHA-256 of a secret token, which is what
+// gets stored instead of the token itself.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The presented
+// refresh token is rotated out; presenting it a second time is treated as
+// token theft and revokes every refresh token of its family.
+func (a *authService) RefreshToken(
+	ctx context.Context,
+	refreshToken string,
+) (*domain.JWTToken, error) {
+	if a.refreshTokenRepo == nil {
+		return nil, ErrRefreshNotSupported
+	}
+
+	claims, err := a.parseToken(
+		refreshToken,
+		domain.TokenTypeRefresh,
+		RefreshTokenAudience,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	record, err := a.refreshTokenRepo.FindByID(ctx, claims.ID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, fmt.Errorf("find refresh token error %w", err)
+	}
+
+	if record.RevokedAt != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := a.checkSession(ctx, claims.SessionID); err != nil {
+		return nil, err
+	}
+
+	if record.RotatedAt != nil {
+		return nil, a.refreshTokenReused(ctx, record, claims)
+	}
+
+	user, err := a.userRepo.FindByUsername(ctx, claims.Username)
+	if err != nil {
+		return nil, fmt.Errorf("find user error %w", err)
+	}
+
+	// the new pair is signed before the presented token is rotated out, and
+	// stored along with the rotation, so that a failure leaves the presented
+	// token good for a retry
+	token, next, err := a.signTokens(user, record.FamilyID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := a.refreshTokenRepo.Rotate(ctx, record.ID, next)
+	if err != nil {
+		return nil, fmt.Errorf("rotate refresh token error %w", err)
+	}
+
+	if !rotated {
+		return nil, a.refreshTokenReused(ctx, record, claims)
+	}
+
+	if a.sessionRepo != nil && claims.SessionID != "" {
+		err := a.sessionRepo.Touch(ctx, claims.SessionID, time.Now().Add(a.sessionDuration()))
+		if err != nil {
+			return nil, fmt.Errorf("touch session error %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+// refreshTokenReused revokes the family of a refresh token presented again,
+// and ends its session.
+func (a *authService) refreshTokenReused(
+	ctx context.Context,
+	record *domain.RefreshToken,
+	claims *domain.JWTClaims,
+) error {
+	if err := a.refreshTokenRepo.RevokeFamily(ctx, record.FamilyID); err != nil {
+		return fmt.Errorf("revoke refresh token family error %w", err)
+	}
+
+	if a.sessionRepo != nil && claims.SessionID != "" {
+		if err := a.endSession(ctx, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// Register registers a new user, whose password has to meet the password
+// policy, and e-mails them a link to verify their address. When that e-mail
+// cannot be sent the user is still returned, together with
+// ErrVerificationMailNotSent.
 func (a *authService) Register(
 	ctx context.Context,
 	user *domain.User,
 ) (*domain.User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(user.Password),
-		DefaultBCryptCost,
-	)
+	if err := a.checkPassword(user.Password, user); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := a.passwordHasher().Hash(user.Password)
 	if err != nil {
 		return nil, fmt.Errorf("hash password error %w", err)
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 
 	savedUser, err := a.userRepo.Create(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("create user error %w", err)
 	}
 
+	if err := a.sendVerificationEmail(ctx, savedUser); err != nil {
+		return savedUser, errors.Join(ErrVerificationMailNotSent, err)
+	}
+
 	return savedUser, nil
 }
 
@@ -209,3 +630,8 @@ func (a *authService) Profile(
 
 	return user, nil
 }
+
+// JWKS returns the public keys tokens can be verified with
+func (a *authService) JWKS() *JWKSet {
+	return a.keyRing.JWKS()
+}
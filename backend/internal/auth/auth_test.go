@@ -5,7 +5,11 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 
 	"goadmin-backend/internal/domain"
 )
@@ -21,7 +25,7 @@ func TestNewAuthService(t *testing.T) {
 	type args struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        []byte
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 		audience         string
 	}
@@ -36,14 +40,14 @@ func TestNewAuthService(t *testing.T) {
 			args: args{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 				audience:         "audience",
 			},
 			want: &authService{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 				audience:         "audience",
 			},
@@ -58,7 +62,7 @@ func TestNewAuthService(t *testing.T) {
 			if got := NewAuthService(
 				tt.args.userRepo,
 				tt.args.revokedTokenRepo,
-				tt.args.jwtSecret,
+				tt.args.keyRing,
 				tt.args.idTokenValidator,
 				tt.args.audience,
 			); !reflect.DeepEqual(
@@ -77,7 +81,7 @@ func Test_authService_Login(t *testing.T) {
 	type fields struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        interface{}
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 	}
 
@@ -97,7 +101,7 @@ func Test_authService_Login(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -113,7 +117,7 @@ func Test_authService_Login(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{hasError: true},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -129,7 +133,7 @@ func Test_authService_Login(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -145,7 +149,7 @@ func Test_authService_Login(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        "invalid_secret", // non-bytes
+				keyRing:          newInvalidKeyRing(), // non-bytes secret
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -166,7 +170,7 @@ func Test_authService_Login(t *testing.T) {
 			a := &authService{
 				userRepo:         tt.fields.userRepo,
 				revokedTokenRepo: tt.fields.revokedTokenRepo,
-				jwtSecret:        tt.fields.jwtSecret,
+				keyRing:          tt.fields.keyRing,
 				idTokenValidator: tt.fields.idTokenValidator,
 			}
 			got, err := a.Login(context.Background(), tt.args.credentials)
@@ -198,7 +202,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 	type fields struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        interface{}
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 	}
 
@@ -218,7 +222,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			want: &domain.User{
@@ -232,7 +236,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{hasError: true},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			wantErr: true,
@@ -242,7 +246,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{isRevoked: true},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			wantErr: true,
@@ -252,7 +256,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{hasError: true},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			wantErr: true,
@@ -262,7 +266,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("invalid_secret"),
+				keyRing:          NewHMACKeyRing([]byte("invalid_secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -275,7 +279,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -293,7 +297,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 			a := &authService{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			}
 
@@ -304,7 +308,7 @@ func Test_authService_VerifyToken(t *testing.T) {
 
 			a.userRepo = tt.fields.userRepo
 			a.revokedTokenRepo = tt.fields.revokedTokenRepo
-			a.jwtSecret = tt.fields.jwtSecret
+			a.keyRing = tt.fields.keyRing
 			a.idTokenValidator = tt.fields.idTokenValidator
 
 			tokenString := token.AccessToken
@@ -343,13 +347,14 @@ func Test_authService_Logout(t *testing.T) {
 	type fields struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        []byte
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 	}
 
 	tests := []struct {
 		name    string
 		fields  fields
+		token   string
 		wantErr bool
 	}{
 		{
@@ -357,16 +362,27 @@ func Test_authService_Logout(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 		},
+		{
+			name: "Invalid Token",
+			fields: fields{
+				userRepo:         &UserRepositoryMock{},
+				revokedTokenRepo: &RevokedTokenRepositoryMock{},
+				keyRing:          NewHMACKeyRing([]byte("secret")),
+				idTokenValidator: &GoogleIDTokenValidatorMock{},
+			},
+			token:   "invalid",
+			wantErr: true,
+		},
 		{
 			name: "Add RevokedToken Error",
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{hasError: true},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			wantErr: true,
@@ -381,7 +397,7 @@ func Test_authService_Logout(t *testing.T) {
 			a := &authService{
 				userRepo:         tt.fields.userRepo,
 				revokedTokenRepo: tt.fields.revokedTokenRepo,
-				jwtSecret:        tt.fields.jwtSecret,
+				keyRing:          tt.fields.keyRing,
 				idTokenValidator: tt.fields.idTokenValidator,
 			}
 
@@ -390,24 +406,188 @@ func Test_authService_Logout(t *testing.T) {
 				Password: "password",
 			})
 
-			if err := a.Logout(context.Background(), token.AccessToken); (err != nil) != tt.wantErr {
+			tokenString := token.AccessToken
+			if tt.token != "" {
+				tokenString = tt.token
+			}
+
+			if err := a.Logout(context.Background(), tokenString); (err != nil) != tt.wantErr {
 				t.Errorf(
 					"authService.Logout() error = %v, wantErr %v",
 					err,
 					tt.wantErr,
 				)
+
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if _, err := a.VerifyToken(context.Background(), tokenString); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("authService.VerifyToken() after Logout() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func Test_authService_RefreshToken(t *testing.T) {
+	t.Parallel()
+
+	user := &domain.User{ID: "1", Username: "username"}
+
+	tests := []struct {
+		name string
+		// prepare returns the refresh token to present
+		prepare     func(a *authService) string
+		wantErr     error
+		wantRevoked bool
+	}{
+		{
+			name: "Success",
+			prepare: func(a *authService) string {
+				token, _ := a.generateToken(context.Background(), user, "", "")
+
+				return token.RefreshToken
+			},
+		},
+		{
+			name: "Reused Token Revokes Family",
+			prepare: func(a *authService) string {
+				token, _ := a.generateToken(context.Background(), user, "", "")
+
+				// rotate once, the second exchange is a reuse
+				_, _ = a.RefreshToken(context.Background(), token.RefreshToken)
+
+				return token.RefreshToken
+			},
+			wantErr:     ErrRefreshTokenReused,
+			wantRevoked: true,
+		},
+		{
+			name: "Access Token Rejected",
+			prepare: func(a *authService) string {
+				token, _ := a.generateToken(context.Background(), user, "", "")
+
+				return token.AccessToken
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "Unknown Token",
+			prepare: func(a *authService) string {
+				token, _ := a.generateToken(context.Background(), user, "", "")
+
+				a.refreshTokenRepo = &RefreshTokenRepositoryMock{}
+
+				return token.RefreshToken
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "Disabled",
+			prepare: func(a *authService) string {
+				a.refreshTokenRepo = nil
+
+				return "token"
+			},
+			wantErr: ErrRefreshNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &RefreshTokenRepositoryMock{}
+			a := &authService{
+				userRepo:         &UserRepositoryMock{},
+				revokedTokenRepo: &RevokedTokenRepositoryMock{},
+				refreshTokenRepo: repo,
+				keyRing:          NewHMACKeyRing([]byte("secret")),
+				idTokenValidator: &GoogleIDTokenValidatorMock{},
+			}
+
+			refreshToken := tt.prepare(a)
+
+			got, err := a.RefreshToken(context.Background(), refreshToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf(
+					"authService.RefreshToken() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+
+				return
+			}
+
+			if tt.wantRevoked && !repo.allRevoked() {
+				t.Errorf("authService.RefreshToken() family not revoked")
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.RefreshToken == "" || got.RefreshToken == refreshToken {
+				t.Errorf("authService.RefreshToken() refresh token was not rotated")
+			}
+
+			if _, err := a.VerifyToken(context.Background(), got.AccessToken); err != nil {
+				t.Errorf("authService.VerifyToken() error = %v", err)
+			}
+
+			if _, err := a.VerifyToken(context.Background(), got.RefreshToken); err == nil {
+				t.Errorf("authService.VerifyToken() accepted a refresh token")
 			}
 		})
 	}
 }
 
+func Test_authService_RefreshToken_retry(t *testing.T) {
+	t.Parallel()
+
+	repo := &RefreshTokenRepositoryMock{}
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		refreshTokenRepo: repo,
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+		idTokenValidator: &GoogleIDTokenValidatorMock{},
+	}
+
+	token, err := a.generateToken(context.Background(), &domain.User{ID: "1", Username: "username"}, "", "")
+	if err != nil {
+		t.Fatalf("authService.generateToken() error = %v", err)
+	}
+
+	repo.failRotate = true
+
+	if _, err := a.RefreshToken(context.Background(), token.RefreshToken); err == nil {
+		t.Fatalf("authService.RefreshToken() error = nil, want error")
+	}
+
+	// the failed exchange did not use the token up
+	repo.failRotate = false
+
+	if _, err := a.RefreshToken(context.Background(), token.RefreshToken); err != nil {
+		t.Errorf("authService.RefreshToken() retry error = %v", err)
+	}
+
+	if repo.allRevoked() {
+		t.Errorf("authService.RefreshToken() retry revoked the family")
+	}
+}
+
 func Test_authService_Register(t *testing.T) {
 	t.Parallel()
 
 	type fields struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        []byte
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 	}
 
@@ -427,13 +607,13 @@ func Test_authService_Register(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
 				user: &domain.User{
 					Username: "username",
-					Password: "password",
+					Password: "violet-harbor",
 				},
 			},
 			want: &domain.User{
@@ -447,7 +627,7 @@ func Test_authService_Register(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{hasError: true},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -463,7 +643,7 @@ func Test_authService_Register(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("secret"),
+				keyRing:          NewHMACKeyRing([]byte("secret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -484,7 +664,7 @@ func Test_authService_Register(t *testing.T) {
 			a := &authService{
 				userRepo:         tt.fields.userRepo,
 				revokedTokenRepo: tt.fields.revokedTokenRepo,
-				jwtSecret:        tt.fields.jwtSecret,
+				keyRing:          tt.fields.keyRing,
 				idTokenValidator: tt.fields.idTokenValidator,
 			}
 			got, err := a.Register(context.Background(), tt.args.user)
@@ -527,6 +707,46 @@ func (u *UserRepositoryMock) FindByUsername(
 	}, nil
 }
 
+func (u *UserRepositoryMock) FindByEmail(
+	_ context.Context,
+	email string,
+) (*domain.User, error) {
+	if u.hasError || email != "user@example.com" {
+		return nil, domain.NewResourceNotFoundError("User", "email="+email)
+	}
+
+	return &domain.User{
+		ID:        "1",
+		Username:  "username",
+		Email:     "user@example.com",
+		FirstName: "User",
+		Password:  passwordHash,
+	}, nil
+}
+
+func (u *UserRepositoryMock) UpdatePassword(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	if u.hasError {
+		return errors.New("error")
+	}
+
+	return nil
+}
+
+func (u *UserRepositoryMock) MarkEmailVerified(
+	_ context.Context,
+	_ string,
+) error {
+	if u.hasError {
+		return errors.New("error")
+	}
+
+	return nil
+}
+
 func (u *UserRepositoryMock) FindByID(
 	_ context.Context,
 	_ string,
@@ -610,26 +830,198 @@ var _ domain.RevokedTokenRepository = &RevokedTokenRepositoryMock{}
 type RevokedTokenRepositoryMock struct {
 	hasError  bool
 	isRevoked bool
+
+	mu      sync.Mutex
+	calls   int
+	revoked map[string]time.Time
 }
 
 func (r *RevokedTokenRepositoryMock) AddRevokedToken(
 	_ context.Context,
-	_ string,
+	tokenID string,
+	expiresAt time.Time,
 ) error {
 	if r.hasError {
 		return errors.New("error")
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.revoked == nil {
+		r.revoked = make(map[string]time.Time)
+	}
+
+	r.revoked[tokenID] = expiresAt
+
 	return nil
 }
 
 func (r *RevokedTokenRepositoryMock) IsRevoked(
 	_ context.Context,
-	_ string,
+	tokenID string,
 ) (bool, error) {
 	if r.hasError {
 		return false, errors.New("error")
 	}
 
-	return r.isRevoked, nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	_, ok := r.revoked[tokenID]
+
+	return r.isRevoked || ok, nil
+}
+
+func (r *RevokedTokenRepositoryMock) DeleteExpired(_ context.Context) (int64, error) {
+	if r.hasError {
+		return 0, errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+
+	for tokenID, expiresAt := range r.revoked {
+		if expiresAt.Before(time.Now()) {
+			delete(r.revoked, tokenID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+var _ domain.RefreshTokenRepository = &RefreshTokenRepositoryMock{}
+
+// RefreshTokenRepositoryMock keeps refresh tokens in memory so rotation and
+// reuse detection can be exercised end to end.
+type RefreshTokenRepositoryMock struct {
+	mu         sync.Mutex
+	tokens     map[string]*domain.RefreshToken
+	failRotate bool
+}
+
+func (r *RefreshTokenRepositoryMock) Create(
+	_ context.Context,
+	token *domain.RefreshToken,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tokens == nil {
+		r.tokens = map[string]*domain.RefreshToken{}
+	}
+
+	saved := *token
+	r.tokens[token.ID] = &saved
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryMock) FindByID(
+	_ context.Context,
+	id string,
+) (*domain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("RefreshToken", "id="+id)
+	}
+
+	found := *token
+
+	return &found, nil
+}
+
+func (r *RefreshTokenRepositoryMock) Rotate(
+	_ context.Context,
+	id string,
+	next *domain.RefreshToken,
+) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failRotate {
+		return false, errors.New("error")
+	}
+
+	token, ok := r.tokens[id]
+	if !ok || token.RotatedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	token.RotatedAt = &now
+
+	saved := *next
+	r.tokens[next.ID] = &saved
+
+	return true, nil
+}
+
+func (r *RefreshTokenRepositoryMock) RevokeFamily(
+	_ context.Context,
+	familyID string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID {
+			token.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryMock) RevokeAllByUserID(
+	_ context.Context,
+	userID string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			token.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryMock) allRevoked() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.RevokedAt == nil {
+			return false
+		}
+	}
+
+	return len(r.tokens) > 0
+}
+
+// newInvalidKeyRing returns a key ring whose HMAC secret is not a []byte, so
+// signing fails.
+func newInvalidKeyRing() *KeyRing {
+	keyRing, _ := NewKeyRing(DefaultKeyID, &SigningKey{
+		ID:         DefaultKeyID,
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: "invalid_secret",
+		PublicKey:  "invalid_secret",
+	})
+
+	return keyRing
 }
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+// ErrDirectoryUserNotLinked is returned when a user with the username of a
+// directory user exists but is not linked to the directory, and may not be
+// linked by username.
+var ErrDirectoryUserNotLinked = errors.New("the directory user is not linked to the user with its username")
+
+// CredentialVerifier verifies usernames and passwords against a directory
+// other than the user table, such as LDAP or Active Directory.
+type CredentialVerifier interface {
+	// Name names the directory in the identities of its users.
+	Name() string
+	// VerifyCredentials returns the directory user the credentials belong
+	// to. Unknown users are *domain.ResourceNotFoundError and wrong
+	// passwords ErrInvalidCredentials.
+	VerifyCredentials(ctx context.Context, credentials domain.Credentials) (*DirectoryUser, error)
+}
+
+// DirectoryUser is a user as a directory knows them. Their attributes are
+// copied to the user table on every sign-in.
+type DirectoryUser struct {
+	// Subject identifies the user in the directory, e.g. their DN.
+	Subject   string
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	// Roles are the roles the directory grants the user. ManagedRoles are
+	// all the roles the directory grants; those the user is not granted
+	// anymore are revoked.
+	Roles        []string
+	ManagedRoles []string
+}
+
+// WithCredentialVerifiers adds directories usernames and passwords are
+// verified against, in order, when they do not match a password of the user
+// table. Users are created on their first sign-in.
+func WithCredentialVerifiers(verifiers ...CredentialVerifier) Option {
+	return func(a *authService) {
+		a.credentialVerifiers = verifiers
+	}
+}
+
+// WithDirectoryUsernameLinking lets the named directories link their users
+// on their first sign-in to existing users with the same username, for
+// directories taking over accounts of the user table. Users with a password
+// of their own or the admin role are never linked.
+func WithDirectoryUsernameLinking(directories ...string) Option {
+	return func(a *authService) {
+		a.usernameLinking = directories
+	}
+}
+
+// verifyCredentials returns the user the credentials belong to, checking the
+// password of the user table first and then the directories. A directory
+// failing only fails the sign-ins that get to it.
+func (a *authService) verifyCredentials(ctx context.Context, credentials domain.Credentials) (*domain.User, error) {
+	user, err := a.verifyPassword(ctx, credentials)
+	if err == nil {
+		return user, nil
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if !errors.Is(err, ErrInvalidCredentials) && !errors.As(err, &notFoundErr) {
+		return nil, err
+	}
+
+	for _, verifier := range a.credentialVerifiers {
+		directoryUser, verifyErr := verifier.VerifyCredentials(ctx, credentials)
+
+		switch {
+		case verifyErr == nil:
+			return a.syncDirectoryUser(ctx, verifier.Name(), directoryUser)
+		case errors.Is(verifyErr, ErrInvalidCredentials):
+			err = ErrInvalidCredentials
+		case !errors.As(verifyErr, &notFoundErr):
+			return nil, fmt.Errorf("verify credentials with %s error %w", verifier.Name(), verifyErr)
+		}
+	}
+
+	return nil, err
+}
+
+// verifyPassword checks the credentials against the password hash of the
+// user table.
+func (a *authService) verifyPassword(ctx context.Context, credentials domain.Credentials) (*domain.User, error) {
+	user, err := a.userRepo.FindByUsername(ctx, credentials.Username)
+	if err != nil {
+		return nil, fmt.Errorf("find user error %w", err)
+	}
+
+	// a hash that cannot be verified, e.g. of a user signed up with Google,
+	// is as good as a wrong password
+	ok, rehash, err := a.passwordHasher().Verify(credentials.Password, user.Password)
+	if err != nil || !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if rehash {
+		a.rehashPassword(ctx, user, credentials.Password)
+	}
+
+	return user, nil
+}
+
+// syncDirectoryUser returns the user of a directory user, creating them on
+// their first sign-in, and copies their attributes and roles over. Users
+// are linked to the directory by subject when identities are enabled.
+func (a *authService) syncDirectoryUser(
+	ctx context.Context,
+	directory string,
+	directoryUser *DirectoryUser,
+) (*domain.User, error) {
+	user, err := a.directoryUser(ctx, directory, directoryUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if (directoryUser.Email != "" && directoryUser.Email != user.Email) ||
+		(directoryUser.FirstName != "" && directoryUser.FirstName != user.FirstName) ||
+		(directoryUser.LastName != "" && directoryUser.LastName != user.LastName) {
+		if user, err = a.userRepo.Update(ctx, &domain.User{
+			ID:        user.ID,
+			Email:     directoryUser.Email,
+			FirstName: directoryUser.FirstName,
+			LastName:  directoryUser.LastName,
+		}); err != nil {
+			return nil, fmt.Errorf("update user error %w", err)
+		}
+
+		// a new address from the directory is as trusted as the first one
+		if user.Email != "" && user.EmailVerifiedAt == nil {
+			if err := a.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+				return nil, fmt.Errorf("mark email verified error %w", err)
+			}
+
+			verifiedAt := time.Now()
+			user.EmailVerifiedAt = &verifiedAt
+		}
+	}
+
+	if a.roleRepo == nil {
+		return user, nil
+	}
+
+	for _, role := range directoryUser.ManagedRoles {
+		if slices.Contains(directoryUser.Roles, role) {
+			err = a.roleRepo.GrantRole(ctx, user.ID, role)
+		} else {
+			err = a.roleRepo.RevokeRole(ctx, user.ID, role)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("sync role %s error %w", role, err)
+		}
+	}
+
+	return user, nil
+}
+
+// linkableByUsername returns an error unless the directory may link its
+// user to user by username: the directory is allowed to, and user has no
+// password of their own and is no admin. Refusals are invalid credentials,
+// like the passwords of other users.
+func (a *authService) linkableByUsername(ctx context.Context, directory string, user *domain.User) error {
+	if !slices.Contains(a.usernameLinking, directory) || user.Password != "" {
+		return errors.Join(ErrInvalidCredentials, ErrDirectoryUserNotLinked)
+	}
+
+	admin, err := a.HasRole(ctx, user.ID, domain.RoleAdmin)
+	if err != nil && !errors.Is(err, ErrRolesNotSupported) {
+		return fmt.Errorf("check role error %w", err)
+	}
+
+	if admin {
+		return errors.Join(ErrInvalidCredentials, ErrDirectoryUserNotLinked)
+	}
+
+	return nil
+}
+
+// directoryUser returns the user linked to a directory user, or creates one.
+// Without identities, directory users are found by username, under the
+// rules of linking by username.
+func (a *authService) directoryUser(
+	ctx context.Context,
+	directory string,
+	directoryUser *DirectoryUser,
+) (*domain.User, error) {
+	var notFoundErr *domain.ResourceNotFoundError
+
+	if a.identityRepo != nil {
+		linked, err := a.identityRepo.Find(ctx, directory, directoryUser.Subject)
+		if err == nil {
+			if err := a.identityRepo.Touch(ctx, linked.ID, time.Now()); err != nil {
+				return nil, fmt.Errorf("touch user identity error %w", err)
+			}
+
+			user, err := a.userRepo.FindByID(ctx, linked.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("find user by id error %w", err)
+			}
+
+			return user, nil
+		}
+
+		if !errors.As(err, &notFoundErr) {
+			return nil, fmt.Errorf("find user identity error %w", err)
+		}
+	}
+
+	user, err := a.userRepo.FindByUsername(ctx, directoryUser.Username)
+
+	switch {
+	case errors.As(err, &notFoundErr):
+		user, err = a.userRepo.Create(ctx, &domain.User{
+			Username:  directoryUser.Username,
+			Email:     directoryUser.Email,
+			FirstName: directoryUser.FirstName,
+			LastName:  directoryUser.LastName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create user error %w", err)
+		}
+
+		// the directory is trusted with the address
+		if directoryUser.Email != "" {
+			if err := a.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+				return nil, fmt.Errorf("mark email verified error %w", err)
+			}
+
+			verifiedAt := time.Now()
+			user.EmailVerifiedAt = &verifiedAt
+		}
+	case err != nil:
+		return nil, fmt.Errorf("find user error %w", err)
+	default:
+		if err := a.linkableByUsername(ctx, directory, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.identityRepo != nil {
+		if _, err := a.identityRepo.Create(ctx, &domain.UserIdentity{
+			UserID:   user.ID,
+			Provider: directory,
+			Subject:  directoryUser.Subject,
+			Email:    directoryUser.Email,
+		}); err != nil {
+			return nil, fmt.Errorf("link user identity error %w", err)
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func Test_authService_verifyCredentials(t *testing.T) {
+	t.Parallel()
+
+	directory := &CredentialVerifierMock{
+		name: "ldap",
+		users: map[string]DirectoryUser{
+			"alice": {
+				Subject:      "uid=alice,ou=people,dc=example,dc=com",
+				Username:     "alice",
+				Email:        "alice@example.com",
+				FirstName:    "Alice",
+				Roles:        []string{domain.RoleAdmin},
+				ManagedRoles: []string{domain.RoleAdmin, "editor"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		verifiers    []CredentialVerifier
+		credentials  domain.Credentials
+		wantErr      bool
+		wantInvalid  bool
+		wantNotFound bool
+	}{
+		{name: "Directory User", verifiers: []CredentialVerifier{directory}, credentials: domain.Credentials{Username: "alice", Password: "secret"}},
+		{
+			name:        "Next Directory",
+			verifiers:   []CredentialVerifier{&CredentialVerifierMock{name: "ad"}, directory},
+			credentials: domain.Credentials{Username: "alice", Password: "secret"},
+		},
+		{
+			name:        "Wrong Password",
+			verifiers:   []CredentialVerifier{directory, &CredentialVerifierMock{name: "ad"}},
+			credentials: domain.Credentials{Username: "alice", Password: "wrong"},
+			wantInvalid: true,
+		},
+		{
+			name:         "Unknown User",
+			verifiers:    []CredentialVerifier{directory},
+			credentials:  domain.Credentials{Username: "bob", Password: "secret"},
+			wantNotFound: true,
+		},
+		{name: "No Directories", credentials: domain.Credentials{Username: "alice", Password: "secret"}, wantNotFound: true},
+		{
+			name:        "Directory Down",
+			verifiers:   []CredentialVerifier{&CredentialVerifierMock{name: "ad", err: errors.New("connection refused")}, directory},
+			credentials: domain.Credentials{Username: "alice", Password: "secret"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			roleRepo := &RoleRepositoryMock{granted: map[string][]string{"1": {"editor"}}}
+			a := &authService{userRepo: &DirectoryUserRepositoryMock{}, roleRepo: roleRepo}
+			WithCredentialVerifiers(tt.verifiers...)(a)
+
+			user, err := a.verifyCredentials(context.Background(), tt.credentials)
+
+			var notFoundErr *domain.ResourceNotFoundError
+
+			if (err != nil) != (tt.wantErr || tt.wantInvalid || tt.wantNotFound) ||
+				errors.Is(err, ErrInvalidCredentials) != tt.wantInvalid ||
+				errors.As(err, &notFoundErr) != tt.wantNotFound {
+				t.Fatalf("authService.verifyCredentials() error = %v, wantErr %v, wantInvalid %v, wantNotFound %v",
+					err, tt.wantErr, tt.wantInvalid, tt.wantNotFound)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if user.Username != "alice" || user.Email != "alice@example.com" || user.EmailVerifiedAt == nil {
+				t.Errorf("authService.verifyCredentials() = %+v, want alice created from the directory", user)
+			}
+
+			if roles := roleRepo.granted[user.ID]; !slices.Equal(roles, []string{domain.RoleAdmin}) {
+				t.Errorf("authService.verifyCredentials() roles = %v, want admin only", roles)
+			}
+		})
+	}
+}
+
+func Test_authService_syncDirectoryUser(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	userRepo := &DirectoryUserRepositoryMock{}
+	identityRepo := &UserIdentityRepositoryMock{}
+	a := &authService{userRepo: userRepo}
+	WithUserIdentityRepo(identityRepo)(a)
+
+	directoryUser := &DirectoryUser{Subject: "uid=alice,dc=example,dc=com", Username: "alice", Email: "alice@example.com"}
+
+	created, err := a.syncDirectoryUser(ctx, "ldap", directoryUser)
+	if err != nil {
+		t.Fatalf("authService.syncDirectoryUser() error = %v", err)
+	}
+
+	// renamed in the directory, found by the linked identity
+	directoryUser.Username = "alice.smith"
+	directoryUser.LastName = "Smith"
+	directoryUser.Email = "alice.smith@example.com"
+
+	synced, err := a.syncDirectoryUser(ctx, "ldap", directoryUser)
+	if err != nil || synced.ID != created.ID || synced.LastName != "Smith" {
+		t.Errorf("authService.syncDirectoryUser() = %+v, %v, want the linked user updated", synced, err)
+	}
+
+	if synced.Email != directoryUser.Email || synced.EmailVerifiedAt == nil {
+		t.Errorf("authService.syncDirectoryUser() = %+v, want the new address of the directory verified", synced)
+	}
+
+	if identity, err := identityRepo.Find(ctx, "ldap", directoryUser.Subject); err != nil || identity.LastUsedAt == nil {
+		t.Errorf("authService.syncDirectoryUser() identity = %+v, %v, want it used", identity, err)
+	}
+}
+
+func Test_authService_verifyCredentials_existingUser(t *testing.T) {
+	t.Parallel()
+
+	directory := &CredentialVerifierMock{
+		name:  "ldap",
+		users: map[string]DirectoryUser{"admin": {Subject: "uid=admin,dc=example,dc=com", Username: "admin"}},
+	}
+
+	tests := []struct {
+		name       string
+		existing   domain.User
+		roles      []string
+		linking    []string
+		wantLinked bool
+	}{
+		{name: "Local Admin", existing: domain.User{Username: "admin", Password: passwordHash}, roles: []string{domain.RoleAdmin}, linking: []string{"ldap"}},
+		{name: "Local Password", existing: domain.User{Username: "admin", Password: passwordHash}, linking: []string{"ldap"}},
+		{name: "Admin Without Password", existing: domain.User{Username: "admin"}, roles: []string{domain.RoleAdmin}, linking: []string{"ldap"}},
+		{name: "Not Opted In", existing: domain.User{Username: "admin"}},
+		{name: "Other Directory Opted In", existing: domain.User{Username: "admin"}, linking: []string{"ad"}},
+		{name: "Linked", existing: domain.User{Username: "admin"}, linking: []string{"ldap"}, wantLinked: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			userRepo := &DirectoryUserRepositoryMock{}
+			identityRepo := &UserIdentityRepositoryMock{}
+
+			existing, _ := userRepo.Create(ctx, &tt.existing)
+
+			a := &authService{
+				userRepo: userRepo,
+				roleRepo: &impersonationRoleRepo{RoleRepositoryMock{granted: map[string][]string{existing.ID: tt.roles}}},
+			}
+			WithUserIdentityRepo(identityRepo)(a)
+			WithCredentialVerifiers(directory)(a)
+			WithDirectoryUsernameLinking(tt.linking...)(a)
+
+			user, err := a.verifyCredentials(ctx, domain.Credentials{Username: "admin", Password: "secret"})
+
+			if !tt.wantLinked {
+				if !errors.Is(err, ErrInvalidCredentials) || !errors.Is(err, ErrDirectoryUserNotLinked) {
+					t.Fatalf("authService.verifyCredentials() = %+v, %v, want %v", user, err, ErrDirectoryUserNotLinked)
+				}
+
+				if _, err := identityRepo.Find(ctx, "ldap", "uid=admin,dc=example,dc=com"); err == nil {
+					t.Errorf("authService.verifyCredentials() linked the directory user to %+v", existing)
+				}
+
+				return
+			}
+
+			if err != nil || user.ID != existing.ID {
+				t.Fatalf("authService.verifyCredentials() = %+v, %v, want %+v linked", user, err, existing)
+			}
+
+			if identity, err := identityRepo.Find(ctx, "ldap", "uid=admin,dc=example,dc=com"); err != nil || identity.UserID != existing.ID {
+				t.Errorf("authService.verifyCredentials() identity = %+v, %v, want it linked to %s", identity, err, existing.ID)
+			}
+		})
+	}
+}
+
+var _ CredentialVerifier = &CredentialVerifierMock{}
+
+// CredentialVerifierMock is a directory of users whose password is
+// "secret".
+type CredentialVerifierMock struct {
+	name  string
+	users map[string]DirectoryUser
+	err   error
+}
+
+func (v *CredentialVerifierMock) Name() string {
+	return v.name
+}
+
+func (v *CredentialVerifierMock) VerifyCredentials(
+	_ context.Context,
+	credentials domain.Credentials,
+) (*DirectoryUser, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	user, ok := v.users[credentials.Username]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("DirectoryUser", "username="+credentials.Username)
+	}
+
+	if credentials.Password != "secret" {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &user, nil
+}
+
+// DirectoryUserRepositoryMock keeps the users it creates, who have no
+// password.
+type DirectoryUserRepositoryMock struct {
+	UserRepositoryMock
+
+	mu    sync.Mutex
+	users []*domain.User
+}
+
+func (u *DirectoryUserRepositoryMock) find(match func(user *domain.User) bool) (*domain.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, user := range u.users {
+		if match(user) {
+			found := *user
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("User", "")
+}
+
+func (u *DirectoryUserRepositoryMock) FindByUsername(_ context.Context, username string) (*domain.User, error) {
+	return u.find(func(user *domain.User) bool { return user.Username == username })
+}
+
+func (u *DirectoryUserRepositoryMock) FindByID(_ context.Context, id string) (*domain.User, error) {
+	return u.find(func(user *domain.User) bool { return user.ID == id })
+}
+
+func (u *DirectoryUserRepositoryMock) Create(_ context.Context, user *domain.User) (*domain.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	created := *user
+	created.ID = strconv.Itoa(len(u.users) + 1)
+	u.users = append(u.users, &created)
+
+	found := created
+
+	return &found, nil
+}
+
+func (u *DirectoryUserRepositoryMock) Update(ctx context.Context, user *domain.User) (*domain.User, error) {
+	u.mu.Lock()
+
+	for _, existing := range u.users {
+		if existing.ID == user.ID {
+			if user.Email != "" && user.Email != existing.Email {
+				existing.Email, existing.EmailVerifiedAt = user.Email, nil
+			}
+
+			existing.FirstName = cmp.Or(user.FirstName, existing.FirstName)
+			existing.LastName = cmp.Or(user.LastName, existing.LastName)
+		}
+	}
+
+	u.mu.Unlock()
+
+	return u.FindByID(ctx, user.ID)
+}
+
+func (u *DirectoryUserRepositoryMock) MarkEmailVerified(_ context.Context, id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, user := range u.users {
+		if user.ID == id {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	return nil
+}
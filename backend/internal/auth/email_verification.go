@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/mail"
+)
+
+const DefaultEmailVerificationDuration = 72 * time.Hour
+
+var (
+	ErrEmailNotVerified            = errors.New("email address is not verified")
+	ErrInvalidVerificationToken    = errors.New("invalid or expired email verification token")
+	ErrVerificationMailNotSent     = errors.New("verification email could not be sent")
+	ErrEmailVerificationNotEnabled = errors.New("email verification is not enabled")
+)
+
+// WithRequireVerifiedEmail makes Login refuse accounts whose e-mail address
+// has not been verified yet.
+func WithRequireVerifiedEmail(required bool) Option {
+	return func(a *authService) {
+		a.requireVerifiedEmail = required
+	}
+}
+
+// sendVerificationEmail e-mails user a link proving they own their address.
+// It is a no-op when no mailer is configured.
+func (a *authService) sendVerificationEmail(ctx context.Context, user *domain.User) error {
+	if a.userTokenRepo == nil || a.mailer == nil {
+		return nil
+	}
+
+	secret, err := a.issueUserToken(
+		ctx,
+		user,
+		domain.UserTokenEmailVerification,
+		DefaultEmailVerificationDuration,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = a.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your e-mail address",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Please confirm that this is your e-mail address by following the link below:\n\n" +
+			a.appLink("/verify-email", secret) + "\n\n" +
+			"If you did not sign up or change your e-mail address, ignore this e-mail.\n",
+	})
+	if err != nil {
+		return fmt.Errorf("send verification mail error %w", err)
+	}
+
+	return nil
+}
+
+// ResendVerificationEmail e-mails a new verification link to the user of an
+// address not verified yet. Unknown and verified addresses are ignored, not
+// to tell whether they belong to a user.
+func (a *authService) ResendVerificationEmail(ctx context.Context, email string) error {
+	if a.userTokenRepo == nil || a.mailer == nil {
+		return ErrEmailVerificationNotEnabled
+	}
+
+	user, err := a.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return a.sendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail marks the e-mail address of the owner of a verification token
+// as verified. The token is used up.
+func (a *authService) VerifyEmail(ctx context.Context, token string) error {
+	if a.userTokenRepo == nil {
+		return ErrEmailVerificationNotEnabled
+	}
+
+	userToken, err := a.userTokenRepo.Consume(
+		ctx,
+		domain.UserTokenEmailVerification,
+		hashToken(token),
+	)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidVerificationToken
+		}
+
+		return fmt.Errorf("consume email verification token error %w", err)
+	}
+
+	if err := a.userRepo.MarkEmailVerified(ctx, userToken.UserID); err != nil {
+		return fmt.Errorf("mark email verified error %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/mail"
+)
+
+type failingMailer struct{}
+
+func (failingMailer) Send(_ context.Context, _ mail.Message) error {
+	return errors.New("smtp error")
+}
+
+func Test_authService_Register_verificationEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		mailer   mail.Mailer
+		wantMail bool
+		wantErr  error
+	}{
+		{
+			name:     "Mail Sent",
+			mailer:   mail.NewMemoryMailer(),
+			wantMail: true,
+		},
+		{
+			name:    "Mail Not Sent",
+			mailer:  failingMailer{},
+			wantErr: ErrVerificationMailNotSent,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			userTokenRepo := &UserTokenRepositoryMock{}
+			a := &authService{
+				userRepo:      &UserRepositoryMock{},
+				userTokenRepo: userTokenRepo,
+				mailer:        tt.mailer,
+				appURL:        "https://app.example.com",
+			}
+
+			got, err := a.Register(context.Background(), &domain.User{
+				Username:  "username",
+				Email:     "user@example.com",
+				Password:  "violet-harbor",
+				FirstName: "User",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.Register() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got == nil {
+				t.Fatalf("authService.Register() user = nil, want the created user")
+			}
+
+			if !tt.wantMail {
+				return
+			}
+
+			messages := tt.mailer.(*mail.MemoryMailer).Messages()
+			if len(messages) != 1 {
+				t.Fatalf("authService.Register() sent %v mails, want 1", len(messages))
+			}
+
+			token := tokenFromMail(t, messages[0])
+
+			if err := a.VerifyEmail(context.Background(), token); err != nil {
+				t.Errorf("authService.VerifyEmail() error = %v", err)
+			}
+
+			if err := a.VerifyEmail(context.Background(), token); !errors.Is(err, ErrInvalidVerificationToken) {
+				t.Errorf("authService.VerifyEmail() reused token error = %v, want %v", err, ErrInvalidVerificationToken)
+			}
+		})
+	}
+}
+
+func Test_authService_ResendVerificationEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		email         string
+		userTokenRepo *UserTokenRepositoryMock
+		wantMail      bool
+		wantErr       error
+	}{
+		{
+			name:          "Success",
+			email:         "user@example.com",
+			userTokenRepo: &UserTokenRepositoryMock{},
+			wantMail:      true,
+		},
+		{
+			name:          "Unknown Email",
+			email:         "nobody@example.com",
+			userTokenRepo: &UserTokenRepositoryMock{},
+		},
+		{
+			name:    "Not Enabled",
+			email:   "user@example.com",
+			wantErr: ErrEmailVerificationNotEnabled,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mailer := mail.NewMemoryMailer()
+			a := &authService{
+				userRepo: &UserRepositoryMock{},
+				mailer:   mailer,
+				appURL:   "https://app.example.com",
+			}
+
+			if tt.userTokenRepo != nil {
+				a.userTokenRepo = tt.userTokenRepo
+			}
+
+			err := a.ResendVerificationEmail(context.Background(), tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.ResendVerificationEmail() error = %v, want %v", err, tt.wantErr)
+			}
+
+			messages := mailer.Messages()
+			if got := len(messages) == 1; got != tt.wantMail {
+				t.Fatalf("authService.ResendVerificationEmail() sent %v mails, want mail %v", len(messages), tt.wantMail)
+			}
+
+			if tt.wantMail {
+				if err := a.VerifyEmail(context.Background(), tokenFromMail(t, messages[0])); err != nil {
+					t.Errorf("authService.VerifyEmail() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_authService_Login_unverifiedEmail(t *testing.T) {
+	t.Parallel()
+
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithRequireVerifiedEmail(true)(a)
+
+	_, err := a.Login(context.Background(), domain.Credentials{
+		Username: "username",
+		Password: "password",
+	})
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("authService.Login() error = %v, want %v", err, ErrEmailNotVerified)
+	}
+}
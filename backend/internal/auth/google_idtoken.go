@@ -3,14 +3,16 @@ package auth
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	"google.golang.org/api/idtoken"
 
 	"goadmin-backend/internal/domain"
 )
 
-var ErrInvalidIDToken = errors.New("invalid token")
+var (
+	ErrInvalidIDToken         = errors.New("invalid token")
+	ErrInvalidGoogleCSRFToken = errors.New("invalid g_csrf_token")
+)
 
 // GoogleIDTokenValidator is an interface for validating Google ID tokens.
 type GoogleIDTokenValidator interface {
@@ -31,10 +33,19 @@ func (a *authService) ValidateGoogleIDToken(
 		return nil, errors.Join(ErrInvalidIDToken, err)
 	}
 
-	user, err := a.userRepo.FindByUsername(ctx, tokenInfo.Claims["email"].(string))
-	if err != nil {
-		return nil, fmt.Errorf("error find user by username: %w", err)
-	}
-
-	return a.generateToken(user.Username)
+	email, _ := tokenInfo.Claims["email"].(string)
+	emailVerified, _ := tokenInfo.Claims["email_verified"].(bool)
+	firstName, _ := tokenInfo.Claims["given_name"].(string)
+	lastName, _ := tokenInfo.Claims["family_name"].(string)
+	picture, _ := tokenInfo.Claims["picture"].(string)
+
+	return a.signInWithIdentity(ctx, &OIDCIdentity{
+		Provider:      GoogleProvider,
+		Subject:       tokenInfo.Subject,
+		Email:         email,
+		EmailVerified: emailVerified,
+		FirstName:     firstName,
+		LastName:      lastName,
+		Picture:       picture,
+	})
 }
@@ -13,10 +13,21 @@ import (
 func Test_authService_VerifyGoogleIDToken(t *testing.T) {
 	t.Parallel()
 
+	// Google is trusted with the e-mail addresses of its accounts
+	google, err := NewOIDCProvider(OIDCProviderConfig{
+		Name:         GoogleProvider,
+		Issuer:       "https://accounts.google.com",
+		ClientID:     "audience",
+		Provisioning: ProvisioningPolicy{LinkByEmail: true},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+
 	type fields struct {
 		userRepo         domain.UserRepository
 		revokedTokenRepo domain.RevokedTokenRepository
-		jwtSecret        []byte
+		keyRing          *KeyRing
 		idTokenValidator GoogleIDTokenValidator
 	}
 
@@ -37,7 +48,7 @@ func Test_authService_VerifyGoogleIDToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("jwtSecret"),
+				keyRing:          NewHMACKeyRing([]byte("jwtSecret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -51,7 +62,7 @@ func Test_authService_VerifyGoogleIDToken(t *testing.T) {
 			fields: fields{
 				userRepo:         &UserRepositoryMock{},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("jwtSecret"),
+				keyRing:          NewHMACKeyRing([]byte("jwtSecret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{
 					hasError: true,
 				},
@@ -62,12 +73,40 @@ func Test_authService_VerifyGoogleIDToken(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "No Email",
+			fields: fields{
+				userRepo:         &UserRepositoryMock{},
+				revokedTokenRepo: &RevokedTokenRepositoryMock{},
+				keyRing:          NewHMACKeyRing([]byte("jwtSecret")),
+				idTokenValidator: &GoogleIDTokenValidatorMock{claims: map[string]interface{}{}},
+			},
+			args: args{
+				idToken: "idToken",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unverified Email",
+			fields: fields{
+				userRepo:         &UserRepositoryMock{},
+				revokedTokenRepo: &RevokedTokenRepositoryMock{},
+				keyRing:          NewHMACKeyRing([]byte("jwtSecret")),
+				idTokenValidator: &GoogleIDTokenValidatorMock{
+					claims: map[string]interface{}{"email": "user@example.com"},
+				},
+			},
+			args: args{
+				idToken: "idToken",
+			},
+			wantErr: true,
+		},
 		{
 			name: "Get user error",
 			fields: fields{
 				userRepo:         &UserRepositoryMock{hasError: true},
 				revokedTokenRepo: &RevokedTokenRepositoryMock{},
-				jwtSecret:        []byte("jwtSecret"),
+				keyRing:          NewHMACKeyRing([]byte("jwtSecret")),
 				idTokenValidator: &GoogleIDTokenValidatorMock{},
 			},
 			args: args{
@@ -85,9 +124,11 @@ func Test_authService_VerifyGoogleIDToken(t *testing.T) {
 			a := &authService{
 				userRepo:         tt.fields.userRepo,
 				revokedTokenRepo: tt.fields.revokedTokenRepo,
-				jwtSecret:        tt.fields.jwtSecret,
+				keyRing:          tt.fields.keyRing,
 				idTokenValidator: tt.fields.idTokenValidator,
 			}
+			WithOIDCProviders(google)(a)
+
 			got, err := a.ValidateGoogleIDToken(
 				context.Background(),
 				tt.args.idToken,
@@ -119,6 +160,7 @@ var _ GoogleIDTokenValidator = &GoogleIDTokenValidatorMock{}
 
 type GoogleIDTokenValidatorMock struct {
 	hasError bool
+	claims   map[string]interface{}
 }
 
 func (g *GoogleIDTokenValidatorMock) Validate(
@@ -130,8 +172,13 @@ func (g *GoogleIDTokenValidatorMock) Validate(
 		return nil, errors.New("error")
 	}
 
+	claims := g.claims
+	if claims == nil {
+		claims = map[string]interface{}{"email": "user@example.com", "email_verified": true}
+	}
+
 	return &idtoken.Payload{
 		Subject: "subject",
-		Claims:  map[string]interface{}{"email": "email"},
+		Claims:  claims,
 	}, nil
 }
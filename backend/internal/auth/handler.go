@@ -1,27 +1,59 @@
 package auth
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 
 	"goadmin-backend/internal/domain"
 	"goadmin-backend/internal/platform/httperr"
 	"goadmin-backend/internal/platform/httpjson"
+	"goadmin-backend/internal/platform/slices"
 )
 
 type Handler struct {
 	httpjson.Handler
-	authService Service
+	authService  Service
+	tokenSources []TokenSource
+	cookies      *TokenCookieConfig
+}
+
+// HandlerOption configures optional features of the auth handler.
+type HandlerOption func(*Handler)
+
+// WithTokenSources sets where the Authenticator looks for credentials by
+// default: the Authorization header, the X-API-Key header and the jwt
+// cookie when not set.
+func WithTokenSources(sources ...TokenSource) HandlerOption {
+	return func(h *Handler) {
+		h.tokenSources = sources
+	}
 }
 
-func NewHandler(authService Service, logger *slog.Logger) *Handler {
-	return &Handler{
+func NewHandler(authService Service, logger *slog.Logger, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		Handler: httpjson.Handler{
 			Logger: logger,
 		},
 		authService: authService,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // Login handler signs in a user.
@@ -34,8 +66,17 @@ func (h *Handler) Login(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	token, err := h.authService.Login(req.Context(), credentials)
+	token, err := h.authService.Login(clientContext(req), credentials)
 	if err != nil {
+		var throttledErr *LoginThrottledError
+		if errors.As(err, &throttledErr) {
+			h.Logger.Warn("error login throttled", slog.Any("err", err))
+
+			writeLoginThrottledError(res, req, throttledErr)
+
+			return
+		}
+
 		if errors.Is(err, ErrInvalidCredentials) {
 			h.Logger.Error("error invalid credentials", slog.Any("err", err))
 
@@ -44,6 +85,14 @@ func (h *Handler) Login(res http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		if errors.Is(err, ErrEmailNotVerified) {
+			h.Logger.Error("error email not verified", slog.Any("err", err))
+
+			httperr.JSONError(res, newEmailNotVerifiedError(req.URL.Path), http.StatusForbidden)
+
+			return
+		}
+
 		h.Logger.Error("error logging in", slog.Any("err", err))
 
 		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
@@ -51,7 +100,97 @@ func (h *Handler) Login(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	h.RespondJSON(res, token, http.StatusOK)
+	if token.MFAToken != "" {
+		h.RespondJSON(res, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token.MFAToken,
+		}, http.StatusOK)
+
+		return
+	}
+
+	h.respondToken(res, req, token)
+}
+
+// VerifyMFA handler completes a sign-in started by Login with the second
+// factor of the user.
+func (h *Handler) VerifyMFA(res http.ResponseWriter, req *http.Request) {
+	var verifyReq MFAVerifyRequest
+
+	if err := h.ParseJSON(res, req, &verifyReq); err != nil {
+		h.Logger.Error("error decoding mfa verify request", slog.Any("err", err))
+
+		return
+	}
+
+	token, err := h.authService.VerifyMFA(clientContext(req), verifyReq.MFAToken, verifyReq.Code)
+	if err != nil {
+		h.Logger.Error("error verifying mfa", slog.Any("err", err))
+
+		var throttledErr *LoginThrottledError
+		if errors.As(err, &throttledErr) {
+			writeLoginThrottledError(res, req, throttledErr)
+
+			return
+		}
+
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidMFACode) {
+			httperr.JSONError(res, err, http.StatusUnauthorized, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.respondToken(res, req, token)
+}
+
+// Refresh handler exchanges a refresh token for a new token pair.
+func (h *Handler) Refresh(res http.ResponseWriter, req *http.Request) {
+	var refreshReq RefreshTokenRequest
+
+	if err := h.ParseJSON(res, req, &refreshReq); err != nil {
+		h.Logger.Error("error decoding refresh token request", slog.Any("err", err))
+
+		return
+	}
+
+	refreshToken := refreshReq.RefreshToken
+
+	// browsers in cookie mode send the cookie, which needs the CSRF token
+	if refreshToken == "" && h.cookies != nil {
+		if cookie, err := req.Cookie(refreshTokenCookie); err == nil {
+			refreshToken = cookie.Value
+		}
+
+		if !validCSRFToken(req) {
+			httperr.JSONError(res, ErrInvalidCSRFToken, http.StatusForbidden, req.URL.Path)
+
+			return
+		}
+	}
+
+	token, err := h.authService.RefreshToken(req.Context(), refreshToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRefreshTokenReused) {
+			h.Logger.Error("error invalid refresh token", slog.Any("err", err))
+
+			httperr.JSONError(res, err, http.StatusUnauthorized, req.URL.Path)
+
+			return
+		}
+
+		h.Logger.Error("error refreshing token", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.respondToken(res, req, token)
 }
 
 // register handler signs up a user
@@ -73,9 +212,24 @@ func (h *Handler) Register(res http.ResponseWriter, req *http.Request) {
 	}
 
 	newUser, err := h.authService.Register(req.Context(), user)
+	if errors.Is(err, ErrVerificationMailNotSent) {
+		// the account exists, the user can ask for the e-mail again with
+		// ResendVerificationEmail
+		h.Logger.Warn("error sending verification email", slog.Any("err", err))
+
+		err = nil
+	}
+
 	if err != nil {
 		h.Logger.Error("error registering user", slog.Any("err", err))
 
+		var validationErr *httperr.ValidationError
+		if errors.As(err, &validationErr) {
+			httperr.JSONError(res, err, http.StatusUnprocessableEntity, req.URL.Path)
+
+			return
+		}
+
 		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
 
 		return
@@ -88,6 +242,12 @@ func (h *Handler) Register(res http.ResponseWriter, req *http.Request) {
 
 // SignInWithGoogle handler signs in a user using Google.
 func (h *Handler) SignInWithGoogle(res http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		h.signInWithGoogleOneTap(res, req)
+
+		return
+	}
+
 	var idTokenReq GoogleIDTokenVerifyRequest
 
 	if err := h.ParseJSON(res, req, &idTokenReq); err != nil {
@@ -96,8 +256,17 @@ func (h *Handler) SignInWithGoogle(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// the token is forwarded from One Tap, which double-submits it
+	if idTokenReq.GCSRFToken != "" && !validGoogleCSRFToken(req, idTokenReq.GCSRFToken) {
+		h.Logger.Error("error invalid g_csrf_token")
+
+		httperr.JSONError(res, ErrInvalidGoogleCSRFToken, http.StatusForbidden, req.URL.Path)
+
+		return
+	}
+
 	token, err := h.authService.ValidateGoogleIDToken(
-		req.Context(),
+		clientContext(req),
 		idTokenReq.IDToken,
 		"", // Use the default audience
 	)
@@ -121,6 +290,14 @@ func (h *Handler) SignInWithGoogle(res http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		if errors.Is(err, ErrIdentityNotLinked) {
+			h.Logger.Error("error identity not linked", slog.Any("err", err))
+
+			httperr.JSONError(res, err, http.StatusConflict)
+
+			return
+		}
+
 		// other errors
 		h.Logger.Error("error validating google id token", slog.Any("err", err))
 
@@ -129,27 +306,438 @@ func (h *Handler) SignInWithGoogle(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	h.RespondJSON(res, token, http.StatusOK)
+	if token.MFAToken != "" {
+		h.RespondJSON(res, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token.MFAToken,
+		}, http.StatusOK)
+
+		return
+	}
+
+	h.respondToken(res, req, token)
+}
+
+// signInWithGoogleOneTap signs in the user of the credential Google One Tap
+// posts to its login_uri, and sends them on to the frontend.
+func (h *Handler) signInWithGoogleOneTap(res http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		h.Logger.Error("error parsing google one tap form", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	if !validGoogleCSRFToken(req, req.PostForm.Get("g_csrf_token")) {
+		h.Logger.Error("error invalid g_csrf_token")
+
+		httperr.JSONError(res, ErrInvalidGoogleCSRFToken, http.StatusForbidden, req.URL.Path)
+
+		return
+	}
+
+	if h.authService.LoginRedirectURL() == "" {
+		httperr.JSONError(res, ErrOIDCLoginNotSupported, http.StatusNotFound, req.URL.Path)
+
+		return
+	}
+
+	token, err := h.authService.ValidateGoogleIDToken(clientContext(req), req.PostForm.Get("credential"), "")
+	if err != nil {
+		h.Logger.Error("error validating google id token", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.Is(err, ErrInvalidIDToken):
+			h.redirectLogin(res, req, nil, "", LoginErrorInvalidIDToken)
+		case errors.As(err, &notFoundErr):
+			h.redirectLogin(res, req, nil, "", LoginErrorUserNotFound)
+		case errors.Is(err, ErrIdentityNotLinked):
+			h.redirectLogin(res, req, nil, "", LoginErrorIdentityNotLinked)
+		default:
+			h.redirectLogin(res, req, nil, "", LoginErrorServerError)
+		}
+
+		return
+	}
+
+	h.redirectLogin(res, req, token, "", "")
+}
+
+// OIDCProviders handler lists the OpenID Connect providers users can sign
+// in with.
+func (h *Handler) OIDCProviders(res http.ResponseWriter, _ *http.Request) {
+	h.RespondJSON(res, slices.Map(h.authService.OIDCProviders(), ToOIDCProviderResponse), http.StatusOK)
+}
+
+// SignInWithOIDC handler signs in a user with the ID token of an OpenID
+// Connect provider.
+func (h *Handler) SignInWithOIDC(res http.ResponseWriter, req *http.Request) {
+	var signInReq OIDCSignInRequest
+
+	if err := h.ParseJSON(res, req, &signInReq); err != nil {
+		h.Logger.Error("error decoding oidc sign in request", slog.Any("err", err))
+
+		return
+	}
+
+	token, err := h.authService.SignInWithOIDC(
+		clientContext(req),
+		chi.URLParam(req, "provider"),
+		signInReq.IDToken,
+		signInReq.Nonce,
+	)
+	if err != nil {
+		h.Logger.Error("error signing in with oidc", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.Is(err, ErrUnknownProvider):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidIDToken):
+			httperr.JSONError(res, err, http.StatusUnauthorized, req.URL.Path)
+		case errors.As(err, &notFoundErr):
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrIdentityNotLinked):
+			httperr.JSONError(res, err, http.StatusConflict, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	if token.MFAToken != "" {
+		h.RespondJSON(res, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token.MFAToken,
+		}, http.StatusOK)
+
+		return
+	}
+
+	h.respondToken(res, req, token)
+}
+
+// oidcFlowCookie keeps the flow token of a sign-in with redirects between
+// the start and the callback.
+const oidcFlowCookie = "goadmin_oidc_flow"
+
+// googleCSRFCookie is the double-submit cookie Google One Tap sets along
+// with the g_csrf_token field it posts.
+const googleCSRFCookie = "g_csrf_token"
+
+// StartOIDCLogin handler sends the browser to a provider to sign in, with
+// the authorization code flow.
+func (h *Handler) StartOIDCLogin(res http.ResponseWriter, req *http.Request) {
+	login, err := h.authService.StartOIDCLogin(
+		req.Context(),
+		chi.URLParam(req, "provider"),
+		req.URL.Query().Get("redirect_to"),
+	)
+	if err != nil {
+		h.Logger.Error("error starting oidc login", slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrUnknownProvider), errors.Is(err, ErrOIDCLoginNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidRedirectTo):
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		case errors.Is(err, ErrProviderDiscovery):
+			httperr.JSONError(res, err, http.StatusBadGateway, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	// without a Path the cookie is scoped to /auth/{provider}/, wherever the
+	// API is mounted; Lax still sends it on the redirect back
+	http.SetCookie(res, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    login.FlowToken,
+		Expires:  login.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secureRequest(req),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	res.Header().Set("Cache-Control", "no-store")
+
+	http.Redirect(res, req, login.AuthorizationURL, http.StatusFound)
+}
+
+// FinishOIDCLogin handler is the callback providers redirect back to. The
+// user is sent on to the frontend with the token pair, or the error, in the
+// URL fragment.
+func (h *Handler) FinishOIDCLogin(res http.ResponseWriter, req *http.Request) {
+	var flowToken string
+	if cookie, err := req.Cookie(oidcFlowCookie); err == nil {
+		flowToken = cookie.Value
+	}
+
+	// the flow token is good for one callback
+	http.SetCookie(res, &http.Cookie{
+		Name:     oidcFlowCookie,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(req),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := req.URL.Query()
+
+	result, err := h.authService.FinishOIDCLogin(
+		clientContext(req),
+		chi.URLParam(req, "provider"),
+		flowToken,
+		OIDCCallback{
+			State:            query.Get("state"),
+			Code:             query.Get("code"),
+			Error:            query.Get("error"),
+			ErrorDescription: query.Get("error_description"),
+		},
+	)
+	if err != nil {
+		h.Logger.Error("error finishing oidc login", slog.Any("err", err))
+
+		var loginErr *OIDCLoginError
+
+		switch {
+		case errors.As(err, &loginErr):
+			h.redirectLogin(res, req, nil, loginErr.RedirectTo, loginErr.Code)
+		case errors.Is(err, ErrUnknownProvider):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidLoginState):
+			// not the browser the sign-in was started in
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	h.redirectLogin(res, req, result.Token, result.RedirectTo, "")
+}
+
+// samlFlowCookie keeps the flow token of a SAML sign-in between the start
+// and the assertion consumer service.
+const samlFlowCookie = "goadmin_saml_flow"
+
+// SAMLMetadata handler serves our service provider metadata for a SAML
+// connection.
+func (h *Handler) SAMLMetadata(res http.ResponseWriter, req *http.Request) {
+	metadata, err := h.authService.SAMLMetadata(chi.URLParam(req, "connection"))
+	if err != nil {
+		h.Logger.Error("error getting saml metadata", slog.Any("err", err))
+
+		if errors.Is(err, ErrUnknownSAMLConnection) {
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		} else {
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/samlmetadata+xml")
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := res.Write(metadata); err != nil {
+		h.Logger.Error("error writing saml metadata", slog.Any("err", err))
+	}
+}
+
+// StartSAMLLogin handler sends the browser to the identity provider of a
+// SAML connection with a signed authentication request.
+func (h *Handler) StartSAMLLogin(res http.ResponseWriter, req *http.Request) {
+	login, err := h.authService.StartSAMLLogin(
+		req.Context(),
+		chi.URLParam(req, "connection"),
+		req.URL.Query().Get("redirect_to"),
+	)
+	if err != nil {
+		h.Logger.Error("error starting saml login", slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrUnknownSAMLConnection), errors.Is(err, ErrOIDCLoginNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidRedirectTo):
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		case errors.Is(err, ErrSAMLMetadata):
+			httperr.JSONError(res, err, http.StatusBadGateway, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	// the response is posted back cross-site, which only sends SameSite=None
+	// cookies; those have to be secure, so plain HTTP is left with Lax, good
+	// for an identity provider of the same site only
+	http.SetCookie(res, &http.Cookie{
+		Name:     samlFlowCookie,
+		Value:    login.FlowToken,
+		Expires:  login.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secureRequest(req),
+		SameSite: samlFlowCookieSameSite(req),
+	})
+
+	res.Header().Set("Cache-Control", "no-store")
+
+	http.Redirect(res, req, login.AuthorizationURL, http.StatusFound)
+}
+
+// FinishSAMLLogin handler is the assertion consumer service identity
+// providers post their responses to. The user is sent on to the frontend
+// with the token pair, or the error, in the URL fragment.
+func (h *Handler) FinishSAMLLogin(res http.ResponseWriter, req *http.Request) {
+	var flowToken string
+	if cookie, err := req.Cookie(samlFlowCookie); err == nil {
+		flowToken = cookie.Value
+	}
+
+	// the flow token is good for one response
+	http.SetCookie(res, &http.Cookie{
+		Name:     samlFlowCookie,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(req),
+		SameSite: samlFlowCookieSameSite(req),
+	})
+
+	if err := req.ParseForm(); err != nil {
+		h.Logger.Error("error parsing saml response form", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	result, err := h.authService.FinishSAMLLogin(
+		clientContext(req),
+		chi.URLParam(req, "connection"),
+		flowToken,
+		req.PostForm.Get("SAMLResponse"),
+	)
+	if err != nil {
+		h.Logger.Error("error finishing saml login", slog.Any("err", err))
+
+		var loginErr *OIDCLoginError
+
+		switch {
+		case errors.As(err, &loginErr):
+			h.redirectLogin(res, req, nil, loginErr.RedirectTo, loginErr.Code)
+		case errors.Is(err, ErrUnknownSAMLConnection):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidLoginState):
+			// not the browser the sign-in was started in
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	h.redirectLogin(res, req, result.Token, result.RedirectTo, "")
+}
+
+func samlFlowCookieSameSite(req *http.Request) http.SameSite {
+	if secureRequest(req) {
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteLaxMode
+}
+
+// redirectLogin sends the user to the login redirect page of the frontend
+// with the outcome of a sign-in in the URL fragment, which is not sent to
+// servers: the token pair, the MFA challenge or an error code. In cookie mode
+// the token pair is set in cookies instead.
+func (h *Handler) redirectLogin(
+	res http.ResponseWriter,
+	req *http.Request,
+	token *domain.JWTToken,
+	redirectTo, errorCode string,
+) {
+	fragment := url.Values{}
+
+	switch {
+	case errorCode != "":
+		fragment.Set("error", errorCode)
+	case token.MFAToken != "":
+		fragment.Set("mfa_token", token.MFAToken)
+	case h.cookies != nil:
+		if _, err := h.setTokenCookies(res, token); err != nil {
+			h.Logger.Error("error setting token cookies", slog.Any("err", err))
+
+			fragment.Set("error", LoginErrorServerError)
+		}
+	default:
+		fragment.Set("access_token", token.AccessToken)
+		fragment.Set("refresh_token", token.RefreshToken)
+	}
+
+	if redirectTo != "" {
+		fragment.Set("redirect_to", redirectTo)
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+
+	http.Redirect(res, req, h.authService.LoginRedirectURL()+"#"+fragment.Encode(), http.StatusSeeOther)
+}
+
+// secureRequest reports whether req came in over HTTPS, directly or through
+// a proxy, so that cookies set in response are only sent back over HTTPS.
+func secureRequest(req *http.Request) bool {
+	return req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// validGoogleCSRFToken checks the double-submit g_csrf_token of Google One
+// Tap: the field posted must match the cookie.
+func validGoogleCSRFToken(req *http.Request, token string) bool {
+	cookie, err := req.Cookie(googleCSRFCookie)
+	if err != nil || cookie.Value == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
 }
 
 // Logout handler logs out the current user.
 func (h *Handler) Logout(res http.ResponseWriter, req *http.Request) {
-	tokenString := FindToken(req)
+	tokenString := h.tokenFromRequest(req)
 
-	if err := h.authService.Logout(req.Context(), tokenString); err != nil {
+	if err := h.authService.Logout(clientContext(req), tokenString); err != nil {
 		h.Logger.Error("error logging out", slog.Any("err", err))
 
+		if errors.Is(err, ErrInvalidToken) {
+			httperr.JSONError(res, err, http.StatusUnauthorized)
+
+			return
+		}
+
 		httperr.JSONError(res, err, http.StatusInternalServerError)
 
 		return
 	}
 
+	h.clearTokenCookies(res)
+
 	res.WriteHeader(http.StatusOK)
 }
 
 // Profile handler gets the profile of the current user.
 func (h *Handler) Profile(res http.ResponseWriter, req *http.Request) {
-	tokenString := FindToken(req)
+	tokenString := h.tokenFromRequest(req)
 
 	user, err := h.authService.Profile(req.Context(), tokenString)
 	if err != nil {
@@ -162,3 +750,979 @@ func (h *Handler) Profile(res http.ResponseWriter, req *http.Request) {
 
 	h.RespondJSON(res, ToUserResponse(user), http.StatusOK)
 }
+
+// JWKS handler publishes the public keys tokens can be verified with.
+func (h *Handler) JWKS(res http.ResponseWriter, _ *http.Request) {
+	res.Header().Set("Cache-Control", "public, max-age=300")
+
+	h.RespondJSON(res, h.authService.JWKS(), http.StatusOK)
+}
+
+// ForgotPassword handler e-mails a password reset link. It answers the same
+// whether or not the address belongs to a user.
+func (h *Handler) ForgotPassword(res http.ResponseWriter, req *http.Request) {
+	var forgotReq ForgotPasswordRequest
+
+	if err := h.ParseJSON(res, req, &forgotReq); err != nil {
+		h.Logger.Error("error decoding forgot password request", slog.Any("err", err))
+
+		return
+	}
+
+	if err := h.authService.ForgotPassword(req.Context(), forgotReq.Email); err != nil {
+		h.Logger.Error("error sending password reset", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword handler sets a new password with a password reset token.
+func (h *Handler) ResetPassword(res http.ResponseWriter, req *http.Request) {
+	var resetReq ResetPasswordRequest
+
+	if err := h.ParseJSON(res, req, &resetReq); err != nil {
+		h.Logger.Error("error decoding reset password request", slog.Any("err", err))
+
+		return
+	}
+
+	err := h.authService.ResetPassword(req.Context(), resetReq.Token, resetReq.Password)
+	if err != nil {
+		h.Logger.Error("error resetting password", slog.Any("err", err))
+
+		if errors.Is(err, ErrInvalidResetToken) || errors.Is(err, ErrEmptyPassword) {
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+			return
+		}
+
+		var validationErr *httperr.ValidationError
+		if errors.As(err, &validationErr) {
+			httperr.JSONError(res, err, http.StatusUnprocessableEntity, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail handler verifies the e-mail address of a user with the token
+// of the link e-mailed on sign up.
+func (h *Handler) VerifyEmail(res http.ResponseWriter, req *http.Request) {
+	err := h.authService.VerifyEmail(req.Context(), req.URL.Query().Get("token"))
+	if err != nil {
+		h.Logger.Error("error verifying email", slog.Any("err", err))
+
+		if errors.Is(err, ErrInvalidVerificationToken) {
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerificationEmail handler e-mails a new verification link. It does
+// not tell whether the address belongs to a user.
+func (h *Handler) ResendVerificationEmail(res http.ResponseWriter, req *http.Request) {
+	var resendReq ResendVerificationEmailRequest
+
+	if err := h.ParseJSON(res, req, &resendReq); err != nil {
+		h.Logger.Error("error decoding resend verification email request", slog.Any("err", err))
+
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(req.Context(), resendReq.Email); err != nil {
+		h.Logger.Error("error resending verification email", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusAccepted)
+}
+
+// newEmailNotVerifiedError is the problem returned when an account cannot
+// sign in before its e-mail address is verified.
+func newEmailNotVerifiedError(instance string) *httperr.RESTAPIError {
+	return httperr.NewRESTAPIError(
+		instance,
+		"/errors/email-not-verified",
+		"Email Not Verified",
+		http.StatusForbidden,
+		"The e-mail address of this account has not been verified yet",
+	)
+}
+
+// writeLoginThrottledError answers a refused sign-in with 423 when the
+// account is locked out and 429 otherwise, telling the client when to retry
+// in the Retry-After header and the problem detail.
+func writeLoginThrottledError(res http.ResponseWriter, req *http.Request, err *LoginThrottledError) {
+	status, errType, title := http.StatusTooManyRequests, "/errors/too-many-requests", "Too Many Requests"
+	if errors.Is(err, ErrAccountLocked) {
+		status, errType, title = http.StatusLocked, "/errors/account-locked", "Account Locked"
+	}
+
+	retryAfter := int(math.Ceil(time.Until(err.RetryAfter).Seconds()))
+	retryAfter = max(retryAfter, 1)
+
+	res.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	httperr.JSONError(res, httperr.NewRESTAPIError(
+		req.URL.Path,
+		errType,
+		title,
+		status,
+		fmt.Sprintf("Too many failed sign-in attempts, retry in %d seconds", retryAfter),
+	), status)
+}
+
+// UnlockUser handler lifts the sign-in lockout of a user.
+func (h *Handler) UnlockUser(res http.ResponseWriter, req *http.Request) {
+	if err := h.authService.UnlockUser(req.Context(), chi.URLParam(req, "id")); err != nil {
+		h.Logger.Error("error unlocking user", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// Impersonate handler issues the admin a short-lived access token of the
+// user, for support staff to see what the user sees.
+func (h *Handler) Impersonate(res http.ResponseWriter, req *http.Request) {
+	var impersonateReq ImpersonateRequest
+
+	if err := h.ParseJSON(res, req, &impersonateReq); err != nil {
+		h.Logger.Error("error decoding impersonate request", slog.Any("err", err))
+
+		return
+	}
+
+	token, err := h.authService.Impersonate(
+		clientContext(req),
+		h.tokenFromRequest(req),
+		chi.URLParam(req, "id"),
+		impersonateReq.Reason,
+	)
+	if err != nil {
+		h.Logger.Error("error impersonating user", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &notFoundErr):
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrImpersonationNotAllowed):
+			httperr.JSONError(res, err, http.StatusForbidden, req.URL.Path)
+		case errors.Is(err, ErrImpersonationNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	h.RespondJSON(res, TokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(DefaultImpersonationDuration.Seconds()),
+	}, http.StatusCreated)
+}
+
+// CreateServiceAccount handler registers a service account. The client
+// secret is only shown in this response.
+func (h *Handler) CreateServiceAccount(res http.ResponseWriter, req *http.Request) {
+	var createReq CreateServiceAccountRequest
+
+	if err := h.ParseJSON(res, req, &createReq); err != nil {
+		h.Logger.Error("error decoding create service account request", slog.Any("err", err))
+
+		return
+	}
+
+	user, _ := UserFromContext(req.Context())
+
+	account, secret, err := h.authService.CreateServiceAccount(
+		req.Context(),
+		createReq.Name,
+		createReq.Scopes,
+		user.ID,
+	)
+	if err != nil {
+		h.Logger.Error("error creating service account", slog.Any("err", err))
+
+		if errors.Is(err, ErrUnknownScope) {
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, CreatedServiceAccountResponse{
+		ServiceAccountResponse: ToServiceAccountResponse(account),
+		ClientSecret:           secret,
+	}, http.StatusCreated)
+}
+
+// ServiceAccounts handler lists every service account.
+func (h *Handler) ServiceAccounts(res http.ResponseWriter, req *http.Request) {
+	accounts, err := h.authService.ListServiceAccounts(req.Context())
+	if err != nil {
+		h.Logger.Error("error listing service accounts", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, slices.Map(accounts, ToServiceAccountResponse), http.StatusOK)
+}
+
+// DeleteServiceAccount handler removes a service account.
+func (h *Handler) DeleteServiceAccount(res http.ResponseWriter, req *http.Request) {
+	err := h.authService.DeleteServiceAccount(req.Context(), chi.URLParam(req, "id"))
+	if err != nil {
+		h.Logger.Error("error deleting service account", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// Token handler is the OAuth2 token endpoint (RFC 6749). It supports the
+// client credentials grant of service accounts and the authorization code
+// grant of OAuth clients. Clients authenticate with HTTP Basic or with
+// client_id and client_secret in the form; public OAuth clients send their
+// client_id only. Errors are answered the OAuth2 way rather than as problem
+// details, which is what OAuth2 clients expect.
+func (h *Handler) Token(res http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		h.oauthError(res, http.StatusBadRequest, "invalid_request", "the request body is not a valid form")
+
+		return
+	}
+
+	grantType := req.PostForm.Get("grant_type")
+	if grantType != GrantTypeClientCredentials && grantType != GrantTypeAuthorizationCode {
+		h.oauthError(
+			res,
+			http.StatusBadRequest,
+			"unsupported_grant_type",
+			"only client_credentials and authorization_code are supported",
+		)
+
+		return
+	}
+
+	clientID, clientSecret, basic, err := clientCredentials(req)
+	if err != nil {
+		h.oauthError(res, http.StatusBadRequest, "invalid_request", err.Error())
+
+		return
+	}
+
+	var (
+		token  *domain.JWTToken
+		scopes []string
+	)
+
+	if grantType == GrantTypeAuthorizationCode {
+		token, scopes, err = h.authService.ExchangeAuthorizationCode(
+			req.Context(),
+			clientID,
+			clientSecret,
+			req.PostForm.Get("code"),
+			req.PostForm.Get("redirect_uri"),
+			req.PostForm.Get("code_verifier"),
+		)
+	} else {
+		token, scopes, err = h.authService.ClientCredentials(
+			req.Context(),
+			clientID,
+			clientSecret,
+			strings.Fields(req.PostForm.Get("scope")),
+		)
+	}
+
+	if err != nil {
+		h.Logger.Error("error issuing token", slog.String("grant_type", grantType), slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrInvalidClient):
+			if basic {
+				res.Header().Set("WWW-Authenticate", `Basic realm="goadmin"`)
+			}
+
+			h.oauthError(res, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		case errors.Is(err, ErrInvalidScope):
+			h.oauthError(res, http.StatusBadRequest, "invalid_scope", err.Error())
+		case errors.Is(err, ErrInvalidGrant):
+			// the reason is logged but not told, not to help guessing codes
+			h.oauthError(res, http.StatusBadRequest, "invalid_grant", "the code is invalid or expired")
+		case errors.Is(err, ErrServiceAccountsNotSupported), errors.Is(err, ErrOAuthNotSupported):
+			h.oauthError(res, http.StatusBadRequest, "unsupported_grant_type", err.Error())
+		default:
+			h.oauthError(res, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Pragma", "no-cache")
+
+	h.RespondJSON(res, TokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(DefaultTokenDuration.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+		IDToken:     token.IDToken,
+	}, http.StatusOK)
+}
+
+// clientCredentials returns the credentials of the client calling the token
+// endpoint and whether they came with HTTP Basic authentication.
+func clientCredentials(req *http.Request) (string, string, bool, error) {
+	if clientID, clientSecret, ok := req.BasicAuth(); ok {
+		// RFC 6749 has clients form-encode both before Basic encoding
+		clientID, err := url.QueryUnescape(clientID)
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid client_id: %w", err)
+		}
+
+		clientSecret, err := url.QueryUnescape(clientSecret)
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid client_secret: %w", err)
+		}
+
+		return clientID, clientSecret, true, nil
+	}
+
+	// public clients authenticate with their client_id alone
+	clientID, clientSecret := req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	if clientID == "" {
+		return "", "", false, errors.New("client credentials are missing")
+	}
+
+	return clientID, clientSecret, false, nil
+}
+
+func (h *Handler) oauthError(res http.ResponseWriter, status int, code, description string) {
+	res.Header().Set("Cache-Control", "no-store")
+
+	h.RespondJSON(res, OAuthErrorResponse{Error: code, ErrorDescription: description}, status)
+}
+
+// CreateOAuthClient handler registers an OAuth client.
+func (h *Handler) CreateOAuthClient(res http.ResponseWriter, req *http.Request) {
+	var createReq CreateOAuthClientRequest
+
+	if err := h.ParseJSON(res, req, &createReq); err != nil {
+		h.Logger.Error("error decoding create oauth client request", slog.Any("err", err))
+
+		return
+	}
+
+	client, secret, err := h.authService.CreateOAuthClient(
+		req.Context(),
+		createReq.Name,
+		createReq.RedirectURIs,
+		createReq.Scopes,
+		createReq.Public,
+	)
+	if err != nil {
+		h.Logger.Error("error creating oauth client", slog.Any("err", err))
+
+		if errors.Is(err, ErrUnknownScope) || errors.Is(err, ErrInvalidRedirectURI) {
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, CreatedOAuthClientResponse{
+		OAuthClientResponse: ToOAuthClientResponse(client),
+		ClientSecret:        secret,
+	}, http.StatusCreated)
+}
+
+// OAuthClients handler lists every OAuth client.
+func (h *Handler) OAuthClients(res http.ResponseWriter, req *http.Request) {
+	clients, err := h.authService.ListOAuthClients(req.Context())
+	if err != nil {
+		h.Logger.Error("error listing oauth clients", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, slices.Map(clients, ToOAuthClientResponse), http.StatusOK)
+}
+
+// DeleteOAuthClient handler removes an OAuth client.
+func (h *Handler) DeleteOAuthClient(res http.ResponseWriter, req *http.Request) {
+	err := h.authService.DeleteOAuthClient(req.Context(), chi.URLParam(req, "id"))
+	if err != nil {
+		h.Logger.Error("error deleting oauth client", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// Authorize handler takes the authorization request the consent page was
+// opened with. It answers where to send the user agent, or that the user
+// has to approve the client first.
+func (h *Handler) Authorize(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	result, err := h.authService.Authorize(req.Context(), h.tokenFromRequest(req), AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	})
+
+	h.respondAuthorization(res, req, result, err)
+}
+
+// Consent handler records whether the user approved an authorization
+// request and answers where to send the user agent.
+func (h *Handler) Consent(res http.ResponseWriter, req *http.Request) {
+	var consentReq ConsentRequest
+
+	if err := h.ParseJSON(res, req, &consentReq); err != nil {
+		h.Logger.Error("error decoding consent request", slog.Any("err", err))
+
+		return
+	}
+
+	result, err := h.authService.Consent(
+		req.Context(),
+		h.tokenFromRequest(req),
+		consentReq.AuthorizationRequest,
+		consentReq.Approve,
+	)
+
+	h.respondAuthorization(res, req, result, err)
+}
+
+func (h *Handler) respondAuthorization(
+	res http.ResponseWriter,
+	req *http.Request,
+	result *AuthorizationResult,
+	err error,
+) {
+	if err != nil {
+		h.Logger.Error("error authorizing oauth client", slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			httperr.JSONError(res, err, http.StatusUnauthorized, req.URL.Path)
+		case errors.Is(err, ErrInvalidClient), errors.Is(err, ErrInvalidRedirectURI):
+			// never redirect to a URI that was not registered
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+
+	h.RespondJSON(res, ToAuthorizationResponse(result), http.StatusOK)
+}
+
+// UserInfo handler is the OpenID Connect userinfo endpoint. It takes the
+// access tokens issued to OAuth clients, not those of the API.
+func (h *Handler) UserInfo(res http.ResponseWriter, req *http.Request) {
+	userInfo, err := h.authService.UserInfo(req.Context(), TokenFromHeader(req))
+	if err != nil {
+		h.Logger.Error("error getting userinfo", slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			// RFC 6750 section 3
+			res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			h.oauthError(res, http.StatusUnauthorized, "invalid_token", "the access token is invalid")
+		case errors.Is(err, ErrOAuthNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		default:
+			h.oauthError(res, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+
+	h.RespondJSON(res, userInfo, http.StatusOK)
+}
+
+// OpenIDConfiguration handler publishes the OpenID Connect discovery
+// document.
+func (h *Handler) OpenIDConfiguration(res http.ResponseWriter, req *http.Request) {
+	cfg, err := h.authService.OpenIDConfiguration()
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+
+		return
+	}
+
+	res.Header().Set("Cache-Control", "public, max-age=300")
+
+	h.RespondJSON(res, cfg, http.StatusOK)
+}
+
+// Sessions handler lists the active sessions of the current user.
+func (h *Handler) Sessions(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(req.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("error listing sessions", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, slices.Map(sessions, ToSessionResponse), http.StatusOK)
+}
+
+// RevokeSession handler signs the current user out of one of their sessions.
+func (h *Handler) RevokeSession(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	err := h.authService.RevokeSession(req.Context(), user.ID, chi.URLParam(req, "id"))
+	if err != nil {
+		h.Logger.Error("error revoking session", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAPIKey handler issues an API key for the current user. The key is
+// only shown in this response.
+func (h *Handler) CreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	var createReq CreateAPIKeyRequest
+
+	if err := h.ParseJSON(res, req, &createReq); err != nil {
+		h.Logger.Error("error decoding create api key request", slog.Any("err", err))
+
+		return
+	}
+
+	apiKey, key, err := h.authService.CreateAPIKey(
+		req.Context(),
+		user.ID,
+		createReq.Name,
+		createReq.Scopes,
+		createReq.ExpiresAt,
+	)
+	if err != nil {
+		h.Logger.Error("error creating api key", slog.Any("err", err))
+
+		if errors.Is(err, ErrUnknownScope) || errors.Is(err, ErrInvalidExpiry) {
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, CreatedAPIKeyResponse{
+		APIKeyResponse: ToAPIKeyResponse(apiKey),
+		Key:            key,
+	}, http.StatusCreated)
+}
+
+// APIKeys handler lists the API keys of the current user.
+func (h *Handler) APIKeys(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	apiKeys, err := h.authService.ListAPIKeys(req.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("error listing api keys", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, slices.Map(apiKeys, ToAPIKeyResponse), http.StatusOK)
+}
+
+// RevokeAPIKey handler deletes an API key of the current user.
+func (h *Handler) RevokeAPIKey(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	err := h.authService.RevokeAPIKey(req.Context(), user.ID, chi.URLParam(req, "id"))
+	if err != nil {
+		h.Logger.Error("error revoking api key", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// UserIdentities handler lists the external identities linked to the
+// current user.
+func (h *Handler) UserIdentities(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	identities, err := h.authService.UserIdentities(req.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("error listing user identities", slog.Any("err", err))
+
+		if errors.Is(err, ErrIdentitiesNotSupported) {
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+
+			return
+		}
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, slices.Map(identities, ToUserIdentityResponse), http.StatusOK)
+}
+
+// LinkIdentity handler links the identity an ID token of a provider asserts
+// to the current user.
+func (h *Handler) LinkIdentity(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	var linkReq LinkIdentityRequest
+
+	if err := h.ParseJSON(res, req, &linkReq); err != nil {
+		h.Logger.Error("error decoding link identity request", slog.Any("err", err))
+
+		return
+	}
+
+	identity, err := h.authService.LinkIdentity(
+		req.Context(),
+		user.ID,
+		linkReq.Provider,
+		linkReq.IDToken,
+		linkReq.Nonce,
+	)
+	if err != nil {
+		h.Logger.Error("error linking user identity", slog.Any("err", err))
+
+		switch {
+		case errors.Is(err, ErrUnknownProvider), errors.Is(err, ErrIdentitiesNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrInvalidIDToken):
+			// the current user's own token is fine
+			httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+		case errors.Is(err, ErrIdentityLinked):
+			httperr.JSONError(res, err, http.StatusConflict, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	h.RespondJSON(res, ToUserIdentityResponse(identity), http.StatusCreated)
+}
+
+// UnlinkIdentity handler unlinks an external identity of the current user.
+func (h *Handler) UnlinkIdentity(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	err := h.authService.UnlinkIdentity(req.Context(), user.ID, chi.URLParam(req, "id"))
+	if err != nil {
+		h.Logger.Error("error unlinking user identity", slog.Any("err", err))
+
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &notFoundErr):
+			httperr.JSONError(res, notFoundErr, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrIdentitiesNotSupported):
+			httperr.JSONError(res, err, http.StatusNotFound, req.URL.Path)
+		case errors.Is(err, ErrLastSignInMethod):
+			httperr.JSONError(res, err, http.StatusConflict, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
+
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll handler signs the current user out of every session.
+func (h *Handler) LogoutAll(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	if err := h.authService.LogoutAll(req.Context(), user.ID); err != nil {
+		h.Logger.Error("error logging out everywhere", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.clearTokenCookies(res)
+
+	res.WriteHeader(http.StatusOK)
+}
+
+// EnrollTOTP handler generates a TOTP secret for the current user.
+func (h *Handler) EnrollTOTP(res http.ResponseWriter, req *http.Request) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTP(req.Context(), &user)
+	if err != nil {
+		h.Logger.Error("error enrolling totp", slog.Any("err", err))
+
+		h.mfaError(res, req, err)
+
+		return
+	}
+
+	h.RespondJSON(res, enrollment, http.StatusOK)
+}
+
+// ConfirmTOTP handler turns on the enrolled TOTP factor of the current user
+// and returns their recovery codes.
+func (h *Handler) ConfirmTOTP(res http.ResponseWriter, req *http.Request) {
+	h.withMFACode(res, req, func(userID, code string) {
+		codes, err := h.authService.ConfirmTOTP(req.Context(), userID, code)
+		if err != nil {
+			h.Logger.Error("error confirming totp", slog.Any("err", err))
+
+			h.mfaError(res, req, err)
+
+			return
+		}
+
+		h.RespondJSON(res, RecoveryCodesResponse{RecoveryCodes: codes}, http.StatusOK)
+	})
+}
+
+// DisableTOTP handler turns off the TOTP factor of the current user.
+func (h *Handler) DisableTOTP(res http.ResponseWriter, req *http.Request) {
+	h.withMFACode(res, req, func(userID, code string) {
+		if err := h.authService.DisableTOTP(req.Context(), userID, code); err != nil {
+			h.Logger.Error("error disabling totp", slog.Any("err", err))
+
+			h.mfaError(res, req, err)
+
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// RegenerateRecoveryCodes handler replaces the recovery codes of the current
+// user.
+func (h *Handler) RegenerateRecoveryCodes(res http.ResponseWriter, req *http.Request) {
+	h.withMFACode(res, req, func(userID, code string) {
+		codes, err := h.authService.RegenerateRecoveryCodes(req.Context(), userID, code)
+		if err != nil {
+			h.Logger.Error("error regenerating recovery codes", slog.Any("err", err))
+
+			h.mfaError(res, req, err)
+
+			return
+		}
+
+		h.RespondJSON(res, RecoveryCodesResponse{RecoveryCodes: codes}, http.StatusOK)
+	})
+}
+
+// withMFACode calls next with the current user and the code of the request.
+func (h *Handler) withMFACode(
+	res http.ResponseWriter,
+	req *http.Request,
+	next func(userID, code string),
+) {
+	user, ok := UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, ErrInvalidToken, http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	var codeReq MFACodeRequest
+
+	if err := h.ParseJSON(res, req, &codeReq); err != nil {
+		h.Logger.Error("error decoding mfa code request", slog.Any("err", err))
+
+		return
+	}
+
+	next(user.ID, codeReq.Code)
+}
+
+// mfaError writes the problem matching an error of the TOTP management
+// endpoints.
+func (h *Handler) mfaError(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMFACode):
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+	case errors.Is(err, ErrTOTPAlreadyEnabled), errors.Is(err, ErrTOTPNotEnrolled):
+		httperr.JSONError(res, err, http.StatusConflict, req.URL.Path)
+	default:
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+	}
+}
+
+// clientContext returns the request context carrying the client a new
+// session is created for.
+func clientContext(req *http.Request) context.Context {
+	ipAddress, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ipAddress = req.RemoteAddr
+	}
+
+	return ContextWithClientInfo(req.Context(), domain.ClientInfo{
+		UserAgent: req.UserAgent(),
+		IPAddress: ipAddress,
+	})
+}
@@ -2,15 +2,21 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 
 	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
 	"goadmin-backend/internal/platform/httpjson"
 	"goadmin-backend/internal/platform/logging"
 )
@@ -132,6 +138,21 @@ func TestHandler_Login(t *testing.T) {
 				body: `{"type":"/errors/unauthorized","title":"Unauthorized","status":401,"detail":"You are not authorized to perform this action","instance":"/login"}` + "\n",
 			},
 		},
+		{
+			name: "Fail Email Not Verified",
+			fields: fields{
+				authService: &ServiceMock{err: ErrEmailNotVerified},
+				logger:      logging.NewLogger(),
+			},
+			args: args{
+				res: httptest.NewRecorder(),
+				req: newRequest(http.MethodPost, "/login", domain.Credentials{}),
+			},
+			want: want{
+				code: http.StatusForbidden,
+				body: `{"type":"/errors/email-not-verified","title":"Email Not Verified","status":403,"detail":"The e-mail address of this account has not been verified yet","instance":"/login"}` + "\n",
+			},
+		},
 		{
 			name: "Fail Internal Server Error",
 			fields: fields{
@@ -199,6 +220,118 @@ func TestHandler_Login(t *testing.T) {
 	}
 }
 
+func TestHandler_Refresh(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		code int
+		body string
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		req         *http.Request
+		want        want
+	}{
+		{
+			name:        "Success",
+			authService: &ServiceMock{},
+			req: newRequest(http.MethodPost, "/auth/refresh", RefreshTokenRequest{
+				RefreshToken: "refresh_token",
+			}),
+			want: want{
+				code: http.StatusOK,
+				body: `{"access_token":"good_token","refresh_token":""}` + "\n",
+			},
+		},
+		{
+			name:        "Reused",
+			authService: &ServiceMock{err: ErrRefreshTokenReused},
+			req: newRequest(http.MethodPost, "/auth/refresh", RefreshTokenRequest{
+				RefreshToken: "refresh_token",
+			}),
+			want: want{
+				code: http.StatusUnauthorized,
+				body: `{"type":"/errors/unauthorized","title":"Unauthorized","status":401,"detail":"You are not authorized to perform this action","instance":"/auth/refresh"}` + "\n",
+			},
+		},
+		{
+			name:        "Fail Internal Server Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			req: newRequest(http.MethodPost, "/auth/refresh", RefreshTokenRequest{
+				RefreshToken: "refresh_token",
+			}),
+			want: want{
+				code: http.StatusInternalServerError,
+				body: `{"type":"/errors/internal-server-error","title":"Internal Server Error","status":500,"detail":"An internal server error occurred","instance":"/auth/refresh"}` + "\n",
+			},
+		},
+		{
+			name:        "Fail Decode",
+			authService: &ServiceMock{},
+			req:         httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader([]byte("invalid"))),
+			want: want{
+				code: http.StatusBadRequest,
+				body: `{"type":"/errors/bad-request","title":"Bad Request","status":400,"detail":"The request was invalid or cannot be served","instance":"/auth/refresh"}` + "\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			h.Refresh(res, tt.req)
+
+			if res.Code != tt.want.code {
+				t.Errorf("Handler.Refresh() = %v, want %v", res.Code, tt.want.code)
+			}
+
+			if res.Body.String() != tt.want.body {
+				t.Errorf("Handler.Refresh() = %v, want %v", res.Body.String(), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestHandler_JWKS(t *testing.T) {
+	t.Parallel()
+
+	h := &Handler{
+		authService: &ServiceMock{},
+		Handler: httpjson.Handler{
+			Logger: logging.NewLogger(),
+		},
+	}
+
+	res := httptest.NewRecorder()
+
+	h.JWKS(res, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if res.Code != http.StatusOK {
+		t.Errorf("Handler.JWKS() = %v, want %v", res.Code, http.StatusOK)
+	}
+
+	if got := res.Header().Get("Cache-Control"); got == "" {
+		t.Errorf("Handler.JWKS() Cache-Control = %v, want max-age", got)
+	}
+
+	if got, want := res.Body.String(), `{"keys":[]}`+"\n"; got != want {
+		t.Errorf("Handler.JWKS() = %v, want %v", got, want)
+	}
+}
+
 func TestHandler_Register(t *testing.T) {
 	t.Parallel()
 
@@ -238,7 +371,7 @@ func TestHandler_Register(t *testing.T) {
 			},
 			want: want{
 				code: http.StatusCreated,
-				body: `{"id":"1","username":"","first_name":"","last_name":"","email":"","active":false,"deleted_at":null}` + "\n",
+				body: `{"id":"1","username":"","first_name":"","last_name":"","email":"","active":false,"deleted_at":null,"email_verified":false}` + "\n",
 			},
 		},
 		{
@@ -256,6 +389,25 @@ func TestHandler_Register(t *testing.T) {
 				body: `{"type":"/errors/internal-server-error","title":"Internal Server Error","status":500,"detail":"An internal server error occurred","instance":"/register"}` + "\n",
 			},
 		},
+		{
+			name: "Fail Password Policy",
+			fields: fields{
+				authService: &ServiceMock{err: httperr.NewValidationError(
+					"",
+					"The password does not meet the password policy",
+					[]httperr.ValidationErrorItem{{Detail: "must be at least 8 characters long", Pointer: "/password"}},
+				)},
+				logger: logging.NewLogger(),
+			},
+			args: args{
+				res: httptest.NewRecorder(),
+				req: newRequest(http.MethodPost, "/register", domain.User{Username: "username", Password: "pass"}),
+			},
+			want: want{
+				code: http.StatusUnprocessableEntity,
+				body: `{"type":"/errors/validation-error","title":"Validation Error","status":422,"detail":"The password does not meet the password policy","instance":"/register","errors":[{"detail":"must be at least 8 characters long","pointer":"/password"}]}` + "\n",
+			},
+		},
 		{
 			name: "Fail Decode",
 			fields: fields{
@@ -415,3 +567,1828 @@ func TestHandler_SignInWithGoogle(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Sessions(t *testing.T) {
+	t.Parallel()
+
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), userKey, domain.User{ID: "1"}))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "List",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Sessions },
+			req:         withUser(httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "List Without User",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Sessions },
+			req:         httptest.NewRequest(http.MethodGet, "/auth/sessions", nil),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "List Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Sessions },
+			req:         withUser(httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "Revoke",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.RevokeSession },
+			req:         withUser(httptest.NewRequest(http.MethodDelete, "/auth/sessions/1", nil)),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Revoke Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("Session", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.RevokeSession },
+			req:         withUser(httptest.NewRequest(http.MethodDelete, "/auth/sessions/1", nil)),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Logout All",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LogoutAll },
+			req:         withUser(httptest.NewRequest(http.MethodPost, "/auth/logout-all", nil)),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Logout All Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LogoutAll },
+			req:         withUser(httptest.NewRequest(http.MethodPost, "/auth/logout-all", nil)),
+			wantCode:    http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_PasswordReset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		body        string
+		wantCode    int
+	}{
+		{
+			name:        "Forgot",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ForgotPassword },
+			body:        `{"email":"user@example.com"}`,
+			wantCode:    http.StatusAccepted,
+		},
+		{
+			name:        "Forgot Error",
+			authService: &ServiceMock{err: errors.New("smtp error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ForgotPassword },
+			body:        `{"email":"user@example.com"}`,
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "Forgot Decode Error",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ForgotPassword },
+			body:        `invalid`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Reset",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ResetPassword },
+			body:        `{"token":"token","password":"password"}`,
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Reset Invalid Token",
+			authService: &ServiceMock{err: ErrInvalidResetToken},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ResetPassword },
+			body:        `{"token":"token","password":"password"}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name: "Reset Weak Password",
+			authService: &ServiceMock{err: httperr.NewValidationError("", "weak", []httperr.ValidationErrorItem{
+				{Detail: "must be at least 8 characters long", Pointer: "/password"},
+			})},
+			handler:  func(h *Handler) http.HandlerFunc { return h.ResetPassword },
+			body:     `{"token":"token","password":"pass"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "Reset Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ResetPassword },
+			body:        `{"token":"token","password":"password"}`,
+			wantCode:    http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/auth/password", bytes.NewReader([]byte(tt.body)))
+
+			tt.handler(h)(res, req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_VerifyEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		authService Service
+		wantCode    int
+	}{
+		{name: "Success", authService: &ServiceMock{}, wantCode: http.StatusNoContent},
+		{name: "Invalid Token", authService: &ServiceMock{err: ErrInvalidVerificationToken}, wantCode: http.StatusBadRequest},
+		{name: "Error", authService: &ServiceMock{err: errors.New("db error")}, wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			h.VerifyEmail(res, httptest.NewRequest(http.MethodGet, "/auth/verify-email?token=token", nil))
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler.VerifyEmail() = %v, want %v", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_ResendVerificationEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		authService Service
+		body        string
+		wantCode    int
+	}{
+		{name: "Success", authService: &ServiceMock{}, body: `{"email":"user@example.com"}`, wantCode: http.StatusAccepted},
+		{name: "Error", authService: &ServiceMock{err: errors.New("smtp error")}, body: `{"email":"user@example.com"}`, wantCode: http.StatusInternalServerError},
+		{name: "Decode Error", authService: &ServiceMock{}, body: `invalid`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/verify-email/resend", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			res := httptest.NewRecorder()
+
+			h.ResendVerificationEmail(res, req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler.ResendVerificationEmail() = %v, want %v", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_MFA(t *testing.T) {
+	t.Parallel()
+
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), userKey, domain.User{ID: "1"}))
+	}
+
+	newReq := func(path, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Verify",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.VerifyMFA },
+			req:         newReq("/auth/mfa/verify", `{"mfa_token":"token","code":"123456"}`),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Verify Invalid Code",
+			authService: &ServiceMock{err: ErrInvalidMFACode},
+			handler:     func(h *Handler) http.HandlerFunc { return h.VerifyMFA },
+			req:         newReq("/auth/mfa/verify", `{"mfa_token":"token","code":"123456"}`),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Verify Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.VerifyMFA },
+			req:         newReq("/auth/mfa/verify", `{"mfa_token":"token","code":"123456"}`),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "Enroll",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.EnrollTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/enroll", "")),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Enroll Without User",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.EnrollTOTP },
+			req:         newReq("/auth/mfa/totp/enroll", ""),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Enroll Already Enabled",
+			authService: &ServiceMock{err: ErrTOTPAlreadyEnabled},
+			handler:     func(h *Handler) http.HandlerFunc { return h.EnrollTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/enroll", "")),
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "Confirm",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ConfirmTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/confirm", `{"code":"123456"}`)),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Confirm Invalid Code",
+			authService: &ServiceMock{err: ErrInvalidMFACode},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ConfirmTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/confirm", `{"code":"123456"}`)),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Disable",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DisableTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/disable", `{"code":"123456"}`)),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Disable Not Enrolled",
+			authService: &ServiceMock{err: ErrTOTPNotEnrolled},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DisableTOTP },
+			req:         withUser(newReq("/auth/mfa/totp/disable", `{"code":"123456"}`)),
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "Regenerate Recovery Codes",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.RegenerateRecoveryCodes },
+			req:         withUser(newReq("/auth/mfa/recovery-codes", `{"code":"123456"}`)),
+			wantCode:    http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_Lockout(t *testing.T) {
+	t.Parallel()
+
+	loginReq := func() *http.Request {
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/auth/login",
+			strings.NewReader(`{"username":"username","password":"password"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+
+		return req
+	}
+
+	retryAfter := time.Now().Add(30 * time.Second)
+
+	tests := []struct {
+		name           string
+		authService    Service
+		handler        func(h *Handler) http.HandlerFunc
+		req            *http.Request
+		wantCode       int
+		wantRetryAfter bool
+	}{
+		{
+			name:           "Login Locked",
+			authService:    &ServiceMock{err: &LoginThrottledError{Err: ErrAccountLocked, RetryAfter: retryAfter}},
+			handler:        func(h *Handler) http.HandlerFunc { return h.Login },
+			req:            loginReq(),
+			wantCode:       http.StatusLocked,
+			wantRetryAfter: true,
+		},
+		{
+			name:           "Login Too Many Attempts",
+			authService:    &ServiceMock{err: &LoginThrottledError{Err: ErrTooManyAttempts, RetryAfter: retryAfter}},
+			handler:        func(h *Handler) http.HandlerFunc { return h.Login },
+			req:            loginReq(),
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: true,
+		},
+		{
+			name:        "Unlock",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UnlockUser },
+			req:         httptest.NewRequest(http.MethodPost, "/v1/users/1/unlock", nil),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Unlock Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("User", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UnlockUser },
+			req:         httptest.NewRequest(http.MethodPost, "/v1/users/1/unlock", nil),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+
+			if got := res.Header().Get("Retry-After"); (got != "") != tt.wantRetryAfter {
+				t.Errorf("Handler %s Retry-After = %q, want set %v", tt.name, got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestHandler_RequireRole(t *testing.T) {
+	t.Parallel()
+
+	withUser := func(userID string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/users/2/unlock", nil)
+
+		return req.WithContext(context.WithValue(req.Context(), userKey, domain.User{ID: userID}))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		req         *http.Request
+		wantCode    int
+	}{
+		{name: "Admin", authService: &ServiceMock{}, req: withUser("1"), wantCode: http.StatusOK},
+		{name: "Not Admin", authService: &ServiceMock{}, req: withUser("2"), wantCode: http.StatusForbidden},
+		{
+			name:        "No User",
+			authService: &ServiceMock{},
+			req:         httptest.NewRequest(http.MethodPost, "/v1/users/2/unlock", nil),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{name: "Error", authService: &ServiceMock{err: errors.New("db error")}, req: withUser("1"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			h.RequireRole(domain.RoleAdmin)(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			})).ServeHTTP(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler.RequireRole() = %v, want %v", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), userKey, domain.User{ID: "1"}))
+	}
+
+	newCreateRequest := func() *http.Request {
+		return withUser(httptest.NewRequest(
+			http.MethodPost,
+			"/auth/api-keys",
+			strings.NewReader(`{"name":"ci","scopes":["users:read"]}`),
+		))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Create",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateAPIKey },
+			req:         newCreateRequest(),
+			wantCode:    http.StatusCreated,
+		},
+		{
+			name:        "Create Unknown Scope",
+			authService: &ServiceMock{err: ErrUnknownScope},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateAPIKey },
+			req:         newCreateRequest(),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Create Without User",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateAPIKey },
+			req:         httptest.NewRequest(http.MethodPost, "/auth/api-keys", nil),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Create Error",
+			authService: &ServiceMock{err: ErrAPIKeysNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateAPIKey },
+			req:         newCreateRequest(),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "List",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.APIKeys },
+			req:         withUser(httptest.NewRequest(http.MethodGet, "/auth/api-keys", nil)),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Revoke",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.RevokeAPIKey },
+			req:         withUser(httptest.NewRequest(http.MethodDelete, "/auth/api-keys/1", nil)),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Revoke Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("APIKey", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.RevokeAPIKey },
+			req:         withUser(httptest.NewRequest(http.MethodDelete, "/auth/api-keys/1", nil)),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_RequireScope(t *testing.T) {
+	t.Parallel()
+
+	withAPIKey := func(scopes ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		ctx := context.WithValue(req.Context(), userKey, domain.User{ID: "1"})
+
+		return req.WithContext(context.WithValue(ctx, apiKeyKey, &domain.APIKey{ID: "1", Scopes: scopes}))
+	}
+
+	withServiceAccount := func(scopes ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+
+		return req.WithContext(context.WithValue(req.Context(), serviceAccountKey, &domain.ServiceAccount{ID: "1", Scopes: scopes}))
+	}
+
+	withToken := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	withToken = withToken.WithContext(context.WithValue(withToken.Context(), userKey, domain.User{ID: "1"}))
+
+	withImpersonation := withToken.WithContext(
+		context.WithValue(withToken.Context(), actorKey, &domain.TokenActor{Subject: "2", Username: "admin"}),
+	)
+
+	ok := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		middleware func(h *Handler) func(http.Handler) http.Handler
+		req        *http.Request
+		wantCode   int
+	}{
+		{
+			name:       "Scope Granted",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RequireScope(ScopeUsersRead) },
+			req:        withAPIKey(ScopeUsersRead),
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Scope Missing",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RequireScope(ScopeUsersWrite) },
+			req:        withAPIKey(ScopeUsersRead),
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "Access Token",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RequireScope(ScopeUsersWrite) },
+			req:        withToken,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Service Account Scope Granted",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RequireScope(ScopeUsersRead) },
+			req:        withServiceAccount(ScopeUsersRead),
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Service Account Scope Missing",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RequireScope(ScopeUsersWrite) },
+			req:        withServiceAccount(ScopeUsersRead),
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "Reject API Key",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RejectAPIKeys() },
+			req:        withAPIKey(ScopeUsersRead, ScopeUsersWrite),
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "Reject API Key With Access Token",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RejectAPIKeys() },
+			req:        withToken,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Reject Impersonation",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RejectImpersonation() },
+			req:        withImpersonation,
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "Reject Impersonation With Access Token",
+			middleware: func(h *Handler) func(http.Handler) http.Handler { return h.RejectImpersonation() },
+			req:        withToken,
+			wantCode:   http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: &ServiceMock{},
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.middleware(h)(ok).ServeHTTP(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_ServiceAccounts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Create",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateServiceAccount },
+			req:         httptest.NewRequest(http.MethodPost, "/v1/service-accounts", strings.NewReader(`{"name":"billing"}`)),
+			wantCode:    http.StatusCreated,
+		},
+		{
+			name:        "Create Unknown Scope",
+			authService: &ServiceMock{err: ErrUnknownScope},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateServiceAccount },
+			req:         httptest.NewRequest(http.MethodPost, "/v1/service-accounts", strings.NewReader(`{"name":"billing"}`)),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "List",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ServiceAccounts },
+			req:         httptest.NewRequest(http.MethodGet, "/v1/service-accounts", nil),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "List Error",
+			authService: &ServiceMock{err: ErrServiceAccountsNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.ServiceAccounts },
+			req:         httptest.NewRequest(http.MethodGet, "/v1/service-accounts", nil),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "Delete",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DeleteServiceAccount },
+			req:         httptest.NewRequest(http.MethodDelete, "/v1/service-accounts/1", nil),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Delete Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("ServiceAccount", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DeleteServiceAccount },
+			req:         httptest.NewRequest(http.MethodDelete, "/v1/service-accounts/1", nil),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_OAuthClients(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Create",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateOAuthClient },
+			req: httptest.NewRequest(
+				http.MethodPost,
+				"/v1/oauth-clients",
+				strings.NewReader(`{"name":"wiki","redirect_uris":["https://wiki.example.com/callback"]}`),
+			),
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:        "Create Invalid Redirect URI",
+			authService: &ServiceMock{err: ErrInvalidRedirectURI},
+			handler:     func(h *Handler) http.HandlerFunc { return h.CreateOAuthClient },
+			req: httptest.NewRequest(
+				http.MethodPost,
+				"/v1/oauth-clients",
+				strings.NewReader(`{"name":"wiki","redirect_uris":["/callback"]}`),
+			),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:        "List",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.OAuthClients },
+			req:         httptest.NewRequest(http.MethodGet, "/v1/oauth-clients", nil),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "List Error",
+			authService: &ServiceMock{err: ErrOAuthNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.OAuthClients },
+			req:         httptest.NewRequest(http.MethodGet, "/v1/oauth-clients", nil),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:        "Delete",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DeleteOAuthClient },
+			req:         httptest.NewRequest(http.MethodDelete, "/v1/oauth-clients/1", nil),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Delete Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("OAuthClient", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.DeleteOAuthClient },
+			req:         httptest.NewRequest(http.MethodDelete, "/v1/oauth-clients/1", nil),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_Authorize(t *testing.T) {
+	t.Parallel()
+
+	const query = "?response_type=code&client_id=client&state=xyz" +
+		"&code_challenge=E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM&code_challenge_method=S256"
+
+	tests := []struct {
+		name           string
+		authService    Service
+		handler        func(h *Handler) http.HandlerFunc
+		req            *http.Request
+		wantCode       int
+		wantConsent    bool
+		wantRedirectTo string
+	}{
+		{
+			name:        "Consent Required",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Authorize },
+			req:         httptest.NewRequest(http.MethodGet, "/oauth/authorize"+query, nil),
+			wantCode:    http.StatusOK,
+			wantConsent: true,
+		},
+		{
+			name:        "Unknown Client",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Authorize },
+			req:         httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=unknown", nil),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Not Signed In",
+			authService: &ServiceMock{err: ErrInvalidToken},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Authorize },
+			req:         httptest.NewRequest(http.MethodGet, "/oauth/authorize"+query, nil),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Approve",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Consent },
+			req: httptest.NewRequest(
+				http.MethodPost,
+				"/oauth/authorize",
+				strings.NewReader(`{"client_id":"client","state":"xyz","approve":true}`),
+			),
+			wantCode:       http.StatusOK,
+			wantRedirectTo: "https://wiki.example.com/callback?code=code&state=xyz",
+		},
+		{
+			name:        "Deny",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Consent },
+			req: httptest.NewRequest(
+				http.MethodPost,
+				"/oauth/authorize",
+				strings.NewReader(`{"client_id":"client","state":"xyz","approve":false}`),
+			),
+			wantCode:       http.StatusOK,
+			wantRedirectTo: "https://wiki.example.com/callback?error=access_denied&state=xyz",
+		},
+		{
+			name:        "Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.Consent },
+			req: httptest.NewRequest(
+				http.MethodPost,
+				"/oauth/authorize",
+				strings.NewReader(`{"client_id":"client","approve":true}`),
+			),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+
+			if res.Code != http.StatusOK {
+				return
+			}
+
+			var got AuthorizationResponse
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("Handler %s decode error = %v", tt.name, err)
+			}
+
+			if got.ConsentRequired != tt.wantConsent || got.RedirectTo != tt.wantRedirectTo || got.ClientName != "wiki" {
+				t.Errorf("Handler %s = %+v", tt.name, got)
+			}
+		})
+	}
+}
+
+func TestHandler_UserInfo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		authService   Service
+		handler       func(h *Handler) http.HandlerFunc
+		wantCode      int
+		wantChallenge bool
+	}{
+		{
+			name:        "UserInfo",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UserInfo },
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:          "UserInfo Invalid Token",
+			authService:   &ServiceMock{err: ErrInvalidToken},
+			handler:       func(h *Handler) http.HandlerFunc { return h.UserInfo },
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: true,
+		},
+		{
+			name:        "UserInfo Disabled",
+			authService: &ServiceMock{err: ErrOAuthNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UserInfo },
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Discovery",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.OpenIDConfiguration },
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Discovery Disabled",
+			authService: &ServiceMock{err: ErrOAuthNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.OpenIDConfiguration },
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+
+			if got := res.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("Handler %s WWW-Authenticate set = %v, want %v", tt.name, got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestHandler_Token(t *testing.T) {
+	t.Parallel()
+
+	newTokenRequest := func(form string, basic bool) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if basic {
+			req.SetBasicAuth("sa_client", "secret")
+		}
+
+		return req
+	}
+
+	tests := []struct {
+		name          string
+		authService   Service
+		req           *http.Request
+		wantCode      int
+		wantError     string
+		wantChallenge bool
+	}{
+		{
+			name:        "Basic Auth",
+			authService: &ServiceMock{},
+			req:         newTokenRequest("grant_type=client_credentials&scope=users:read", true),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Form Credentials",
+			authService: &ServiceMock{},
+			req:         newTokenRequest("grant_type=client_credentials&client_id=sa_client&client_secret=secret", false),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Wrong Secret",
+			authService: &ServiceMock{},
+			req:         newTokenRequest("grant_type=client_credentials&client_id=sa_client&client_secret=wrong", false),
+			wantCode:    http.StatusUnauthorized,
+			wantError:   "invalid_client",
+		},
+		{
+			name:          "Wrong Basic Auth",
+			authService:   &ServiceMock{err: ErrInvalidClient},
+			req:           newTokenRequest("grant_type=client_credentials", true),
+			wantCode:      http.StatusUnauthorized,
+			wantError:     "invalid_client",
+			wantChallenge: true,
+		},
+		{
+			name:        "Missing Credentials",
+			authService: &ServiceMock{},
+			req:         newTokenRequest("grant_type=client_credentials", false),
+			wantCode:    http.StatusBadRequest,
+			wantError:   "invalid_request",
+		},
+		{
+			name:        "Unsupported Grant",
+			authService: &ServiceMock{},
+			req:         newTokenRequest("grant_type=password&username=jdoe&password=secret", false),
+			wantCode:    http.StatusBadRequest,
+			wantError:   "unsupported_grant_type",
+		},
+		{
+			name:        "Scope Not Granted",
+			authService: &ServiceMock{err: ErrInvalidScope},
+			req:         newTokenRequest("grant_type=client_credentials&scope=users:write", true),
+			wantCode:    http.StatusBadRequest,
+			wantError:   "invalid_scope",
+		},
+		{
+			name:        "Error",
+			authService: &ServiceMock{err: errors.New("db error")},
+			req:         newTokenRequest("grant_type=client_credentials", true),
+			wantCode:    http.StatusInternalServerError,
+			wantError:   "server_error",
+		},
+		{
+			name:        "Authorization Code",
+			authService: &ServiceMock{},
+			req: newTokenRequest(
+				"grant_type=authorization_code&client_id=client&code=code&code_verifier=verifier",
+				false,
+			),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "Invalid Authorization Code",
+			authService: &ServiceMock{},
+			req: newTokenRequest(
+				"grant_type=authorization_code&client_id=client&code=used&code_verifier=verifier",
+				false,
+			),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_grant",
+		},
+		{
+			name:        "Authorization Server Disabled",
+			authService: &ServiceMock{err: ErrOAuthNotSupported},
+			req:         newTokenRequest("grant_type=authorization_code&client_id=client&code=code", false),
+			wantCode:    http.StatusBadRequest,
+			wantError:   "unsupported_grant_type",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			h.Token(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("Handler.Token() = %v, want %v", res.Code, tt.wantCode)
+			}
+
+			if res.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Handler.Token() Cache-Control = %q, want no-store", res.Header().Get("Cache-Control"))
+			}
+
+			if got := res.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("Handler.Token() WWW-Authenticate set = %v, want %v", got, tt.wantChallenge)
+			}
+
+			if tt.wantError == "" {
+				var token TokenResponse
+				if err := json.NewDecoder(res.Body).Decode(&token); err != nil || token.TokenType != "Bearer" {
+					t.Errorf("Handler.Token() = %+v, %v, want a bearer token", token, err)
+				}
+
+				return
+			}
+
+			var oauthErr OAuthErrorResponse
+			if err := json.NewDecoder(res.Body).Decode(&oauthErr); err != nil || oauthErr.Error != tt.wantError {
+				t.Errorf("Handler.Token() error = %+v, %v, want %v", oauthErr, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestHandler_OIDC(t *testing.T) {
+	t.Parallel()
+
+	signIn := func(provider, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/auth/oidc/"+provider+"/signin", strings.NewReader(body))
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", provider)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Providers",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.OIDCProviders },
+			req:         httptest.NewRequest(http.MethodGet, "/auth/oidc/providers", nil),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Sign In",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithOIDC },
+			req:         signIn("okta", `{"id_token":"id-token"}`),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Sign In Unknown Provider",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithOIDC },
+			req:         signIn("gitlab", `{"id_token":"id-token"}`),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Sign In Invalid ID Token",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithOIDC },
+			req:         signIn("okta", `{"id_token":"forged"}`),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Sign In Unknown User",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("User", "email=user@example.com")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithOIDC },
+			req:         signIn("okta", `{"id_token":"id-token"}`),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Sign In Bad Request",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithOIDC },
+			req:         signIn("okta", `{`),
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_OIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	withProvider := func(req *http.Request, provider string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", provider)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	callback := func(provider, query string, flowToken string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/auth/"+provider+"/callback?"+query, nil)
+		if flowToken != "" {
+			req.AddCookie(&http.Cookie{Name: oidcFlowCookie, Value: flowToken})
+		}
+
+		return withProvider(req, provider)
+	}
+
+	oneTap := func(csrfField, csrfCookie string) *http.Request {
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/auth/signin-with-google",
+			strings.NewReader("credential=id-token&g_csrf_token="+csrfField),
+		)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: googleCSRFCookie, Value: csrfCookie})
+		}
+
+		return req
+	}
+
+	tests := []struct {
+		name         string
+		authService  Service
+		handler      func(h *Handler) http.HandlerFunc
+		req          *http.Request
+		wantCode     int
+		wantLocation string
+		wantCookie   string
+	}{
+		{
+			name:         "Start",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.StartOIDCLogin },
+			req:          withProvider(httptest.NewRequest(http.MethodGet, "/auth/okta/start", nil), "okta"),
+			wantCode:     http.StatusFound,
+			wantLocation: "https://example.okta.com/authorize?state=state",
+			wantCookie:   "flow-token",
+		},
+		{
+			name:        "Start Unknown Provider",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.StartOIDCLogin },
+			req:         withProvider(httptest.NewRequest(http.MethodGet, "/auth/gitlab/start", nil), "gitlab"),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Start Invalid Redirect",
+			authService: &ServiceMock{err: ErrInvalidRedirectTo},
+			handler:     func(h *Handler) http.HandlerFunc { return h.StartOIDCLogin },
+			req:         withProvider(httptest.NewRequest(http.MethodGet, "/auth/okta/start", nil), "okta"),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:         "Callback",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.FinishOIDCLogin },
+			req:          callback("okta", "state=state&code=code", "flow-token"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#access_token=token&redirect_to=%2Fusers&refresh_token=refresh",
+		},
+		{
+			name:         "Callback Denied",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.FinishOIDCLogin },
+			req:          callback("okta", "state=state&error=access_denied", "flow-token"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#error=access_denied&redirect_to=%2Fusers",
+		},
+		{
+			name:        "Callback Without Flow Cookie",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.FinishOIDCLogin },
+			req:         callback("okta", "state=state&code=code", ""),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Callback Unknown Provider",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.FinishOIDCLogin },
+			req:         callback("gitlab", "state=state&code=code", "flow-token"),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:         "One Tap",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.SignInWithGoogle },
+			req:          oneTap("csrf", "csrf"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#access_token=good_token&refresh_token=",
+		},
+		{
+			name:         "One Tap Invalid ID Token",
+			authService:  &ServiceMock{err: ErrInvalidIDToken},
+			handler:      func(h *Handler) http.HandlerFunc { return h.SignInWithGoogle },
+			req:          oneTap("csrf", "csrf"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#error=invalid_id_token",
+		},
+		{
+			name:        "One Tap Without CSRF Cookie",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithGoogle },
+			req:         oneTap("csrf", ""),
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "One Tap CSRF Mismatch",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithGoogle },
+			req:         oneTap("csrf", "other"),
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Google CSRF Mismatch",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SignInWithGoogle },
+			req: newRequest(http.MethodPost, "/auth/signin-with-google", GoogleIDTokenVerifyRequest{
+				IDToken:    "good_token",
+				GCSRFToken: "csrf",
+			}),
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+
+			if location := res.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Handler %s Location = %q, want %q", tt.name, location, tt.wantLocation)
+			}
+
+			for _, cookie := range res.Result().Cookies() {
+				if cookie.Name == oidcFlowCookie && (cookie.Value != tt.wantCookie || !cookie.HttpOnly) {
+					t.Errorf("Handler %s flow cookie = %+v, want %q", tt.name, cookie, tt.wantCookie)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_SAMLLogin(t *testing.T) {
+	t.Parallel()
+
+	withConnection := func(req *http.Request, connection string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("connection", connection)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	acs := func(connection, samlResponse, flowToken string) *http.Request {
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/auth/saml/"+connection+"/acs",
+			strings.NewReader("SAMLResponse="+samlResponse),
+		)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		if flowToken != "" {
+			req.AddCookie(&http.Cookie{Name: samlFlowCookie, Value: flowToken})
+		}
+
+		return withConnection(req, connection)
+	}
+
+	start := func(connection string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/auth/saml/"+connection+"/start", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		return withConnection(req, connection)
+	}
+
+	tests := []struct {
+		name         string
+		authService  Service
+		handler      func(h *Handler) http.HandlerFunc
+		req          *http.Request
+		wantCode     int
+		wantLocation string
+		wantCookie   string
+	}{
+		{
+			name:        "Metadata",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SAMLMetadata },
+			req:         withConnection(httptest.NewRequest(http.MethodGet, "/auth/saml/adfs/metadata", nil), "adfs"),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Metadata Unknown Connection",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.SAMLMetadata },
+			req:         withConnection(httptest.NewRequest(http.MethodGet, "/auth/saml/okta/metadata", nil), "okta"),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:         "Start",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.StartSAMLLogin },
+			req:          start("adfs"),
+			wantCode:     http.StatusFound,
+			wantLocation: "https://adfs.example.com/adfs/ls?SAMLRequest=request",
+			wantCookie:   "flow-token",
+		},
+		{
+			name:        "Start Unknown Connection",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.StartSAMLLogin },
+			req:         start("okta"),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Start Metadata Unavailable",
+			authService: &ServiceMock{err: ErrSAMLMetadata},
+			handler:     func(h *Handler) http.HandlerFunc { return h.StartSAMLLogin },
+			req:         start("adfs"),
+			wantCode:    http.StatusBadGateway,
+		},
+		{
+			name:         "ACS",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.FinishSAMLLogin },
+			req:          acs("adfs", "response", "flow-token"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#access_token=token&redirect_to=%2Fusers&refresh_token=refresh",
+		},
+		{
+			name:         "ACS Invalid Assertion",
+			authService:  &ServiceMock{},
+			handler:      func(h *Handler) http.HandlerFunc { return h.FinishSAMLLogin },
+			req:          acs("adfs", "forged", "flow-token"),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "http://localhost:3000/auth/callback#error=invalid_assertion&redirect_to=%2Fusers",
+		},
+		{
+			name:        "ACS Without Flow Cookie",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.FinishSAMLLogin },
+			req:         acs("adfs", "response", ""),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "ACS Unknown Connection",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.FinishSAMLLogin },
+			req:         acs("okta", "response", "flow-token"),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+
+			if location := res.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Handler %s Location = %q, want %q", tt.name, location, tt.wantLocation)
+			}
+
+			// the response is posted cross-site
+			for _, cookie := range res.Result().Cookies() {
+				if cookie.Name == samlFlowCookie && (cookie.Value != tt.wantCookie || !cookie.HttpOnly ||
+					!cookie.Secure || cookie.SameSite != http.SameSiteNoneMode) {
+					t.Errorf("Handler %s flow cookie = %+v, want %q", tt.name, cookie, tt.wantCookie)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_UserIdentities(t *testing.T) {
+	t.Parallel()
+
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), userKey, domain.User{ID: "1"}))
+	}
+
+	link := func(provider, idToken string) *http.Request {
+		return withUser(
+			newRequest(http.MethodPost, "/auth/identities", LinkIdentityRequest{Provider: provider, IDToken: idToken}),
+		)
+	}
+
+	unlink := func() *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+
+		req := httptest.NewRequest(http.MethodDelete, "/auth/identities/1", nil)
+
+		return withUser(req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		handler     func(h *Handler) http.HandlerFunc
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "List",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UserIdentities },
+			req:         withUser(httptest.NewRequest(http.MethodGet, "/auth/identities", nil)),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "List Not Enabled",
+			authService: &ServiceMock{err: ErrIdentitiesNotSupported},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UserIdentities },
+			req:         withUser(httptest.NewRequest(http.MethodGet, "/auth/identities", nil)),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "List Unauthenticated",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UserIdentities },
+			req:         httptest.NewRequest(http.MethodGet, "/auth/identities", nil),
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Link",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LinkIdentity },
+			req:         link("okta", "id-token"),
+			wantCode:    http.StatusCreated,
+		},
+		{
+			name:        "Link Unknown Provider",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LinkIdentity },
+			req:         link("gitlab", "id-token"),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Link Invalid ID Token",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LinkIdentity },
+			req:         link("okta", "forged"),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Link Linked Already",
+			authService: &ServiceMock{err: ErrIdentityLinked},
+			handler:     func(h *Handler) http.HandlerFunc { return h.LinkIdentity },
+			req:         link("okta", "id-token"),
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "Unlink",
+			authService: &ServiceMock{},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UnlinkIdentity },
+			req:         unlink(),
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name:        "Unlink Last Sign-In Method",
+			authService: &ServiceMock{err: ErrLastSignInMethod},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UnlinkIdentity },
+			req:         unlink(),
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "Unlink Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("UserIdentity", "id=1")},
+			handler:     func(h *Handler) http.HandlerFunc { return h.UnlinkIdentity },
+			req:         unlink(),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			tt.handler(h)(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler %s = %v, want %v", tt.name, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_Impersonate(t *testing.T) {
+	t.Parallel()
+
+	impersonate := func(body string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/users/1/impersonate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer good_token")
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	tests := []struct {
+		name        string
+		authService Service
+		req         *http.Request
+		wantCode    int
+	}{
+		{
+			name:        "Impersonate",
+			authService: &ServiceMock{},
+			req:         impersonate(`{"reason":"support ticket 42"}`),
+			wantCode:    http.StatusCreated,
+		},
+		{
+			name:        "Invalid Body",
+			authService: &ServiceMock{},
+			req:         impersonate(`{`),
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Not Allowed",
+			authService: &ServiceMock{err: ErrImpersonationNotAllowed},
+			req:         impersonate(`{"reason":"support ticket 42"}`),
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Not Found",
+			authService: &ServiceMock{err: domain.NewResourceNotFoundError("User", "id=1")},
+			req:         impersonate(`{"reason":"support ticket 42"}`),
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Not Enabled",
+			authService: &ServiceMock{err: ErrImpersonationNotSupported},
+			req:         impersonate(`{"reason":"support ticket 42"}`),
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				authService: tt.authService,
+				Handler: httpjson.Handler{
+					Logger: logging.NewLogger(),
+				},
+			}
+
+			res := httptest.NewRecorder()
+
+			h.Impersonate(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("Handler.Impersonate() = %v, want %v", res.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+
+			var got TokenResponse
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("Handler.Impersonate() body error = %v", err)
+			}
+
+			if got.AccessToken == "" || got.ExpiresIn != int(DefaultImpersonationDuration.Seconds()) {
+				t.Errorf("Handler.Impersonate() = %+v, want a short-lived token", got)
+			}
+		})
+	}
+}
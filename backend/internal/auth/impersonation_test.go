@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+// impersonationUserRepo knows the admin "1", the user "2" and the admin "3".
+type impersonationUserRepo struct {
+	UserRepositoryMock
+}
+
+var impersonationUsers = map[string]*domain.User{
+	"1": {ID: "1", Username: "admin"},
+	"2": {ID: "2", Username: "user"},
+	"3": {ID: "3", Username: "other-admin"},
+}
+
+func (u *impersonationUserRepo) FindByID(_ context.Context, id string) (*domain.User, error) {
+	user, ok := impersonationUsers[id]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("User", "id="+id)
+	}
+
+	return user, nil
+}
+
+func (u *impersonationUserRepo) FindByUsername(_ context.Context, username string) (*domain.User, error) {
+	for _, user := range impersonationUsers {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("User", "username="+username)
+}
+
+// impersonationRoleRepo only has the roles granted.
+type impersonationRoleRepo struct {
+	RoleRepositoryMock
+}
+
+func (r *impersonationRoleRepo) FindByUserID(_ context.Context, userID string) ([]*domain.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roles := make([]*domain.Role, 0, len(r.granted[userID]))
+	for _, name := range r.granted[userID] {
+		roles = append(roles, &domain.Role{Name: name})
+	}
+
+	return roles, nil
+}
+
+func newImpersonationService(auditLogRepo *AuditLogRepositoryMock) *authService {
+	a := newSessionAuthService(&SessionRepositoryMock{})
+	a.userRepo = &impersonationUserRepo{}
+	a.roleRepo = &impersonationRoleRepo{RoleRepositoryMock{granted: map[string][]string{
+		"1": {domain.RoleAdmin},
+		"3": {domain.RoleAdmin},
+	}}}
+
+	if auditLogRepo != nil {
+		WithAuditLogRepo(auditLogRepo)(a)
+	}
+
+	return a
+}
+
+func Test_authService_Impersonate(t *testing.T) {
+	t.Parallel()
+
+	auditLogRepo := &AuditLogRepositoryMock{}
+	a := newImpersonationService(auditLogRepo)
+	ctx := ContextWithClientInfo(context.Background(), domain.ClientInfo{
+		UserAgent: "Mozilla/5.0",
+		IPAddress: "127.0.0.1",
+	})
+
+	admin, err := a.signIn(ctx, impersonationUsers["1"])
+	if err != nil {
+		t.Fatalf("authService.signIn() error = %v", err)
+	}
+
+	token, err := a.Impersonate(ctx, admin.AccessToken, "2", "support ticket 42")
+	if err != nil {
+		t.Fatalf("authService.Impersonate() error = %v", err)
+	}
+
+	if token.RefreshToken != "" {
+		t.Errorf("authService.Impersonate() refresh token = %q, want none", token.RefreshToken)
+	}
+
+	claims, err := a.parseToken(token.AccessToken, domain.TokenTypeAccess, AccessTokenAudience)
+	if err != nil {
+		t.Fatalf("authService.parseToken() error = %v", err)
+	}
+
+	adminClaims, _ := a.parseToken(admin.AccessToken, domain.TokenTypeAccess, AccessTokenAudience)
+
+	if claims.Username != "user" || claims.Subject != "2" || claims.Actor == nil ||
+		claims.Actor.Subject != "1" || claims.SessionID != adminClaims.SessionID {
+		t.Errorf("authService.Impersonate() claims = %+v, want user 2 acted on by admin 1 in their session", claims)
+	}
+
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > DefaultImpersonationDuration {
+		t.Errorf("authService.Impersonate() token expires in %v, want at most %v", ttl, DefaultImpersonationDuration)
+	}
+
+	user, err := a.VerifyToken(ctx, token.AccessToken)
+	if err != nil || user.ID != "2" {
+		t.Fatalf("authService.VerifyToken() = %v, %v, want user 2", user, err)
+	}
+
+	// impersonation cannot be nested
+	if _, err := a.Impersonate(ctx, token.AccessToken, "2", "nested"); !errors.Is(err, ErrImpersonationNotAllowed) {
+		t.Errorf("authService.Impersonate() error = %v, want %v", err, ErrImpersonationNotAllowed)
+	}
+
+	// signing out ends the impersonation only
+	if err := a.Logout(ctx, token.AccessToken); err != nil {
+		t.Fatalf("authService.Logout() error = %v", err)
+	}
+
+	if _, err := a.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if _, err := a.VerifyToken(ctx, admin.AccessToken); err != nil {
+		t.Errorf("authService.VerifyToken() of the admin error = %v", err)
+	}
+
+	events := auditLogRepo.recorded()
+	if len(events) != 2 {
+		t.Fatalf("audit log = %v, want 2 events", events)
+	}
+
+	start, end := events[0], events[1]
+	if start.Action != domain.AuditActionImpersonationStart || start.ActorID != "1" || start.SubjectID != "2" ||
+		start.Reason != "support ticket 42" || start.IPAddress != "127.0.0.1" {
+		t.Errorf("audit log start = %+v, want the impersonation of user 2 by admin 1", start)
+	}
+
+	if end.Action != domain.AuditActionImpersonationEnd || end.ActorID != "1" || end.SubjectID != "2" {
+		t.Errorf("audit log end = %+v, want the end of the impersonation of user 2 by admin 1", end)
+	}
+
+	// the impersonation ends with the session of the admin
+	token, _ = a.Impersonate(ctx, admin.AccessToken, "2", "support ticket 42")
+
+	if err := a.Logout(ctx, admin.AccessToken); err != nil {
+		t.Fatalf("authService.Logout() error = %v", err)
+	}
+
+	if _, err := a.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func Test_authService_Impersonate_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		auditLogRepo *AuditLogRepositoryMock
+		userID       string
+		wantErr      error
+	}{
+		{
+			name:         "Admin",
+			auditLogRepo: &AuditLogRepositoryMock{},
+			userID:       "3",
+			wantErr:      ErrImpersonationNotAllowed,
+		},
+		{
+			name:         "Self",
+			auditLogRepo: &AuditLogRepositoryMock{},
+			userID:       "1",
+			wantErr:      ErrImpersonationNotAllowed,
+		},
+		{
+			name:         "Not Enabled",
+			auditLogRepo: nil,
+			userID:       "2",
+			wantErr:      ErrImpersonationNotSupported,
+		},
+		{
+			name:         "Not Found",
+			auditLogRepo: &AuditLogRepositoryMock{},
+			userID:       "9",
+		},
+		{
+			name:         "Audit Log Error",
+			auditLogRepo: &AuditLogRepositoryMock{hasError: true},
+			userID:       "2",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := newImpersonationService(tt.auditLogRepo)
+			ctx := context.Background()
+
+			admin, err := a.signIn(ctx, impersonationUsers["1"])
+			if err != nil {
+				t.Fatalf("authService.signIn() error = %v", err)
+			}
+
+			token, err := a.Impersonate(ctx, admin.AccessToken, tt.userID, "support ticket 42")
+			if err == nil || token != nil {
+				t.Fatalf("authService.Impersonate() = %v, %v, want error", token, err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("authService.Impersonate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+var _ domain.AuditLogRepository = &AuditLogRepositoryMock{}
+
+type AuditLogRepositoryMock struct {
+	hasError bool
+
+	mu     sync.Mutex
+	events []*domain.AuditEvent
+}
+
+func (r *AuditLogRepositoryMock) Create(
+	_ context.Context,
+	event *domain.AuditEvent,
+) (*domain.AuditEvent, error) {
+	if r.hasError {
+		return nil, errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+
+	return event, nil
+}
+
+func (r *AuditLogRepositoryMock) recorded() []*domain.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*domain.AuditEvent(nil), r.events...)
+}
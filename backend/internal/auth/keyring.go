@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKeyID is the key ID given to the shared secret when no key ring is
+// configured.
+const DefaultKeyID = "default"
+
+var (
+	ErrUnknownKeyID      = errors.New("unknown key id")
+	ErrUnsupportedKey    = errors.New("unsupported key")
+	ErrNoSigningKey      = errors.New("no signing key")
+	ErrUnexpectedAlg     = errors.New("unexpected signing algorithm")
+	ErrUnsupportedMethod = errors.New("unsupported signing method")
+)
+
+// SigningKey is a named key used to sign or verify tokens.
+type SigningKey struct {
+	// ID is put in the "kid" header of every token signed with this key.
+	ID     string
+	Method jwt.SigningMethod
+
+	// PrivateKey signs tokens. It is nil for verification-only keys, e.g. a
+	// retired key kept around until the tokens it signed have expired.
+	PrivateKey any
+
+	// PublicKey verifies tokens. For HMAC it is the shared secret.
+	PublicKey any
+}
+
+// KeyRing holds every key tokens are verified with and the one key new
+// tokens are signed with, which allows keys to be rotated without
+// invalidating tokens that are still in flight.
+type KeyRing struct {
+	signingKey *SigningKey
+	keys       map[string]*SigningKey
+}
+
+// NewKeyRing returns a key ring signing with the key identified by
+// signingKeyID and verifying with any of keys.
+func NewKeyRing(signingKeyID string, keys ...*SigningKey) (*KeyRing, error) {
+	keyRing := &KeyRing{
+		keys: make(map[string]*SigningKey, len(keys)),
+	}
+
+	for _, key := range keys {
+		if key.Method == nil || key.PublicKey == nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, ErrUnsupportedKey)
+		}
+
+		keyRing.keys[key.ID] = key
+	}
+
+	signingKey, ok := keyRing.keys[signingKeyID]
+	if !ok || signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("key %q: %w", signingKeyID, ErrNoSigningKey)
+	}
+
+	keyRing.signingKey = signingKey
+
+	return keyRing, nil
+}
+
+// NewHMACKeyRing returns a key ring signing and verifying with a single
+// HS256 shared secret.
+func NewHMACKeyRing(secret []byte) *KeyRing {
+	key := &SigningKey{
+		ID:         DefaultKeyID,
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: secret,
+		PublicKey:  secret,
+	}
+
+	return &KeyRing{
+		signingKey: key,
+		keys:       map[string]*SigningKey{key.ID: key},
+	}
+}
+
+// ParseSigningKey builds a signing key for the given JWS algorithm from PEM
+// encoded key material. privatePEM may be empty for a verification-only key;
+// for HMAC algorithms the raw secret is passed as privatePEM.
+func ParseSigningKey(keyID, alg string, privatePEM, publicPEM []byte) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnsupportedMethod)
+	}
+
+	if len(privatePEM) == 0 && len(publicPEM) == 0 {
+		return nil, fmt.Errorf("key %q: %w", keyID, ErrUnsupportedKey)
+	}
+
+	key := &SigningKey{ID: keyID, Method: method}
+
+	var err error
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		key.PrivateKey, key.PublicKey = privatePEM, privatePEM
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key.PrivateKey, key.PublicKey, err = parseKeyPair(
+			privatePEM,
+			publicPEM,
+			jwt.ParseRSAPrivateKeyFromPEM,
+			jwt.ParseRSAPublicKeyFromPEM,
+		)
+	case *jwt.SigningMethodECDSA:
+		key.PrivateKey, key.PublicKey, err = parseKeyPair(
+			privatePEM,
+			publicPEM,
+			jwt.ParseECPrivateKeyFromPEM,
+			jwt.ParseECPublicKeyFromPEM,
+		)
+	case *jwt.SigningMethodEd25519:
+		key.PrivateKey, key.PublicKey, err = parseKeyPair(
+			privatePEM,
+			publicPEM,
+			jwt.ParseEdPrivateKeyFromPEM,
+			jwt.ParseEdPublicKeyFromPEM,
+		)
+	default:
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnsupportedMethod)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parse key %q error: %w", keyID, err)
+	}
+
+	return key, nil
+}
+
+// parseKeyPair parses the private key when given and derives the public key
+// from it unless a public key is given as well.
+func parseKeyPair[Priv, Pub any](
+	privatePEM, publicPEM []byte,
+	parsePrivate func([]byte) (Priv, error),
+	parsePublic func([]byte) (Pub, error),
+) (any, any, error) {
+	var privateKey, publicKey any
+
+	if len(privatePEM) > 0 {
+		priv, err := parsePrivate(privatePEM)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signer, ok := any(priv).(crypto.Signer)
+		if !ok {
+			return nil, nil, ErrUnsupportedKey
+		}
+
+		privateKey, publicKey = priv, signer.Public()
+	}
+
+	if len(publicPEM) > 0 {
+		pub, err := parsePublic(publicPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		publicKey = pub
+	}
+
+	return privateKey, publicKey, nil
+}
+
+// SigningKeyID returns the ID of the key new tokens are signed with.
+func (k *KeyRing) SigningKeyID() string {
+	return k.signingKey.ID
+}
+
+// SigningAlg returns the algorithm new tokens are signed with.
+func (k *KeyRing) SigningAlg() string {
+	return k.signingKey.Method.Alg()
+}
+
+// SigningKeyPublished reports whether the JWKS holds the key new tokens are
+// signed with, i.e. whether others can verify them.
+func (k *KeyRing) SigningKeyPublished() bool {
+	_, ok := toJWK(k.signingKey)
+
+	return ok
+}
+
+// Sign signs claims with the current signing key and sets the "kid" header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signingKey.Method, claims)
+	token.Header["kid"] = k.signingKey.ID
+
+	tokenString, err := token.SignedString(k.signingKey.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("sign token error %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// Keyfunc resolves the verification key of a token from its "kid" header and
+// makes sure the token was signed with the algorithm the key is meant for.
+// Tokens without a "kid" were issued before key IDs were introduced and are
+// checked against the signing key.
+func (k *KeyRing) Keyfunc(token *jwt.Token) (any, error) {
+	key := k.signingKey
+
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = k.keys[kid]; !ok {
+			return nil, fmt.Errorf("%s: %w", kid, ErrUnknownKeyID)
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%s: %w", token.Method.Alg(), ErrUnexpectedAlg)
+	}
+
+	return key.PublicKey, nil
+}
+
+// ValidMethods returns the algorithms of all keys in the ring.
+func (k *KeyRing) ValidMethods() []string {
+	methods := make([]string, 0, len(k.keys))
+
+	for _, key := range k.keys {
+		methods = append(methods, key.Method.Alg())
+	}
+
+	return methods
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517 section 5).
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring. Shared secrets are never
+// published, so a ring holding only HMAC keys returns an empty set.
+func (k *KeyRing) JWKS() *JWKSet {
+	set := &JWKSet{Keys: make([]JWK, 0, len(k.keys))}
+
+	for _, key := range k.keys {
+		jwk, ok := toJWK(key)
+		if ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+func toJWK(key *SigningKey) (JWK, bool) {
+	jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBigInt(pub.N)
+		jwk.E = encodeBigInt(big.NewInt(int64(pub.E)))
+	case *ecdsa.PublicKey:
+		// crypto/elliptic names the curves "P-256", ... as JWA does
+		size := (pub.Curve.Params().BitSize + 7) / 8 //nolint:gomnd // bits to bytes
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeyPEM(t *testing.T, alg string) ([]byte, []byte) {
+	t.Helper()
+
+	var (
+		privateKey any
+		publicKey  any
+		err        error
+	)
+
+	switch alg {
+	case "RS256":
+		var key *rsa.PrivateKey
+
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		privateKey, publicKey = key, &key.PublicKey
+	case "ES256":
+		var key *ecdsa.PrivateKey
+
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		privateKey, publicKey = key, &key.PublicKey
+	case "EdDSA":
+		publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	}
+
+	if err != nil {
+		t.Fatalf("generate %s key error = %v", alg, err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("marshal private key error = %v", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("marshal public key error = %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func TestParseSigningKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		alg         string
+		private     bool
+		public      bool
+		wantKeyType string
+		wantErr     bool
+	}{
+		{name: "RS256", alg: "RS256", private: true, wantKeyType: "RSA"},
+		{name: "ES256", alg: "ES256", private: true, wantKeyType: "EC"},
+		{name: "EdDSA", alg: "EdDSA", private: true, wantKeyType: "OKP"},
+		{name: "Verification Only", alg: "ES256", public: true, wantKeyType: "EC"},
+		{name: "No Key Material", alg: "RS256", wantErr: true},
+		{name: "Unsupported Alg", alg: "none", private: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var privatePEM, publicPEM []byte
+
+			if tt.alg != "none" && (tt.private || tt.public) {
+				privatePEM, publicPEM = newTestKeyPEM(t, tt.alg)
+			}
+
+			if !tt.private {
+				privatePEM = nil
+			}
+
+			if !tt.public {
+				publicPEM = nil
+			}
+
+			if tt.alg == "none" {
+				privatePEM = []byte("secret")
+			}
+
+			got, err := ParseSigningKey("kid", tt.alg, privatePEM, publicPEM)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if (got.PrivateKey != nil) != tt.private {
+				t.Errorf("ParseSigningKey() private key = %v, want %v", got.PrivateKey, tt.private)
+			}
+
+			jwk, ok := toJWK(got)
+			if !ok || jwk.KeyType != tt.wantKeyType || jwk.KeyID != "kid" {
+				t.Errorf("toJWK() = %+v, want kty %v", jwk, tt.wantKeyType)
+			}
+		})
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	t.Parallel()
+
+	oldPrivate, oldPublic := newTestKeyPEM(t, "RS256")
+	newPrivate, _ := newTestKeyPEM(t, "ES256")
+
+	oldKey, _ := ParseSigningKey("2024-01", "RS256", oldPrivate, nil)
+	retiredKey, _ := ParseSigningKey("2024-01", "RS256", nil, oldPublic)
+	newKey, _ := ParseSigningKey("2024-06", "ES256", newPrivate, nil)
+	strayKey, _ := ParseSigningKey("stray", "RS256", oldPrivate, nil)
+
+	oldRing, err := NewKeyRing("2024-01", oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	rotatedRing, err := NewKeyRing("2024-06", retiredKey, newKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	claims := jwt.MapClaims{"sub": "1"}
+
+	strayRing, _ := NewKeyRing("stray", strayKey)
+
+	oldToken, _ := oldRing.Sign(claims)
+	newToken, _ := rotatedRing.Sign(claims)
+	strayToken, _ := strayRing.Sign(claims)
+
+	tests := []struct {
+		name    string
+		keyRing *KeyRing
+		token   string
+		wantErr error
+	}{
+		{name: "Old Token With Rotated Ring", keyRing: rotatedRing, token: oldToken},
+		{name: "New Token With Rotated Ring", keyRing: rotatedRing, token: newToken},
+		{name: "New Token With Old Ring", keyRing: oldRing, token: newToken, wantErr: ErrUnknownKeyID},
+		{name: "Unknown Key ID", keyRing: oldRing, token: strayToken, wantErr: ErrUnknownKeyID},
+		{
+			name:    "HMAC Token With RSA Key",
+			keyRing: rotatedRing,
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				token.Header["kid"] = "2024-01"
+				tokenString, _ := token.SignedString(oldPublic)
+
+				return tokenString
+			}(),
+			wantErr: ErrUnexpectedAlg,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// no jwt.WithValidMethods so that Keyfunc alone is put to the test
+			_, err := jwt.Parse(tt.token, tt.keyRing.Keyfunc)
+			if (err != nil) != (tt.wantErr != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("jwt.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := rotatedRing.JWKS(); len(got.Keys) != 2 || got.Keys[0].KeyID != "2024-01" {
+		t.Errorf("KeyRing.JWKS() = %+v, want 2 keys", got)
+	}
+
+	if !rotatedRing.SigningKeyPublished() || rotatedRing.SigningAlg() != "ES256" {
+		t.Errorf("KeyRing.SigningAlg() = %v, want a published ES256 key", rotatedRing.SigningAlg())
+	}
+}
+
+func TestNewKeyRing(t *testing.T) {
+	t.Parallel()
+
+	_, publicPEM := newTestKeyPEM(t, "EdDSA")
+	verifyOnly, _ := ParseSigningKey("verify", "EdDSA", nil, publicPEM)
+
+	tests := []struct {
+		name         string
+		signingKeyID string
+		keys         []*SigningKey
+		wantErr      bool
+	}{
+		{
+			name:         "Success",
+			signingKeyID: DefaultKeyID,
+			keys:         []*SigningKey{NewHMACKeyRing([]byte("secret")).signingKey},
+		},
+		{
+			name:         "Unknown Signing Key",
+			signingKeyID: "unknown",
+			keys:         []*SigningKey{verifyOnly},
+			wantErr:      true,
+		},
+		{
+			name:         "Verification Only Signing Key",
+			signingKeyID: "verify",
+			keys:         []*SigningKey{verifyOnly},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewKeyRing(tt.signingKeyID, tt.keys...); (err != nil) != tt.wantErr {
+				t.Errorf("NewKeyRing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := NewHMACKeyRing([]byte("secret")).JWKS(); len(got.Keys) != 0 {
+		t.Errorf("KeyRing.JWKS() published a shared secret: %+v", got)
+	}
+
+	if NewHMACKeyRing([]byte("secret")).SigningKeyPublished() {
+		t.Errorf("KeyRing.SigningKeyPublished() = true for a shared secret")
+	}
+}
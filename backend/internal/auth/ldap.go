@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"goadmin-backend/internal/domain"
+)
+
+const (
+	// DefaultLDAPName names LDAP directories in the identities of their users.
+	DefaultLDAPName = "ldap"
+
+	// DefaultLDAPTimeout bounds the requests made to LDAP servers.
+	DefaultLDAPTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidLDAPConfig = errors.New("invalid LDAP configuration")
+	ErrAmbiguousLDAPUser = errors.New("the username matches more than one LDAP entry")
+)
+
+// LDAPAttributeMapping names the attributes of LDAP entries the profile of
+// users is read from. Empty names fall back to the inetOrgPerson ones, and
+// Groups to the memberOf of Active Directory and OpenLDAP.
+type LDAPAttributeMapping struct {
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Groups    string
+}
+
+// LDAPConfig is the configuration of an LDAP or Active Directory server.
+type LDAPConfig struct {
+	// Name names the directory in the identities of its users; it defaults
+	// to DefaultLDAPName.
+	Name string
+	// URL is the ldap:// or ldaps:// URL of the server.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS bool
+	// TLSConfig verifies the certificate of the server, e.g. against a
+	// corporate CA; the system roots are used when it is nil.
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the service account users are searched
+	// with; the search is anonymous when BindDN is empty.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where users are searched, in the whole subtree.
+	BaseDN string
+	// UserFilter finds the entry of a username, which replaces %s, escaped,
+	// e.g. "(&(objectClass=user)(sAMAccountName=%s))".
+	UserFilter string
+	Attributes LDAPAttributeMapping
+	// GroupRoles maps the DNs of groups to the roles their members get.
+	GroupRoles map[string]string
+	// Timeout defaults to DefaultLDAPTimeout.
+	Timeout time.Duration
+}
+
+// LDAPVerifier verifies credentials against an LDAP server by searching the
+// entry of the username with the service account, then binding as the
+// entry with the password.
+type LDAPVerifier struct {
+	cfg LDAPConfig
+}
+
+var _ CredentialVerifier = &LDAPVerifier{}
+
+func NewLDAPVerifier(cfg LDAPConfig) (*LDAPVerifier, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil || (serverURL.Scheme != "ldap" && serverURL.Scheme != "ldaps") || serverURL.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an ldap:// or ldaps:// URL", ErrInvalidLDAPConfig)
+	}
+
+	if cfg.StartTLS && serverURL.Scheme == "ldaps" {
+		return nil, fmt.Errorf("%w: start_tls is for ldap:// URLs", ErrInvalidLDAPConfig)
+	}
+
+	if cfg.BaseDN == "" || !strings.Contains(cfg.UserFilter, "%s") {
+		return nil, fmt.Errorf("%w: base_dn and a user_filter with %%s are required", ErrInvalidLDAPConfig)
+	}
+
+	cfg.Name = cmp.Or(cfg.Name, DefaultLDAPName)
+	cfg.Timeout = cmp.Or(cfg.Timeout, DefaultLDAPTimeout)
+	cfg.Attributes.Username = cmp.Or(cfg.Attributes.Username, "uid")
+	cfg.Attributes.Email = cmp.Or(cfg.Attributes.Email, "mail")
+	cfg.Attributes.FirstName = cmp.Or(cfg.Attributes.FirstName, "givenName")
+	cfg.Attributes.LastName = cmp.Or(cfg.Attributes.LastName, "sn")
+	cfg.Attributes.Groups = cmp.Or(cfg.Attributes.Groups, "memberOf")
+
+	return &LDAPVerifier{cfg: cfg}, nil
+}
+
+// Name names the directory in the identities of its users.
+func (v *LDAPVerifier) Name() string {
+	return v.cfg.Name
+}
+
+// VerifyCredentials searches the entry of the username and binds as it with
+// the password.
+func (v *LDAPVerifier) VerifyCredentials(
+	ctx context.Context,
+	credentials domain.Credentials,
+) (*DirectoryUser, error) {
+	// an empty password would be an unauthenticated bind, which succeeds
+	if credentials.Username == "" || credentials.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := v.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// go-ldap has no contexts; closing the connection fails the request
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if v.cfg.BindDN != "" {
+		if err := conn.Bind(v.cfg.BindDN, v.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service account bind error %w", err)
+		}
+	}
+
+	attributes := v.cfg.Attributes
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		v.cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, //nolint:gomnd // enough to tell that a username is ambiguous
+		int(v.cfg.Timeout.Seconds()),
+		false,
+		strings.ReplaceAll(v.cfg.UserFilter, "%s", ldap.EscapeFilter(credentials.Username)),
+		[]string{attributes.Username, attributes.Email, attributes.FirstName, attributes.LastName, attributes.Groups},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap search error %w", err)
+	}
+
+	switch {
+	case len(result.Entries) == 0:
+		return nil, domain.NewResourceNotFoundError("LDAPUser", "username="+credentials.Username)
+	case len(result.Entries) > 1:
+		return nil, fmt.Errorf("%w: %s", ErrAmbiguousLDAPUser, credentials.Username)
+	}
+
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, credentials.Password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, fmt.Errorf("ldap bind error %w", err)
+	}
+
+	user := &DirectoryUser{
+		Subject:   entry.DN,
+		Username:  entry.GetEqualFoldAttributeValue(attributes.Username),
+		Email:     entry.GetEqualFoldAttributeValue(attributes.Email),
+		FirstName: entry.GetEqualFoldAttributeValue(attributes.FirstName),
+		LastName:  entry.GetEqualFoldAttributeValue(attributes.LastName),
+	}
+
+	if user.Username == "" {
+		user.Username = credentials.Username
+	}
+
+	// DNs are case-insensitive
+	groups := entry.GetEqualFoldAttributeValues(attributes.Groups)
+
+	for group, role := range v.cfg.GroupRoles {
+		if !slices.Contains(user.ManagedRoles, role) {
+			user.ManagedRoles = append(user.ManagedRoles, role)
+		}
+
+		if !slices.Contains(user.Roles, role) && slices.ContainsFunc(groups, func(memberOf string) bool {
+			return strings.EqualFold(memberOf, group)
+		}) {
+			user.Roles = append(user.Roles, role)
+		}
+	}
+
+	return user, nil
+}
+
+// dial connects to the server, upgrading to TLS when configured.
+func (v *LDAPVerifier) dial() (*ldap.Conn, error) {
+	tlsConfig := v.cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if tlsConfig.ServerName == "" {
+		serverURL, _ := url.Parse(v.cfg.URL)
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = serverURL.Hostname()
+	}
+
+	conn, err := ldap.DialURL(
+		v.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: v.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ldap dial error %w", err)
+	}
+
+	conn.SetTimeout(v.cfg.Timeout)
+
+	if v.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+
+			return nil, fmt.Errorf("ldap start tls error %w", err)
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestNewLDAPVerifier(t *testing.T) {
+	t.Parallel()
+
+	valid := LDAPConfig{URL: "ldaps://ldap.example.com", BaseDN: "dc=example,dc=com", UserFilter: "(uid=%s)"}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *LDAPConfig)
+		wantErr bool
+	}{
+		{name: "Valid", modify: func(*LDAPConfig) {}},
+		{name: "HTTP URL", modify: func(cfg *LDAPConfig) { cfg.URL = "https://ldap.example.com" }, wantErr: true},
+		{name: "No Host", modify: func(cfg *LDAPConfig) { cfg.URL = "ldap://" }, wantErr: true},
+		{name: "StartTLS Over LDAPS", modify: func(cfg *LDAPConfig) { cfg.StartTLS = true }, wantErr: true},
+		{name: "No Base DN", modify: func(cfg *LDAPConfig) { cfg.BaseDN = "" }, wantErr: true},
+		{name: "Filter Without Username", modify: func(cfg *LDAPConfig) { cfg.UserFilter = "(uid=*)" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := valid
+			tt.modify(&cfg)
+
+			verifier, err := NewLDAPVerifier(cfg)
+			if errors.Is(err, ErrInvalidLDAPConfig) != tt.wantErr {
+				t.Fatalf("NewLDAPVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && (verifier.Name() != DefaultLDAPName || verifier.cfg.Attributes.Email != "mail") {
+				t.Errorf("NewLDAPVerifier() config = %+v, want the defaults", verifier.cfg)
+			}
+		})
+	}
+}
+
+func TestLDAPVerifier_VerifyCredentials(t *testing.T) {
+	t.Parallel()
+
+	server := newStubLDAPServer(t)
+
+	newVerifier := func(t *testing.T, scheme string, startTLS bool, bindPassword string) *LDAPVerifier {
+		t.Helper()
+
+		addr := server.addr
+		if scheme == "ldaps" {
+			addr = server.tlsAddr
+		}
+
+		verifier, err := NewLDAPVerifier(LDAPConfig{
+			URL:          scheme + "://" + addr,
+			StartTLS:     startTLS,
+			TLSConfig:    server.clientTLS,
+			BindDN:       "cn=goadmin,dc=example,dc=com",
+			BindPassword: bindPassword,
+			BaseDN:       "dc=example,dc=com",
+			UserFilter:   "(uid=%s)",
+			GroupRoles: map[string]string{
+				"cn=Admins,ou=groups,dc=example,dc=com":  domain.RoleAdmin,
+				"cn=editors,ou=groups,dc=example,dc=com": "editor",
+			},
+			Timeout: time.Second,
+		})
+		if err != nil {
+			t.Fatalf("NewLDAPVerifier() error = %v", err)
+		}
+
+		return verifier
+	}
+
+	tests := []struct {
+		name          string
+		verifier      *LDAPVerifier
+		credentials   domain.Credentials
+		wantErr       error
+		wantNotFound  bool
+		wantServerErr bool
+	}{
+		{name: "Success", verifier: newVerifier(t, "ldap", false, "service"), credentials: domain.Credentials{Username: "alice", Password: "alice-password"}},
+		{name: "StartTLS", verifier: newVerifier(t, "ldap", true, "service"), credentials: domain.Credentials{Username: "alice", Password: "alice-password"}},
+		{name: "LDAPS", verifier: newVerifier(t, "ldaps", false, "service"), credentials: domain.Credentials{Username: "alice", Password: "alice-password"}},
+		{
+			name:        "Wrong Password",
+			verifier:    newVerifier(t, "ldap", false, "service"),
+			credentials: domain.Credentials{Username: "alice", Password: "wrong"},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "Empty Password",
+			verifier:    newVerifier(t, "ldap", false, "service"),
+			credentials: domain.Credentials{Username: "alice"},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:         "Unknown User",
+			verifier:     newVerifier(t, "ldap", false, "service"),
+			credentials:  domain.Credentials{Username: "bob", Password: "bob-password"},
+			wantNotFound: true,
+		},
+		{
+			name:         "Filter Injection",
+			verifier:     newVerifier(t, "ldap", false, "service"),
+			credentials:  domain.Credentials{Username: "*", Password: "alice-password"},
+			wantNotFound: true,
+		},
+		{
+			name:        "Ambiguous",
+			verifier:    newVerifier(t, "ldap", false, "service"),
+			credentials: domain.Credentials{Username: "carol", Password: "carol-password"},
+			wantErr:     ErrAmbiguousLDAPUser,
+		},
+		{
+			name:          "Wrong Service Password",
+			verifier:      newVerifier(t, "ldap", false, "wrong"),
+			credentials:   domain.Credentials{Username: "alice", Password: "alice-password"},
+			wantServerErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			user, err := tt.verifier.VerifyCredentials(context.Background(), tt.credentials)
+
+			var notFoundErr *domain.ResourceNotFoundError
+
+			switch {
+			case tt.wantNotFound:
+				if !errors.As(err, &notFoundErr) {
+					t.Errorf("LDAPVerifier.VerifyCredentials() error = %v, want not found", err)
+				}
+
+				return
+			case tt.wantServerErr:
+				if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.As(err, &notFoundErr) {
+					t.Errorf("LDAPVerifier.VerifyCredentials() error = %v, want a server error", err)
+				}
+
+				return
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("LDAPVerifier.VerifyCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			case err != nil:
+				return
+			}
+
+			slices.Sort(user.ManagedRoles)
+
+			if user.Subject != "uid=alice,ou=people,dc=example,dc=com" || user.Username != "alice" ||
+				user.Email != "alice@example.com" || user.FirstName != "Alice" || user.LastName != "Liddell" ||
+				!slices.Equal(user.Roles, []string{domain.RoleAdmin}) ||
+				!slices.Equal(user.ManagedRoles, []string{domain.RoleAdmin, "editor"}) {
+				t.Errorf("LDAPVerifier.VerifyCredentials() = %+v", user)
+			}
+		})
+	}
+
+	t.Run("Server Down", func(t *testing.T) {
+		t.Parallel()
+
+		verifier, _ := NewLDAPVerifier(LDAPConfig{URL: "ldap://127.0.0.1:1", BaseDN: "dc=example,dc=com", UserFilter: "(uid=%s)"})
+
+		if _, err := verifier.VerifyCredentials(context.Background(), domain.Credentials{Username: "alice", Password: "alice-password"}); err == nil {
+			t.Errorf("LDAPVerifier.VerifyCredentials() error = nil, want error")
+		}
+	})
+}
+
+type stubLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// stubLDAPServer is an in-process LDAP server answering binds, searches by
+// uid and StartTLS, in plain text and over TLS.
+type stubLDAPServer struct {
+	addr      string
+	tlsAddr   string
+	clientTLS *tls.Config
+	serverTLS *tls.Config
+
+	serviceDN       string
+	servicePassword string
+	entries         []stubLDAPEntry
+}
+
+func newStubLDAPServer(t *testing.T) *stubLDAPServer {
+	t.Helper()
+
+	server := &stubLDAPServer{
+		serviceDN:       "cn=goadmin,dc=example,dc=com",
+		servicePassword: "service",
+		entries: []stubLDAPEntry{
+			{
+				dn:       "uid=alice,ou=people,dc=example,dc=com",
+				password: "alice-password",
+				attributes: map[string][]string{
+					"uid":       {"alice"},
+					"mail":      {"alice@example.com"},
+					"givenName": {"Alice"},
+					"sn":        {"Liddell"},
+					"memberOf":  {"CN=admins,OU=groups,DC=example,DC=com", "cn=staff,ou=groups,dc=example,dc=com"},
+				},
+			},
+			{dn: "uid=carol,ou=people,dc=example,dc=com", password: "carol-password", attributes: map[string][]string{"uid": {"carol"}}},
+			{dn: "uid=carol,ou=contractors,dc=example,dc=com", password: "carol-password", attributes: map[string][]string{"uid": {"carol"}}},
+		},
+	}
+
+	server.serverTLS, server.clientTLS = newStubTLSConfigs(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	tlsListener, err := tls.Listen("tcp", "127.0.0.1:0", server.serverTLS)
+	if err != nil {
+		t.Fatalf("tls.Listen() error = %v", err)
+	}
+
+	server.addr = listener.Addr().String()
+	server.tlsAddr = tlsListener.Addr().String()
+
+	var wg sync.WaitGroup
+
+	for _, l := range []net.Listener{listener, tlsListener} {
+		l := l
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					server.serve(conn)
+				}()
+			}
+		}()
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+		tlsListener.Close()
+		wg.Wait()
+	})
+
+	return server
+}
+
+func (s *stubLDAPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	boundDN := ""
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 { //nolint:gomnd // message ID and operation
+			return
+		}
+
+		messageID := packet.Children[0].Value
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+
+			code := ldap.LDAPResultInvalidCredentials
+			if s.authenticate(dn, password) {
+				code, boundDN = ldap.LDAPResultSuccess, dn
+			}
+
+			s.write(conn, messageID, ldapResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			if boundDN != s.serviceDN {
+				s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+
+				continue
+			}
+
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+
+			for _, entry := range s.entries {
+				if filter == fmt.Sprintf("(uid=%s)", ldap.EscapeFilter(entry.attributes["uid"][0])) {
+					s.write(conn, messageID, ldapEntry(entry))
+				}
+			}
+
+			s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationExtendedRequest:
+			s.write(conn, messageID, ldapResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+
+			conn = tls.Server(conn, s.serverTLS)
+		default:
+			return
+		}
+	}
+}
+
+func (s *stubLDAPServer) authenticate(dn, password string) bool {
+	if dn == s.serviceDN {
+		return password == s.servicePassword
+	}
+
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			return password != "" && password == entry.password
+		}
+	}
+
+	return false
+}
+
+func (s *stubLDAPServer) write(conn net.Conn, messageID any, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(op)
+
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+
+	return result
+}
+
+func ldapEntry(entry stubLDAPEntry) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+
+	for name, values := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+
+	result.AppendChild(attributes)
+
+	return result
+}
+
+// newStubTLSConfigs returns the TLS configurations of a server with a
+// self-signed certificate for 127.0.0.1, and of clients trusting it.
+func newStubTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate() error = %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}, &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+}
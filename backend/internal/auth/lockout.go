@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed sign-in attempts")
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+)
+
+// LockoutPolicy defines how failed password sign-ins are throttled.
+//
+// Every failure for a username delays the next attempt for it by BaseDelay,
+// doubling with each further failure; after MaxAttempts failures in a row
+// the username is locked for LockoutDuration. A client IP address is blocked
+// for LockoutDuration after MaxIPAttempts failures, whatever the usernames.
+// Failures are forgotten LockoutDuration after the last one.
+type LockoutPolicy struct {
+	MaxAttempts     int
+	MaxIPAttempts   int
+	BaseDelay       time.Duration
+	LockoutDuration time.Duration
+}
+
+// DefaultLockoutPolicy returns the policy used for the unset fields of a
+// configured policy.
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts:     5,
+		MaxIPAttempts:   50,
+		BaseDelay:       time.Second,
+		LockoutDuration: 15 * time.Minute,
+	}
+}
+
+func (p LockoutPolicy) withDefaults() LockoutPolicy {
+	defaults := DefaultLockoutPolicy()
+
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if p.MaxIPAttempts <= 0 {
+		p.MaxIPAttempts = defaults.MaxIPAttempts
+	}
+
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaults.BaseDelay
+	}
+
+	if p.LockoutDuration <= 0 {
+		p.LockoutDuration = defaults.LockoutDuration
+	}
+
+	return p
+}
+
+// delay returns how long the next attempt of a username is refused after its
+// failures-th failure in a row.
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	if failures >= p.MaxAttempts {
+		return p.LockoutDuration
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.LockoutDuration; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.LockoutDuration)
+}
+
+// LoginThrottledError is returned by Login when a sign-in is refused before
+// the password is even checked. It wraps ErrAccountLocked when the username
+// is locked out and ErrTooManyAttempts otherwise.
+type LoginThrottledError struct {
+	Err        error
+	RetryAfter time.Time
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter.Format(time.RFC3339))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// WithLoginAttemptRepo enables brute-force protection of password sign-ins
+// according to policy.
+func WithLoginAttemptRepo(repo domain.LoginAttemptRepository, policy LockoutPolicy) Option {
+	return func(a *authService) {
+		a.loginAttemptRepo = repo
+		a.lockoutPolicy = policy.withDefaults()
+	}
+}
+
+func usernameAttemptKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipAttemptKey(ctx context.Context) string {
+	if ip := clientInfoFromContext(ctx).IPAddress; ip != "" {
+		return "ip:" + ip
+	}
+
+	return ""
+}
+
+// checkLoginThrottle refuses a sign-in while the username or the client is
+// locked out or backing off.
+func (a *authService) checkLoginThrottle(ctx context.Context, username string) error {
+	if a.loginAttemptRepo == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	for _, key := range []string{usernameAttemptKey(username), ipAttemptKey(ctx)} {
+		if key == "" {
+			continue
+		}
+
+		attempt, err := a.loginAttemptRepo.FindByKey(ctx, key)
+		if err != nil {
+			var notFoundErr *domain.ResourceNotFoundError
+			if errors.As(err, &notFoundErr) {
+				continue
+			}
+
+			return fmt.Errorf("find login attempt error %w", err)
+		}
+
+		if !attempt.Locked(now) {
+			continue
+		}
+
+		throttledErr := &LoginThrottledError{Err: ErrTooManyAttempts, RetryAfter: *attempt.LockedUntil}
+		if strings.HasPrefix(key, "user:") && attempt.Failures >= a.lockoutPolicy.MaxAttempts {
+			throttledErr.Err = ErrAccountLocked
+		}
+
+		return throttledErr
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed sign-in against the username and the
+// client, and backs them off.
+func (a *authService) recordLoginFailure(ctx context.Context, username string) error {
+	if a.loginAttemptRepo == nil {
+		return nil
+	}
+
+	policy := a.lockoutPolicy
+
+	attempt, err := a.loginAttemptRepo.RecordFailure(ctx, usernameAttemptKey(username), policy.LockoutDuration)
+	if err != nil {
+		return fmt.Errorf("record login failure error %w", err)
+	}
+
+	err = a.loginAttemptRepo.Lock(ctx, attempt.Key, time.Now().Add(policy.delay(attempt.Failures)))
+	if err != nil {
+		return fmt.Errorf("lock login error %w", err)
+	}
+
+	key := ipAttemptKey(ctx)
+	if key == "" {
+		return nil
+	}
+
+	attempt, err = a.loginAttemptRepo.RecordFailure(ctx, key, policy.LockoutDuration)
+	if err != nil {
+		return fmt.Errorf("record login failure error %w", err)
+	}
+
+	if attempt.Failures < policy.MaxIPAttempts {
+		return nil
+	}
+
+	if err := a.loginAttemptRepo.Lock(ctx, key, time.Now().Add(policy.LockoutDuration)); err != nil {
+		return fmt.Errorf("lock login error %w", err)
+	}
+
+	return nil
+}
+
+// resetLoginFailures forgets the failures of a username once its owner
+// signed in. Failures of the client are kept, they may be spread over many
+// usernames.
+func (a *authService) resetLoginFailures(ctx context.Context, username string) error {
+	if a.loginAttemptRepo == nil {
+		return nil
+	}
+
+	if err := a.loginAttemptRepo.Delete(ctx, usernameAttemptKey(username)); err != nil {
+		return fmt.Errorf("delete login attempt error %w", err)
+	}
+
+	return nil
+}
+
+// UnlockUser lifts the lockout of a user, e.g. after an administrator made
+// sure the failed sign-ins were their own.
+func (a *authService) UnlockUser(ctx context.Context, userID string) error {
+	if a.loginAttemptRepo == nil {
+		return nil
+	}
+
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	return a.resetLoginFailures(ctx, user.Username)
+}
@@ -0,0 +1,347 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestLockoutPolicy_delay(t *testing.T) {
+	t.Parallel()
+
+	policy := LockoutPolicy{
+		MaxAttempts:     5,
+		BaseDelay:       time.Second,
+		LockoutDuration: 10 * time.Second,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 3, want: 4 * time.Second},
+		{failures: 4, want: 8 * time.Second},
+		{failures: 5, want: 10 * time.Second},
+		{failures: 50, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("LockoutPolicy.delay(%v) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func Test_authService_Login_lockout(t *testing.T) {
+	t.Parallel()
+
+	ctx := ContextWithClientInfo(context.Background(), domain.ClientInfo{IPAddress: "192.0.2.1"})
+	attempts := &LoginAttemptRepositoryMock{}
+
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithLoginAttemptRepo(attempts, LockoutPolicy{MaxAttempts: 3, BaseDelay: time.Hour, LockoutDuration: 24 * time.Hour})(a)
+
+	_, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "wrong"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("authService.Login() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	if got := attempts.failures("user:username"); got != 1 {
+		t.Errorf("authService.Login() recorded %v failures for the username, want 1", got)
+	}
+
+	if got := attempts.failures("ip:192.0.2.1"); got != 1 {
+		t.Errorf("authService.Login() recorded %v failures for the client, want 1", got)
+	}
+
+	// backing off: even the right password is not checked
+	_, err = a.Login(ctx, domain.Credentials{Username: "UserName", Password: "password"})
+
+	var throttledErr *LoginThrottledError
+	if !errors.As(err, &throttledErr) || !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("authService.Login() error = %v, want %v", err, ErrTooManyAttempts)
+	}
+
+	if time.Until(throttledErr.RetryAfter) < 59*time.Minute {
+		t.Errorf("authService.Login() retry after = %v, want in an hour", throttledErr.RetryAfter)
+	}
+
+	if err := a.UnlockUser(ctx, "1"); err != nil {
+		t.Fatalf("authService.UnlockUser() error = %v", err)
+	}
+
+	if _, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "password"}); err != nil {
+		t.Fatalf("authService.Login() after unlock error = %v", err)
+	}
+
+	// the failure of the client is kept
+	if got := attempts.failures("ip:192.0.2.1"); got != 1 {
+		t.Errorf("authService.Login() left %v failures for the client, want 1", got)
+	}
+}
+
+func Test_authService_checkLoginThrottle(t *testing.T) {
+	t.Parallel()
+
+	locked := time.Now().Add(time.Minute)
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		attempts map[string]*domain.LoginAttempt
+		ip       string
+		wantErr  error
+	}{
+		{
+			name: "No Failures",
+			ip:   "192.0.2.1",
+		},
+		{
+			name: "Locked Out",
+			attempts: map[string]*domain.LoginAttempt{
+				"user:username": {Key: "user:username", Failures: 5, LockedUntil: &locked},
+			},
+			wantErr: ErrAccountLocked,
+		},
+		{
+			name: "Backing Off",
+			attempts: map[string]*domain.LoginAttempt{
+				"user:username": {Key: "user:username", Failures: 2, LockedUntil: &locked},
+			},
+			wantErr: ErrTooManyAttempts,
+		},
+		{
+			name: "Lockout Expired",
+			attempts: map[string]*domain.LoginAttempt{
+				"user:username": {Key: "user:username", Failures: 5, LockedUntil: &expired},
+			},
+		},
+		{
+			name: "Client Blocked",
+			attempts: map[string]*domain.LoginAttempt{
+				"ip:192.0.2.1": {Key: "ip:192.0.2.1", Failures: 50, LockedUntil: &locked},
+			},
+			ip:      "192.0.2.1",
+			wantErr: ErrTooManyAttempts,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &authService{}
+			WithLoginAttemptRepo(&LoginAttemptRepositoryMock{attempts: tt.attempts}, LockoutPolicy{})(a)
+
+			ctx := ContextWithClientInfo(context.Background(), domain.ClientInfo{IPAddress: tt.ip})
+
+			err := a.checkLoginThrottle(ctx, "username")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("authService.checkLoginThrottle() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_authService_recordLoginFailure_clientBlocked(t *testing.T) {
+	t.Parallel()
+
+	ctx := ContextWithClientInfo(context.Background(), domain.ClientInfo{IPAddress: "192.0.2.1"})
+	a := &authService{}
+	WithLoginAttemptRepo(&LoginAttemptRepositoryMock{}, LockoutPolicy{MaxIPAttempts: 3})(a)
+
+	// spread over usernames, so that none of them is backing off
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := a.recordLoginFailure(ctx, username); err != nil {
+			t.Fatalf("authService.recordLoginFailure() error = %v", err)
+		}
+	}
+
+	if err := a.checkLoginThrottle(ctx, "dave"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("authService.checkLoginThrottle() error = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func Test_authService_HasRole(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		roleRepo domain.RoleRepository
+		roles    []string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "Has Role", roleRepo: &RoleRepositoryMock{}, roles: []string{"editor", domain.RoleAdmin}, want: true},
+		{name: "Missing Role", roleRepo: &RoleRepositoryMock{}, roles: []string{"editor"}},
+		{name: "Error", roleRepo: &RoleRepositoryMock{hasError: true}, roles: []string{domain.RoleAdmin}, wantErr: true},
+		{name: "Not Enabled", roles: []string{domain.RoleAdmin}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &authService{roleRepo: tt.roleRepo}
+
+			got, err := a.HasRole(context.Background(), "1", tt.roles...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authService.HasRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("authService.HasRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var _ domain.LoginAttemptRepository = &LoginAttemptRepositoryMock{}
+
+type LoginAttemptRepositoryMock struct {
+	mu       sync.Mutex
+	attempts map[string]*domain.LoginAttempt
+}
+
+func (r *LoginAttemptRepositoryMock) failures(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		return attempt.Failures
+	}
+
+	return 0
+}
+
+func (r *LoginAttemptRepositoryMock) FindByKey(
+	_ context.Context,
+	key string,
+) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("LoginAttempt", "key="+key)
+	}
+
+	found := *attempt
+
+	return &found, nil
+}
+
+func (r *LoginAttemptRepositoryMock) RecordFailure(
+	_ context.Context,
+	key string,
+	_ time.Duration,
+) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.attempts == nil {
+		r.attempts = make(map[string]*domain.LoginAttempt)
+	}
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &domain.LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = time.Now()
+
+	found := *attempt
+
+	return &found, nil
+}
+
+func (r *LoginAttemptRepositoryMock) Lock(
+	_ context.Context,
+	key string,
+	until time.Time,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts[key].LockedUntil = &until
+
+	return nil
+}
+
+func (r *LoginAttemptRepositoryMock) Delete(
+	_ context.Context,
+	key string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+
+	return nil
+}
+
+var _ domain.RoleRepository = &RoleRepositoryMock{}
+
+type RoleRepositoryMock struct {
+	hasError bool
+
+	mu      sync.Mutex
+	granted map[string][]string
+}
+
+func (r *RoleRepositoryMock) FindByUserID(
+	_ context.Context,
+	_ string,
+) ([]*domain.Role, error) {
+	if r.hasError {
+		return nil, errors.New("error")
+	}
+
+	return []*domain.Role{{ID: "1", Name: domain.RoleAdmin}}, nil
+}
+
+func (r *RoleRepositoryMock) GrantRole(_ context.Context, userID, roleName string) error {
+	if r.hasError {
+		return errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.granted == nil {
+		r.granted = make(map[string][]string)
+	}
+
+	if !slices.Contains(r.granted[userID], roleName) {
+		r.granted[userID] = append(r.granted[userID], roleName)
+	}
+
+	return nil
+}
+
+func (r *RoleRepositoryMock) RevokeRole(_ context.Context, userID, roleName string) error {
+	if r.hasError {
+		return errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.granted[userID] = slices.DeleteFunc(r.granted[userID], func(role string) bool { return role == roleName })
+
+	return nil
+}
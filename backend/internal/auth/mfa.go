@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/cache"
+	"goadmin-backend/internal/platform/random"
+	"goadmin-backend/internal/platform/totp"
+)
+
+const (
+	DefaultMFAChallengeDuration = 5 * time.Minute
+
+	// MFAChallengeAudience is the "aud" claim of MFA challenge tokens, which
+	// are accepted by VerifyMFA only.
+	MFAChallengeAudience = "goadmin-mfa"
+
+	// DefaultTOTPIssuer names the account in authenticator apps.
+	DefaultTOTPIssuer = "GoAdmin"
+
+	// RecoveryCodeCount is the number of recovery codes handed out at once.
+	RecoveryCodeCount = 10
+
+	// MaxMFAAttempts is the number of wrong codes a challenge survives.
+	MaxMFAAttempts = 5
+
+	// recoveryCodeSize is the number of random bytes in a recovery code.
+	recoveryCodeSize = 5
+
+	// totpSkew is the number of time steps a code may be off by.
+	totpSkew = 1
+)
+
+var (
+	ErrMFANotSupported    = errors.New("multi-factor authentication is not enabled")
+	ErrInvalidMFACode     = errors.New("invalid authentication code")
+	ErrTOTPAlreadyEnabled = errors.New("totp is already enabled")
+	ErrTOTPNotEnrolled    = errors.New("totp is not enrolled")
+)
+
+// TOTPEnrollment is what an authenticator app is set up with.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI is the otpauth:// URI to render as a QR code.
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// WithMFARepo enables TOTP second factors. Users who confirmed one have to
+// pass it after their password; issuer names the account in their
+// authenticator app.
+func WithMFARepo(repo domain.MFARepository, issuer string) Option {
+	return func(a *authService) {
+		if issuer == "" {
+			issuer = DefaultTOTPIssuer
+		}
+
+		a.mfaRepo = repo
+		a.totpIssuer = issuer
+		a.mfaAttempts = cache.NewLRU[string, int](DefaultRevocationCacheSize)
+	}
+}
+
+// mfaRequired reports whether user has a confirmed second factor.
+func (a *authService) mfaRequired(ctx context.Context, user *domain.User) (bool, error) {
+	if a.mfaRepo == nil {
+		return false, nil
+	}
+
+	factor, err := a.findTOTP(ctx, user.ID)
+	if errors.Is(err, ErrTOTPNotEnrolled) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return factor.Confirmed(), nil
+}
+
+// issueMFAChallenge returns the short-lived token proving user passed the
+// first factor, a password or a provider, to be exchanged at VerifyMFA.
+func (a *authService) issueMFAChallenge(user *domain.User) (*domain.JWTToken, error) {
+	tokenID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return nil, fmt.Errorf("generate token id error %w", err)
+	}
+
+	now := time.Now()
+	claims := &domain.JWTClaims{
+		Username:  user.Username,
+		TokenType: domain.TokenTypeMFAChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(DefaultMFAChallengeDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{MFAChallengeAudience},
+		},
+	}
+
+	tokenString, err := a.keyRing.Sign(claims)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	return &domain.JWTToken{MFAToken: tokenString}, nil
+}
+
+// VerifyMFA exchanges an MFA challenge token and a TOTP or recovery code for
+// a token pair. A challenge is used up by a successful exchange or by
+// MaxMFAAttempts wrong codes.
+func (a *authService) VerifyMFA(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+) (*domain.JWTToken, error) {
+	if a.mfaRepo == nil {
+		return nil, ErrMFANotSupported
+	}
+
+	claims, err := a.parseToken(mfaToken, domain.TokenTypeMFAChallenge, MFAChallengeAudience)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	isRevoked, err := a.revokedTokenRepo.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("check revoked token error %w", err)
+	}
+
+	attempts, _ := a.mfaAttempts.Get(claims.ID)
+	if isRevoked || attempts >= MaxMFAAttempts {
+		return nil, ErrInvalidToken
+	}
+
+	// the challenges of a locked out user are void as well
+	if err := a.checkLoginThrottle(ctx, claims.Username); err != nil {
+		return nil, err
+	}
+
+	user, err := a.userRepo.FindByUsername(ctx, claims.Username)
+	if err != nil {
+		return nil, fmt.Errorf("find user error %w", err)
+	}
+
+	if err := a.checkSecondFactor(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			a.mfaAttempts.Set(claims.ID, attempts+1, DefaultMFAChallengeDuration)
+
+			if err := a.recordLoginFailure(ctx, user.Username); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	// a challenge signs in once only
+	err = a.revokedTokenRepo.AddRevokedToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, fmt.Errorf("revoke token error %w", err)
+	}
+
+	a.mfaAttempts.Delete(claims.ID)
+
+	if err := a.resetLoginFailures(ctx, user.Username); err != nil {
+		return nil, err
+	}
+
+	return a.signIn(ctx, user)
+}
+
+// checkSecondFactor accepts a TOTP code of the confirmed factor of a user,
+// or one of their recovery codes. Either is accepted once only.
+func (a *authService) checkSecondFactor(ctx context.Context, userID, code string) error {
+	factor, err := a.findTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !factor.Confirmed() {
+		return ErrTOTPNotEnrolled
+	}
+
+	if step, ok := totp.Validate(factor.Secret, code, time.Now(), totpSkew); ok {
+		used, err := a.mfaRepo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return fmt.Errorf("use totp step error %w", err)
+		}
+
+		if !used {
+			return ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	used, err := a.mfaRepo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("use recovery code error %w", err)
+	}
+
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (a *authService) findTOTP(ctx context.Context, userID string) (*domain.TOTPFactor, error) {
+	factor, err := a.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, ErrTOTPNotEnrolled
+		}
+
+		return nil, fmt.Errorf("find totp error %w", err)
+	}
+
+	return factor, nil
+}
+
+// EnrollTOTP generates a new TOTP secret for user. It has no effect on
+// sign-in until it is confirmed with ConfirmTOTP.
+func (a *authService) EnrollTOTP(ctx context.Context, user *domain.User) (*TOTPEnrollment, error) {
+	if a.mfaRepo == nil {
+		return nil, ErrMFANotSupported
+	}
+
+	factor, err := a.findTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, ErrTOTPNotEnrolled) {
+		return nil, err
+	}
+
+	if factor != nil && factor.Confirmed() {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret error %w", err)
+	}
+
+	if err := a.mfaRepo.SaveTOTP(ctx, &domain.TOTPFactor{UserID: user.ID, Secret: secret}); err != nil {
+		return nil, fmt.Errorf("save totp error %w", err)
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(a.totpIssuer, account, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns on the enrolled TOTP factor of a user once they proved
+// their authenticator app works, and returns their recovery codes. The codes
+// are not stored in clear and cannot be shown again.
+func (a *authService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if a.mfaRepo == nil {
+		return nil, ErrMFANotSupported
+	}
+
+	factor, err := a.findTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if factor.Confirmed() {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(factor.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	if err := a.mfaRepo.ConfirmTOTP(ctx, userID, step); err != nil {
+		return nil, fmt.Errorf("confirm totp error %w", err)
+	}
+
+	return a.replaceRecoveryCodes(ctx, userID)
+}
+
+// DisableTOTP turns off the TOTP factor of a user. It takes a current code
+// or a recovery code, so that a stolen access token is not enough.
+func (a *authService) DisableTOTP(ctx context.Context, userID, code string) error {
+	if a.mfaRepo == nil {
+		return ErrMFANotSupported
+	}
+
+	if err := a.checkSecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := a.mfaRepo.DeleteTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("delete totp error %w", err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, e.g. when
+// they ran out of them. It takes a current code like DisableTOTP.
+func (a *authService) RegenerateRecoveryCodes(
+	ctx context.Context,
+	userID string,
+	code string,
+) ([]string, error) {
+	if a.mfaRepo == nil {
+		return nil, ErrMFANotSupported
+	}
+
+	if err := a.checkSecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	return a.replaceRecoveryCodes(ctx, userID)
+}
+
+func (a *authService) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i], hashes[i] = code, hashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := a.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("replace recovery codes error %w", err)
+	}
+
+	return codes, nil
+}
+
+//nolint:gochecknoglobals // This is a constant.
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode returns a code such as "k7qz-mx2p", case-insensitive and
+// easy to type from a printout.
+func newRecoveryCode() (string, error) {
+	buffer := make([]byte, recoveryCodeSize)
+
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("generate recovery code error %w", err)
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buffer))
+
+	return code[:4] + "-" + code[4:], nil
+}
+
+// normalizeRecoveryCode drops the formatting users may or may not type.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/totp"
+)
+
+func newMFAService(mfaRepo domain.MFARepository) *authService {
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithMFARepo(mfaRepo, "")(a)
+
+	return a
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("totp.Code() error = %v", err)
+	}
+
+	return code
+}
+
+func Test_authService_TOTP(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a := newMFAService(&MFARepositoryMock{})
+	user := &domain.User{ID: "1", Username: "username", Email: "user@example.com"}
+
+	enrollment, err := a.EnrollTOTP(ctx, user)
+	if err != nil {
+		t.Fatalf("authService.EnrollTOTP() error = %v", err)
+	}
+
+	if _, err := a.ConfirmTOTP(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("authService.ConfirmTOTP() error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	step := totp.Step(time.Now())
+
+	codes, err := a.ConfirmTOTP(ctx, user.ID, totpCode(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatalf("authService.ConfirmTOTP() error = %v", err)
+	}
+
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("authService.ConfirmTOTP() = %v codes, want %v", len(codes), RecoveryCodeCount)
+	}
+
+	if _, err := a.EnrollTOTP(ctx, user); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Errorf("authService.EnrollTOTP() error = %v, want %v", err, ErrTOTPAlreadyEnabled)
+	}
+
+	// the password alone only gets a challenge
+	challenge, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "password"})
+	if err != nil {
+		t.Fatalf("authService.Login() error = %v", err)
+	}
+
+	if challenge.MFAToken == "" || challenge.AccessToken != "" {
+		t.Fatalf("authService.Login() = %+v, want an MFA challenge only", challenge)
+	}
+
+	if _, err := a.VerifyToken(ctx, challenge.MFAToken); err == nil {
+		t.Errorf("authService.VerifyToken() accepted an MFA challenge")
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "Replayed Code", code: totpCode(t, enrollment.Secret, step), wantErr: ErrInvalidMFACode},
+		{name: "Wrong Code", code: "000000", wantErr: ErrInvalidMFACode},
+		{name: "Recovery Code", code: codes[0]},
+		{name: "Used Recovery Code", code: codes[0], wantErr: ErrInvalidMFACode},
+		{name: "Recovery Code Typed Differently", code: " " + codes[1][:4] + codes[1][5:]},
+		{name: "Next Code", code: totpCode(t, enrollment.Secret, step+1)},
+	}
+	for _, tt := range tests {
+		challenge, err := a.issueMFAChallenge(user)
+		if err != nil {
+			t.Fatalf("authService.issueMFAChallenge() error = %v", err)
+		}
+
+		token, err := a.VerifyMFA(ctx, challenge.MFAToken, tt.code)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: authService.VerifyMFA() error = %v, want %v", tt.name, err, tt.wantErr)
+
+			continue
+		}
+
+		if tt.wantErr == nil && token.AccessToken == "" {
+			t.Errorf("%s: authService.VerifyMFA() = %+v, want a token pair", tt.name, token)
+		}
+	}
+
+	if err := a.DisableTOTP(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("authService.DisableTOTP() error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	if err := a.DisableTOTP(ctx, user.ID, codes[2]); err != nil {
+		t.Fatalf("authService.DisableTOTP() error = %v", err)
+	}
+
+	token, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "password"})
+	if err != nil || token.AccessToken == "" {
+		t.Errorf("authService.Login() = %+v, %v, want a token pair", token, err)
+	}
+}
+
+func Test_authService_VerifyMFA(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	user := &domain.User{ID: "1", Username: "username"}
+	mfaRepo := &MFARepositoryMock{
+		factors: map[string]*domain.TOTPFactor{
+			"1": {UserID: "1", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", ConfirmedAt: &time.Time{}},
+		},
+	}
+	a := newMFAService(mfaRepo)
+
+	challenge, err := a.issueMFAChallenge(user)
+	if err != nil {
+		t.Fatalf("authService.issueMFAChallenge() error = %v", err)
+	}
+
+	for i := 0; i < MaxMFAAttempts; i++ {
+		if _, err := a.VerifyMFA(ctx, challenge.MFAToken, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("authService.VerifyMFA() error = %v, want %v", err, ErrInvalidMFACode)
+		}
+	}
+
+	// the challenge is burnt, even with the right code
+	code := totpCode(t, mfaRepo.factors["1"].Secret, totp.Step(time.Now()))
+	if _, err := a.VerifyMFA(ctx, challenge.MFAToken, code); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyMFA() error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	accessToken, err := a.generateToken(ctx, user, "", "")
+	if err != nil {
+		t.Fatalf("authService.generateToken() error = %v", err)
+	}
+
+	if _, err := a.VerifyMFA(ctx, accessToken.AccessToken, code); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyMFA() with an access token error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if _, err := (&authService{}).VerifyMFA(ctx, challenge.MFAToken, code); !errors.Is(err, ErrMFANotSupported) {
+		t.Errorf("authService.VerifyMFA() error = %v, want %v", err, ErrMFANotSupported)
+	}
+}
+
+var _ domain.MFARepository = &MFARepositoryMock{}
+
+type MFARepositoryMock struct {
+	hasError bool
+
+	mu            sync.Mutex
+	factors       map[string]*domain.TOTPFactor
+	recoveryCodes map[string]map[string]bool
+}
+
+func (m *MFARepositoryMock) FindTOTP(
+	_ context.Context,
+	userID string,
+) (*domain.TOTPFactor, error) {
+	if m.hasError {
+		return nil, errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	factor, ok := m.factors[userID]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("TOTPFactor", "user_id="+userID)
+	}
+
+	found := *factor
+
+	return &found, nil
+}
+
+func (m *MFARepositoryMock) SaveTOTP(
+	_ context.Context,
+	factor *domain.TOTPFactor,
+) error {
+	if m.hasError {
+		return errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.factors == nil {
+		m.factors = make(map[string]*domain.TOTPFactor)
+	}
+
+	if existing, ok := m.factors[factor.UserID]; ok && existing.Confirmed() {
+		return nil
+	}
+
+	saved := *factor
+	m.factors[factor.UserID] = &saved
+
+	return nil
+}
+
+func (m *MFARepositoryMock) ConfirmTOTP(
+	_ context.Context,
+	userID string,
+	step int64,
+) error {
+	if m.hasError {
+		return errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.factors[userID].ConfirmedAt = &now
+	m.factors[userID].LastUsedStep = step
+
+	return nil
+}
+
+func (m *MFARepositoryMock) UseTOTPStep(
+	_ context.Context,
+	userID string,
+	step int64,
+) (bool, error) {
+	if m.hasError {
+		return false, errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	factor := m.factors[userID]
+	if factor.LastUsedStep >= step {
+		return false, nil
+	}
+
+	factor.LastUsedStep = step
+
+	return true, nil
+}
+
+func (m *MFARepositoryMock) DeleteTOTP(
+	_ context.Context,
+	userID string,
+) error {
+	if m.hasError {
+		return errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.factors, userID)
+	delete(m.recoveryCodes, userID)
+
+	return nil
+}
+
+func (m *MFARepositoryMock) ReplaceRecoveryCodes(
+	_ context.Context,
+	userID string,
+	codeHashes []string,
+) error {
+	if m.hasError {
+		return errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.recoveryCodes == nil {
+		m.recoveryCodes = make(map[string]map[string]bool)
+	}
+
+	m.recoveryCodes[userID] = make(map[string]bool, len(codeHashes))
+
+	for _, codeHash := range codeHashes {
+		m.recoveryCodes[userID][codeHash] = false
+	}
+
+	return nil
+}
+
+func (m *MFARepositoryMock) UseRecoveryCode(
+	_ context.Context,
+	userID string,
+	codeHash string,
+) (bool, error) {
+	if m.hasError {
+		return false, errors.New("error")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used, ok := m.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+
+	m.recoveryCodes[userID][codeHash] = true
+
+	return true, nil
+}
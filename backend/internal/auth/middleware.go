@@ -2,55 +2,229 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+
+	"goadmin-backend/internal/domain"
 )
 
 type contextKey string
 
-const userKey = contextKey("user")
+const (
+	userKey           = contextKey("user")
+	apiKeyKey         = contextKey("api_key")
+	serviceAccountKey = contextKey("service_account")
+	actorKey          = contextKey("actor")
+	tokenKey          = contextKey("token")
+)
+
+// TokenSource names where a request carries its access token or API key.
+type TokenSource string
+
+const (
+	TokenSourceHeader       TokenSource = "header"
+	TokenSourceAPIKeyHeader TokenSource = "api_key_header"
+	TokenSourceCookie       TokenSource = "cookie"
+	TokenSourceQuery        TokenSource = "query"
+)
+
+var ErrUnknownTokenSource = errors.New("unknown token source")
+
+// tokenExtractors find the credential of a request at each source.
+var tokenExtractors = map[TokenSource]func(*http.Request) string{
+	TokenSourceHeader:       TokenFromHeader,
+	TokenSourceAPIKeyHeader: TokenFromAPIKeyHeader,
+	TokenSourceCookie:       TokenFromCookie,
+	TokenSourceQuery:        TokenFromQuery,
+}
+
+// defaultTokenSources are where the Authenticator looks unless told
+// otherwise. Tokens in URLs end up in access logs and browser histories, so
+// TokenSourceQuery has to be configured explicitly.
+var defaultTokenSources = []TokenSource{TokenSourceHeader, TokenSourceAPIKeyHeader, TokenSourceCookie}
 
-func (h *Handler) Authenticator() func(http.Handler) http.Handler {
+// ParseTokenSource returns the token source named s.
+func ParseTokenSource(s string) (TokenSource, error) {
+	source := TokenSource(s)
+	if _, ok := tokenExtractors[source]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownTokenSource, s)
+	}
+
+	return source, nil
+}
+
+// requestToken is the credential the Authenticator found for a request.
+type requestToken struct {
+	value  string
+	source TokenSource
+}
+
+// Authenticator only lets through requests carrying a valid access token or
+// API key, and puts the user into the request context. Requests of service
+// accounts get the service account instead of a user, and requests with an
+// impersonation token get the admin acting as the user too. The credential
+// is only looked for at the sources of the route among the token sources of
+// the handler, or at all of those when the route names none.
+func (h *Handler) Authenticator(sources ...TokenSource) func(http.Handler) http.Handler {
 	// returns middleware
 	return func(next http.Handler) http.Handler {
 		// returns HandlerFunc
 		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-			tokenString := FindToken(req)
+			tokenString, source := findToken(req, h.allowedTokenSources(sources))
 			if tokenString == "" {
 				http.Error(res, "Unauthorized", http.StatusUnauthorized)
 
 				return
 			}
 
-			ctx := req.Context()
-
-			user, err := h.authService.VerifyToken(ctx, tokenString)
+			newCtx, err := h.authenticate(req.Context(), tokenString)
 			if err != nil {
 				http.Error(res, "Unauthorized", http.StatusUnauthorized)
 
 				return
 			}
 
-			// create new context with user value
-			newCtx := context.WithValue(ctx, userKey, *user)
+			newCtx = context.WithValue(newCtx, tokenKey, requestToken{value: tokenString, source: source})
 
 			next.ServeHTTP(res, req.WithContext(newCtx))
 		})
 	}
 }
 
+// authenticate returns a context with the user the token belongs to, and the
+// API key when the token is one or the admin impersonating the user, or with
+// the service account of the token.
+func (h *Handler) authenticate(ctx context.Context, tokenString string) (context.Context, error) {
+	if tokenPrincipal(tokenString) == domain.PrincipalTypeServiceAccount {
+		account, err := h.authService.VerifyServiceAccountToken(ctx, tokenString)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // only tells the request is unauthorized
+		}
+
+		return context.WithValue(ctx, serviceAccountKey, account), nil
+	}
+
+	if IsAPIKey(tokenString) {
+		user, apiKey, err := h.authService.VerifyAPIKey(ctx, tokenString)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // only tells the request is unauthorized
+		}
+
+		ctx = context.WithValue(ctx, apiKeyKey, apiKey)
+
+		return context.WithValue(ctx, userKey, *user), nil
+	}
+
+	user, err := h.authService.VerifyToken(ctx, tokenString)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // only tells the request is unauthorized
+	}
+
+	if actor := tokenActor(tokenString); actor != nil {
+		ctx = context.WithValue(ctx, actorKey, actor)
+	}
+
+	// create new context with user value
+	return context.WithValue(ctx, userKey, *user), nil
+}
+
+// UserFromContext returns the user the Authenticator middleware put into the
+// request context.
+func UserFromContext(ctx context.Context) (domain.User, bool) {
+	user, ok := ctx.Value(userKey).(domain.User)
+
+	return user, ok
+}
+
+// APIKeyFromContext returns the API key the request was authenticated with,
+// if it was not an access token.
+func APIKeyFromContext(ctx context.Context) (*domain.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyKey).(*domain.APIKey)
+
+	return apiKey, ok
+}
+
+// ActorFromContext returns the admin impersonating the user the request was
+// authenticated as, if any.
+func ActorFromContext(ctx context.Context) (*domain.TokenActor, bool) {
+	actor, ok := ctx.Value(actorKey).(*domain.TokenActor)
+
+	return actor, ok
+}
+
+// ServiceAccountFromContext returns the service account the request was
+// authenticated as, if it was not a user. Its Scopes are those of the token.
+func ServiceAccountFromContext(ctx context.Context) (*domain.ServiceAccount, bool) {
+	account, ok := ctx.Value(serviceAccountKey).(*domain.ServiceAccount)
+
+	return account, ok
+}
+
+// allowedTokenSources returns the sources of a route the handler allows, in
+// the order of the handler. The handler allows its own sources, or else the
+// default ones, and routes without sources get all of them.
+func (h *Handler) allowedTokenSources(route []TokenSource) []TokenSource {
+	sources := h.tokenSources
+	if len(sources) == 0 {
+		sources = defaultTokenSources
+	}
+
+	if len(route) == 0 {
+		return sources
+	}
+
+	allowed := make([]TokenSource, 0, len(route))
+
+	for _, source := range sources {
+		if slices.Contains(route, source) {
+			allowed = append(allowed, source)
+		}
+	}
+
+	return allowed
+}
+
+// tokenSourceFromContext returns where the Authenticator found the
+// credential of the request.
+func tokenSourceFromContext(ctx context.Context) (TokenSource, bool) {
+	token, ok := ctx.Value(tokenKey).(requestToken)
+
+	return token.source, ok
+}
+
+// FindToken looks for a credential at the default sources, which leave the
+// query string out.
 func FindToken(req *http.Request) string {
-	for _, f := range []func(*http.Request) string{
-		TokenFromQuery,
-		TokenFromHeader,
-		TokenFromCookie,
-	} {
-		if token := f(req); token != "" {
-			return token
+	token, _ := findToken(req, defaultTokenSources)
+
+	return token
+}
+
+// findToken returns the first credential found at sources, and where.
+func findToken(req *http.Request, sources []TokenSource) (string, TokenSource) {
+	for _, source := range sources {
+		if token := tokenExtractors[source](req); token != "" {
+			return token, source
 		}
 	}
 
-	return ""
+	return "", ""
+}
+
+// tokenFromRequest returns the credential the Authenticator accepted, or
+// looks for one at the sources of the handler when the request did not go
+// through it.
+func (h *Handler) tokenFromRequest(req *http.Request) string {
+	if token, ok := req.Context().Value(tokenKey).(requestToken); ok {
+		return token.value
+	}
+
+	token, _ := findToken(req, h.allowedTokenSources(nil))
+
+	return token
 }
 
 // TokenFromHeader tries to retrieve the token string from the
@@ -65,6 +239,12 @@ func TokenFromHeader(req *http.Request) string {
 	return ""
 }
 
+// TokenFromAPIKeyHeader tries to retrieve an API key from the "X-API-Key"
+// request header, for clients that cannot send bearer tokens.
+func TokenFromAPIKeyHeader(req *http.Request) string {
+	return req.Header.Get("X-API-Key")
+}
+
 // TokenFromQuery tries to retrieve the token string from the "jwt" URI
 // query parameter.
 //
@@ -83,7 +263,7 @@ func TokenFromQuery(req *http.Request) string {
 // TokenFromCookie tries to retrieve the token string from a cookie named
 // "jwt".
 func TokenFromCookie(req *http.Request) string {
-	cookie, err := req.Cookie("jwt")
+	cookie, err := req.Cookie(accessTokenCookie)
 	if err != nil {
 		return ""
 	}
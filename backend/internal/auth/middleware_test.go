@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 
 	"goadmin-backend/internal/domain"
 )
@@ -17,6 +20,10 @@ func newRequestWithToken(token, whereIsToken string) *http.Request {
 		}
 	} else if whereIsToken == "query" {
 		req.URL.RawQuery = "jwt=" + token
+	} else if whereIsToken == "api-key" {
+		req.Header = http.Header{
+			"X-Api-Key": []string{token},
+		}
 	} else if whereIsToken == "cookie" {
 		req.AddCookie(&http.Cookie{
 			Name:  "jwt",
@@ -27,6 +34,31 @@ func newRequestWithToken(token, whereIsToken string) *http.Request {
 	return req
 }
 
+// serviceAccountToken returns a token claiming to be of a service account;
+// the ServiceMock does not check signatures.
+func serviceAccountToken() string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &domain.JWTClaims{
+		TokenType:     domain.TokenTypeAccess,
+		PrincipalType: domain.PrincipalTypeServiceAccount,
+	}).SignedString([]byte("secret"))
+
+	return token
+}
+
+// impersonationToken returns a token of an admin acting as the user; the
+// ServiceMock does not check signatures.
+func impersonationToken(userID string) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &domain.JWTClaims{
+		TokenType: domain.TokenTypeAccess,
+		Actor:     &domain.TokenActor{Subject: "2", Username: "admin"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: userID,
+		},
+	}).SignedString([]byte("secret"))
+
+	return token
+}
+
 func TestHandler_Authenticator(t *testing.T) {
 	t.Parallel()
 
@@ -35,11 +67,13 @@ func TestHandler_Authenticator(t *testing.T) {
 	}
 
 	tests := []struct {
-		name       string
-		fields     fields
-		req        *http.Request
-		wantStatus int
-		want       string
+		name         string
+		fields       fields
+		sources      []TokenSource
+		routeSources []TokenSource
+		req          *http.Request
+		wantStatus   int
+		want         string
 	}{
 		{
 			name: "Test Authenticator() with valid token in header",
@@ -55,10 +89,60 @@ func TestHandler_Authenticator(t *testing.T) {
 			fields: fields{
 				authService: &ServiceMock{},
 			},
+			sources:    []TokenSource{TokenSourceQuery},
 			req:        newRequestWithToken("good_token", "query"),
 			wantStatus: http.StatusOK,
 			want:       "OK\n",
 		},
+		{
+			name: "Test Authenticator() with token in query by default",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			req:        newRequestWithToken("good_token", "query"),
+			wantStatus: http.StatusUnauthorized,
+			want:       "Unauthorized\n",
+		},
+		{
+			name: "Test Authenticator() with token in cookie of a header only handler",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			sources:    []TokenSource{TokenSourceHeader},
+			req:        newRequestWithToken("good_token", "cookie"),
+			wantStatus: http.StatusUnauthorized,
+			want:       "Unauthorized\n",
+		},
+		{
+			name: "Test Authenticator() with token in cookie of a header only route",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			routeSources: []TokenSource{TokenSourceHeader, TokenSourceAPIKeyHeader},
+			req:          newRequestWithToken("good_token", "cookie"),
+			wantStatus:   http.StatusUnauthorized,
+			want:         "Unauthorized\n",
+		},
+		{
+			name: "Test Authenticator() with valid token in header of a header only route",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			routeSources: []TokenSource{TokenSourceHeader, TokenSourceAPIKeyHeader},
+			req:          newRequestWithToken("good_token", "header"),
+			wantStatus:   http.StatusOK,
+			want:         "OK\n",
+		},
+		{
+			name: "Test Authenticator() with token in query of a route the handler does not allow it for",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			routeSources: []TokenSource{TokenSourceHeader, TokenSourceQuery},
+			req:          newRequestWithToken("good_token", "query"),
+			wantStatus:   http.StatusUnauthorized,
+			want:         "Unauthorized\n",
+		},
 		{
 			name: "Test Authenticator() with valid token in cookie",
 			fields: fields{
@@ -68,6 +152,33 @@ func TestHandler_Authenticator(t *testing.T) {
 			wantStatus: http.StatusOK,
 			want:       "OK\n",
 		},
+		{
+			name: "Test Authenticator() with api key in header",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			req:        newRequestWithToken(APIKeyPrefix+"good_key", "api-key"),
+			wantStatus: http.StatusOK,
+			want:       "OK api key 1\n",
+		},
+		{
+			name: "Test Authenticator() with service account token in header",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			req:        newRequestWithToken(serviceAccountToken(), "header"),
+			wantStatus: http.StatusOK,
+			want:       "OK service account sa_client\n",
+		},
+		{
+			name: "Test Authenticator() with impersonation token in header",
+			fields: fields{
+				authService: &ServiceMock{},
+			},
+			req:        newRequestWithToken(impersonationToken("1"), "header"),
+			wantStatus: http.StatusOK,
+			want:       "OK impersonated by admin\n",
+		},
 		{
 			name: "Test Authenticator() with invalid token in header",
 			fields: fields{
@@ -96,14 +207,33 @@ func TestHandler_Authenticator(t *testing.T) {
 			t.Parallel()
 
 			h := &Handler{
-				authService: tt.fields.authService,
+				authService:  tt.fields.authService,
+				tokenSources: tt.sources,
 			}
 
-			handler := h.Authenticator()(
+			handler := h.Authenticator(tt.routeSources...)(
 				http.HandlerFunc(
-					func(res http.ResponseWriter, _ *http.Request) {
-						// Do nothing
+					func(res http.ResponseWriter, req *http.Request) {
 						t.Log("Auth passed")
+
+						if account, ok := ServiceAccountFromContext(req.Context()); ok {
+							res.Write([]byte("OK service account " + account.ClientID + "\n"))
+
+							return
+						}
+
+						if apiKey, ok := APIKeyFromContext(req.Context()); ok {
+							res.Write([]byte("OK api key " + apiKey.ID + "\n"))
+
+							return
+						}
+
+						if actor, ok := ActorFromContext(req.Context()); ok {
+							res.Write([]byte("OK impersonated by " + actor.Username + "\n"))
+
+							return
+						}
+
 						res.Write([]byte("OK\n"))
 					},
 				),
@@ -151,6 +281,20 @@ func TestFindToken(t *testing.T) {
 			},
 			want: "good_token",
 		},
+		{
+			name: "API Key Header",
+			args: args{
+				req: newRequestWithToken(APIKeyPrefix+"good_key", "api-key"),
+			},
+			want: APIKeyPrefix + "good_key",
+		},
+		{
+			name: "Query",
+			args: args{
+				req: newRequestWithToken("good_token", "query"),
+			},
+			want: "",
+		},
 		{
 			name: "Fail",
 			args: args{
@@ -387,3 +531,553 @@ func (s *ServiceMock) Profile(
 		ID: "1",
 	}, nil
 }
+
+func (s *ServiceMock) JWKS() *JWKSet {
+	return &JWKSet{Keys: []JWK{}}
+}
+
+func (s *ServiceMock) ListSessions(
+	_ context.Context,
+	_ string,
+) ([]*domain.Session, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*domain.Session{{ID: "1", UserID: "1"}}, nil
+}
+
+func (s *ServiceMock) RevokeSession(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) LogoutAll(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) ForgotPassword(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) ResendVerificationEmail(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) ResetPassword(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) VerifyEmail(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) VerifyMFA(
+	_ context.Context,
+	_ string,
+	_ string,
+) (*domain.JWTToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &domain.JWTToken{
+		AccessToken: "good_token",
+	}, nil
+}
+
+func (s *ServiceMock) EnrollTOTP(
+	_ context.Context,
+	_ *domain.User,
+) (*TOTPEnrollment, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          "SECRET",
+		ProvisioningURI: "otpauth://totp/GoAdmin:1?secret=SECRET",
+	}, nil
+}
+
+func (s *ServiceMock) ConfirmTOTP(
+	_ context.Context,
+	_ string,
+	_ string,
+) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []string{"aaaa-bbbb"}, nil
+}
+
+func (s *ServiceMock) DisableTOTP(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) RegenerateRecoveryCodes(
+	_ context.Context,
+	_ string,
+	_ string,
+) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []string{"aaaa-bbbb"}, nil
+}
+
+func (s *ServiceMock) UnlockUser(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) HasRole(
+	_ context.Context,
+	userID string,
+	_ ...string,
+) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+
+	return userID == "1", nil
+}
+
+func (s *ServiceMock) ChangePassword(
+	_ context.Context,
+	_, _, _ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) SetPassword(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) CreateAPIKey(
+	_ context.Context,
+	userID, name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (*domain.APIKey, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+
+	return &domain.APIKey{
+		ID:        "1",
+		UserID:    userID,
+		Name:      name,
+		Prefix:    "gak_abcdefgh",
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}, "gak_abcdefgh", nil
+}
+
+func (s *ServiceMock) ListAPIKeys(
+	_ context.Context,
+	userID string,
+) ([]*domain.APIKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*domain.APIKey{{ID: "1", UserID: userID, Name: "ci", Prefix: "gak_abcdefgh"}}, nil
+}
+
+func (s *ServiceMock) RevokeAPIKey(
+	_ context.Context,
+	_ string,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) VerifyAPIKey(
+	_ context.Context,
+	_ string,
+) (*domain.User, *domain.APIKey, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	return &domain.User{ID: "1"}, &domain.APIKey{
+		ID:     "1",
+		UserID: "1",
+		Scopes: []string{ScopeUsersRead},
+	}, nil
+}
+
+func (s *ServiceMock) CreateServiceAccount(
+	_ context.Context,
+	name string,
+	scopes []string,
+	_ string,
+) (*domain.ServiceAccount, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+
+	return &domain.ServiceAccount{ID: "1", Name: name, ClientID: "sa_client", Scopes: scopes}, "secret", nil
+}
+
+func (s *ServiceMock) ListServiceAccounts(
+	_ context.Context,
+) ([]*domain.ServiceAccount, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*domain.ServiceAccount{{ID: "1", Name: "billing", ClientID: "sa_client"}}, nil
+}
+
+func (s *ServiceMock) DeleteServiceAccount(
+	_ context.Context,
+	_ string,
+) error {
+	return s.err
+}
+
+func (s *ServiceMock) ClientCredentials(
+	_ context.Context,
+	clientID, clientSecret string,
+	scopes []string,
+) (*domain.JWTToken, []string, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	if clientID != "sa_client" || clientSecret != "secret" {
+		return nil, nil, ErrInvalidClient
+	}
+
+	return &domain.JWTToken{AccessToken: "token"}, scopes, nil
+}
+
+func (s *ServiceMock) VerifyServiceAccountToken(
+	_ context.Context,
+	_ string,
+) (*domain.ServiceAccount, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &domain.ServiceAccount{ID: "1", ClientID: "sa_client", Scopes: []string{ScopeUsersRead}}, nil
+}
+
+func (s *ServiceMock) CreateOAuthClient(
+	_ context.Context,
+	name string,
+	redirectURIs []string,
+	scopes []string,
+	public bool,
+) (*domain.OAuthClient, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+
+	client := &domain.OAuthClient{
+		ID:           "1",
+		ClientID:     "client",
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+	}
+
+	if public {
+		return client, "", nil
+	}
+
+	secretHash := hashToken("secret")
+	client.ClientSecretHash = &secretHash
+
+	return client, "secret", nil
+}
+
+func (s *ServiceMock) ListOAuthClients(_ context.Context) ([]*domain.OAuthClient, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*domain.OAuthClient{
+		{ID: "1", ClientID: "client", Name: "wiki", RedirectURIs: []string{"https://wiki.example.com/callback"}},
+	}, nil
+}
+
+func (s *ServiceMock) DeleteOAuthClient(_ context.Context, _ string) error {
+	return s.err
+}
+
+func (s *ServiceMock) Authorize(
+	_ context.Context,
+	_ string,
+	authReq AuthorizationRequest,
+) (*AuthorizationResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if authReq.ClientID != "client" {
+		return nil, ErrInvalidClient
+	}
+
+	return &AuthorizationResult{
+		Client:          &domain.OAuthClient{ClientID: "client", Name: "wiki"},
+		Scopes:          []string{ScopeOpenID},
+		ConsentRequired: true,
+	}, nil
+}
+
+func (s *ServiceMock) Consent(
+	_ context.Context,
+	_ string,
+	authReq AuthorizationRequest,
+	approve bool,
+) (*AuthorizationResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	result := &AuthorizationResult{
+		Client:     &domain.OAuthClient{ClientID: "client", Name: "wiki"},
+		RedirectTo: "https://wiki.example.com/callback?error=access_denied&state=" + authReq.State,
+	}
+
+	if approve {
+		result.RedirectTo = "https://wiki.example.com/callback?code=code&state=" + authReq.State
+	}
+
+	return result, nil
+}
+
+func (s *ServiceMock) ExchangeAuthorizationCode(
+	_ context.Context,
+	_, _ string,
+	code, _, _ string,
+) (*domain.JWTToken, []string, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	if code != "code" {
+		return nil, nil, ErrInvalidGrant
+	}
+
+	return &domain.JWTToken{AccessToken: "token", IDToken: "id-token"}, []string{ScopeOpenID}, nil
+}
+
+func (s *ServiceMock) UserInfo(_ context.Context, _ string) (*UserInfo, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &UserInfo{Subject: "1"}, nil
+}
+
+func (s *ServiceMock) OpenIDConfiguration() (*OpenIDConfiguration, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &OpenIDConfiguration{Issuer: "http://localhost:3600"}, nil
+}
+
+func (s *ServiceMock) OIDCProviders() []*OIDCProvider {
+	provider, _ := NewOIDCProvider(OIDCProviderConfig{
+		Name:     "okta",
+		Issuer:   "https://example.okta.com",
+		ClientID: "client",
+	}, nil)
+
+	return []*OIDCProvider{provider}
+}
+
+func (s *ServiceMock) SignInWithOIDC(
+	_ context.Context,
+	provider, idToken, _ string,
+) (*domain.JWTToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if provider != "okta" {
+		return nil, ErrUnknownProvider
+	}
+
+	if idToken != "id-token" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return &domain.JWTToken{AccessToken: "token", RefreshToken: "refresh"}, nil
+}
+
+func (s *ServiceMock) StartOIDCLogin(_ context.Context, provider, _ string) (*OIDCLogin, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if provider != "okta" {
+		return nil, ErrUnknownProvider
+	}
+
+	return &OIDCLogin{
+		AuthorizationURL: "https://example.okta.com/authorize?state=state",
+		FlowToken:        "flow-token",
+		ExpiresAt:        time.Now().Add(OIDCLoginDuration),
+	}, nil
+}
+
+func (s *ServiceMock) FinishOIDCLogin(
+	_ context.Context,
+	provider, flowToken string,
+	callback OIDCCallback,
+) (*OIDCLoginResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if provider != "okta" {
+		return nil, ErrUnknownProvider
+	}
+
+	if flowToken != "flow-token" || callback.State != "state" {
+		return nil, ErrInvalidLoginState
+	}
+
+	if callback.Error != "" {
+		return nil, &OIDCLoginError{Code: LoginErrorAccessDenied, RedirectTo: "/users", Err: ErrInvalidIDToken}
+	}
+
+	return &OIDCLoginResult{
+		Token:      &domain.JWTToken{AccessToken: "token", RefreshToken: "refresh"},
+		RedirectTo: "/users",
+	}, nil
+}
+
+func (s *ServiceMock) LoginRedirectURL() string {
+	return "http://localhost:3000/auth/callback"
+}
+
+func (s *ServiceMock) SAMLMetadata(connection string) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if connection != "adfs" {
+		return nil, ErrUnknownSAMLConnection
+	}
+
+	return []byte(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata"></EntityDescriptor>`), nil
+}
+
+func (s *ServiceMock) StartSAMLLogin(_ context.Context, connection, _ string) (*OIDCLogin, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if connection != "adfs" {
+		return nil, ErrUnknownSAMLConnection
+	}
+
+	return &OIDCLogin{
+		AuthorizationURL: "https://adfs.example.com/adfs/ls?SAMLRequest=request",
+		FlowToken:        "flow-token",
+		ExpiresAt:        time.Now().Add(OIDCLoginDuration),
+	}, nil
+}
+
+func (s *ServiceMock) FinishSAMLLogin(
+	_ context.Context,
+	connection, flowToken, samlResponse string,
+) (*OIDCLoginResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if connection != "adfs" {
+		return nil, ErrUnknownSAMLConnection
+	}
+
+	if flowToken != "flow-token" {
+		return nil, ErrInvalidLoginState
+	}
+
+	if samlResponse != "response" {
+		return nil, &OIDCLoginError{Code: LoginErrorInvalidAssertion, RedirectTo: "/users", Err: ErrInvalidSAMLResponse}
+	}
+
+	return &OIDCLoginResult{
+		Token:      &domain.JWTToken{AccessToken: "token", RefreshToken: "refresh"},
+		RedirectTo: "/users",
+	}, nil
+}
+
+func (s *ServiceMock) UserIdentities(_ context.Context, userID string) ([]*domain.UserIdentity, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*domain.UserIdentity{{ID: "1", UserID: userID, Provider: "okta", Subject: "okta-user-1"}}, nil
+}
+
+func (s *ServiceMock) LinkIdentity(
+	_ context.Context,
+	userID, provider, idToken, _ string,
+) (*domain.UserIdentity, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if provider != "okta" {
+		return nil, ErrUnknownProvider
+	}
+
+	if idToken != "id-token" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return &domain.UserIdentity{ID: "2", UserID: userID, Provider: provider, Subject: "okta-user-2"}, nil
+}
+
+func (s *ServiceMock) UnlinkIdentity(_ context.Context, _, _ string) error {
+	return s.err
+}
+
+func (s *ServiceMock) Impersonate(_ context.Context, _, userID, _ string) (*domain.JWTToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &domain.JWTToken{AccessToken: impersonationToken(userID)}, nil
+}
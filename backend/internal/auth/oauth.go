@@ -0,0 +1,727 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// ScopeOpenID, ScopeProfile and ScopeEmail are the OpenID Connect scopes
+	// OAuth clients can be granted.
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+
+	// GrantTypeAuthorizationCode is the grant OAuth clients exchange codes
+	// with.
+	GrantTypeAuthorizationCode = "authorization_code"
+
+	// ResponseTypeCode is the only response type of the authorization
+	// endpoint, and CodeChallengeMethodS256 the only PKCE method.
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+
+	// UserInfoAudience is the "aud" claim of access tokens issued to OAuth
+	// clients, which are good for the userinfo endpoint only.
+	UserInfoAudience = "goadmin-userinfo"
+
+	// AuthorizationCodeDuration is how long an authorization code can be
+	// exchanged.
+	AuthorizationCodeDuration = time.Minute
+
+	// authorizationCodeSize is the number of random bytes in a code.
+	authorizationCodeSize = 32
+)
+
+var (
+	ErrOAuthNotSupported  = errors.New("the OAuth2 authorization server is not enabled")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	ErrInvalidGrant       = errors.New("invalid grant")
+)
+
+// OAuthScopes returns every scope an OAuth client can be granted.
+func OAuthScopes() []string {
+	return []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+}
+
+// OAuthServerConfig configures the OAuth2/OpenID Connect authorization
+// server.
+type OAuthServerConfig struct {
+	// Issuer is the "iss" claim of ID tokens and the base URL of the
+	// endpoints advertised by the discovery document.
+	Issuer string
+	// AuthorizationEndpoint is the page users approve clients on. It calls
+	// the authorize API and follows the redirect it answers.
+	AuthorizationEndpoint string
+}
+
+// WithOAuthServer enables the OAuth2/OpenID Connect authorization server,
+// which lets registered clients sign users in.
+func WithOAuthServer(
+	clientRepo domain.OAuthClientRepository,
+	grantRepo domain.OAuthGrantRepository,
+	cfg OAuthServerConfig,
+) Option {
+	return func(a *authService) {
+		a.oauthClientRepo = clientRepo
+		a.oauthGrantRepo = grantRepo
+		a.oauthServer = cfg
+	}
+}
+
+func (a *authService) oauthEnabled() bool {
+	return a.oauthClientRepo != nil && a.oauthGrantRepo != nil && a.oauthServer.Issuer != ""
+}
+
+// AuthorizationRequest holds the parameters of an OAuth2 authorization
+// request (RFC 6749 section 4.1.1, RFC 7636 section 4.3).
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// AuthorizationResult tells the consent page what to do next: either ask
+// the user to approve Scopes for Client, or send the user agent to
+// RedirectTo, which carries the code or the error for the client.
+type AuthorizationResult struct {
+	Client          *domain.OAuthClient
+	Scopes          []string
+	ConsentRequired bool
+	RedirectTo      string
+}
+
+// UserInfoClaims are the OpenID Connect standard claims of a user. They are
+// given out according to the scopes granted.
+type UserInfoClaims struct {
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// UserInfo is the response of the userinfo endpoint.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	UserInfoClaims
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	Nonce           string `json:"nonce,omitempty"`
+	AuthTime        int64  `json:"auth_time"`
+	AuthorizedParty string `json:"azp"`
+	UserInfoClaims
+	jwt.RegisteredClaims
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document.
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// CreateOAuthClient registers an OAuth client. Confidential clients are
+// given a secret, which is only returned here; public clients get none.
+func (a *authService) CreateOAuthClient(
+	ctx context.Context,
+	name string,
+	redirectURIs []string,
+	scopes []string,
+	public bool,
+) (*domain.OAuthClient, string, error) {
+	if !a.oauthEnabled() {
+		return nil, "", ErrOAuthNotSupported
+	}
+
+	if len(redirectURIs) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one is required", ErrInvalidRedirectURI)
+	}
+
+	for _, redirectURI := range redirectURIs {
+		// RFC 6749 section 3.1.2: absolute and without a fragment
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidRedirectURI, redirectURI)
+		}
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(OAuthScopes(), scope) {
+			return nil, "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		scopes = OAuthScopes()
+	}
+
+	clientID, err := random.Token(clientIDSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate client id error %w", err)
+	}
+
+	client := &domain.OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       sortedScopes(scopes),
+	}
+
+	var secret string
+
+	if !public {
+		secret, err = random.Token(clientSecretSize)
+		if err != nil {
+			return nil, "", fmt.Errorf("generate client secret error %w", err)
+		}
+
+		secretHash := hashToken(secret)
+		client.ClientSecretHash = &secretHash
+	}
+
+	client, err = a.oauthClientRepo.Create(ctx, client)
+	if err != nil {
+		return nil, "", fmt.Errorf("create oauth client error %w", err)
+	}
+
+	return client, secret, nil
+}
+
+// ListOAuthClients returns every OAuth client
+func (a *authService) ListOAuthClients(ctx context.Context) ([]*domain.OAuthClient, error) {
+	if !a.oauthEnabled() {
+		return nil, ErrOAuthNotSupported
+	}
+
+	clients, err := a.oauthClientRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find oauth clients error %w", err)
+	}
+
+	return clients, nil
+}
+
+// DeleteOAuthClient removes an OAuth client along with the consents given
+// to it.
+func (a *authService) DeleteOAuthClient(ctx context.Context, id string) error {
+	if !a.oauthEnabled() {
+		return ErrOAuthNotSupported
+	}
+
+	if err := a.oauthClientRepo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete oauth client error %w", err)
+	}
+
+	return nil
+}
+
+// Authorize handles an authorization request of the user signed in with
+// tokenString. A code is issued right away when the user already consented
+// to the requested scopes; otherwise the result asks for consent.
+//
+// An unknown client or redirect URI is returned as ErrInvalidClient or
+// ErrInvalidRedirectURI, as the user must not be sent there; any other
+// problem with the request is reported to the client via RedirectTo.
+func (a *authService) Authorize(
+	ctx context.Context,
+	tokenString string,
+	authReq AuthorizationRequest,
+) (*AuthorizationResult, error) {
+	return a.authorize(ctx, tokenString, authReq, nil)
+}
+
+// Consent records the answer of the user to an authorization request and
+// issues a code when they approved it.
+func (a *authService) Consent(
+	ctx context.Context,
+	tokenString string,
+	authReq AuthorizationRequest,
+	approve bool,
+) (*AuthorizationResult, error) {
+	return a.authorize(ctx, tokenString, authReq, &approve)
+}
+
+func (a *authService) authorize(
+	ctx context.Context,
+	tokenString string,
+	authReq AuthorizationRequest,
+	approve *bool,
+) (*AuthorizationResult, error) {
+	if !a.oauthEnabled() {
+		return nil, ErrOAuthNotSupported
+	}
+
+	user, err := a.VerifyToken(ctx, tokenString)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	client, redirectURI, err := a.authorizationClient(ctx, authReq.ClientID, authReq.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AuthorizationResult{Client: client}
+
+	scopes, errCode, errDescription := checkAuthorizationRequest(client, authReq)
+	if errCode != "" {
+		result.RedirectTo = a.authorizationRedirect(redirectURI, url.Values{
+			"error":             {errCode},
+			"error_description": {errDescription},
+			"state":             {authReq.State},
+		})
+
+		return result, nil
+	}
+
+	result.Scopes = scopes
+
+	consent, err := a.oauthGrantRepo.FindConsent(ctx, user.ID, client.ClientID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			return nil, fmt.Errorf("find oauth consent error %w", err)
+		}
+
+		consent = &domain.OAuthConsent{UserID: user.ID, ClientID: client.ClientID}
+	}
+
+	switch {
+	case approve != nil && !*approve:
+		result.RedirectTo = a.authorizationRedirect(redirectURI, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"the user denied the request"},
+			"state":             {authReq.State},
+		})
+
+		return result, nil
+	case approve != nil:
+		consent.Scopes = sortedScopes(append(consent.Scopes, scopes...))
+
+		if err := a.oauthGrantRepo.SaveConsent(ctx, consent); err != nil {
+			return nil, fmt.Errorf("save oauth consent error %w", err)
+		}
+	case !containsAll(consent.Scopes, scopes):
+		result.ConsentRequired = true
+
+		return result, nil
+	}
+
+	code, err := random.Token(authorizationCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("generate authorization code error %w", err)
+	}
+
+	now := time.Now()
+
+	err = a.oauthGrantRepo.CreateCode(ctx, &domain.AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   authReq.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         authReq.Nonce,
+		CodeChallenge: authReq.CodeChallenge,
+		AuthTime:      a.authTime(ctx, tokenString),
+		ExpiresAt:     now.Add(AuthorizationCodeDuration),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create authorization code error %w", err)
+	}
+
+	result.RedirectTo = a.authorizationRedirect(redirectURI, url.Values{
+		"code":  {code},
+		"state": {authReq.State},
+	})
+
+	return result, nil
+}
+
+// authorizationClient returns the client of an authorization request and
+// the redirect URI to answer to, which must be registered. It may only be
+// left out when the client registered a single one.
+func (a *authService) authorizationClient(
+	ctx context.Context,
+	clientID, redirectURI string,
+) (*domain.OAuthClient, string, error) {
+	if clientID == "" {
+		return nil, "", ErrInvalidClient
+	}
+
+	client, err := a.oauthClientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, "", ErrInvalidClient
+		}
+
+		return nil, "", fmt.Errorf("find oauth client error %w", err)
+	}
+
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		return client, client.RedirectURIs[0], nil
+	}
+
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return nil, "", ErrInvalidRedirectURI
+	}
+
+	return client, redirectURI, nil
+}
+
+// checkAuthorizationRequest returns the scopes requested, or the OAuth2
+// error code and description when the request is invalid. PKCE with S256 is
+// required from every client, confidential ones included.
+func checkAuthorizationRequest(
+	client *domain.OAuthClient,
+	authReq AuthorizationRequest,
+) ([]string, string, string) {
+	if authReq.ResponseType != ResponseTypeCode {
+		return nil, "unsupported_response_type", "only the code response type is supported"
+	}
+
+	if authReq.CodeChallenge == "" {
+		return nil, "invalid_request", "code_challenge is required"
+	}
+
+	if authReq.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return nil, "invalid_request", "code_challenge_method must be S256"
+	}
+
+	// a S256 challenge is the base64url encoded SHA-256 hash of the verifier
+	if challenge, err := base64.RawURLEncoding.DecodeString(authReq.CodeChallenge); err != nil ||
+		len(challenge) != sha256.Size {
+		return nil, "invalid_request", "code_challenge is malformed"
+	}
+
+	scopes := strings.Fields(authReq.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, "invalid_scope", fmt.Sprintf("scope %q is not allowed", scope)
+		}
+	}
+
+	return sortedScopes(scopes), "", ""
+}
+
+// authorizationRedirect adds params to the redirect URI of the client, along
+// with our issuer identifier (RFC 9207).
+func (a *authService) authorizationRedirect(redirectURI string, params url.Values) string {
+	// the URI was checked when the client was registered
+	u, _ := url.Parse(redirectURI)
+
+	query := u.Query()
+	query.Set("iss", a.oauthServer.Issuer)
+
+	for key, values := range params {
+		if values[0] != "" {
+			query[key] = values
+		}
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// authTime returns when the user signed in: the start of their session, or
+// the issue time of the token when sessions are not tracked.
+func (a *authService) authTime(ctx context.Context, tokenString string) time.Time {
+	claims, err := a.parseToken(tokenString, domain.TokenTypeAccess, AccessTokenAudience)
+	if err != nil || claims.IssuedAt == nil {
+		return time.Now()
+	}
+
+	if a.sessionRepo != nil && claims.SessionID != "" {
+		if session, err := a.sessionRepo.FindByID(ctx, claims.SessionID); err == nil {
+			return session.CreatedAt
+		}
+	}
+
+	return claims.IssuedAt.Time
+}
+
+// ExchangeAuthorizationCode implements the authorization code grant: it
+// issues an access token, and an ID token when the openid scope was
+// granted, for a code and the PKCE verifier of its challenge. A code is
+// consumed by the first attempt, whether it succeeds or not.
+func (a *authService) ExchangeAuthorizationCode(
+	ctx context.Context,
+	clientID, clientSecret string,
+	code, redirectURI, codeVerifier string,
+) (*domain.JWTToken, []string, error) {
+	if !a.oauthEnabled() {
+		return nil, nil, ErrOAuthNotSupported
+	}
+
+	client, err := a.authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authCode, err := a.oauthGrantRepo.ConsumeCode(ctx, hashToken(code))
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, nil, ErrInvalidGrant
+		}
+
+		return nil, nil, fmt.Errorf("consume authorization code error %w", err)
+	}
+
+	now := time.Now()
+
+	switch {
+	case authCode.ClientID != client.ClientID:
+		return nil, nil, fmt.Errorf("%w: the code was issued to another client", ErrInvalidGrant)
+	case !now.Before(authCode.ExpiresAt):
+		return nil, nil, fmt.Errorf("%w: the code expired", ErrInvalidGrant)
+	// the redirect_uri of the authorization request, if it had one, has to
+	// be repeated (RFC 6749 section 4.1.3)
+	case redirectURI != authCode.RedirectURI:
+		return nil, nil, fmt.Errorf("%w: redirect_uri does not match", ErrInvalidGrant)
+	case !verifyCodeChallenge(authCode.CodeChallenge, codeVerifier):
+		return nil, nil, fmt.Errorf("%w: code_verifier does not match", ErrInvalidGrant)
+	}
+
+	user, err := a.userRepo.FindByID(ctx, authCode.UserID)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidGrant, err)
+	}
+
+	tokenID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate token id error %w", err)
+	}
+
+	accessToken, err := a.keyRing.Sign(&domain.JWTClaims{
+		Username:  user.Username,
+		TokenType: domain.TokenTypeAccess,
+		Scope:     strings.Join(authCode.Scopes, " "),
+		ClientID:  client.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(DefaultTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{UserInfoAudience},
+		},
+	})
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	token := &domain.JWTToken{AccessToken: accessToken}
+
+	if slices.Contains(authCode.Scopes, ScopeOpenID) {
+		token.IDToken, err = a.keyRing.Sign(&IDTokenClaims{
+			Nonce:           authCode.Nonce,
+			AuthTime:        authCode.AuthTime.Unix(),
+			AuthorizedParty: client.ClientID,
+			UserInfoClaims:  userInfoClaims(user, authCode.Scopes),
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   user.ID,
+				ExpiresAt: jwt.NewNumericDate(now.Add(DefaultTokenDuration)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				Issuer:    a.oauthServer.Issuer,
+				Audience:  jwt.ClaimStrings{client.ClientID},
+			},
+		})
+		if err != nil {
+			return nil, nil, err //nolint:wrapcheck // already wrapped by the key ring
+		}
+	}
+
+	return token, authCode.Scopes, nil
+}
+
+// authenticateOAuthClient checks the secret of a confidential client. Public
+// clients must not send one.
+func (a *authService) authenticateOAuthClient(
+	ctx context.Context,
+	clientID, clientSecret string,
+) (*domain.OAuthClient, error) {
+	client, err := a.oauthClientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, ErrInvalidClient
+		}
+
+		return nil, fmt.Errorf("find oauth client error %w", err)
+	}
+
+	if client.Public() {
+		if clientSecret != "" {
+			return nil, ErrInvalidClient
+		}
+
+		return client, nil
+	}
+
+	secretHash := hashToken(clientSecret)
+	if subtle.ConstantTimeCompare([]byte(secretHash), []byte(*client.ClientSecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// verifyCodeChallenge checks a PKCE verifier against its S256 challenge
+// (RFC 7636 section 4.6).
+func verifyCodeChallenge(challenge, verifier string) bool {
+	// RFC 7636 section 4.1 allows 43 to 128 characters
+	if len(verifier) < 43 || len(verifier) > 128 { //nolint:gomnd // RFC 7636 limits
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(verifier))
+	want := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	return subtle.ConstantTimeCompare([]byte(want), []byte(challenge)) == 1
+}
+
+// UserInfo returns the claims of the user an OAuth access token was issued
+// for, limited to the scopes granted.
+func (a *authService) UserInfo(ctx context.Context, tokenString string) (*UserInfo, error) {
+	if !a.oauthEnabled() {
+		return nil, ErrOAuthNotSupported
+	}
+
+	claims, err := a.parseToken(tokenString, domain.TokenTypeAccess, UserInfoAudience)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, ErrInvalidToken
+	}
+
+	isRevoked, err := a.revokedTokenRepo.IsRevoked(ctx, revocationID(claims, tokenString))
+	if err != nil {
+		return nil, fmt.Errorf("check revoked token error %w", err)
+	}
+
+	if isRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := a.userRepo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	return &UserInfo{Subject: user.ID, UserInfoClaims: userInfoClaims(user, scopes)}, nil
+}
+
+func userInfoClaims(user *domain.User, scopes []string) UserInfoClaims {
+	var claims UserInfoClaims
+
+	if slices.Contains(scopes, ScopeProfile) {
+		claims.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+		claims.PreferredUsername = user.Username
+		claims.Picture = user.Picture
+	}
+
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+
+	return claims
+}
+
+// OpenIDConfiguration returns the OpenID Connect discovery document.
+func (a *authService) OpenIDConfiguration() (*OpenIDConfiguration, error) {
+	if !a.oauthEnabled() {
+		return nil, ErrOAuthNotSupported
+	}
+
+	issuer := strings.TrimSuffix(a.oauthServer.Issuer, "/")
+
+	return &OpenIDConfiguration{
+		Issuer:                 a.oauthServer.Issuer,
+		AuthorizationEndpoint:  a.oauthServer.AuthorizationEndpoint,
+		TokenEndpoint:          issuer + "/oauth/token",
+		UserInfoEndpoint:       issuer + "/oauth/userinfo",
+		JWKSURI:                issuer + "/.well-known/jwks.json",
+		ScopesSupported:        OAuthScopes(),
+		ResponseTypesSupported: []string{ResponseTypeCode},
+		GrantTypesSupported: []string{
+			GrantTypeAuthorizationCode,
+			GrantTypeClientCredentials,
+		},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{a.keyRing.SigningAlg()},
+		TokenEndpointAuthMethodsSupported: []string{
+			"client_secret_basic",
+			"client_secret_post",
+			"none",
+		},
+		CodeChallengeMethodsSupported: []string{CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"name", "given_name", "family_name", "preferred_username",
+			"picture", "email", "email_verified",
+		},
+	}, nil
+}
+
+func sortedScopes(scopes []string) []string {
+	scopes = append([]string{}, scopes...)
+	slices.Sort(scopes)
+
+	return slices.Compact(scopes)
+}
+
+func containsAll(granted, scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
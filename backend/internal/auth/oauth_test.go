@@ -0,0 +1,692 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+const (
+	testIssuer       = "https://id.example.com"
+	testRedirectURI  = "https://wiki.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUpU1p1r_wW1gFWFOEjXk"
+)
+
+func testCodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// newOAuthService returns a service with a public client registered and the
+// access token of a signed in user.
+func newOAuthService(t *testing.T) (*authService, *domain.OAuthClient, string) {
+	t.Helper()
+
+	privatePEM, _ := newTestKeyPEM(t, "ES256")
+	key, _ := ParseSigningKey("oidc", "ES256", privatePEM, nil)
+
+	keyRing, err := NewKeyRing("oidc", key)
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          keyRing,
+		oauthClientRepo:  &OAuthClientRepositoryMock{},
+		oauthGrantRepo:   &OAuthGrantRepositoryMock{},
+		oauthServer: OAuthServerConfig{
+			Issuer:                testIssuer,
+			AuthorizationEndpoint: "https://app.example.com/oauth/authorize",
+		},
+	}
+
+	ctx := context.Background()
+
+	client, _, err := a.CreateOAuthClient(ctx, "wiki", []string{testRedirectURI}, nil, true)
+	if err != nil {
+		t.Fatalf("authService.CreateOAuthClient() error = %v", err)
+	}
+
+	token, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "password"})
+	if err != nil {
+		t.Fatalf("authService.Login() error = %v", err)
+	}
+
+	return a, client, token.AccessToken
+}
+
+func newAuthorizationRequest(clientID string) AuthorizationRequest {
+	return AuthorizationRequest{
+		ResponseType:        ResponseTypeCode,
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid email",
+		State:               "af0ifjsldkj",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       testCodeChallenge(testCodeVerifier),
+		CodeChallengeMethod: CodeChallengeMethodS256,
+	}
+}
+
+// authorizationCode approves an authorization request and returns the code
+// it was answered with.
+func authorizationCode(t *testing.T, a *authService, token string, authReq AuthorizationRequest) string {
+	t.Helper()
+
+	result, err := a.Consent(context.Background(), token, authReq, true)
+	if err != nil {
+		t.Fatalf("authService.Consent() error = %v", err)
+	}
+
+	redirectTo, _ := url.Parse(result.RedirectTo)
+
+	code := redirectTo.Query().Get("code")
+	if code == "" {
+		t.Fatalf("authService.Consent() redirects to %v, want a code", result.RedirectTo)
+	}
+
+	return code
+}
+
+func Test_authService_CreateOAuthClient(t *testing.T) {
+	t.Parallel()
+
+	a, _, _ := newOAuthService(t)
+
+	tests := []struct {
+		name         string
+		authService  *authService
+		redirectURIs []string
+		scopes       []string
+		public       bool
+		wantScopes   []string
+		wantErr      error
+	}{
+		{
+			name:         "Confidential",
+			authService:  a,
+			redirectURIs: []string{testRedirectURI},
+			scopes:       []string{ScopeProfile, ScopeOpenID, ScopeOpenID},
+			wantScopes:   []string{ScopeOpenID, ScopeProfile},
+		},
+		{
+			name:         "Public",
+			authService:  a,
+			redirectURIs: []string{"http://localhost:5173/callback"},
+			public:       true,
+			wantScopes:   []string{ScopeEmail, ScopeOpenID, ScopeProfile},
+		},
+		{name: "No Redirect URI", authService: a, wantErr: ErrInvalidRedirectURI},
+		{
+			name:         "Relative Redirect URI",
+			authService:  a,
+			redirectURIs: []string{"/callback"},
+			wantErr:      ErrInvalidRedirectURI,
+		},
+		{
+			name:         "Redirect URI With Fragment",
+			authService:  a,
+			redirectURIs: []string{testRedirectURI + "#top"},
+			wantErr:      ErrInvalidRedirectURI,
+		},
+		{
+			name:         "Unknown Scope",
+			authService:  a,
+			redirectURIs: []string{testRedirectURI},
+			scopes:       []string{ScopeUsersRead},
+			wantErr:      ErrUnknownScope,
+		},
+		{name: "Not Enabled", authService: &authService{}, wantErr: ErrOAuthNotSupported},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, secret, err := tt.authService.CreateOAuthClient(
+				context.Background(),
+				"app",
+				tt.redirectURIs,
+				tt.scopes,
+				tt.public,
+			)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.CreateOAuthClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if client.Public() != tt.public || (secret == "") != tt.public {
+				t.Errorf("authService.CreateOAuthClient() public = %v, secret %q", client.Public(), secret)
+			}
+
+			if !tt.public && *client.ClientSecretHash != hashToken(secret) {
+				t.Errorf("authService.CreateOAuthClient() stored %v, want the hash of the secret", *client.ClientSecretHash)
+			}
+
+			if !reflect.DeepEqual(client.Scopes, tt.wantScopes) {
+				t.Errorf("authService.CreateOAuthClient() scopes = %v, want %v", client.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func Test_authService_Authorize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a, client, token := newOAuthService(t)
+
+	result, err := a.Authorize(ctx, token, newAuthorizationRequest(client.ClientID))
+	if err != nil {
+		t.Fatalf("authService.Authorize() error = %v", err)
+	}
+
+	if !result.ConsentRequired || result.RedirectTo != "" ||
+		!reflect.DeepEqual(result.Scopes, []string{ScopeEmail, ScopeOpenID}) {
+		t.Fatalf("authService.Authorize() = %+v, want consent for email and openid", result)
+	}
+
+	denied, err := a.Consent(ctx, token, newAuthorizationRequest(client.ClientID), false)
+	if err != nil || !strings.Contains(denied.RedirectTo, "error=access_denied") {
+		t.Errorf("authService.Consent() = %+v, %v, want access_denied", denied, err)
+	}
+
+	approved, err := a.Consent(ctx, token, newAuthorizationRequest(client.ClientID), true)
+	if err != nil {
+		t.Fatalf("authService.Consent() error = %v", err)
+	}
+
+	redirectTo, _ := url.Parse(approved.RedirectTo)
+	if query := redirectTo.Query(); !strings.HasPrefix(approved.RedirectTo, testRedirectURI+"?") ||
+		query.Get("code") == "" || query.Get("state") != "af0ifjsldkj" || query.Get("iss") != testIssuer {
+		t.Errorf("authService.Consent() redirects to %v, want a code, the state and the issuer", approved.RedirectTo)
+	}
+
+	// the consent is remembered for the scopes approved
+	again, err := a.Authorize(ctx, token, newAuthorizationRequest(client.ClientID))
+	if err != nil || again.ConsentRequired || !strings.Contains(again.RedirectTo, "code=") {
+		t.Errorf("authService.Authorize() = %+v, %v, want a code without consent", again, err)
+	}
+
+	moreScopes := newAuthorizationRequest(client.ClientID)
+	moreScopes.Scope = "openid profile"
+
+	if more, err := a.Authorize(ctx, token, moreScopes); err != nil || !more.ConsentRequired {
+		t.Errorf("authService.Authorize() = %+v, %v, want consent for a new scope", more, err)
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(*AuthorizationRequest)
+		wantError string
+		wantErr   error
+	}{
+		{
+			name:      "Unsupported Response Type",
+			modify:    func(r *AuthorizationRequest) { r.ResponseType = "token" },
+			wantError: "unsupported_response_type",
+		},
+		{
+			name:      "No PKCE",
+			modify:    func(r *AuthorizationRequest) { r.CodeChallenge, r.CodeChallengeMethod = "", "" },
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Plain PKCE",
+			modify:    func(r *AuthorizationRequest) { r.CodeChallenge, r.CodeChallengeMethod = testCodeVerifier+"x", "plain" },
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Malformed Challenge",
+			modify:    func(r *AuthorizationRequest) { r.CodeChallenge = "short" },
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Scope Not Allowed",
+			modify:    func(r *AuthorizationRequest) { r.Scope = "openid " + ScopeUsersRead },
+			wantError: "invalid_scope",
+		},
+		{
+			name:    "Unknown Client",
+			modify:  func(r *AuthorizationRequest) { r.ClientID = "unknown" },
+			wantErr: ErrInvalidClient,
+		},
+		{
+			name:    "Unregistered Redirect URI",
+			modify:  func(r *AuthorizationRequest) { r.RedirectURI = "https://evil.example.com/callback" },
+			wantErr: ErrInvalidRedirectURI,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			authReq := newAuthorizationRequest(client.ClientID)
+			tt.modify(&authReq)
+
+			result, err := a.Authorize(ctx, token, authReq)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.Authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			redirectTo, _ := url.Parse(result.RedirectTo)
+			if got := redirectTo.Query().Get("error"); got != tt.wantError {
+				t.Errorf("authService.Authorize() redirects to %v, want error %v", result.RedirectTo, tt.wantError)
+			}
+		})
+	}
+
+	if _, err := a.Authorize(ctx, "not-a-token", newAuthorizationRequest(client.ClientID)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.Authorize() without a user error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func Test_authService_ExchangeAuthorizationCode(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a, client, token := newOAuthService(t)
+
+	tests := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		redirectURI  string
+		// noAuthRedirectURI leaves redirect_uri out of the authorization request
+		noAuthRedirectURI bool
+		codeVerifier      string
+		expire            bool
+		wantErr           error
+	}{
+		{name: "Success", clientID: client.ClientID, redirectURI: testRedirectURI, codeVerifier: testCodeVerifier},
+		{
+			name:              "Single Redirect URI Left Out",
+			clientID:          client.ClientID,
+			noAuthRedirectURI: true,
+			codeVerifier:      testCodeVerifier,
+		},
+		{
+			name:         "Redirect URI Of The Authorization Left Out",
+			clientID:     client.ClientID,
+			codeVerifier: testCodeVerifier,
+			wantErr:      ErrInvalidGrant,
+		},
+		{
+			name:              "Redirect URI Not In The Authorization",
+			clientID:          client.ClientID,
+			redirectURI:       testRedirectURI,
+			noAuthRedirectURI: true,
+			codeVerifier:      testCodeVerifier,
+			wantErr:           ErrInvalidGrant,
+		},
+		{
+			name:         "Wrong Verifier",
+			clientID:     client.ClientID,
+			redirectURI:  testRedirectURI,
+			codeVerifier: strings.Repeat("a", 43),
+			wantErr:      ErrInvalidGrant,
+		},
+		{name: "No Verifier", clientID: client.ClientID, redirectURI: testRedirectURI, wantErr: ErrInvalidGrant},
+		{
+			name:         "Wrong Redirect URI",
+			clientID:     client.ClientID,
+			redirectURI:  "https://wiki.example.com/other",
+			codeVerifier: testCodeVerifier,
+			wantErr:      ErrInvalidGrant,
+		},
+		{
+			name:         "Expired",
+			clientID:     client.ClientID,
+			redirectURI:  testRedirectURI,
+			codeVerifier: testCodeVerifier,
+			expire:       true,
+			wantErr:      ErrInvalidGrant,
+		},
+		{
+			name:         "Secret For Public Client",
+			clientID:     client.ClientID,
+			clientSecret: "secret",
+			redirectURI:  testRedirectURI,
+			codeVerifier: testCodeVerifier,
+			wantErr:      ErrInvalidClient,
+		},
+		{name: "Unknown Client", clientID: "unknown", codeVerifier: testCodeVerifier, wantErr: ErrInvalidClient},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			authReq := newAuthorizationRequest(client.ClientID)
+			if tt.noAuthRedirectURI {
+				authReq.RedirectURI = ""
+			}
+
+			code := authorizationCode(t, a, token, authReq)
+
+			if tt.expire {
+				a.oauthGrantRepo.(*OAuthGrantRepositoryMock).expire(hashToken(code))
+			}
+
+			issued, scopes, err := a.ExchangeAuthorizationCode(
+				ctx,
+				tt.clientID,
+				tt.clientSecret,
+				code,
+				tt.redirectURI,
+				tt.codeVerifier,
+			)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.ExchangeAuthorizationCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(scopes, []string{ScopeEmail, ScopeOpenID}) || issued.RefreshToken != "" {
+				t.Errorf("authService.ExchangeAuthorizationCode() = %+v, %v", issued, scopes)
+			}
+
+			// a code is only good once
+			_, _, err = a.ExchangeAuthorizationCode(ctx, tt.clientID, "", code, tt.redirectURI, tt.codeVerifier)
+			if !errors.Is(err, ErrInvalidGrant) {
+				t.Errorf("authService.ExchangeAuthorizationCode() reused error = %v, want %v", err, ErrInvalidGrant)
+			}
+		})
+	}
+}
+
+func Test_authService_IDToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a, client, token := newOAuthService(t)
+
+	code := authorizationCode(t, a, token, newAuthorizationRequest(client.ClientID))
+
+	issued, _, err := a.ExchangeAuthorizationCode(ctx, client.ClientID, "", code, testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatalf("authService.ExchangeAuthorizationCode() error = %v", err)
+	}
+
+	claims := &IDTokenClaims{}
+
+	_, err = jwt.ParseWithClaims(
+		issued.IDToken,
+		claims,
+		a.keyRing.Keyfunc,
+		jwt.WithIssuer(testIssuer),
+		jwt.WithAudience(client.ClientID),
+	)
+	if err != nil {
+		t.Fatalf("parse id token error = %v", err)
+	}
+
+	if claims.Subject != "1" || claims.Nonce != "n-0S6_WzA2Mj" || claims.AuthorizedParty != client.ClientID ||
+		claims.AuthTime == 0 {
+		t.Errorf("ID token claims = %+v", claims)
+	}
+
+	// the profile scope was not granted
+	if claims.PreferredUsername != "" || claims.EmailVerified == nil {
+		t.Errorf("ID token claims = %+v, want the email claims only", claims.UserInfoClaims)
+	}
+
+	userInfo, err := a.UserInfo(ctx, issued.AccessToken)
+	if err != nil || userInfo.Subject != "1" || userInfo.EmailVerified == nil {
+		t.Errorf("authService.UserInfo() = %+v, %v, want the email claims of user 1", userInfo, err)
+	}
+
+	// the token of the client is no good for the API, and vice versa
+	if _, err := a.VerifyToken(ctx, issued.AccessToken); err == nil {
+		t.Errorf("authService.VerifyToken() accepted a client token")
+	}
+
+	if _, err := a.UserInfo(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.UserInfo() of an API token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func Test_authService_ExchangeAuthorizationCode_Confidential(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a, _, token := newOAuthService(t)
+
+	client, secret, err := a.CreateOAuthClient(ctx, "crm", []string{testRedirectURI}, []string{ScopeProfile}, false)
+	if err != nil {
+		t.Fatalf("authService.CreateOAuthClient() error = %v", err)
+	}
+
+	authReq := newAuthorizationRequest(client.ClientID)
+	authReq.Scope = ""
+
+	if _, _, err := a.ExchangeAuthorizationCode(
+		ctx,
+		client.ClientID,
+		"",
+		authorizationCode(t, a, token, authReq),
+		testRedirectURI,
+		testCodeVerifier,
+	); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("authService.ExchangeAuthorizationCode() without a secret error = %v, want %v", err, ErrInvalidClient)
+	}
+
+	issued, scopes, err := a.ExchangeAuthorizationCode(
+		ctx,
+		client.ClientID,
+		secret,
+		authorizationCode(t, a, token, authReq),
+		testRedirectURI,
+		testCodeVerifier,
+	)
+	if err != nil {
+		t.Fatalf("authService.ExchangeAuthorizationCode() error = %v", err)
+	}
+
+	// without the openid scope this is plain OAuth2
+	if issued.IDToken != "" || !reflect.DeepEqual(scopes, []string{ScopeProfile}) {
+		t.Errorf("authService.ExchangeAuthorizationCode() = %+v, %v, want no ID token", issued, scopes)
+	}
+}
+
+func Test_authService_OpenIDConfiguration(t *testing.T) {
+	t.Parallel()
+
+	a, _, _ := newOAuthService(t)
+
+	cfg, err := a.OpenIDConfiguration()
+	if err != nil {
+		t.Fatalf("authService.OpenIDConfiguration() error = %v", err)
+	}
+
+	if cfg.Issuer != testIssuer || cfg.TokenEndpoint != testIssuer+"/oauth/token" ||
+		!reflect.DeepEqual(cfg.IDTokenSigningAlgValuesSupported, []string{"ES256"}) ||
+		!reflect.DeepEqual(cfg.CodeChallengeMethodsSupported, []string{CodeChallengeMethodS256}) {
+		t.Errorf("authService.OpenIDConfiguration() = %+v", cfg)
+	}
+
+	if _, err := (&authService{}).OpenIDConfiguration(); !errors.Is(err, ErrOAuthNotSupported) {
+		t.Errorf("authService.OpenIDConfiguration() error = %v, want %v", err, ErrOAuthNotSupported)
+	}
+}
+
+var _ domain.OAuthClientRepository = &OAuthClientRepositoryMock{}
+
+type OAuthClientRepositoryMock struct {
+	mu      sync.Mutex
+	nextID  int
+	clients map[string]*domain.OAuthClient
+}
+
+func (r *OAuthClientRepositoryMock) Create(
+	_ context.Context,
+	client *domain.OAuthClient,
+) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clients == nil {
+		r.clients = make(map[string]*domain.OAuthClient)
+	}
+
+	r.nextID++
+
+	created := *client
+	created.ID = strconv.Itoa(r.nextID)
+	created.CreatedAt = time.Now()
+	r.clients[created.ID] = &created
+
+	found := created
+
+	return &found, nil
+}
+
+func (r *OAuthClientRepositoryMock) FindByClientID(
+	_ context.Context,
+	clientID string,
+) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, client := range r.clients {
+		if client.ClientID == clientID {
+			found := *client
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("OAuthClient", "client_id="+clientID)
+}
+
+func (r *OAuthClientRepositoryMock) FindAll(_ context.Context) ([]*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := make([]*domain.OAuthClient, 0, len(r.clients))
+
+	for _, client := range r.clients {
+		found := *client
+		clients = append(clients, &found)
+	}
+
+	return clients, nil
+}
+
+func (r *OAuthClientRepositoryMock) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[id]; !ok {
+		return domain.NewResourceNotFoundError("OAuthClient", "id="+id)
+	}
+
+	delete(r.clients, id)
+
+	return nil
+}
+
+var _ domain.OAuthGrantRepository = &OAuthGrantRepositoryMock{}
+
+type OAuthGrantRepositoryMock struct {
+	mu       sync.Mutex
+	consents map[string]*domain.OAuthConsent
+	codes    map[string]*domain.AuthorizationCode
+}
+
+func (r *OAuthGrantRepositoryMock) expire(codeHash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.codes[codeHash].ExpiresAt = time.Now().Add(-time.Second)
+}
+
+func (r *OAuthGrantRepositoryMock) FindConsent(
+	_ context.Context,
+	userID, clientID string,
+) (*domain.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	consent, ok := r.consents[userID+"/"+clientID]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("OAuthConsent", "client_id="+clientID)
+	}
+
+	found := *consent
+
+	return &found, nil
+}
+
+func (r *OAuthGrantRepositoryMock) SaveConsent(_ context.Context, consent *domain.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.consents == nil {
+		r.consents = make(map[string]*domain.OAuthConsent)
+	}
+
+	saved := *consent
+	r.consents[consent.UserID+"/"+consent.ClientID] = &saved
+
+	return nil
+}
+
+func (r *OAuthGrantRepositoryMock) CreateCode(_ context.Context, code *domain.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.codes == nil {
+		r.codes = make(map[string]*domain.AuthorizationCode)
+	}
+
+	created := *code
+	r.codes[code.CodeHash] = &created
+
+	return nil
+}
+
+func (r *OAuthGrantRepositoryMock) ConsumeCode(
+	_ context.Context,
+	codeHash string,
+) (*domain.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("AuthorizationCode", "code_hash")
+	}
+
+	delete(r.codes, codeHash)
+
+	return code, nil
+}
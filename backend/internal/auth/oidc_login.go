@@ -0,0 +1,363 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// OIDCLoginDuration is how long users have to sign in at the provider
+	// once they were sent there.
+	OIDCLoginDuration = 10 * time.Minute
+
+	// OIDCLoginAudience is the "aud" claim of the flow tokens binding a
+	// sign-in started with a provider to the browser it was started in.
+	OIDCLoginAudience = "goadmin-oidc-login"
+
+	// oidcLoginSecretSize is the number of random bytes in the state, the
+	// nonce and the PKCE code verifier.
+	oidcLoginSecretSize = 32
+)
+
+// Codes of the errors a provider callback ends with, passed on to the
+// frontend.
+const (
+	LoginErrorAccessDenied   = "access_denied"
+	LoginErrorInvalidIDToken = "invalid_id_token"
+	LoginErrorUserNotFound   = "user_not_found"
+	LoginErrorServerError    = "server_error"
+
+	// LoginErrorIdentityNotLinked is the code of identities a user with their
+	// e-mail address has to link first.
+	LoginErrorIdentityNotLinked = "identity_not_linked"
+
+	// LoginErrorInvalidAssertion is the code of SAML responses that do not
+	// verify or lack the attributes of the user.
+	LoginErrorInvalidAssertion = "invalid_assertion"
+)
+
+var (
+	ErrOIDCLoginNotSupported = errors.New("sign-in with redirects is not enabled for the identity provider")
+	ErrInvalidLoginState     = errors.New("invalid sign-in state")
+	ErrInvalidRedirectTo     = errors.New("redirect_to must be a path of the frontend")
+)
+
+// OIDCLogin is a sign-in started with a provider. The browser is sent to
+// AuthorizationURL and keeps FlowToken, in a cookie, until the callback.
+type OIDCLogin struct {
+	AuthorizationURL string
+	FlowToken        string
+	ExpiresAt        time.Time
+}
+
+// OIDCCallback is what a provider redirects back with.
+type OIDCCallback struct {
+	State            string
+	Code             string
+	Error            string
+	ErrorDescription string
+}
+
+// OIDCLoginResult is a sign-in finished at the callback. RedirectTo is the
+// frontend path the sign-in was started from, if any.
+type OIDCLoginResult struct {
+	Token      *domain.JWTToken
+	RedirectTo string
+}
+
+// OIDCLoginError is a sign-in that failed after the callback was matched to
+// the browser it was started in, so that the user can be sent back to the
+// frontend with Code.
+type OIDCLoginError struct {
+	Code       string
+	RedirectTo string
+	Err        error
+}
+
+func (e *OIDCLoginError) Error() string {
+	return fmt.Sprintf("sign-in failed (%s): %v", e.Code, e.Err)
+}
+
+func (e *OIDCLoginError) Unwrap() error {
+	return e.Err
+}
+
+// oidcLoginClaims are the claims of a flow token.
+type oidcLoginClaims struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	RedirectTo   string `json:"redirect_to,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// oidcTokenResponse is the token endpoint response of a provider.
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// WithLoginRedirect sets the frontend page users land on after signing in
+// with redirects, which enables it. The outcome is passed in the URL
+// fragment.
+func WithLoginRedirect(loginRedirectURL string) Option {
+	return func(a *authService) {
+		a.loginRedirectURL = loginRedirectURL
+	}
+}
+
+// LoginRedirectURL returns the frontend page users land on after signing in
+// with redirects, or "" when it is not enabled.
+func (a *authService) LoginRedirectURL() string {
+	return a.loginRedirectURL
+}
+
+// StartOIDCLogin starts signing in with the authorization code flow of a
+// provider. The state, the nonce and the PKCE code verifier are kept in the
+// returned flow token. redirectTo is an optional frontend path the user is
+// sent back to.
+func (a *authService) StartOIDCLogin(
+	ctx context.Context,
+	providerName, redirectTo string,
+) (*OIDCLogin, error) {
+	provider, err := a.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.loginRedirectURL == "" || provider.cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("%w: %q", ErrOIDCLoginNotSupported, providerName)
+	}
+
+	if !validRedirectTo(redirectTo) {
+		return nil, ErrInvalidRedirectTo
+	}
+
+	discovery, err := provider.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]string, 4) //nolint:gomnd // token ID, state, nonce and code verifier
+	for i := range secrets {
+		if secrets[i], err = random.Token(oidcLoginSecretSize); err != nil {
+			return nil, fmt.Errorf("generate sign-in secret error %w", err)
+		}
+	}
+
+	now := time.Now()
+	claims := &oidcLoginClaims{
+		Provider:     provider.Name(),
+		State:        secrets[1],
+		Nonce:        secrets[2],
+		CodeVerifier: secrets[3],
+		RedirectTo:   redirectTo,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        secrets[0],
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCLoginDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{OIDCLoginAudience},
+		},
+	}
+
+	flowToken, err := a.keyRing.Sign(claims)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	challenge := sha256.Sum256([]byte(claims.CodeVerifier))
+	query := url.Values{
+		"response_type":         {ResponseTypeCode},
+		"client_id":             {provider.cfg.ClientID},
+		"redirect_uri":          {provider.cfg.RedirectURL},
+		"scope":                 {strings.Join(provider.cfg.Scopes, " ")},
+		"state":                 {claims.State},
+		"nonce":                 {claims.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	}
+
+	authorizationURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil || !authorizationURL.IsAbs() {
+		return nil, fmt.Errorf("%w: %s: invalid authorization endpoint", ErrProviderDiscovery, provider.Name())
+	}
+
+	// keep the parameters the provider put in its endpoint, e.g. a tenant
+	for name, values := range authorizationURL.Query() {
+		if _, ok := query[name]; !ok {
+			query[name] = values
+		}
+	}
+
+	authorizationURL.RawQuery = query.Encode()
+
+	return &OIDCLogin{
+		AuthorizationURL: authorizationURL.String(),
+		FlowToken:        flowToken,
+		ExpiresAt:        claims.ExpiresAt.Time,
+	}, nil
+}
+
+// FinishOIDCLogin finishes a sign-in started with StartOIDCLogin, at the
+// callback of the provider. The callback has to come back to the browser
+// holding the flow token, with its state; the code is then exchanged
+// server-side with the PKCE code verifier, and the ID token must carry the
+// nonce. Failures past the state check are *OIDCLoginError.
+func (a *authService) FinishOIDCLogin(
+	ctx context.Context,
+	providerName, flowToken string,
+	callback OIDCCallback,
+) (*OIDCLoginResult, error) {
+	provider, err := a.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &oidcLoginClaims{}
+	if err := a.parseFlowToken(flowToken, OIDCLoginAudience, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(callback.State)) != 1 {
+		return nil, fmt.Errorf("%w: state mismatch", ErrInvalidLoginState)
+	}
+
+	fail := func(code string, err error) (*OIDCLoginResult, error) {
+		return nil, &OIDCLoginError{Code: code, RedirectTo: claims.RedirectTo, Err: err}
+	}
+
+	if callback.Error != "" {
+		//nolint:goerr113 // the provider's own error
+		return fail(LoginErrorAccessDenied, fmt.Errorf("%s: %s", callback.Error, callback.ErrorDescription))
+	}
+
+	idToken, err := provider.exchangeCode(ctx, callback.Code, claims.CodeVerifier)
+	if err != nil {
+		return fail(LoginErrorInvalidIDToken, err)
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, idToken, claims.Nonce)
+	if err != nil {
+		return fail(LoginErrorInvalidIDToken, errors.Join(ErrInvalidIDToken, err))
+	}
+
+	token, err := a.signInWithIdentity(ctx, identity)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.Is(err, ErrInvalidIDToken):
+			return fail(LoginErrorInvalidIDToken, err)
+		case errors.As(err, &notFoundErr):
+			return fail(LoginErrorUserNotFound, err)
+		case errors.Is(err, ErrIdentityNotLinked):
+			return fail(LoginErrorIdentityNotLinked, err)
+		default:
+			return fail(LoginErrorServerError, err)
+		}
+	}
+
+	return &OIDCLoginResult{Token: token, RedirectTo: claims.RedirectTo}, nil
+}
+
+// validRedirectTo reports whether redirectTo, when given, is a path of the
+// frontend; an absolute URL would make us an open redirect.
+func validRedirectTo(redirectTo string) bool {
+	return redirectTo == "" || (strings.HasPrefix(redirectTo, "/") && !strings.HasPrefix(redirectTo, "//") &&
+		!strings.Contains(redirectTo, `\`))
+}
+
+// parseFlowToken verifies a flow token of the audience and reads its claims.
+func (a *authService) parseFlowToken(flowToken, audience string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(
+		flowToken,
+		claims,
+		a.keyRing.Keyfunc,
+		jwt.WithValidMethods(a.keyRing.ValidMethods()),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return errors.Join(ErrInvalidLoginState, err)
+	}
+
+	return nil
+}
+
+// exchangeCode exchanges an authorization code at the token endpoint of the
+// provider and returns the ID token.
+func (p *OIDCProvider) exchangeCode(ctx context.Context, code, codeVerifier string) (string, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+
+	// client_secret_basic is the default of OpenID Connect Discovery
+	methods := discovery.TokenEndpointAuthMethods
+	basicAuth := p.cfg.ClientSecret != "" && (len(methods) == 0 || slices.Contains(methods, "client_secret_basic"))
+
+	if p.cfg.ClientSecret != "" && !basicAuth {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("new request error %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if basicAuth {
+		// RFC 6749 section 2.3.1
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("post %s error %w", discovery.TokenEndpoint, err)
+	}
+	defer res.Body.Close()
+
+	var tokenRes oidcTokenResponse
+	if err := json.NewDecoder(http.MaxBytesReader(nil, res.Body, maxOIDCResponseSize)).Decode(&tokenRes); err != nil {
+		return "", fmt.Errorf("decode %s error %w", discovery.TokenEndpoint, err)
+	}
+
+	if res.StatusCode != http.StatusOK || tokenRes.IDToken == "" {
+		return "", fmt.Errorf(
+			"%w: code exchange failed with status %d: %s %s",
+			ErrInvalidGrant, res.StatusCode, tokenRes.Error, tokenRes.ErrorDescription,
+		)
+	}
+
+	return tokenRes.IDToken, nil
+}
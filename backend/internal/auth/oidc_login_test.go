@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newOIDCLoginService(t *testing.T, issuer *stubIssuer) *authService {
+	t.Helper()
+
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithOIDCProviders(newStubProvider(t, issuer, OIDCClaimMapping{}))(a)
+	WithLoginRedirect("http://localhost:3000/auth/callback")(a)
+
+	return a
+}
+
+func Test_authService_StartOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	issuer := newStubIssuer(t)
+	a := newOIDCLoginService(t, issuer)
+
+	tests := []struct {
+		name       string
+		a          *authService
+		provider   string
+		redirectTo string
+		wantErr    error
+	}{
+		{name: "Success", a: a, provider: "okta", redirectTo: "/users?page=2"},
+		{name: "Unknown Provider", a: a, provider: "gitlab", wantErr: ErrUnknownProvider},
+		{name: "Absolute Redirect", a: a, provider: "okta", redirectTo: "https://evil.example.com", wantErr: ErrInvalidRedirectTo},
+		{name: "Protocol-Relative Redirect", a: a, provider: "okta", redirectTo: "//evil.example.com", wantErr: ErrInvalidRedirectTo},
+		{
+			name:     "Not Enabled",
+			a:        &authService{oidcProviders: a.oidcProviders, keyRing: a.keyRing},
+			provider: "okta",
+			wantErr:  ErrOIDCLoginNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			login, err := tt.a.StartOIDCLogin(context.Background(), tt.provider, tt.redirectTo)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.StartOIDCLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			callback := issuer.authorize(t, login.AuthorizationURL)
+
+			claims := &oidcLoginClaims{}
+			if _, err := jwt.ParseWithClaims(login.FlowToken, claims, a.keyRing.Keyfunc); err != nil {
+				t.Fatalf("parse flow token error = %v", err)
+			}
+
+			if claims.State != callback.State || claims.Nonce == "" || claims.CodeVerifier == "" ||
+				claims.RedirectTo != tt.redirectTo || !login.ExpiresAt.Equal(claims.ExpiresAt.Time) {
+				t.Errorf("authService.StartOIDCLogin() flow token claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func Test_authService_FinishOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	issuer := newStubIssuer(t)
+	a := newOIDCLoginService(t, issuer)
+
+	tests := []struct {
+		name     string
+		provider string
+		callback func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback)
+		wantErr  error
+		wantCode string
+	}{
+		{
+			name:     "Success",
+			provider: "okta",
+			callback: func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				return login.FlowToken, callback
+			},
+		},
+		{
+			name:     "Unknown Provider",
+			provider: "gitlab",
+			callback: func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				return login.FlowToken, callback
+			},
+			wantErr: ErrUnknownProvider,
+		},
+		{
+			name:     "No Flow Token",
+			provider: "okta",
+			callback: func(_ *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				return "", callback
+			},
+			wantErr: ErrInvalidLoginState,
+		},
+		{
+			name:     "Wrong State",
+			provider: "okta",
+			callback: func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				callback.State = "forged"
+
+				return login.FlowToken, callback
+			},
+			wantErr: ErrInvalidLoginState,
+		},
+		{
+			name:     "Denied",
+			provider: "okta",
+			callback: func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				return login.FlowToken, OIDCCallback{State: callback.State, Error: "access_denied"}
+			},
+			wantCode: LoginErrorAccessDenied,
+		},
+		{
+			name:     "Wrong Code",
+			provider: "okta",
+			callback: func(login *OIDCLogin, callback OIDCCallback) (string, OIDCCallback) {
+				callback.Code = "forged"
+
+				return login.FlowToken, callback
+			},
+			wantCode: LoginErrorInvalidIDToken,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			login, err := a.StartOIDCLogin(context.Background(), "okta", "/users")
+			if err != nil {
+				t.Fatalf("authService.StartOIDCLogin() error = %v", err)
+			}
+
+			flowToken, callback := tt.callback(login, issuer.authorize(t, login.AuthorizationURL))
+
+			result, err := a.FinishOIDCLogin(context.Background(), tt.provider, flowToken, callback)
+
+			var loginErr *OIDCLoginError
+			if tt.wantCode != "" {
+				if !errors.As(err, &loginErr) || loginErr.Code != tt.wantCode || loginErr.RedirectTo != "/users" {
+					t.Errorf("authService.FinishOIDCLogin() error = %v, want code %s", err, tt.wantCode)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.FinishOIDCLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && (result.Token.AccessToken == "" || result.RedirectTo != "/users") {
+				t.Errorf("authService.FinishOIDCLogin() = %+v, want a token", result)
+			}
+		})
+	}
+
+	// codes are good once
+	login, _ := a.StartOIDCLogin(context.Background(), "okta", "")
+	callback := issuer.authorize(t, login.AuthorizationURL)
+
+	if _, err := a.FinishOIDCLogin(context.Background(), "okta", login.FlowToken, callback); err != nil {
+		t.Fatalf("authService.FinishOIDCLogin() error = %v", err)
+	}
+
+	if _, err := a.FinishOIDCLogin(context.Background(), "okta", login.FlowToken, callback); err == nil {
+		t.Errorf("authService.FinishOIDCLogin() accepted a code twice")
+	}
+}
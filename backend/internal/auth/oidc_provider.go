@@ -0,0 +1,479 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+const (
+	// DefaultOIDCCacheTTL is how long the discovery document and the JWKS of
+	// a provider are cached.
+	DefaultOIDCCacheTTL = time.Hour
+
+	// jwksRefreshInterval throttles fetching the JWKS again for a token
+	// signed with an unknown key, which is how key rotations are picked up.
+	jwksRefreshInterval = time.Minute
+
+	// idTokenLeeway allows for clock skew between us and the provider.
+	idTokenLeeway = 30 * time.Second
+
+	// maxOIDCResponseSize caps the discovery documents and JWKS we read.
+	maxOIDCResponseSize = 1 << 20
+)
+
+var (
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrInvalidProvider    = errors.New("invalid identity provider configuration")
+	ErrProviderDiscovery  = errors.New("identity provider discovery failed")
+	ErrUnknownProviderKey = errors.New("unknown identity provider key")
+)
+
+// OIDCProviderConfig describes an external OpenID Connect provider users can
+// sign in with, e.g. Okta, Azure AD, Keycloak or GitLab.
+type OIDCProviderConfig struct {
+	// Name identifies the provider in URLs, e.g. "okta".
+	Name string
+	// Issuer is the issuer URL; the discovery document is fetched from
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes to request; "openid" is always added.
+	Scopes []string
+	Claims OIDCClaimMapping
+	// CacheTTL defaults to DefaultOIDCCacheTTL.
+	CacheTTL time.Duration
+	// Provisioning creates the users signing in for the first time.
+	Provisioning ProvisioningPolicy
+	// RedirectURL is our callback the provider redirects back to after
+	// signing in with redirects; the authorization code flow is not used
+	// without it.
+	RedirectURL string
+}
+
+// OIDCClaimMapping names the ID token claims user attributes are read from.
+// Unset fields fall back to the standard claims of OpenID Connect.
+type OIDCClaimMapping struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Username      string
+	FirstName     string
+	LastName      string
+	Picture       string
+}
+
+func (m OIDCClaimMapping) withDefaults() OIDCClaimMapping {
+	defaults := OIDCClaimMapping{
+		Subject:       "sub",
+		Email:         "email",
+		EmailVerified: "email_verified",
+		Username:      "preferred_username",
+		FirstName:     "given_name",
+		LastName:      "family_name",
+		Picture:       "picture",
+	}
+
+	for _, field := range []struct{ value, fallback *string }{
+		{&m.Subject, &defaults.Subject},
+		{&m.Email, &defaults.Email},
+		{&m.EmailVerified, &defaults.EmailVerified},
+		{&m.Username, &defaults.Username},
+		{&m.FirstName, &defaults.FirstName},
+		{&m.LastName, &defaults.LastName},
+		{&m.Picture, &defaults.Picture},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+
+	return m
+}
+
+// OIDCDiscovery is the part of a provider discovery document we use.
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// OIDCIdentity is a user as asserted by the ID token of a provider.
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	FirstName     string
+	LastName      string
+	Picture       string
+}
+
+// OIDCProvider verifies the ID tokens of an external OpenID Connect
+// provider. The discovery document and the JWKS are fetched on first use and
+// cached, so that an unreachable provider does not keep us from starting.
+type OIDCProvider struct {
+	cfg    OIDCProviderConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu                 sync.Mutex
+	discovery          *OIDCDiscovery
+	discoveryExpiresAt time.Time
+	keys               map[string]any
+	keysExpiresAt      time.Time
+	keysFetchedAt      time.Time
+}
+
+// NewOIDCProvider returns a provider for cfg, fetching its documents with
+// client.
+func NewOIDCProvider(cfg OIDCProviderConfig, client *http.Client) (*OIDCProvider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("%w: name and client_id are required", ErrInvalidProvider)
+	}
+
+	if u, err := url.Parse(cfg.Issuer); err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("%w: %s: invalid issuer %q", ErrInvalidProvider, cfg.Name, cfg.Issuer)
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultOIDCCacheTTL
+	}
+
+	cfg.Claims = cfg.Claims.withDefaults()
+	cfg.Scopes = append([]string{ScopeOpenID}, slices.DeleteFunc(slices.Clone(cfg.Scopes), func(scope string) bool {
+		return scope == ScopeOpenID
+	})...)
+
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// Name returns the name of the provider.
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Config returns the configuration of the provider, with defaults applied.
+func (p *OIDCProvider) Config() OIDCProviderConfig {
+	return p.cfg
+}
+
+// Discovery returns the discovery document of the provider.
+func (p *OIDCProvider) Discovery(ctx context.Context) (*OIDCDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.loadDiscovery(ctx)
+}
+
+func (p *OIDCProvider) loadDiscovery(ctx context.Context) (*OIDCDiscovery, error) {
+	if p.discovery != nil && p.now().Before(p.discoveryExpiresAt) {
+		return p.discovery, nil
+	}
+
+	var discovery OIDCDiscovery
+
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, endpoint, &discovery); err != nil {
+		return nil, errors.Join(ErrProviderDiscovery, err)
+	}
+
+	// OpenID Connect Discovery section 4.3
+	if discovery.Issuer != p.cfg.Issuer || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: %s: issuer %q does not match", ErrProviderDiscovery, p.cfg.Name, discovery.Issuer)
+	}
+
+	p.discovery = &discovery
+	p.discoveryExpiresAt = p.now().Add(p.cfg.CacheTTL)
+
+	return p.discovery, nil
+}
+
+// key returns the public key a token of the provider was signed with. The
+// JWKS is fetched again when it expired, or when the key is unknown and it
+// was not fetched just now.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	key, ok := p.keys[kid]
+	if ok && now.Before(p.keysExpiresAt) {
+		return key, nil
+	}
+
+	if ok || p.keys == nil || now.Sub(p.keysFetchedAt) >= jwksRefreshInterval {
+		if err := p.loadKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: %q", ErrUnknownProviderKey, p.cfg.Name, kid)
+	}
+
+	return key, nil
+}
+
+func (p *OIDCProvider) loadKeys(ctx context.Context) error {
+	discovery, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set JWKSet
+	if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return errors.Join(ErrProviderDiscovery, err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// keys of unsupported types are skipped rather than failing the set
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+	p.keysExpiresAt = p.keysFetchedAt.Add(p.cfg.CacheTTL)
+
+	return nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("new request error %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("get %s error %w", endpoint, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: unexpected status %d", endpoint, res.StatusCode) //nolint:goerr113 // one-off
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(nil, res.Body, maxOIDCResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("decode %s error %w", endpoint, err)
+	}
+
+	return nil
+}
+
+// VerifyIDToken verifies an ID token issued by the provider to our client
+// and returns the identity it asserts. The nonce is checked when given.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		idToken,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+
+			return p.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512", "EdDSA",
+		}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(idTokenLeeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parse id token error %w", err)
+	}
+
+	// OpenID Connect Core section 3.1.3.7: azp, when present, is us
+	if azp, ok := claims["azp"].(string); ok && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp %q", ErrInvalidIDToken, azp)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); nonce != "" && tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	mapping := p.cfg.Claims
+	identity := &OIDCIdentity{
+		Provider:      p.cfg.Name,
+		Subject:       stringClaim(claims, mapping.Subject),
+		Email:         stringClaim(claims, mapping.Email),
+		EmailVerified: boolClaim(claims, mapping.EmailVerified),
+		Username:      stringClaim(claims, mapping.Username),
+		FirstName:     stringClaim(claims, mapping.FirstName),
+		LastName:      stringClaim(claims, mapping.LastName),
+		Picture:       stringClaim(claims, mapping.Picture),
+	}
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	return identity, nil
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+
+	return value
+}
+
+// boolClaim reads a boolean claim; some providers send "true" as a string.
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
+
+// PublicKey returns the public key of a JWK.
+func (k JWK) PublicKey() (any, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, errN := decodeBigInt(k.N)
+		e, errE := decodeBigInt(k.E)
+
+		if err := errors.Join(errN, errE); err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedKey, k.KeyID)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}
+
+		curve, ok := curves[k.Curve]
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+
+		if err := errors.Join(errX, errY); err != nil || !ok || !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedKey, k.KeyID)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedKey, k.KeyID)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKey, k.KeyID)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("%w: bad integer", ErrUnsupportedKey)
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// WithOIDCProviders enables signing in with external OpenID Connect
+// providers.
+func WithOIDCProviders(providers ...*OIDCProvider) Option {
+	return func(a *authService) {
+		a.oidcProviders = make(map[string]*OIDCProvider, len(providers))
+
+		for _, provider := range providers {
+			a.oidcProviders[provider.Name()] = provider
+		}
+	}
+}
+
+// OIDCProviders returns the providers users can sign in with, by name.
+func (a *authService) OIDCProviders() []*OIDCProvider {
+	providers := make([]*OIDCProvider, 0, len(a.oidcProviders))
+
+	for _, provider := range a.oidcProviders {
+		providers = append(providers, provider)
+	}
+
+	slices.SortFunc(providers, func(x, y *OIDCProvider) int {
+		return strings.Compare(x.Name(), y.Name())
+	})
+
+	return providers
+}
+
+func (a *authService) oidcProvider(name string) (*OIDCProvider, error) {
+	provider, ok := a.oidcProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+
+	return provider, nil
+}
+
+// SignInWithOIDC signs in the user an ID token of a provider was issued for.
+// Users are matched by their linked identity, or else by e-mail address,
+// which the provider must have verified.
+func (a *authService) SignInWithOIDC(
+	ctx context.Context,
+	providerName, idToken, nonce string,
+) (*domain.JWTToken, error) {
+	provider, err := a.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, idToken, nonce)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidIDToken, err)
+	}
+
+	return a.signInWithIdentity(ctx, identity)
+}
@@ -0,0 +1,478 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+// stubIssuer is a local OpenID Connect provider serving a discovery
+// document and a JWKS, and signing ID tokens.
+type stubIssuer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	kid      string
+	key      *rsa.PrivateKey
+	requests map[string]int
+	codes    map[string]stubCode
+}
+
+// stubCode is an authorization code handed out by the stub issuer.
+type stubCode struct {
+	clientID, redirectURI, nonce, codeChallenge string
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+
+	issuer := &stubIssuer{
+		kid:      "key-1",
+		key:      key,
+		requests: make(map[string]int),
+		codes:    make(map[string]stubCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(res http.ResponseWriter, req *http.Request) {
+		issuer.count(req.URL.Path)
+
+		_ = json.NewEncoder(res).Encode(OIDCDiscovery{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize?tenant=acme",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(res http.ResponseWriter, req *http.Request) {
+		issuer.count(req.URL.Path)
+
+		issuer.mu.Lock()
+		jwk := JWK{
+			KeyType:   "RSA",
+			KeyID:     issuer.kid,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         encodeBigInt(issuer.key.N),
+			E:         encodeBigInt(big.NewInt(int64(issuer.key.E))),
+		}
+		issuer.mu.Unlock()
+
+		_ = json.NewEncoder(res).Encode(JWKSet{Keys: []JWK{jwk, {KeyType: "oct", KeyID: "secret"}}})
+	})
+	mux.HandleFunc("/token", func(res http.ResponseWriter, req *http.Request) {
+		issuer.count(req.URL.Path)
+
+		res.Header().Set("Content-Type", "application/json")
+
+		clientID, secret, _ := req.BasicAuth()
+		_ = req.ParseForm()
+
+		issuer.mu.Lock()
+		code, ok := issuer.codes[req.PostForm.Get("code")]
+		delete(issuer.codes, req.PostForm.Get("code"))
+		issuer.mu.Unlock()
+
+		if !ok || clientID != code.clientID || secret != "secret" ||
+			req.PostForm.Get("grant_type") != GrantTypeAuthorizationCode ||
+			req.PostForm.Get("redirect_uri") != code.redirectURI ||
+			!verifyCodeChallenge(code.codeChallenge, req.PostForm.Get("code_verifier")) {
+			res.WriteHeader(http.StatusBadRequest)
+			_, _ = res.Write([]byte(`{"error":"invalid_grant"}`))
+
+			return
+		}
+
+		_ = json.NewEncoder(res).Encode(map[string]string{
+			"access_token": "provider-access-token",
+			"token_type":   "Bearer",
+			"id_token":     issuer.idToken(t, clientID, jwt.MapClaims{"nonce": code.nonce}),
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func (s *stubIssuer) count(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[path]++
+}
+
+func (s *stubIssuer) requestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// authorize signs the user in at the authorization URL we sent them to,
+// and returns the callback the stub issuer redirects back with.
+func (s *stubIssuer) authorize(t *testing.T, authorizationURL string) OIDCCallback {
+	t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil || !strings.HasPrefix(authorizationURL, s.URL+"/authorize?") {
+		t.Fatalf("authorization URL = %q, want the stub issuer", authorizationURL)
+	}
+
+	query := u.Query()
+	if query.Get("tenant") != "acme" || query.Get("code_challenge_method") != CodeChallengeMethodS256 ||
+		query.Get("response_type") != ResponseTypeCode || !strings.HasPrefix(query.Get("scope"), ScopeOpenID) {
+		t.Fatalf("authorization URL = %q, want an authorization code request with PKCE", authorizationURL)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := "code-" + query.Get("state")
+	s.codes[code] = stubCode{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+
+	return OIDCCallback{State: query.Get("state"), Code: code}
+}
+
+// rotate replaces the signing key, as providers do now and then.
+func (s *stubIssuer) rotate(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.kid, s.key = kid, key
+}
+
+// idToken signs an ID token for clientID with the claims given on top of
+// the registered ones.
+func (s *stubIssuer) idToken(t *testing.T, clientID string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            "okta-user-1",
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+
+	for name, value := range claims {
+		if value == nil {
+			delete(all, name)
+
+			continue
+		}
+
+		all[name] = value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = s.kid
+
+	tokenString, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("sign id token error = %v", err)
+	}
+
+	return tokenString
+}
+
+func newStubProvider(t *testing.T, issuer *stubIssuer, claims OIDCClaimMapping) *OIDCProvider {
+	t.Helper()
+
+	provider, err := NewOIDCProvider(OIDCProviderConfig{
+		Name:         "okta",
+		Issuer:       issuer.URL,
+		ClientID:     "goadmin",
+		ClientSecret: "secret",
+		Scopes:       []string{ScopeEmail, ScopeOpenID},
+		Claims:       claims,
+		RedirectURL:  "http://localhost:3600/auth/okta/callback",
+		// the stub issuer is trusted with e-mail addresses
+		Provisioning: ProvisioningPolicy{LinkByEmail: true},
+	}, issuer.Client())
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+
+	return provider
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     OIDCProviderConfig
+		wantErr bool
+	}{
+		{name: "Success", cfg: OIDCProviderConfig{Name: "gitlab", Issuer: "https://gitlab.com", ClientID: "client"}},
+		{name: "No Name", cfg: OIDCProviderConfig{Issuer: "https://gitlab.com", ClientID: "client"}, wantErr: true},
+		{name: "No Client ID", cfg: OIDCProviderConfig{Name: "gitlab", Issuer: "https://gitlab.com"}, wantErr: true},
+		{name: "Relative Issuer", cfg: OIDCProviderConfig{Name: "gitlab", Issuer: "gitlab.com", ClientID: "client"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			provider, err := NewOIDCProvider(tt.cfg, http.DefaultClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewOIDCProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if cfg := provider.Config(); cfg.CacheTTL != DefaultOIDCCacheTTL || cfg.Claims.Email != "email" ||
+				len(cfg.Scopes) != 1 || cfg.Scopes[0] != ScopeOpenID {
+				t.Errorf("NewOIDCProvider() config = %+v, want the defaults", cfg)
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_VerifyIDToken(t *testing.T) {
+	t.Parallel()
+
+	issuer := newStubIssuer(t)
+	provider := newStubProvider(t, issuer, OIDCClaimMapping{Username: "nickname"})
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		nonce   string
+		want    *OIDCIdentity
+		wantErr bool
+	}{
+		{
+			name:   "Success",
+			claims: jwt.MapClaims{"nickname": "jdoe", "given_name": "John", "nonce": "n-1"},
+			nonce:  "n-1",
+			want: &OIDCIdentity{
+				Provider:      "okta",
+				Subject:       "okta-user-1",
+				Email:         "user@example.com",
+				EmailVerified: true,
+				Username:      "jdoe",
+				FirstName:     "John",
+			},
+		},
+		{
+			name:   "Email Verified As String",
+			claims: jwt.MapClaims{"email_verified": "true"},
+			want: &OIDCIdentity{
+				Provider:      "okta",
+				Subject:       "okta-user-1",
+				Email:         "user@example.com",
+				EmailVerified: true,
+			},
+		},
+		{name: "Wrong Nonce", claims: jwt.MapClaims{"nonce": "n-1"}, nonce: "n-2", wantErr: true},
+		{name: "Wrong Audience", claims: jwt.MapClaims{"aud": "someone-else"}, wantErr: true},
+		{
+			name:    "Wrong Authorized Party",
+			claims:  jwt.MapClaims{"aud": []string{"goadmin", "someone-else"}, "azp": "someone-else"},
+			wantErr: true,
+		},
+		{name: "Wrong Issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}, wantErr: true},
+		{name: "Expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, wantErr: true},
+		{name: "No Expiry", claims: jwt.MapClaims{"exp": nil}, wantErr: true},
+		{name: "No Subject", claims: jwt.MapClaims{"sub": nil}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := provider.VerifyIDToken(context.Background(), issuer.idToken(t, "goadmin", tt.claims), tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OIDCProvider.VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && *got != *tt.want {
+				t.Errorf("OIDCProvider.VerifyIDToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := provider.VerifyIDToken(context.Background(), "not-a-token", ""); err == nil {
+		t.Errorf("OIDCProvider.VerifyIDToken() accepted a malformed token")
+	}
+}
+
+func TestOIDCProvider_Caching(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	issuer := newStubIssuer(t)
+	provider := newStubProvider(t, issuer, OIDCClaimMapping{})
+
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := provider.VerifyIDToken(ctx, issuer.idToken(t, "goadmin", nil), ""); err != nil {
+			t.Fatalf("OIDCProvider.VerifyIDToken() error = %v", err)
+		}
+	}
+
+	if got := issuer.requestCount("/jwks"); got != 1 {
+		t.Errorf("JWKS fetched %d times, want once", got)
+	}
+
+	// a token signed with a new key is verified once the JWKS may be fetched
+	// again, but not before, so that unknown keys cannot flood the provider
+	issuer.rotate(t, "key-2")
+
+	if _, err := provider.VerifyIDToken(ctx, issuer.idToken(t, "goadmin", nil), ""); !errors.Is(err, ErrUnknownProviderKey) {
+		t.Errorf("OIDCProvider.VerifyIDToken() error = %v, want %v", err, ErrUnknownProviderKey)
+	}
+
+	now = now.Add(jwksRefreshInterval)
+
+	if _, err := provider.VerifyIDToken(ctx, issuer.idToken(t, "goadmin", nil), ""); err != nil {
+		t.Errorf("OIDCProvider.VerifyIDToken() after a rotation error = %v", err)
+	}
+
+	if got := issuer.requestCount("/jwks"); got != 2 {
+		t.Errorf("JWKS fetched %d times, want twice", got)
+	}
+
+	if got := issuer.requestCount("/.well-known/openid-configuration"); got != 1 {
+		t.Errorf("discovery document fetched %d times, want once", got)
+	}
+
+	now = now.Add(DefaultOIDCCacheTTL)
+
+	if _, err := provider.Discovery(ctx); err != nil || issuer.requestCount("/.well-known/openid-configuration") != 2 {
+		t.Errorf("OIDCProvider.Discovery() error = %v, want the expired document fetched again", err)
+	}
+}
+
+func TestOIDCProvider_Discovery(t *testing.T) {
+	t.Parallel()
+
+	issuer := newStubIssuer(t)
+
+	// the document names another issuer than the one configured
+	provider, _ := NewOIDCProvider(OIDCProviderConfig{
+		Name:     "okta",
+		Issuer:   issuer.URL + "/",
+		ClientID: "goadmin",
+	}, issuer.Client())
+
+	if _, err := provider.Discovery(context.Background()); !errors.Is(err, ErrProviderDiscovery) {
+		t.Errorf("OIDCProvider.Discovery() error = %v, want %v", err, ErrProviderDiscovery)
+	}
+
+	unreachable, _ := NewOIDCProvider(OIDCProviderConfig{
+		Name:     "okta",
+		Issuer:   "http://127.0.0.1:1",
+		ClientID: "goadmin",
+	}, issuer.Client())
+
+	if _, err := unreachable.Discovery(context.Background()); !errors.Is(err, ErrProviderDiscovery) {
+		t.Errorf("OIDCProvider.Discovery() error = %v, want %v", err, ErrProviderDiscovery)
+	}
+}
+
+func Test_authService_SignInWithOIDC(t *testing.T) {
+	t.Parallel()
+
+	issuer := newStubIssuer(t)
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithOIDCProviders(newStubProvider(t, issuer, OIDCClaimMapping{}))(a)
+
+	tests := []struct {
+		name     string
+		provider string
+		claims   jwt.MapClaims
+		wantErr  error
+	}{
+		{name: "Success", provider: "okta"},
+		{name: "Unknown Provider", provider: "gitlab", wantErr: ErrUnknownProvider},
+		{name: "Unverified Email", provider: "okta", claims: jwt.MapClaims{"email_verified": false}, wantErr: ErrInvalidIDToken},
+		{name: "Invalid Token", provider: "okta", claims: jwt.MapClaims{"aud": "other"}, wantErr: ErrInvalidIDToken},
+		{
+			name:     "Unknown User",
+			provider: "okta",
+			claims:   jwt.MapClaims{"email": "nobody@example.com"},
+			wantErr:  &domain.ResourceNotFoundError{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := a.SignInWithOIDC(context.Background(), tt.provider, issuer.idToken(t, "goadmin", tt.claims), "")
+
+			var notFoundErr *domain.ResourceNotFoundError
+			if _, ok := tt.wantErr.(*domain.ResourceNotFoundError); ok {
+				if !errors.As(err, &notFoundErr) {
+					t.Errorf("authService.SignInWithOIDC() error = %v, want not found", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.SignInWithOIDC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && token.AccessToken == "" {
+				t.Errorf("authService.SignInWithOIDC() = %+v, want a token", token)
+			}
+		})
+	}
+
+	if providers := a.OIDCProviders(); len(providers) != 1 || providers[0].Name() != "okta" {
+		t.Errorf("authService.OIDCProviders() = %v, want okta", providers)
+	}
+}
@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"goadmin-backend/internal/domain"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+var (
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+	ErrInvalidPasswordHash     = errors.New("invalid password hash")
+)
+
+// PasswordHasher hashes passwords into self-describing strings: argon2id
+// hashes in the PHC string format, bcrypt hashes in their usual "$2a$" form.
+// Any hasher verifies both, so that the algorithm and its parameters can be
+// changed without stranding the stored hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded and, if so, whether
+	// encoded should be replaced by a fresh Hash of password because it was
+	// made with another algorithm or weaker parameters.
+	Verify(password, encoded string) (ok, rehash bool, err error)
+}
+
+// WithPasswordHasher hashes the passwords of new users and password resets
+// with hasher, and rehashes on sign-in the passwords hashed otherwise.
+// Without it passwords are hashed with bcrypt at DefaultBCryptCost.
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(a *authService) {
+		a.hasher = hasher
+	}
+}
+
+func (a *authService) passwordHasher() PasswordHasher { //nolint:ireturn // configurable
+	if a.hasher == nil {
+		return NewBCryptHasher(DefaultBCryptCost)
+	}
+
+	return a.hasher
+}
+
+// rehashPassword replaces the stored hash of a user who just signed in with
+// a hash made by the configured hasher. It is best effort: the old hash keeps
+// working, so a failure is retried on the next sign-in.
+func (a *authService) rehashPassword(ctx context.Context, user *domain.User, password string) {
+	hashedPassword, err := a.passwordHasher().Hash(password)
+	if err != nil {
+		return
+	}
+
+	if err := a.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return
+	}
+
+	user.Password = hashedPassword
+}
+
+// Argon2idParams are the cost parameters of argon2id. Memory is in KiB,
+// SaltLength and KeyLength in bytes.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams returns the second recommended option of RFC 9106,
+// for memory-constrained environments.
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+var _ PasswordHasher = &Argon2idHasher{}
+
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher returns an argon2id hasher. Unset parameters fall back
+// to DefaultArgon2idParams.
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	defaults := DefaultArgon2idParams()
+
+	if params.Memory == 0 {
+		params.Memory = defaults.Memory
+	}
+
+	if params.Iterations == 0 {
+		params.Iterations = defaults.Iterations
+	}
+
+	if params.Parallelism == 0 {
+		params.Parallelism = defaults.Parallelism
+	}
+
+	if params.SaltLength == 0 {
+		params.SaltLength = defaults.SaltLength
+	}
+
+	if params.KeyLength == 0 {
+		params.KeyLength = defaults.KeyLength
+	}
+
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt error %w", err)
+	}
+
+	key := argon2.IDKey(
+		[]byte(password),
+		salt,
+		h.params.Iterations,
+		h.params.Memory,
+		h.params.Parallelism,
+		h.params.KeyLength,
+	)
+
+	return encodeArgon2id(h.params, salt, key), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, bool, error) {
+	ok, err := verifyPassword(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	if !strings.HasPrefix(encoded, argon2idPrefix) {
+		return true, true, nil
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	rehash := params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.Parallelism < h.params.Parallelism ||
+		len(salt) < int(h.params.SaltLength) ||
+		len(key) < int(h.params.KeyLength)
+
+	return true, rehash, nil
+}
+
+var _ PasswordHasher = &BCryptHasher{}
+
+type BCryptHasher struct {
+	cost int
+}
+
+// NewBCryptHasher returns a bcrypt hasher. A zero cost falls back to
+// DefaultBCryptCost.
+func NewBCryptHasher(cost int) *BCryptHasher {
+	if cost == 0 {
+		cost = DefaultBCryptCost
+	}
+
+	return &BCryptHasher{cost: cost}
+}
+
+func (h *BCryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("bcrypt error %w", err)
+	}
+
+	return string(hashedPassword), nil
+}
+
+func (h *BCryptHasher) Verify(password, encoded string) (bool, bool, error) {
+	ok, err := verifyPassword(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		// not a bcrypt hash
+		return true, true, nil //nolint:nilerr // it is rehashed
+	}
+
+	return true, cost < h.cost, nil
+}
+
+// verifyPassword checks password against a hash of any supported algorithm.
+func verifyPassword(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+
+		otherKey := argon2.IDKey(
+			[]byte(password),
+			salt,
+			params.Iterations,
+			params.Memory,
+			params.Parallelism,
+			uint32(len(key)),
+		)
+
+		return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+	case strings.HasPrefix(encoded, "$2a$"),
+		strings.HasPrefix(encoded, "$2b$"),
+		strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrInvalidPasswordHash, err)
+		}
+
+		return true, nil
+	default:
+		return false, ErrUnsupportedPasswordHash
+	}
+}
+
+// encodeArgon2id formats an argon2id hash as a PHC string, e.g.
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func encodeArgon2id(params Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
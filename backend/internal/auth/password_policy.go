@@ -0,0 +1,390 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // breach lists are keyed by SHA-1
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+)
+
+const (
+	DefaultPasswordMinLength = 8
+
+	passwordPointer        = "/password"
+	currentPasswordPointer = "/current_password"
+)
+
+var ErrInvalidBreachList = errors.New("invalid breach list")
+
+// PasswordPolicy defines which passwords users may choose. Common passwords,
+// like "password1", are refused by any policy.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MinCharClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols a password must mix.
+	MinCharClasses int
+	// AllowUserInfo lets passwords contain the username or the local part
+	// of the e-mail address.
+	AllowUserInfo bool
+	// BreachList refuses passwords known to have leaked, when set.
+	BreachList *BreachList
+}
+
+// DefaultPasswordPolicy returns the policy used for the unset fields of a
+// configured policy.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      DefaultPasswordMinLength,
+		MinCharClasses: 1,
+	}
+}
+
+func (p PasswordPolicy) withDefaults() PasswordPolicy {
+	defaults := DefaultPasswordPolicy()
+
+	if p.MinLength <= 0 {
+		p.MinLength = defaults.MinLength
+	}
+
+	if p.MinCharClasses <= 0 {
+		p.MinCharClasses = defaults.MinCharClasses
+	}
+
+	return p
+}
+
+// Check returns a *httperr.ValidationError listing every rule password
+// breaks, or nil. The user is the one choosing it.
+func (p PasswordPolicy) Check(password string, user *domain.User) error {
+	var violations []httperr.ValidationErrorItem
+
+	violate := func(detail string) {
+		violations = append(violations, httperr.ValidationErrorItem{
+			Detail:  detail,
+			Pointer: passwordPointer,
+		})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violate(fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if charClasses(password) < p.MinCharClasses {
+		violate(fmt.Sprintf(
+			"must mix at least %d of lowercase letters, uppercase letters, digits and symbols",
+			p.MinCharClasses,
+		))
+	}
+
+	if !p.AllowUserInfo && user != nil {
+		lowerPassword := strings.ToLower(password)
+
+		if containsUserInfo(lowerPassword, user.Username) {
+			violate("must not contain the username")
+		}
+
+		localPart, _, _ := strings.Cut(user.Email, "@")
+		if containsUserInfo(lowerPassword, localPart) {
+			violate("must not contain the e-mail address")
+		}
+	}
+
+	if commonPassword(password) {
+		violate("is too common, choose another one")
+	}
+
+	if p.BreachList != nil && p.BreachList.Contains(password) {
+		violate("appeared in a data breach, choose another one")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return httperr.NewValidationError("", "The password does not meet the password policy", violations)
+}
+
+// WithPasswordPolicy enforces policy on the passwords of new users, password
+// resets and changes. Without it DefaultPasswordPolicy applies.
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(a *authService) {
+		a.passwordPolicy = policy
+	}
+}
+
+func (a *authService) checkPassword(password string, user *domain.User) error {
+	return a.passwordPolicy.withDefaults().Check(password, user)
+}
+
+// ChangePassword sets a new password for a user giving their current one.
+// The password has to meet the password policy, and the user is signed out
+// everywhere.
+func (a *authService) ChangePassword(ctx context.Context, userID, currentPassword, password string) error {
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	// a hash that cannot be verified, e.g. of a user signed up with Google,
+	// is as good as a wrong password
+	if ok, _, err := a.passwordHasher().Verify(currentPassword, user.Password); err != nil || !ok {
+		return httperr.NewValidationError("", "The current password is wrong", []httperr.ValidationErrorItem{{
+			Detail:  "is wrong",
+			Pointer: currentPasswordPointer,
+		}})
+	}
+
+	return a.setPassword(ctx, user, password)
+}
+
+// SetPassword sets a new password for a user without their current one, as
+// admins do. Otherwise it is ChangePassword.
+func (a *authService) SetPassword(ctx context.Context, userID, password string) error {
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	return a.setPassword(ctx, user, password)
+}
+
+func (a *authService) setPassword(ctx context.Context, user *domain.User, password string) error {
+	if err := a.checkPassword(password, user); err != nil {
+		return err
+	}
+
+	hashedPassword, err := a.passwordHasher().Hash(password)
+	if err != nil {
+		return fmt.Errorf("hash password error %w", err)
+	}
+
+	if err := a.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return fmt.Errorf("update password error %w", err)
+	}
+
+	return a.signOutEverywhere(ctx, user.ID)
+}
+
+// signOutEverywhere revokes every session and refresh token of a user whose
+// password changed.
+func (a *authService) signOutEverywhere(ctx context.Context, userID string) error {
+	if a.refreshTokenRepo != nil {
+		if err := a.refreshTokenRepo.RevokeAllByUserID(ctx, userID); err != nil {
+			return fmt.Errorf("revoke refresh tokens error %w", err)
+		}
+	}
+
+	if a.sessionRepo != nil {
+		return a.LogoutAll(ctx, userID)
+	}
+
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+// containsUserInfo ignores very short values, which would refuse too many
+// passwords by chance.
+func containsUserInfo(lowerPassword, info string) bool {
+	return len(info) >= 3 && strings.Contains(lowerPassword, strings.ToLower(info))
+}
+
+// commonPassword reports whether a password is on the built-in list of
+// common passwords, or one of them with digits and symbols appended or
+// letters swapped for look-alikes.
+func commonPassword(password string) bool {
+	lower := strings.ToLower(password)
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+
+	for _, candidate := range []string{lower, base, lookAlikes.Replace(base)} {
+		if _, ok := commonPasswords[candidate]; ok && candidate != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords is the set of the lines of common_passwords.txt.
+var commonPasswords = func() map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[line] = struct{}{}
+		}
+	}
+
+	return passwords
+}()
+
+var lookAlikes = strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// BreachList is a local list of breached passwords, like the one published
+// by Have I Been Pwned: one hex SHA-1 hash per line in ascending order,
+// optionally followed by ":" and the number of times it was seen. The list
+// stays on disk and is binary searched, so even the full download takes no
+// memory.
+type BreachList struct {
+	list io.ReaderAt
+	size int64
+}
+
+// LoadBreachList opens a breach list file, which stays open to be searched.
+func LoadBreachList(path string) (*BreachList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach list error %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("stat breach list error %w", err)
+	}
+
+	list, err := NewBreachList(file, info.Size())
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// NewBreachList returns the breach list in the first size bytes of r, after
+// reading it once to check that every line is a hash and that the hashes
+// are sorted.
+func NewBreachList(r io.ReaderAt, size int64) (*BreachList, error) {
+	scanner := bufio.NewScanner(io.NewSectionReader(r, 0, size))
+
+	var previous string
+
+	for line := 1; scanner.Scan(); line++ {
+		hash, ok := breachHash(scanner.Text())
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidBreachList, line)
+		}
+
+		if hash <= previous {
+			return nil, fmt.Errorf("%w: line %d is out of order", ErrInvalidBreachList, line)
+		}
+
+		previous = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breach list error %w", err)
+	}
+
+	return &BreachList{list: r, size: size}, nil
+}
+
+// Contains reports whether password is in the list. A list that cannot be
+// read any more contains nothing.
+func (l *BreachList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password)) //nolint:gosec // breach lists are keyed by SHA-1
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// low and high are always where lines start, or the end of the list
+	low, high := int64(0), l.size
+
+	for low < high {
+		start, next, hash, ok := l.lineFrom(low + (high-low)/2)
+		if start >= high {
+			// no line starts in the upper half, go on with the lower one
+			start, next, hash, ok = l.lineFrom(low)
+		}
+
+		if !ok {
+			return false
+		}
+
+		switch {
+		case hash == target:
+			return true
+		case hash < target:
+			low = next
+		default:
+			high = start
+		}
+	}
+
+	return false
+}
+
+// lineFrom returns the hash of the first line starting at or after pos,
+// with where it starts and the next line does. At the end of the list start
+// is its size.
+func (l *BreachList) lineFrom(pos int64) (start, next int64, hash string, ok bool) {
+	start = max(pos-1, 0)
+	reader := bufio.NewReader(io.NewSectionReader(l.list, start, l.size-start))
+
+	// skip the rest of the line before pos, just its "\n" if one starts there
+	if pos > 0 {
+		skipped, err := reader.ReadString('\n')
+		start += int64(len(skipped))
+
+		if err != nil {
+			return start, start, "", false
+		}
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return start, start, "", false
+	}
+
+	hash, ok = breachHash(line)
+
+	return start, start + int64(len(line)), hash, ok
+}
+
+// breachHash returns the hash of a line of a breach list in uppercase.
+func breachHash(line string) (string, bool) {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	if len(hash) != sha1.Size*2 {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+
+	return strings.ToUpper(hash), true
+}
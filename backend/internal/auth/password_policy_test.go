@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // breach lists are keyed by SHA-1
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+	"goadmin-backend/internal/platform/mail"
+)
+
+// breachList lists "qwerty123", "Tr0ub4dor&3" and "password1", sorted by
+// hash.
+const breachList = `5cec175b165e3d5e62c9e13ce848ef6feac81bff:1316284
+874572E7A5AE6A49466A6AC578B98ADBA78C6AA6:3
+E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D:2427611
+`
+
+func newBreachList(t *testing.T, list string) *BreachList {
+	t.Helper()
+
+	breaches, err := NewBreachList(strings.NewReader(list), int64(len(list)))
+	if err != nil {
+		t.Fatalf("NewBreachList() error = %v", err)
+	}
+
+	return breaches
+}
+
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *httperr.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want a ValidationError", err)
+	}
+
+	details := make([]string, 0, len(validationErr.Errors))
+
+	for _, item := range validationErr.Errors {
+		if item.Pointer != "/password" {
+			t.Errorf("ValidationErrorItem.Pointer = %v, want /password", item.Pointer)
+		}
+
+		details = append(details, item.Detail)
+	}
+
+	return details
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	breaches := newBreachList(t, breachList)
+	user := &domain.User{Username: "jdoe", Email: "john.doe@example.com"}
+	policy := PasswordPolicy{MinLength: 10, MinCharClasses: 3, BreachList: breaches}.withDefaults()
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{name: "Valid", policy: policy, password: "Correct-Horse-9"},
+		{
+			name:     "Empty",
+			policy:   policy,
+			password: "",
+			want: []string{
+				"must be at least 10 characters long",
+				"must mix at least 3 of lowercase letters, uppercase letters, digits and symbols",
+			},
+		},
+		{
+			name:     "Too Short",
+			policy:   policy,
+			password: "Sh0rt!",
+			want:     []string{"must be at least 10 characters long"},
+		},
+		{
+			name:     "Contains Username",
+			policy:   policy,
+			password: "Hello-JDOE-2024",
+			want:     []string{"must not contain the username"},
+		},
+		{
+			name:     "Contains Email",
+			policy:   policy,
+			password: "john.doe@2024!",
+			want:     []string{"must not contain the e-mail address"},
+		},
+		{
+			name:     "User Info Allowed",
+			policy:   PasswordPolicy{AllowUserInfo: true}.withDefaults(),
+			password: "jdoe-rocks",
+		},
+		{
+			name:     "Breached",
+			policy:   PasswordPolicy{BreachList: breaches}.withDefaults(),
+			password: "Tr0ub4dor&3",
+			want:     []string{"appeared in a data breach, choose another one"},
+		},
+		{
+			name:     "Common",
+			policy:   DefaultPasswordPolicy(),
+			password: "password1",
+			want:     []string{"is too common, choose another one"},
+		},
+		{
+			name:     "Common With Look-Alikes",
+			policy:   DefaultPasswordPolicy(),
+			password: "P@ssw0rd!2024",
+			want:     []string{"is too common, choose another one"},
+		},
+		{name: "Default Policy", policy: DefaultPasswordPolicy(), password: "violet-harbor"},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := violations(t, tt.policy.Check(tt.password, user))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PasswordPolicy.Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBreachList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		list    string
+		wantErr bool
+	}{
+		{name: "Hashes With Counts", list: breachList},
+		{name: "Hashes Only", list: "E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D\n"},
+		{name: "Too Short", list: "E38AD214943DAAD1D64C:1\n", wantErr: true},
+		{name: "Not Hex", list: "Z38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D:1\n", wantErr: true},
+		{name: "Blank Line", list: "5CEC175B165E3D5E62C9E13CE848EF6FEAC81BFF\n\nE38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D\n", wantErr: true},
+		{name: "Out Of Order", list: "E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D\n5CEC175B165E3D5E62C9E13CE848EF6FEAC81BFF\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewBreachList(strings.NewReader(tt.list), int64(len(tt.list)))
+			if errors.Is(err, ErrInvalidBreachList) != tt.wantErr {
+				t.Fatalf("NewBreachList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && (!got.Contains("password1") || got.Contains("password2")) {
+				t.Errorf("BreachList.Contains() is wrong")
+			}
+		})
+	}
+}
+
+func TestBreachList_Contains(t *testing.T) {
+	t.Parallel()
+
+	hashes := make([]string, 0, 1000)
+
+	for i := range 1000 {
+		sum := sha1.Sum([]byte(fmt.Sprintf("breached-%d", 2*i))) //nolint:gosec // breach lists are keyed by SHA-1
+		hashes = append(hashes, fmt.Sprintf("%X:%d\r\n", sum, i))
+	}
+
+	sort.Strings(hashes)
+	breaches := newBreachList(t, strings.Join(hashes, ""))
+
+	for i := range 2000 {
+		password := fmt.Sprintf("breached-%d", i)
+
+		if got, want := breaches.Contains(password), i%2 == 0; got != want {
+			t.Errorf("BreachList.Contains(%q) = %v, want %v", password, got, want)
+		}
+	}
+}
+
+func Test_authService_ChangePassword(t *testing.T) {
+	t.Parallel()
+
+	userRepo := &rehashUserRepoMock{}
+	refreshTokenRepo := &RefreshTokenRepositoryMock{}
+	a := &authService{userRepo: userRepo, refreshTokenRepo: refreshTokenRepo}
+	WithPasswordHasher(NewArgon2idHasher(fastArgon2idParams))(a)
+	WithPasswordPolicy(PasswordPolicy{MinLength: 12})(a)
+
+	ctx := context.Background()
+
+	if err := refreshTokenRepo.Create(ctx, &domain.RefreshToken{ID: "1", FamilyID: "1", UserID: "1"}); err != nil {
+		t.Fatalf("RefreshTokenRepository.Create() error = %v", err)
+	}
+
+	var validationErr *httperr.ValidationError
+
+	err := a.ChangePassword(ctx, "1", "wrong", "long enough password")
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Pointer != "/current_password" {
+		t.Errorf("authService.ChangePassword() error = %v, want the current password refused", err)
+	}
+
+	err = a.ChangePassword(ctx, "1", "password", "short")
+	if got := violations(t, err); len(got) != 1 {
+		t.Errorf("authService.ChangePassword() violations = %q, want 1", got)
+	}
+
+	if userRepo.updatedPassword() != "" {
+		t.Errorf("authService.ChangePassword() stored a refused password")
+	}
+
+	if err := a.ChangePassword(ctx, "1", "password", "long enough password"); err != nil {
+		t.Fatalf("authService.ChangePassword() error = %v", err)
+	}
+
+	if ok, _, _ := a.passwordHasher().Verify("long enough password", userRepo.updatedPassword()); !ok {
+		t.Errorf("authService.ChangePassword() did not store the new password")
+	}
+
+	if !refreshTokenRepo.allRevoked() {
+		t.Errorf("authService.ChangePassword() did not revoke the refresh tokens")
+	}
+
+	if err := a.SetPassword(ctx, "1", "another long password"); err != nil {
+		t.Fatalf("authService.SetPassword() error = %v", err)
+	}
+
+	if ok, _, _ := a.passwordHasher().Verify("another long password", userRepo.updatedPassword()); !ok {
+		t.Errorf("authService.SetPassword() did not store the new password")
+	}
+
+	failing := &authService{userRepo: &UserRepositoryMock{hasError: true}}
+	if err := failing.SetPassword(ctx, "1", "long enough password"); err == nil {
+		t.Errorf("authService.SetPassword() error = nil, wantErr true")
+	}
+}
+
+func Test_authService_ResetPassword_policy(t *testing.T) {
+	t.Parallel()
+
+	mailer := mail.NewMemoryMailer()
+	a := &authService{
+		userRepo:      &UserRepositoryMock{},
+		userTokenRepo: &UserTokenRepositoryMock{},
+		mailer:        mailer,
+	}
+	WithPasswordPolicy(PasswordPolicy{MinLength: 12})(a)
+
+	ctx := context.Background()
+
+	if err := a.ForgotPassword(ctx, "user@example.com"); err != nil {
+		t.Fatalf("authService.ForgotPassword() error = %v", err)
+	}
+
+	msg, _ := mailer.Last("user@example.com")
+	token := tokenFromMail(t, msg)
+
+	if got := violations(t, a.ResetPassword(ctx, token, "too short")); len(got) != 1 {
+		t.Errorf("authService.ResetPassword() violations = %q, want 1", got)
+	}
+
+	// the token was not used up by the refused password
+	if err := a.ResetPassword(ctx, token, "long enough password"); err != nil {
+		t.Errorf("authService.ResetPassword() error = %v", err)
+	}
+}
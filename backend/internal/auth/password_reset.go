@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/mail"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	DefaultPasswordResetDuration = 30 * time.Minute
+
+	// userTokenSize is the number of random bytes in a user token.
+	userTokenSize = 32
+)
+
+var (
+	ErrInvalidResetToken         = errors.New("invalid or expired password reset token")
+	ErrPasswordResetNotSupported = errors.New("password reset is not enabled")
+	ErrEmptyPassword             = errors.New("password must not be empty")
+)
+
+// WithUserTokenRepo enables the flows relying on single-use tokens sent by
+// e-mail, such as password reset.
+func WithUserTokenRepo(repo domain.UserTokenRepository) Option {
+	return func(a *authService) {
+		a.userTokenRepo = repo
+	}
+}
+
+// WithMailer sets the mailer used to reach users. Links in the e-mails point
+// to appURL, the URL of the frontend.
+func WithMailer(mailer mail.Mailer, appURL string) Option {
+	return func(a *authService) {
+		a.mailer = mailer
+		a.appURL = appURL
+	}
+}
+
+// issueUserToken creates a single-use token for user and returns the secret
+// to send them; only its hash is stored. Tokens issued earlier for the same
+// purpose are void.
+func (a *authService) issueUserToken(
+	ctx context.Context,
+	user *domain.User,
+	purpose string,
+	duration time.Duration,
+) (string, error) {
+	secret, err := random.Token(userTokenSize)
+	if err != nil {
+		return "", fmt.Errorf("generate user token error %w", err)
+	}
+
+	if err := a.userTokenRepo.DeleteByUserID(ctx, user.ID, purpose); err != nil {
+		return "", fmt.Errorf("delete user tokens error %w", err)
+	}
+
+	err = a.userTokenRepo.Create(ctx, &domain.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(secret),
+		ExpiresAt: time.Now().Add(duration),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create user token error %w", err)
+	}
+
+	return secret, nil
+}
+
+// appLink returns the frontend URL of path carrying token as query param.
+func (a *authService) appLink(path, token string) string {
+	return a.appURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// ForgotPassword e-mails a password reset link to the user with the given
+// e-mail address. Unknown addresses are silently ignored so that the
+// endpoint cannot be used to find out who has an account.
+func (a *authService) ForgotPassword(ctx context.Context, email string) error {
+	if a.userTokenRepo == nil || a.mailer == nil {
+		return ErrPasswordResetNotSupported
+	}
+
+	user, err := a.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	secret, err := a.issueUserToken(
+		ctx,
+		user,
+		domain.UserTokenPasswordReset,
+		DefaultPasswordResetDuration,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = a.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Someone asked to reset the password of your account. Follow the link below within " +
+			strconv.Itoa(int(DefaultPasswordResetDuration.Minutes())) + " minutes to choose a new one:\n\n" +
+			a.appLink("/reset-password", secret) + "\n\n" +
+			"If this was not you, ignore this e-mail; your password stays unchanged.\n",
+	})
+	if err != nil {
+		return fmt.Errorf("send password reset mail error %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password for the owner of a password reset token.
+// The password has to meet the password policy. The token is used up, and
+// every session of the user is signed out.
+func (a *authService) ResetPassword(ctx context.Context, token, password string) error {
+	if a.userTokenRepo == nil {
+		return ErrPasswordResetNotSupported
+	}
+
+	if password == "" {
+		return ErrEmptyPassword
+	}
+
+	// the policy is checked before the token is used up, so that the user
+	// can try another password
+	userToken, err := a.userTokenRepo.Find(ctx, domain.UserTokenPasswordReset, hashToken(token))
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidResetToken
+		}
+
+		return fmt.Errorf("find password reset token error %w", err)
+	}
+
+	user, err := a.userRepo.FindByID(ctx, userToken.UserID)
+	if err != nil {
+		return fmt.Errorf("find user error %w", err)
+	}
+
+	if err := a.checkPassword(password, user); err != nil {
+		return err
+	}
+
+	userToken, err = a.userTokenRepo.Consume(ctx, domain.UserTokenPasswordReset, hashToken(token))
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidResetToken
+		}
+
+		return fmt.Errorf("consume password reset token error %w", err)
+	}
+
+	hashedPassword, err := a.passwordHasher().Hash(password)
+	if err != nil {
+		return fmt.Errorf("hash password error %w", err)
+	}
+
+	if err := a.userRepo.UpdatePassword(ctx, userToken.UserID, hashedPassword); err != nil {
+		return fmt.Errorf("update password error %w", err)
+	}
+
+	return a.signOutEverywhere(ctx, userToken.UserID)
+}
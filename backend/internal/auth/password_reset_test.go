@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/mail"
+)
+
+// tokenFromMail extracts the token of the first link in an e-mail.
+func tokenFromMail(t *testing.T, msg mail.Message) string {
+	t.Helper()
+
+	for _, field := range strings.Fields(msg.Body) {
+		if link, err := url.Parse(field); err == nil && link.Query().Has("token") {
+			return link.Query().Get("token")
+		}
+	}
+
+	t.Fatalf("no link in mail %q", msg.Body)
+
+	return ""
+}
+
+func Test_authService_ForgotPassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		email         string
+		userTokenRepo *UserTokenRepositoryMock
+		wantMail      bool
+		wantErr       error
+	}{
+		{
+			name:          "Success",
+			email:         "user@example.com",
+			userTokenRepo: &UserTokenRepositoryMock{},
+			wantMail:      true,
+		},
+		{
+			name:          "Unknown Email",
+			email:         "nobody@example.com",
+			userTokenRepo: &UserTokenRepositoryMock{},
+		},
+		{
+			name:          "Token Repository Error",
+			email:         "user@example.com",
+			userTokenRepo: &UserTokenRepositoryMock{hasError: true},
+			wantErr:       errors.New("error"),
+		},
+		{
+			name:    "Not Enabled",
+			email:   "user@example.com",
+			wantErr: ErrPasswordResetNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mailer := mail.NewMemoryMailer()
+			a := &authService{
+				userRepo: &UserRepositoryMock{},
+				mailer:   mailer,
+				appURL:   "https://app.example.com",
+			}
+
+			if tt.userTokenRepo != nil {
+				a.userTokenRepo = tt.userTokenRepo
+			}
+
+			err := a.ForgotPassword(context.Background(), tt.email)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("authService.ForgotPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			msg, sent := mailer.Last(tt.email)
+			if sent != tt.wantMail {
+				t.Fatalf("authService.ForgotPassword() sent mail = %v, want %v", sent, tt.wantMail)
+			}
+
+			if sent && !strings.Contains(msg.Body, "https://app.example.com/reset-password?token=") {
+				t.Errorf("authService.ForgotPassword() mail = %q, want a reset link", msg.Body)
+			}
+		})
+	}
+}
+
+func Test_authService_ResetPassword(t *testing.T) {
+	t.Parallel()
+
+	mailer := mail.NewMemoryMailer()
+	sessionRepo := &SessionRepositoryMock{}
+	a := &authService{
+		userRepo:      &UserRepositoryMock{},
+		userTokenRepo: &UserTokenRepositoryMock{},
+		mailer:        mailer,
+	}
+	WithSessionRepo(sessionRepo)(a)
+
+	ctx := context.Background()
+
+	if _, err := a.startSession(ctx, &domain.User{ID: "1"}); err != nil {
+		t.Fatalf("authService.startSession() error = %v", err)
+	}
+
+	// the second request voids the link of the first one
+	_ = a.ForgotPassword(ctx, "user@example.com")
+	first, _ := mailer.Last("user@example.com")
+	_ = a.ForgotPassword(ctx, "user@example.com")
+	second, _ := mailer.Last("user@example.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantErr  error
+	}{
+		{name: "Voided Token", token: tokenFromMail(t, first), password: "new password", wantErr: ErrInvalidResetToken},
+		{name: "Empty Password", token: tokenFromMail(t, second), password: "", wantErr: ErrEmptyPassword},
+		{name: "Success", token: tokenFromMail(t, second), password: "new password"},
+		{name: "Reused Token", token: tokenFromMail(t, second), password: "new password", wantErr: ErrInvalidResetToken},
+	}
+	for _, tt := range tests {
+		if err := a.ResetPassword(ctx, tt.token, tt.password); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: authService.ResetPassword() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if sessions, _ := sessionRepo.FindActiveByUserID(ctx, "1"); len(sessions) != 0 {
+		t.Errorf("authService.ResetPassword() left %v sessions active", len(sessions))
+	}
+}
+
+var _ domain.UserTokenRepository = &UserTokenRepositoryMock{}
+
+// UserTokenRepositoryMock keeps user tokens in memory.
+type UserTokenRepositoryMock struct {
+	hasError bool
+
+	mu     sync.Mutex
+	tokens []*domain.UserToken
+}
+
+func (r *UserTokenRepositoryMock) Create(_ context.Context, token *domain.UserToken) error {
+	if r.hasError {
+		return errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *token
+	r.tokens = append(r.tokens, &stored)
+
+	return nil
+}
+
+func (r *UserTokenRepositoryMock) Find(
+	_ context.Context,
+	purpose string,
+	tokenHash string,
+) (*domain.UserToken, error) {
+	if r.hasError {
+		return nil, errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash &&
+			token.UsedAt == nil && time.Now().Before(token.ExpiresAt) {
+			found := *token
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("UserToken", "purpose="+purpose)
+}
+
+func (r *UserTokenRepositoryMock) Consume(
+	_ context.Context,
+	purpose string,
+	tokenHash string,
+) (*domain.UserToken, error) {
+	if r.hasError {
+		return nil, errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash &&
+			token.UsedAt == nil && time.Now().Before(token.ExpiresAt) {
+			now := time.Now()
+			token.UsedAt = &now
+			consumed := *token
+
+			return &consumed, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("UserToken", "purpose="+purpose)
+}
+
+func (r *UserTokenRepositoryMock) DeleteByUserID(_ context.Context, userID, purpose string) error {
+	if r.hasError {
+		return errors.New("error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.tokens[:0]
+
+	for _, token := range r.tokens {
+		if token.UserID != userID || token.Purpose != purpose {
+			kept = append(kept, token)
+		}
+	}
+
+	r.tokens = kept
+
+	return nil
+}
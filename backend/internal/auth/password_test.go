@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"goadmin-backend/internal/domain"
+)
+
+// fastArgon2idParams keep the tests quick, never use them for real.
+var fastArgon2idParams = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1} //nolint:gochecknoglobals
+
+func hashWith(t *testing.T, hasher PasswordHasher, password string) string {
+	t.Helper()
+
+	encoded, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("PasswordHasher.Hash() error = %v", err)
+	}
+
+	return encoded
+}
+
+func TestPasswordHasher_Verify(t *testing.T) {
+	t.Parallel()
+
+	argon2id := NewArgon2idHasher(fastArgon2idParams)
+	strongerArgon2id := NewArgon2idHasher(Argon2idParams{Memory: 2048, Iterations: 1, Parallelism: 1})
+	bcryptHasher := NewBCryptHasher(bcrypt.MinCost)
+	strongerBCrypt := NewBCryptHasher(bcrypt.MinCost + 1)
+
+	argon2idHash := hashWith(t, argon2id, "password")
+	strongerHash := hashWith(t, strongerArgon2id, "password")
+	bcryptHash := hashWith(t, bcryptHasher, "password")
+
+	if !strings.HasPrefix(argon2idHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Argon2idHasher.Hash() = %v, want a PHC string", argon2idHash)
+	}
+
+	tests := []struct {
+		name       string
+		hasher     PasswordHasher
+		password   string
+		encoded    string
+		wantOK     bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "Argon2id", hasher: argon2id, password: "password", encoded: argon2idHash, wantOK: true},
+		{name: "Argon2id Wrong Password", hasher: argon2id, password: "wrong", encoded: argon2idHash},
+		{
+			name:       "Argon2id Weaker Parameters",
+			hasher:     strongerArgon2id,
+			password:   "password",
+			encoded:    argon2idHash,
+			wantOK:     true,
+			wantRehash: true,
+		},
+		{name: "Argon2id Stronger Parameters", hasher: argon2id, password: "password", encoded: strongerHash, wantOK: true},
+		{name: "BCrypt", hasher: bcryptHasher, password: "password", encoded: bcryptHash, wantOK: true},
+		{name: "BCrypt To Argon2id", hasher: argon2id, password: "password", encoded: bcryptHash, wantOK: true, wantRehash: true},
+		{name: "Argon2id To BCrypt", hasher: bcryptHasher, password: "password", encoded: argon2idHash, wantOK: true, wantRehash: true},
+		{name: "BCrypt Lower Cost", hasher: strongerBCrypt, password: "password", encoded: bcryptHash, wantOK: true, wantRehash: true},
+		{name: "BCrypt Wrong Password", hasher: argon2id, password: "wrong", encoded: bcryptHash},
+		{name: "Unsupported", hasher: argon2id, password: "password", encoded: "password", wantErr: ErrUnsupportedPasswordHash},
+		{
+			name:     "Invalid Argon2id",
+			hasher:   argon2id,
+			password: "password",
+			encoded:  "$argon2id$v=19$m=1024,t=1$c2FsdA$a2V5",
+			wantErr:  ErrInvalidPasswordHash,
+		},
+		{
+			name:     "Unknown Argon2 Version",
+			hasher:   argon2id,
+			password: "password",
+			encoded:  strings.Replace(argon2idHash, "v=19", "v=16", 1),
+			wantErr:  ErrInvalidPasswordHash,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ok, rehash, err := tt.hasher.Verify(tt.password, tt.encoded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PasswordHasher.Verify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf(
+					"PasswordHasher.Verify() = %v, %v, want %v, %v",
+					ok,
+					rehash,
+					tt.wantOK,
+					tt.wantRehash,
+				)
+			}
+		})
+	}
+}
+
+func Test_authService_Login_rehash(t *testing.T) {
+	t.Parallel()
+
+	userRepo := &rehashUserRepoMock{}
+	a := &authService{
+		userRepo:         userRepo,
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithPasswordHasher(NewArgon2idHasher(fastArgon2idParams))(a)
+
+	credentials := domain.Credentials{Username: "username", Password: "password"}
+
+	if _, err := a.Login(context.Background(), credentials); err != nil {
+		t.Fatalf("authService.Login() error = %v", err)
+	}
+
+	rehashed := userRepo.updatedPassword()
+	if !strings.HasPrefix(rehashed, argon2idPrefix) {
+		t.Fatalf("authService.Login() stored %q, want an argon2id hash", rehashed)
+	}
+
+	if ok, rehash, err := a.passwordHasher().Verify("password", rehashed); !ok || rehash || err != nil {
+		t.Errorf("PasswordHasher.Verify() = %v, %v, %v, want true, false, nil", ok, rehash, err)
+	}
+}
+
+// rehashUserRepoMock records the password hash stored by UpdatePassword.
+type rehashUserRepoMock struct {
+	UserRepositoryMock
+
+	mu       sync.Mutex
+	password string
+}
+
+func (u *rehashUserRepoMock) UpdatePassword(_ context.Context, _ string, passwordHash string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.password = passwordHash
+
+	return nil
+}
+
+func (u *rehashUserRepoMock) updatedPassword() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.password
+}
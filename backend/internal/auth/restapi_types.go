@@ -17,6 +17,58 @@ type LogoutRequest struct {
 	Token string `json:"token" validate:"required"`
 }
 
+// RefreshTokenRequest represents a request to exchange a refresh token for a
+// new token pair.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ForgotPasswordRequest represents a request to e-mail a password reset
+// link.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResendVerificationEmailRequest represents a request to e-mail a new
+// verification link.
+type ResendVerificationEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a
+// password reset token.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// MFAVerifyRequest represents a request to exchange an MFA challenge token
+// and a TOTP or recovery code for a token pair.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery
+// code.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// CreateAPIKeyRequest represents a request to issue an API key. A key
+// without an expiry is valid until it is revoked.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ImpersonateRequest represents a request of an admin to act as a user; the
+// reason is kept in the audit log.
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
 // RegisterRequest represents a request to register a user.
 type RegisterRequest struct {
 	Username  string `json:"username" validate:"required"`
@@ -31,6 +83,41 @@ type GoogleIDTokenVerifyRequest struct {
 	GCSRFToken string `json:"g_csrf_token"`
 }
 
+// OIDCSignInRequest carries the ID token of an OpenID Connect provider, and
+// the nonce the sign-in was started with, if any.
+type OIDCSignInRequest struct {
+	IDToken string `json:"id_token" validate:"required"`
+	Nonce   string `json:"nonce"`
+}
+
+// OIDCProviderResponse is what a frontend needs to offer signing in with a
+// provider.
+// LinkIdentityRequest carries the ID token of the identity to link, at the
+// provider named.
+type LinkIdentityRequest struct {
+	Provider string `json:"provider" validate:"required"`
+	IDToken  string `json:"id_token" validate:"required"`
+	Nonce    string `json:"nonce"`
+}
+
+type OIDCProviderResponse struct {
+	Name     string   `json:"name"`
+	Issuer   string   `json:"issuer"`
+	ClientID string   `json:"client_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+func ToOIDCProviderResponse(provider *OIDCProvider) OIDCProviderResponse {
+	cfg := provider.Config()
+
+	return OIDCProviderResponse{
+		Name:     cfg.Name,
+		Issuer:   cfg.Issuer,
+		ClientID: cfg.ClientID,
+		Scopes:   cfg.Scopes,
+	}
+}
+
 type UserResponse struct {
 	ID        string     `json:"id"`
 	Username  string     `json:"username"`
@@ -39,6 +126,8 @@ type UserResponse struct {
 	Email     string     `json:"email"`
 	Active    bool       `json:"active"`
 	DeletedAt *time.Time `json:"deleted_at"`
+
+	EmailVerified bool `json:"email_verified"`
 }
 
 func ToUserResponse(usr *domain.User) UserResponse {
@@ -50,5 +139,207 @@ func ToUserResponse(usr *domain.User) UserResponse {
 		Email:     usr.Email,
 		Active:    usr.Active,
 		DeletedAt: usr.DeletedAt,
+
+		EmailVerified: usr.EmailVerifiedAt != nil,
+	}
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func ToSessionResponse(session *domain.Session) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func ToAPIKeyResponse(apiKey *domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		CreatedAt:  apiKey.CreatedAt,
 	}
 }
+
+type UserIdentityResponse struct {
+	ID         string     `json:"id"`
+	Provider   string     `json:"provider"`
+	Subject    string     `json:"subject"`
+	Email      string     `json:"email"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func ToUserIdentityResponse(identity *domain.UserIdentity) UserIdentityResponse {
+	return UserIdentityResponse{
+		ID:         identity.ID,
+		Provider:   identity.Provider,
+		Subject:    identity.Subject,
+		Email:      identity.Email,
+		LastUsedAt: identity.LastUsedAt,
+		CreatedAt:  identity.CreatedAt,
+	}
+}
+
+// CreatedAPIKeyResponse is the only response an API key is shown in.
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// CreateServiceAccountRequest represents a request to register a service
+// account.
+type CreateServiceAccountRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes"`
+}
+
+type ServiceAccountResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	ClientID   string     `json:"client_id"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *string    `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func ToServiceAccountResponse(account *domain.ServiceAccount) ServiceAccountResponse {
+	return ServiceAccountResponse{
+		ID:         account.ID,
+		Name:       account.Name,
+		ClientID:   account.ClientID,
+		Scopes:     account.Scopes,
+		CreatedBy:  account.CreatedBy,
+		LastUsedAt: account.LastUsedAt,
+		CreatedAt:  account.CreatedAt,
+	}
+}
+
+// CreatedServiceAccountResponse is the only response a client secret is
+// shown in.
+type CreatedServiceAccountResponse struct {
+	ServiceAccountResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenResponse is the successful response of the OAuth2 token endpoint.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// OAuthErrorResponse is the error response of the OAuth2 token endpoint.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// CreateOAuthClientRequest represents a request to register an OAuth
+// client. Public clients, e.g. single page apps, get no secret.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+type OAuthClientResponse struct {
+	ID           string    `json:"id"`
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func ToOAuthClientResponse(client *domain.OAuthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Public:       client.Public(),
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// CreatedOAuthClientResponse is the only response a client secret is shown
+// in.
+type CreatedOAuthClientResponse struct {
+	OAuthClientResponse
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// ConsentRequest is the answer of the user to an authorization request.
+type ConsentRequest struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizationResponse tells the consent page either where to send the
+// user agent or which scopes the user has to approve for the client.
+type AuthorizationResponse struct {
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+	ConsentRequired bool     `json:"consent_required"`
+	ClientName      string   `json:"client_name"`
+	Scopes          []string `json:"scopes,omitempty"`
+}
+
+func ToAuthorizationResponse(result *AuthorizationResult) AuthorizationResponse {
+	return AuthorizationResponse{
+		RedirectTo:      result.RedirectTo,
+		ConsentRequired: result.ConsentRequired,
+		ClientName:      result.Client.Name,
+		Scopes:          result.Scopes,
+	}
+}
+
+// CookieTokenResponse answers a sign-in in cookie mode, where the token pair
+// is set in HttpOnly cookies. The CSRF token is also in a cookie scripts can
+// read.
+type CookieTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// MFAChallengeResponse is returned by Login instead of a token pair when the
+// user still has to pass a second factor.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
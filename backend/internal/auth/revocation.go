@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/cache"
+)
+
+const (
+	DefaultRevocationCacheSize = 10_000
+	// DefaultRevocationCacheTTL bounds how long a token revoked by another
+	// instance can still be accepted by this one.
+	DefaultRevocationCacheTTL = 30 * time.Second
+	DefaultSweepInterval      = 10 * time.Minute
+)
+
+var _ domain.RevokedTokenRepository = &CachedRevokedTokenRepo{}
+
+// CachedRevokedTokenRepo keeps the answers of IsRevoked in an in-process LRU
+// cache so that authenticating a request does not cost a database round trip.
+//
+// A revocation never goes away before the token expires, so revoked entries
+// are cached until then. "Not revoked" answers are only cached for ttl, as
+// the token may be revoked through another instance in the meantime.
+type CachedRevokedTokenRepo struct {
+	repo  domain.RevokedTokenRepository
+	cache *cache.LRU[string, bool]
+	ttl   time.Duration
+}
+
+func NewCachedRevokedTokenRepo(
+	repo domain.RevokedTokenRepository,
+	size int,
+	ttl time.Duration,
+) *CachedRevokedTokenRepo {
+	return &CachedRevokedTokenRepo{
+		repo:  repo,
+		cache: cache.NewLRU[string, bool](size),
+		ttl:   ttl,
+	}
+}
+
+func (c *CachedRevokedTokenRepo) AddRevokedToken(
+	ctx context.Context,
+	tokenID string,
+	expiresAt time.Time,
+) error {
+	if err := c.repo.AddRevokedToken(ctx, tokenID, expiresAt); err != nil {
+		return err //nolint:wrapcheck // decorator
+	}
+
+	c.cache.Set(tokenID, true, time.Until(expiresAt))
+
+	return nil
+}
+
+func (c *CachedRevokedTokenRepo) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if revoked, ok := c.cache.Get(tokenID); ok {
+		return revoked, nil
+	}
+
+	revoked, err := c.repo.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return false, err //nolint:wrapcheck // decorator
+	}
+
+	// the expiry of the token is unknown here, it is at most the access
+	// token lifetime
+	ttl := c.ttl
+	if revoked {
+		ttl = DefaultTokenDuration
+	}
+
+	c.cache.Set(tokenID, revoked, ttl)
+
+	return revoked, nil
+}
+
+func (c *CachedRevokedTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return c.repo.DeleteExpired(ctx) //nolint:wrapcheck // decorator
+}
+
+// SweepRevokedTokens deletes expired revocations every interval until ctx is
+// done. It is meant to be run in its own goroutine.
+func SweepRevokedTokens(
+	ctx context.Context,
+	repo domain.RevokedTokenRepository,
+	interval time.Duration,
+	logger *slog.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := repo.DeleteExpired(ctx)
+			if err != nil {
+				logger.Error("failed to sweep revoked tokens", slog.Any("err", err))
+
+				continue
+			}
+
+			logger.Debug("swept revoked tokens", slog.Int64("deleted", deleted))
+		}
+	}
+}
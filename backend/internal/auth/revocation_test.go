@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/platform/logging"
+)
+
+func TestCachedRevokedTokenRepo_IsRevoked(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		repo      *RevokedTokenRepositoryMock
+		revoke    bool
+		want      bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "Not Revoked",
+			repo:      &RevokedTokenRepositoryMock{},
+			want:      false,
+			wantCalls: 1,
+		},
+		{
+			name:      "Revoked Elsewhere",
+			repo:      &RevokedTokenRepositoryMock{isRevoked: true},
+			want:      true,
+			wantCalls: 1,
+		},
+		{
+			name:      "Revoked Through Cache",
+			repo:      &RevokedTokenRepositoryMock{},
+			revoke:    true,
+			want:      true,
+			wantCalls: 0,
+		},
+		{
+			name:    "Error",
+			repo:    &RevokedTokenRepositoryMock{hasError: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewCachedRevokedTokenRepo(tt.repo, 10, time.Minute)
+			ctx := context.Background()
+
+			if tt.revoke {
+				if err := c.AddRevokedToken(ctx, "jti", time.Now().Add(time.Hour)); err != nil {
+					t.Fatalf("CachedRevokedTokenRepo.AddRevokedToken() error = %v", err)
+				}
+			}
+
+			for i := 0; i < 3; i++ {
+				got, err := c.IsRevoked(ctx, "jti")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("CachedRevokedTokenRepo.IsRevoked() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if got != tt.want {
+					t.Errorf("CachedRevokedTokenRepo.IsRevoked() = %v, want %v", got, tt.want)
+				}
+			}
+
+			if tt.repo.calls != tt.wantCalls {
+				t.Errorf("CachedRevokedTokenRepo.IsRevoked() hit the repository %v times, want %v", tt.repo.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSweepRevokedTokens(t *testing.T) {
+	t.Parallel()
+
+	repo := &RevokedTokenRepositoryMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_ = repo.AddRevokedToken(ctx, "expired", time.Now().Add(-time.Minute))
+	_ = repo.AddRevokedToken(ctx, "valid", time.Now().Add(time.Hour))
+
+	done := make(chan struct{})
+
+	go func() {
+		SweepRevokedTokens(ctx, repo, time.Millisecond, logging.NewLogger())
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if revoked, _ := repo.IsRevoked(ctx, "expired"); !revoked {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	if revoked, _ := repo.IsRevoked(context.Background(), "expired"); revoked {
+		t.Errorf("SweepRevokedTokens() kept an expired revocation")
+	}
+
+	if revoked, _ := repo.IsRevoked(context.Background(), "valid"); !revoked {
+		t.Errorf("SweepRevokedTokens() deleted a revocation still in use")
+	}
+}
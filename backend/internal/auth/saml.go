@@ -0,0 +1,530 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v5"
+	dsig "github.com/russellhaering/goxmldsig"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// DefaultSAMLMetadataTTL is how long the metadata of an identity
+	// provider fetched from its URL is cached.
+	DefaultSAMLMetadataTTL = 24 * time.Hour
+
+	// SAMLLoginAudience is the "aud" claim of the flow tokens binding a SAML
+	// sign-in to the browser it was started in.
+	SAMLLoginAudience = "goadmin-saml-login"
+
+	// maxSAMLMetadataSize caps the identity provider metadata we read.
+	maxSAMLMetadataSize = 1 << 20
+)
+
+var (
+	ErrUnknownSAMLConnection = errors.New("unknown SAML connection")
+	ErrInvalidSAMLConnection = errors.New("invalid SAML connection configuration")
+	ErrSAMLMetadata          = errors.New("identity provider metadata could not be loaded")
+	ErrInvalidSAMLResponse   = errors.New("invalid SAML response")
+)
+
+// SAMLAttributeMapping names the assertion attributes user attributes are
+// read from, by name or friendly name. Unset fields fall back to the LDAP
+// names most identity providers use; the e-mail address falls back to the
+// NameID when it is an e-mail address.
+type SAMLAttributeMapping struct {
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// SAMLConnectionConfig describes a SAML 2.0 identity provider users can sign
+// in with, e.g. ADFS, Okta or Shibboleth. We are the service provider.
+type SAMLConnectionConfig struct {
+	// Name identifies the connection in URLs, e.g. "adfs".
+	Name string
+	// EntityID identifies us to the identity provider; it defaults to
+	// MetadataURL.
+	EntityID string
+	// MetadataURL and ACSURL are our metadata and assertion consumer service
+	// endpoints for the connection.
+	MetadataURL string
+	ACSURL      string
+	// Key signs our authentication requests and decrypts encrypted
+	// assertions. Certificate, of its public key, is published in our
+	// metadata.
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	// IDPMetadata is the metadata of the identity provider. It is fetched
+	// from IDPMetadataURL instead when empty.
+	IDPMetadata    []byte
+	IDPMetadataURL string
+	// MetadataTTL defaults to DefaultSAMLMetadataTTL.
+	MetadataTTL time.Duration
+	// NameIDFormat is the format of the NameID requested, which identifies
+	// users; it defaults to persistent. Transient NameIDs are refused.
+	NameIDFormat string
+	Attributes   SAMLAttributeMapping
+	// Provisioning creates the users signing in for the first time.
+	Provisioning ProvisioningPolicy
+}
+
+// SAMLConnection signs users in with a SAML 2.0 identity provider: signed
+// authentication requests with the HTTP-Redirect binding, and signed
+// assertions posted back to our assertion consumer service. Metadata
+// fetched from a URL is fetched on first use and cached.
+type SAMLConnection struct {
+	cfg         SAMLConnectionConfig
+	metadataURL url.URL
+	acsURL      url.URL
+	client      *http.Client
+	now         func() time.Time
+
+	mu           sync.Mutex
+	idp          *saml.EntityDescriptor
+	idpExpiresAt time.Time
+}
+
+// NewSAMLConnection returns a connection for cfg, fetching the metadata of
+// the identity provider with client.
+func NewSAMLConnection(cfg SAMLConnectionConfig, client *http.Client) (*SAMLConnection, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSAMLConnection)
+	}
+
+	metadataURL, errMetadata := url.Parse(cfg.MetadataURL)
+	acsURL, errACS := url.Parse(cfg.ACSURL)
+
+	if errors.Join(errMetadata, errACS) != nil || !metadataURL.IsAbs() || !acsURL.IsAbs() {
+		return nil, fmt.Errorf("%w: %s: invalid metadata or ACS URL", ErrInvalidSAMLConnection, cfg.Name)
+	}
+
+	if cfg.Key == nil || cfg.Certificate == nil || !cfg.Key.PublicKey.Equal(cfg.Certificate.PublicKey) {
+		return nil, fmt.Errorf("%w: %s: an RSA key and its certificate are required", ErrInvalidSAMLConnection, cfg.Name)
+	}
+
+	if (len(cfg.IDPMetadata) == 0) == (cfg.IDPMetadataURL == "") {
+		return nil, fmt.Errorf("%w: %s: either idp_metadata or idp_metadata_url is required",
+			ErrInvalidSAMLConnection, cfg.Name)
+	}
+
+	if cfg.NameIDFormat == string(saml.TransientNameIDFormat) {
+		return nil, fmt.Errorf("%w: %s: transient NameIDs do not identify users", ErrInvalidSAMLConnection, cfg.Name)
+	}
+
+	cfg.EntityID = cmp.Or(cfg.EntityID, cfg.MetadataURL)
+	cfg.MetadataTTL = cmp.Or(cfg.MetadataTTL, DefaultSAMLMetadataTTL)
+	cfg.NameIDFormat = cmp.Or(cfg.NameIDFormat, string(saml.PersistentNameIDFormat))
+	cfg.Attributes.Username = cmp.Or(cfg.Attributes.Username, "uid")
+	cfg.Attributes.Email = cmp.Or(cfg.Attributes.Email, "mail")
+	cfg.Attributes.FirstName = cmp.Or(cfg.Attributes.FirstName, "givenName")
+	cfg.Attributes.LastName = cmp.Or(cfg.Attributes.LastName, "sn")
+
+	c := &SAMLConnection{
+		cfg:         cfg,
+		metadataURL: *metadataURL,
+		acsURL:      *acsURL,
+		client:      client,
+		now:         time.Now,
+	}
+
+	// metadata given inline is checked right away, and never expires
+	if len(cfg.IDPMetadata) > 0 {
+		idp, err := parseIDPMetadata(cfg.IDPMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidSAMLConnection, cfg.Name, err)
+		}
+
+		c.idp = idp
+	}
+
+	return c, nil
+}
+
+// Name returns the name of the connection.
+func (c *SAMLConnection) Name() string {
+	return c.cfg.Name
+}
+
+// Metadata returns our service provider metadata for the connection, which
+// the identity provider is set up with.
+func (c *SAMLConnection) Metadata() ([]byte, error) {
+	metadata := c.serviceProvider(nil).Metadata()
+
+	// assertions are only consumed with the HTTP-POST binding
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		descriptor.AssertionConsumerServices = slices.DeleteFunc(
+			descriptor.AssertionConsumerServices,
+			func(endpoint saml.IndexedEndpoint) bool { return endpoint.Binding != saml.HTTPPostBinding },
+		)
+	}
+
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal SAML metadata error %w", err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// serviceProvider returns us as the service provider of the connection to
+// the identity provider of the metadata.
+func (c *SAMLConnection) serviceProvider(idp *saml.EntityDescriptor) *saml.ServiceProvider {
+	return &saml.ServiceProvider{
+		EntityID:          c.cfg.EntityID,
+		Key:               c.cfg.Key,
+		Certificate:       c.cfg.Certificate,
+		MetadataURL:       c.metadataURL,
+		AcsURL:            c.acsURL,
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.NameIDFormat(c.cfg.NameIDFormat),
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}
+}
+
+// idpMetadata returns the metadata of the identity provider.
+func (c *SAMLConnection) idpMetadata(ctx context.Context) (*saml.EntityDescriptor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idp != nil && (c.cfg.IDPMetadataURL == "" || c.now().Before(c.idpExpiresAt)) {
+		return c.idp, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.IDPMetadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request error %w", err)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrSAMLMetadata, fmt.Errorf("get %s error %w", c.cfg.IDPMetadataURL, err))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		//nolint:goerr113 // one-off
+		return nil, errors.Join(ErrSAMLMetadata, fmt.Errorf("get %s: unexpected status %d", c.cfg.IDPMetadataURL, res.StatusCode))
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(nil, res.Body, maxSAMLMetadataSize))
+	if err != nil {
+		return nil, errors.Join(ErrSAMLMetadata, fmt.Errorf("read %s error %w", c.cfg.IDPMetadataURL, err))
+	}
+
+	idp, err := parseIDPMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.idp = idp
+	c.idpExpiresAt = c.now().Add(c.cfg.MetadataTTL)
+
+	return c.idp, nil
+}
+
+// parseIDPMetadata reads the entity of an identity provider from its
+// metadata, which may be an aggregate of entities.
+func parseIDPMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	var entities saml.EntitiesDescriptor
+
+	if err := xml.Unmarshal(data, &entities); err != nil {
+		var entity saml.EntityDescriptor
+		if err := xml.Unmarshal(data, &entity); err != nil {
+			return nil, errors.Join(ErrSAMLMetadata, err)
+		}
+
+		entities.EntityDescriptors = []saml.EntityDescriptor{entity}
+	}
+
+	for i := range entities.EntityDescriptors {
+		entity := &entities.EntityDescriptors[i]
+
+		for _, descriptor := range entity.IDPSSODescriptors {
+			if slices.ContainsFunc(descriptor.SingleSignOnServices, func(endpoint saml.Endpoint) bool {
+				return endpoint.Binding == saml.HTTPRedirectBinding
+			}) {
+				return entity, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no identity provider with an HTTP-Redirect single sign-on service", ErrSAMLMetadata)
+}
+
+// authnRequest returns the URL of a signed authentication request at the
+// identity provider, and the ID of the request.
+func (c *SAMLConnection) authnRequest(ctx context.Context) (string, string, error) {
+	idp, err := c.idpMetadata(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	sp := c.serviceProvider(idp)
+
+	req, err := sp.MakeAuthenticationRequest(
+		sp.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		saml.HTTPRedirectBinding,
+		saml.HTTPPostBinding,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("make SAML authentication request error %w", err)
+	}
+
+	redirectURL, err := req.Redirect("", sp)
+	if err != nil {
+		return "", "", fmt.Errorf("sign SAML authentication request error %w", err)
+	}
+
+	return redirectURL.String(), req.ID, nil
+}
+
+// VerifyResponse verifies a base64 encoded SAML response posted to our
+// assertion consumer service, in response to the request of requestID, and
+// returns the identity its assertion asserts. The identity provider is
+// trusted with the e-mail addresses of its users.
+func (c *SAMLConnection) VerifyResponse(
+	ctx context.Context,
+	samlResponse, requestID string,
+) (*OIDCIdentity, error) {
+	idp, err := c.idpMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidSAMLResponse, err)
+	}
+
+	// the signature, issuer, audience, recipient, request ID and validity
+	// period are all checked
+	assertion, err := c.serviceProvider(idp).ParseXMLResponse(data, []string{requestID})
+	if err != nil {
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) {
+			err = invalidErr.PrivateErr
+		}
+
+		return nil, errors.Join(ErrInvalidSAMLResponse, err)
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("%w: no NameID", ErrInvalidSAMLResponse)
+	}
+
+	// a bearer assertion must be confirmed, which checks the request ID
+	if len(assertion.Subject.SubjectConfirmations) == 0 {
+		return nil, fmt.Errorf("%w: no subject confirmation", ErrInvalidSAMLResponse)
+	}
+
+	nameID := assertion.Subject.NameID
+	if nameID.Format == string(saml.TransientNameIDFormat) {
+		return nil, fmt.Errorf("%w: a transient NameID does not identify users", ErrInvalidSAMLResponse)
+	}
+
+	mapping := c.cfg.Attributes
+	identity := &OIDCIdentity{
+		Provider:      c.cfg.Name,
+		Subject:       nameID.Value,
+		Email:         samlAttribute(assertion, mapping.Email),
+		EmailVerified: true,
+		Username:      samlAttribute(assertion, mapping.Username),
+		FirstName:     samlAttribute(assertion, mapping.FirstName),
+		LastName:      samlAttribute(assertion, mapping.LastName),
+	}
+
+	if identity.Email == "" && nameID.Format == string(saml.EmailAddressNameIDFormat) {
+		identity.Email = nameID.Value
+	}
+
+	return identity, nil
+}
+
+// samlAttribute returns the first value of the attribute of an assertion
+// with the name or friendly name.
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (attribute.Name == name || attribute.FriendlyName == name) && len(attribute.Values) > 0 {
+				return attribute.Values[0].Value
+			}
+		}
+	}
+
+	return ""
+}
+
+// samlLoginClaims are the claims of a SAML flow token.
+type samlLoginClaims struct {
+	Connection string `json:"connection"`
+	RequestID  string `json:"request_id"`
+	RedirectTo string `json:"redirect_to,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// WithSAMLConnections enables signing in with SAML 2.0 identity providers.
+func WithSAMLConnections(connections ...*SAMLConnection) Option {
+	return func(a *authService) {
+		a.samlConnections = make(map[string]*SAMLConnection, len(connections))
+
+		for _, connection := range connections {
+			a.samlConnections[connection.Name()] = connection
+		}
+	}
+}
+
+func (a *authService) samlConnection(name string) (*SAMLConnection, error) {
+	connection, ok := a.samlConnections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSAMLConnection, name)
+	}
+
+	return connection, nil
+}
+
+// SAMLMetadata returns our service provider metadata for a connection.
+func (a *authService) SAMLMetadata(connectionName string) ([]byte, error) {
+	connection, err := a.samlConnection(connectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.Metadata()
+}
+
+// StartSAMLLogin starts signing in with the identity provider of a
+// connection. The ID of the authentication request is kept in the returned
+// flow token, which AuthorizationURL sends it with. redirectTo is an
+// optional frontend path the user is sent back to.
+func (a *authService) StartSAMLLogin(
+	ctx context.Context,
+	connectionName, redirectTo string,
+) (*OIDCLogin, error) {
+	connection, err := a.samlConnection(connectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.loginRedirectURL == "" {
+		return nil, fmt.Errorf("%w: %q", ErrOIDCLoginNotSupported, connectionName)
+	}
+
+	if !validRedirectTo(redirectTo) {
+		return nil, ErrInvalidRedirectTo
+	}
+
+	redirectURL, requestID, err := connection.authnRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, err := random.Token(oidcLoginSecretSize)
+	if err != nil {
+		return nil, fmt.Errorf("generate sign-in secret error %w", err)
+	}
+
+	now := time.Now()
+	claims := &samlLoginClaims{
+		Connection: connection.Name(),
+		RequestID:  requestID,
+		RedirectTo: redirectTo,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCLoginDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{SAMLLoginAudience},
+		},
+	}
+
+	flowToken, err := a.keyRing.Sign(claims)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	return &OIDCLogin{
+		AuthorizationURL: redirectURL,
+		FlowToken:        flowToken,
+		ExpiresAt:        claims.ExpiresAt.Time,
+	}, nil
+}
+
+// FinishSAMLLogin finishes a sign-in started with StartSAMLLogin, at our
+// assertion consumer service. The response has to be posted by the browser
+// holding the flow token, in response to its request; IdP-initiated
+// sign-ins are not accepted. Failures past the flow token check are
+// *OIDCLoginError.
+func (a *authService) FinishSAMLLogin(
+	ctx context.Context,
+	connectionName, flowToken, samlResponse string,
+) (*OIDCLoginResult, error) {
+	connection, err := a.samlConnection(connectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &samlLoginClaims{}
+	if err := a.parseFlowToken(flowToken, SAMLLoginAudience, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Connection != connection.Name() {
+		return nil, fmt.Errorf("%w: connection mismatch", ErrInvalidLoginState)
+	}
+
+	fail := func(code string, err error) (*OIDCLoginResult, error) {
+		return nil, &OIDCLoginError{Code: code, RedirectTo: claims.RedirectTo, Err: err}
+	}
+
+	identity, err := connection.VerifyResponse(ctx, samlResponse, claims.RequestID)
+	if err != nil {
+		var badStatus saml.ErrBadStatus
+
+		switch {
+		case errors.As(err, &badStatus):
+			// e.g. the user cancelled, or is not assigned to us
+			return fail(LoginErrorAccessDenied, err)
+		case errors.Is(err, ErrInvalidSAMLResponse):
+			return fail(LoginErrorInvalidAssertion, err)
+		default:
+			return fail(LoginErrorServerError, err)
+		}
+	}
+
+	token, err := a.signInWithIdentity(ctx, identity)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.Is(err, ErrInvalidIDToken):
+			return fail(LoginErrorInvalidAssertion, err)
+		case errors.As(err, &notFoundErr):
+			return fail(LoginErrorUserNotFound, err)
+		case errors.Is(err, ErrIdentityNotLinked):
+			return fail(LoginErrorIdentityNotLinked, err)
+		default:
+			return fail(LoginErrorServerError, err)
+		}
+	}
+
+	return &OIDCLoginResult{Token: token, RedirectTo: claims.RedirectTo}, nil
+}
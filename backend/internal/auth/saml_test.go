@@ -0,0 +1,648 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v5"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// newSAMLKeyPair returns an RSA key and a self-signed certificate of it.
+func newSAMLKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, cert
+}
+
+// stubSAMLIdP is an identity provider answering the authentication requests
+// of one service provider.
+type stubSAMLIdP struct {
+	idp *saml.IdentityProvider
+	sp  *saml.EntityDescriptor
+}
+
+func newStubSAMLIdP(t *testing.T, entityID string) *stubSAMLIdP {
+	t.Helper()
+
+	key, cert := newSAMLKeyPair(t, entityID)
+
+	s := &stubSAMLIdP{}
+	s.idp = &saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             cert,
+		MetadataURL:             url.URL{Scheme: "https", Host: entityID, Path: "/metadata"},
+		SSOURL:                  url.URL{Scheme: "https", Host: entityID, Path: "/sso"},
+		ServiceProviderProvider: s,
+		SignatureMethod:         dsig.RSASHA256SignatureMethod,
+	}
+
+	return s
+}
+
+func (s *stubSAMLIdP) GetServiceProvider(_ *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	if s.sp == nil || s.sp.EntityID != serviceProviderID {
+		return nil, os.ErrNotExist
+	}
+
+	return s.sp, nil
+}
+
+func (s *stubSAMLIdP) metadata(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := xml.Marshal(s.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// trust sets the identity provider up with the metadata of a connection.
+func (s *stubSAMLIdP) trust(t *testing.T, connection *SAMLConnection) {
+	t.Helper()
+
+	data, err := connection.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.sp = &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, s.sp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withoutEncryption returns the identity provider answering with assertions
+// in plain text, as for a service provider without an encryption key, so
+// that they can be tampered with.
+func (s *stubSAMLIdP) withoutEncryption() *stubSAMLIdP {
+	plain := &stubSAMLIdP{sp: &saml.EntityDescriptor{}}
+
+	idp := *s.idp
+	idp.ServiceProviderProvider = plain
+	plain.idp = &idp
+
+	*plain.sp = *s.sp
+	plain.sp.SPSSODescriptors = slices.Clone(s.sp.SPSSODescriptors)
+	plain.sp.SPSSODescriptors[0].KeyDescriptors = slices.DeleteFunc(
+		slices.Clone(s.sp.SPSSODescriptors[0].KeyDescriptors),
+		func(descriptor saml.KeyDescriptor) bool { return descriptor.Use == "encryption" },
+	)
+
+	return plain
+}
+
+// respond checks the signature of an authentication request sent with the
+// HTTP-Redirect binding and returns the SAML response of the session.
+func (s *stubSAMLIdP) respond(t *testing.T, authnURL string, session *saml.Session) string {
+	t.Helper()
+
+	u, err := url.Parse(authnURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SAML bindings section 3.4.4.1: the signature is of the raw query
+	signed, signature, ok := strings.Cut(u.RawQuery, "&Signature=")
+	if !ok {
+		t.Fatal("the authentication request is not signed")
+	}
+
+	signature, _ = url.QueryUnescape(signature)
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	spCert := s.sp.SPSSODescriptors[0].KeyDescriptors[0].KeyInfo.X509Data.X509Certificates[0].Data
+
+	der, _ := base64.StdEncoding.DecodeString(spCert)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("authentication request signature error = %v", err)
+	}
+
+	req, err := saml.NewIdpAuthnRequest(s.idp, httptest.NewRequest(http.MethodGet, authnURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		t.Fatalf("authentication request error = %v", err)
+	}
+
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := req.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return form.SAMLResponse
+}
+
+func newStubSAMLConnection(t *testing.T, idpMetadata []byte, modify func(cfg *SAMLConnectionConfig)) *SAMLConnection {
+	t.Helper()
+
+	key, cert := newSAMLKeyPair(t, "goadmin")
+	cfg := SAMLConnectionConfig{
+		Name:        "adfs",
+		MetadataURL: "https://api.example.com/auth/saml/adfs/metadata",
+		ACSURL:      "https://api.example.com/auth/saml/adfs/acs",
+		Key:         key,
+		Certificate: cert,
+		IDPMetadata: idpMetadata,
+		// the stub identity provider is trusted with e-mail addresses
+		Provisioning: ProvisioningPolicy{LinkByEmail: true},
+	}
+
+	if modify != nil {
+		modify(&cfg)
+	}
+
+	connection, err := NewSAMLConnection(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return connection
+}
+
+func TestNewSAMLConnection(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+	key, cert := newSAMLKeyPair(t, "goadmin")
+	otherKey, _ := newSAMLKeyPair(t, "other")
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *SAMLConnectionConfig)
+		wantErr bool
+	}{
+		{name: "Success", modify: func(*SAMLConnectionConfig) {}},
+		{name: "Metadata URL", modify: func(cfg *SAMLConnectionConfig) {
+			cfg.IDPMetadata, cfg.IDPMetadataURL = nil, "https://idp.example.com/metadata"
+		}},
+		{name: "No Name", modify: func(cfg *SAMLConnectionConfig) { cfg.Name = "" }, wantErr: true},
+		{name: "Relative ACS URL", modify: func(cfg *SAMLConnectionConfig) { cfg.ACSURL = "/acs" }, wantErr: true},
+		{name: "Key Mismatch", modify: func(cfg *SAMLConnectionConfig) { cfg.Key = otherKey }, wantErr: true},
+		{name: "No Certificate", modify: func(cfg *SAMLConnectionConfig) { cfg.Certificate = nil }, wantErr: true},
+		{name: "No Metadata", modify: func(cfg *SAMLConnectionConfig) { cfg.IDPMetadata = nil }, wantErr: true},
+		{
+			name:    "Both Metadata",
+			modify:  func(cfg *SAMLConnectionConfig) { cfg.IDPMetadataURL = "https://idp.example.com/metadata" },
+			wantErr: true,
+		},
+		{name: "Invalid Metadata", modify: func(cfg *SAMLConnectionConfig) { cfg.IDPMetadata = []byte("<html/>") }, wantErr: true},
+		{
+			name:    "Transient NameID",
+			modify:  func(cfg *SAMLConnectionConfig) { cfg.NameIDFormat = string(saml.TransientNameIDFormat) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := SAMLConnectionConfig{
+				Name:        "adfs",
+				MetadataURL: "https://api.example.com/auth/saml/adfs/metadata",
+				ACSURL:      "https://api.example.com/auth/saml/adfs/acs",
+				Key:         key,
+				Certificate: cert,
+				IDPMetadata: idp.metadata(t),
+			}
+			tt.modify(&cfg)
+
+			_, err := NewSAMLConnection(cfg, http.DefaultClient)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidSAMLConnection)) {
+				t.Errorf("NewSAMLConnection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSAMLConnection_Metadata(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+	connection := newStubSAMLConnection(t, idp.metadata(t), nil)
+
+	data, err := connection.Metadata()
+	if err != nil {
+		t.Fatalf("SAMLConnection.Metadata() error = %v", err)
+	}
+
+	var metadata saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("SAMLConnection.Metadata() = %s, error = %v", data, err)
+	}
+
+	if metadata.EntityID != "https://api.example.com/auth/saml/adfs/metadata" || len(metadata.SPSSODescriptors) != 1 {
+		t.Fatalf("SAMLConnection.Metadata() = %+v", metadata)
+	}
+
+	descriptor := metadata.SPSSODescriptors[0]
+	acs := descriptor.AssertionConsumerServices
+
+	if len(acs) != 1 || acs[0].Binding != saml.HTTPPostBinding || acs[0].Location != "https://api.example.com/auth/saml/adfs/acs" {
+		t.Errorf("SAMLConnection.Metadata() assertion consumer services = %+v, want HTTP-POST only", acs)
+	}
+
+	if descriptor.AuthnRequestsSigned == nil || !*descriptor.AuthnRequestsSigned ||
+		descriptor.NameIDFormats[0] != saml.PersistentNameIDFormat {
+		t.Errorf("SAMLConnection.Metadata() = %+v, want signed requests of persistent NameIDs", descriptor)
+	}
+}
+
+func TestSAMLConnection_idpMetadata(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+
+	var fetches atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+
+		if req.URL.Path != "/metadata" {
+			http.NotFound(res, req)
+
+			return
+		}
+
+		_, _ = res.Write(idp.metadata(t))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	connection := newStubSAMLConnection(t, nil, func(cfg *SAMLConnectionConfig) {
+		cfg.IDPMetadataURL = server.URL + "/metadata"
+		cfg.MetadataTTL = time.Hour
+	})
+	connection.now = func() time.Time { return now }
+
+	for range 2 {
+		if _, _, err := connection.authnRequest(ctx); err != nil {
+			t.Fatalf("SAMLConnection.authnRequest() error = %v", err)
+		}
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("metadata fetched %d times, want once while cached", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+
+	if _, _, err := connection.authnRequest(ctx); err != nil || fetches.Load() != 2 {
+		t.Errorf("SAMLConnection.authnRequest() error = %v, fetches %d, want the metadata fetched again", err, fetches.Load())
+	}
+
+	missing := newStubSAMLConnection(t, nil, func(cfg *SAMLConnectionConfig) {
+		cfg.IDPMetadataURL = server.URL + "/missing"
+	})
+
+	if _, _, err := missing.authnRequest(ctx); !errors.Is(err, ErrSAMLMetadata) {
+		t.Errorf("SAMLConnection.authnRequest() error = %v, want %v", err, ErrSAMLMetadata)
+	}
+}
+
+func newSAMLLoginService(t *testing.T, idp *stubSAMLIdP, modify func(cfg *SAMLConnectionConfig)) *authService {
+	t.Helper()
+
+	connection := newStubSAMLConnection(t, idp.metadata(t), modify)
+	idp.trust(t, connection)
+
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+	}
+	WithSAMLConnections(connection)(a)
+	WithLoginRedirect("http://localhost:3000/auth/callback")(a)
+
+	return a
+}
+
+func Test_authService_StartSAMLLogin(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+	a := newSAMLLoginService(t, idp, nil)
+
+	tests := []struct {
+		name       string
+		a          *authService
+		connection string
+		redirectTo string
+		wantErr    error
+	}{
+		{name: "Success", a: a, connection: "adfs", redirectTo: "/users?page=2"},
+		{name: "Unknown Connection", a: a, connection: "okta", wantErr: ErrUnknownSAMLConnection},
+		{name: "Absolute Redirect", a: a, connection: "adfs", redirectTo: "https://evil.example.com", wantErr: ErrInvalidRedirectTo},
+		{
+			name:       "Not Enabled",
+			a:          &authService{samlConnections: a.samlConnections, keyRing: a.keyRing},
+			connection: "adfs",
+			wantErr:    ErrOIDCLoginNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			login, err := tt.a.StartSAMLLogin(context.Background(), tt.connection, tt.redirectTo)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.StartSAMLLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(login.AuthorizationURL, "https://idp.example.com/sso?SAMLRequest=") {
+				t.Errorf("authService.StartSAMLLogin() AuthorizationURL = %q", login.AuthorizationURL)
+			}
+
+			req, err := saml.NewIdpAuthnRequest(idp.idp, httptest.NewRequest(http.MethodGet, login.AuthorizationURL, nil))
+			if err != nil || req.Validate() != nil {
+				t.Fatalf("authService.StartSAMLLogin() request error = %v", err)
+			}
+
+			claims := &samlLoginClaims{}
+			if _, err := jwt.ParseWithClaims(login.FlowToken, claims, a.keyRing.Keyfunc); err != nil {
+				t.Fatalf("parse flow token error = %v", err)
+			}
+
+			if claims.RequestID != req.Request.ID || claims.Connection != "adfs" || claims.RedirectTo != tt.redirectTo {
+				t.Errorf("authService.StartSAMLLogin() flow token claims = %+v, want request %q", claims, req.Request.ID)
+			}
+		})
+	}
+}
+
+func Test_authService_FinishSAMLLogin(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+	otherIdP := newStubSAMLIdP(t, "idp.example.com")
+	a := newSAMLLoginService(t, idp, nil)
+	otherIdP.sp = idp.sp
+
+	plainIdP := idp.withoutEncryption()
+
+	session := func(nameIDFormat, email string) *saml.Session {
+		return &saml.Session{
+			NameID:       "alice-1",
+			NameIDFormat: nameIDFormat,
+			CustomAttributes: []saml.Attribute{{
+				Name:   "mail",
+				Values: []saml.AttributeValue{{Value: email}},
+			}},
+		}
+	}
+
+	persistent := string(saml.PersistentNameIDFormat)
+
+	tests := []struct {
+		name       string
+		connection string
+		respond    func(t *testing.T, login, other *OIDCLogin) (string, string)
+		wantErr    error
+		wantCode   string
+	}{
+		{
+			name:       "Success",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return login.FlowToken, idp.respond(t, login.AuthorizationURL, session(persistent, "user@example.com"))
+			},
+		},
+		{
+			name:       "Unknown Connection",
+			connection: "okta",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return login.FlowToken, idp.respond(t, login.AuthorizationURL, session(persistent, "user@example.com"))
+			},
+			wantErr: ErrUnknownSAMLConnection,
+		},
+		{
+			name:       "No Flow Token",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return "", idp.respond(t, login.AuthorizationURL, session(persistent, "user@example.com"))
+			},
+			wantErr: ErrInvalidLoginState,
+		},
+		{
+			name:       "Response To Another Request",
+			connection: "adfs",
+			respond: func(t *testing.T, login, other *OIDCLogin) (string, string) {
+				return login.FlowToken, idp.respond(t, other.AuthorizationURL, session(persistent, "user@example.com"))
+			},
+			wantCode: LoginErrorInvalidAssertion,
+		},
+		{
+			name:       "Tampered",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				response := plainIdP.respond(t, login.AuthorizationURL, session(persistent, "nobody@example.com"))
+				data, _ := base64.StdEncoding.DecodeString(response)
+				if !strings.Contains(string(data), "nobody@example.com") {
+					t.Fatal("the assertion is not in plain text")
+				}
+
+				data = []byte(strings.ReplaceAll(string(data), "nobody@example.com", "user@example.com"))
+
+				return login.FlowToken, base64.StdEncoding.EncodeToString(data)
+			},
+			wantCode: LoginErrorInvalidAssertion,
+		},
+		{
+			name:       "Signed By Another IdP",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return login.FlowToken, otherIdP.respond(t, login.AuthorizationURL, session(persistent, "user@example.com"))
+			},
+			wantCode: LoginErrorInvalidAssertion,
+		},
+		{
+			name:       "Transient NameID",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return login.FlowToken, idp.respond(t, login.AuthorizationURL, session("", "user@example.com"))
+			},
+			wantCode: LoginErrorInvalidAssertion,
+		},
+		{
+			name:       "Unknown User",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				return login.FlowToken, idp.respond(t, login.AuthorizationURL, session(persistent, "nobody@example.com"))
+			},
+			wantCode: LoginErrorUserNotFound,
+		},
+		{
+			name:       "Denied",
+			connection: "adfs",
+			respond: func(t *testing.T, login, _ *OIDCLogin) (string, string) {
+				claims := &samlLoginClaims{}
+				if _, err := jwt.ParseWithClaims(login.FlowToken, claims, a.keyRing.Keyfunc); err != nil {
+					t.Fatal(err)
+				}
+
+				response := fmt.Sprintf(
+					`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="id-1" Version="2.0" `+
+						`IssueInstant="%s" InResponseTo="%s"><samlp:Status><samlp:StatusCode `+
+						`Value="urn:oasis:names:tc:SAML:2.0:status:Responder"/></samlp:Status></samlp:Response>`,
+					time.Now().UTC().Format(time.RFC3339), claims.RequestID,
+				)
+
+				return login.FlowToken, base64.StdEncoding.EncodeToString([]byte(response))
+			},
+			wantCode: LoginErrorAccessDenied,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			login, err := a.StartSAMLLogin(ctx, "adfs", "/users")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			other, err := a.StartSAMLLogin(ctx, "adfs", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			flowToken, samlResponse := tt.respond(t, login, other)
+
+			result, err := a.FinishSAMLLogin(ctx, tt.connection, flowToken, samlResponse)
+
+			var loginErr *OIDCLoginError
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("authService.FinishSAMLLogin() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			case tt.wantCode != "":
+				if !errors.As(err, &loginErr) || loginErr.Code != tt.wantCode || loginErr.RedirectTo != "/users" {
+					t.Errorf("authService.FinishSAMLLogin() error = %v, want code %q", err, tt.wantCode)
+				}
+			case err != nil:
+				t.Errorf("authService.FinishSAMLLogin() error = %v", err)
+			case result.Token.AccessToken == "" || result.RedirectTo != "/users":
+				t.Errorf("authService.FinishSAMLLogin() = %+v", result)
+			}
+		})
+	}
+}
+
+func TestSAMLConnection_VerifyResponse(t *testing.T) {
+	t.Parallel()
+
+	idp := newStubSAMLIdP(t, "idp.example.com")
+	connection := newStubSAMLConnection(t, idp.metadata(t), func(cfg *SAMLConnectionConfig) {
+		cfg.Attributes = SAMLAttributeMapping{Username: "eduPersonPrincipalName", FirstName: "cn"}
+	})
+	idp.trust(t, connection)
+
+	authnURL, requestID, err := connection.authnRequest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := idp.respond(t, authnURL, &saml.Session{
+		NameID:         "alice@example.com",
+		NameIDFormat:   string(saml.EmailAddressNameIDFormat),
+		UserEmail:      "alice@corp.example.com",
+		UserCommonName: "Alice",
+		UserSurname:    "Smith",
+	})
+
+	identity, err := connection.VerifyResponse(context.Background(), response, requestID)
+	if err != nil {
+		t.Fatalf("SAMLConnection.VerifyResponse() error = %v", err)
+	}
+
+	want := OIDCIdentity{
+		Provider:      "adfs",
+		Subject:       "alice@example.com",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Username:      "alice@corp.example.com",
+		FirstName:     "Alice",
+		LastName:      "Smith",
+	}
+
+	if *identity != want {
+		t.Errorf("SAMLConnection.VerifyResponse() = %+v, want %+v", *identity, want)
+	}
+}
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// ServiceAccountClientIDPrefix starts the client ID of every service
+	// account.
+	ServiceAccountClientIDPrefix = "sa_"
+
+	// clientIDSize and clientSecretSize are the number of random bytes in
+	// client IDs and secrets.
+	clientIDSize     = 12
+	clientSecretSize = 32
+
+	// GrantTypeClientCredentials is the only OAuth2 grant of the token
+	// endpoint.
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+var (
+	ErrServiceAccountsNotSupported = errors.New("service accounts are not enabled")
+	ErrInvalidClient               = errors.New("invalid client")
+	ErrInvalidScope                = errors.New("scope not granted")
+)
+
+// WithServiceAccountRepo enables service accounts and the client credentials
+// grant.
+func WithServiceAccountRepo(repo domain.ServiceAccountRepository) Option {
+	return func(a *authService) {
+		a.serviceAccountRepo = repo
+	}
+}
+
+// CreateServiceAccount registers a machine client granted scopes. The client
+// secret is only returned here, just its hash is stored.
+func (a *authService) CreateServiceAccount(
+	ctx context.Context,
+	name string,
+	scopes []string,
+	createdBy string,
+) (*domain.ServiceAccount, string, error) {
+	if a.serviceAccountRepo == nil {
+		return nil, "", ErrServiceAccountsNotSupported
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(APIKeyScopes(), scope) {
+			return nil, "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+
+	clientID, err := random.Token(clientIDSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate client id error %w", err)
+	}
+
+	secret, err := random.Token(clientSecretSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate client secret error %w", err)
+	}
+
+	scopes = append([]string{}, scopes...)
+	slices.Sort(scopes)
+
+	account := &domain.ServiceAccount{
+		Name:             name,
+		ClientID:         ServiceAccountClientIDPrefix + clientID,
+		ClientSecretHash: hashToken(secret),
+		Scopes:           slices.Compact(scopes),
+	}
+
+	if createdBy != "" {
+		account.CreatedBy = &createdBy
+	}
+
+	account, err = a.serviceAccountRepo.Create(ctx, account)
+	if err != nil {
+		return nil, "", fmt.Errorf("create service account error %w", err)
+	}
+
+	return account, secret, nil
+}
+
+// ListServiceAccounts returns every service account
+func (a *authService) ListServiceAccounts(ctx context.Context) ([]*domain.ServiceAccount, error) {
+	if a.serviceAccountRepo == nil {
+		return nil, ErrServiceAccountsNotSupported
+	}
+
+	accounts, err := a.serviceAccountRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find service accounts error %w", err)
+	}
+
+	return accounts, nil
+}
+
+// DeleteServiceAccount removes a service account. The tokens it was issued
+// stop working with it.
+func (a *authService) DeleteServiceAccount(ctx context.Context, id string) error {
+	if a.serviceAccountRepo == nil {
+		return ErrServiceAccountsNotSupported
+	}
+
+	if err := a.serviceAccountRepo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete service account error %w", err)
+	}
+
+	return nil
+}
+
+// ClientCredentials implements the OAuth2 client credentials grant: it
+// issues an access token to a service account. The token carries the
+// requested scopes, or every scope of the account when none are requested.
+// No refresh token is issued, clients request a new token instead.
+func (a *authService) ClientCredentials(
+	ctx context.Context,
+	clientID, clientSecret string,
+	scopes []string,
+) (*domain.JWTToken, []string, error) {
+	if a.serviceAccountRepo == nil {
+		return nil, nil, ErrServiceAccountsNotSupported
+	}
+
+	account, err := a.serviceAccountRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, nil, ErrInvalidClient
+		}
+
+		return nil, nil, fmt.Errorf("find service account error %w", err)
+	}
+
+	secretHash := hashToken(clientSecret)
+	if subtle.ConstantTimeCompare([]byte(secretHash), []byte(account.ClientSecretHash)) != 1 {
+		return nil, nil, ErrInvalidClient
+	}
+
+	if len(scopes) == 0 {
+		scopes = account.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(account.Scopes, scope) {
+			return nil, nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	tokenID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate token id error %w", err)
+	}
+
+	now := time.Now()
+	claims := &domain.JWTClaims{
+		TokenType:     domain.TokenTypeAccess,
+		PrincipalType: domain.PrincipalTypeServiceAccount,
+		Scope:         strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   account.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(DefaultTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
+		},
+	}
+
+	tokenString, err := a.keyRing.Sign(claims)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // already wrapped by the key ring
+	}
+
+	if err := a.serviceAccountRepo.Touch(ctx, account.ID, now); err != nil {
+		return nil, nil, fmt.Errorf("touch service account error %w", err)
+	}
+
+	return &domain.JWTToken{AccessToken: tokenString}, scopes, nil
+}
+
+// VerifyServiceAccountToken checks an access token issued by the client
+// credentials grant and returns its service account, with Scopes narrowed
+// to the scopes of the token.
+func (a *authService) VerifyServiceAccountToken(
+	ctx context.Context,
+	tokenString string,
+) (*domain.ServiceAccount, error) {
+	if a.serviceAccountRepo == nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := a.parseToken(
+		tokenString,
+		domain.TokenTypeAccess,
+		AccessTokenAudience,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Principal() != domain.PrincipalTypeServiceAccount {
+		return nil, ErrInvalidToken
+	}
+
+	isRevoked, err := a.revokedTokenRepo.IsRevoked(ctx, revocationID(claims, tokenString))
+	if err != nil {
+		return nil, fmt.Errorf("check revoked token error %w", err)
+	}
+
+	if isRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	account, err := a.serviceAccountRepo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	// the account may have lost scopes since the token was issued
+	account.Scopes = slices.DeleteFunc(strings.Fields(claims.Scope), func(scope string) bool {
+		return !slices.Contains(account.Scopes, scope)
+	})
+
+	return account, nil
+}
+
+// tokenPrincipal returns the principal type claimed by a JWT without
+// verifying it. It only picks the verifier, which checks the claim again.
+func tokenPrincipal(tokenString string) string {
+	claims := &domain.JWTClaims{}
+
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return domain.PrincipalTypeUser
+	}
+
+	return claims.Principal()
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func newServiceAccountService(t *testing.T) (*authService, *domain.ServiceAccount, string) {
+	t.Helper()
+
+	a := &authService{
+		userRepo:           &UserRepositoryMock{},
+		revokedTokenRepo:   &RevokedTokenRepositoryMock{},
+		serviceAccountRepo: &ServiceAccountRepositoryMock{},
+		keyRing:            NewHMACKeyRing([]byte("secret")),
+	}
+
+	account, secret, err := a.CreateServiceAccount(
+		context.Background(),
+		"billing sync",
+		[]string{ScopeUsersWrite, ScopeUsersRead},
+		"1",
+	)
+	if err != nil {
+		t.Fatalf("authService.CreateServiceAccount() error = %v", err)
+	}
+
+	return a, account, secret
+}
+
+func Test_authService_CreateServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	_, account, secret := newServiceAccountService(t)
+
+	if account.ClientSecretHash != hashToken(secret) {
+		t.Errorf("authService.CreateServiceAccount() stored %v, want the hash of the secret", account.ClientSecretHash)
+	}
+
+	if !reflect.DeepEqual(account.Scopes, []string{ScopeUsersRead, ScopeUsersWrite}) {
+		t.Errorf("authService.CreateServiceAccount() scopes = %v", account.Scopes)
+	}
+
+	if account.CreatedBy == nil || *account.CreatedBy != "1" {
+		t.Errorf("authService.CreateServiceAccount() created by = %v, want 1", account.CreatedBy)
+	}
+
+	a := &authService{serviceAccountRepo: &ServiceAccountRepositoryMock{}}
+
+	_, _, err := a.CreateServiceAccount(context.Background(), "bad", []string{"users:delete"}, "")
+	if !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("authService.CreateServiceAccount() error = %v, want %v", err, ErrUnknownScope)
+	}
+
+	if _, _, err := (&authService{}).CreateServiceAccount(context.Background(), "bad", nil, ""); !errors.Is(err, ErrServiceAccountsNotSupported) {
+		t.Errorf("authService.CreateServiceAccount() error = %v, want %v", err, ErrServiceAccountsNotSupported)
+	}
+}
+
+func Test_authService_ClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	a, account, secret := newServiceAccountService(t)
+
+	tests := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		scopes       []string
+		wantScopes   []string
+		wantErr      error
+	}{
+		{
+			name:         "Every Scope",
+			clientID:     account.ClientID,
+			clientSecret: secret,
+			wantScopes:   []string{ScopeUsersRead, ScopeUsersWrite},
+		},
+		{
+			name:         "Requested Scope",
+			clientID:     account.ClientID,
+			clientSecret: secret,
+			scopes:       []string{ScopeUsersRead},
+			wantScopes:   []string{ScopeUsersRead},
+		},
+		{
+			name:         "Scope Not Granted",
+			clientID:     account.ClientID,
+			clientSecret: secret,
+			scopes:       []string{"users:delete"},
+			wantErr:      ErrInvalidScope,
+		},
+		{name: "Wrong Secret", clientID: account.ClientID, clientSecret: "wrong", wantErr: ErrInvalidClient},
+		{name: "Unknown Client", clientID: "sa_unknown", clientSecret: secret, wantErr: ErrInvalidClient},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			token, scopes, err := a.ClientCredentials(ctx, tt.clientID, tt.clientSecret, tt.scopes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authService.ClientCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if token.RefreshToken != "" {
+				t.Errorf("authService.ClientCredentials() issued a refresh token")
+			}
+
+			if !reflect.DeepEqual(scopes, tt.wantScopes) {
+				t.Errorf("authService.ClientCredentials() scopes = %v, want %v", scopes, tt.wantScopes)
+			}
+
+			verified, err := a.VerifyServiceAccountToken(ctx, token.AccessToken)
+			if err != nil {
+				t.Fatalf("authService.VerifyServiceAccountToken() error = %v", err)
+			}
+
+			if verified.ID != account.ID || !reflect.DeepEqual(verified.Scopes, tt.wantScopes) {
+				t.Errorf("authService.VerifyServiceAccountToken() = %+v, want scopes %v", verified, tt.wantScopes)
+			}
+
+			// the token is not mistaken for the token of a user
+			if _, err := a.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("authService.VerifyToken() error = %v, want %v", err, ErrInvalidToken)
+			}
+
+			if got := tokenPrincipal(token.AccessToken); got != domain.PrincipalTypeServiceAccount {
+				t.Errorf("tokenPrincipal() = %v, want %v", got, domain.PrincipalTypeServiceAccount)
+			}
+		})
+	}
+}
+
+func Test_authService_VerifyServiceAccountToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a, account, secret := newServiceAccountService(t)
+
+	userToken, err := a.Login(ctx, domain.Credentials{Username: "username", Password: "password"})
+	if err != nil {
+		t.Fatalf("authService.Login() error = %v", err)
+	}
+
+	if _, err := a.VerifyServiceAccountToken(ctx, userToken.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyServiceAccountToken() of a user token error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if got := tokenPrincipal(userToken.AccessToken); got != domain.PrincipalTypeUser {
+		t.Errorf("tokenPrincipal() = %v, want %v", got, domain.PrincipalTypeUser)
+	}
+
+	token, _, err := a.ClientCredentials(ctx, account.ClientID, secret, nil)
+	if err != nil {
+		t.Fatalf("authService.ClientCredentials() error = %v", err)
+	}
+
+	if err := a.DeleteServiceAccount(ctx, account.ID); err != nil {
+		t.Fatalf("authService.DeleteServiceAccount() error = %v", err)
+	}
+
+	if _, err := a.VerifyServiceAccountToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyServiceAccountToken() of a deleted account error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if accounts, _ := a.ListServiceAccounts(ctx); len(accounts) != 0 {
+		t.Errorf("authService.ListServiceAccounts() = %v, want none", accounts)
+	}
+}
+
+var _ domain.ServiceAccountRepository = &ServiceAccountRepositoryMock{}
+
+type ServiceAccountRepositoryMock struct {
+	mu       sync.Mutex
+	nextID   int
+	accounts map[string]*domain.ServiceAccount
+}
+
+func (r *ServiceAccountRepositoryMock) Create(
+	_ context.Context,
+	account *domain.ServiceAccount,
+) (*domain.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.accounts == nil {
+		r.accounts = make(map[string]*domain.ServiceAccount)
+	}
+
+	r.nextID++
+
+	created := *account
+	created.ID = strconv.Itoa(r.nextID)
+	created.CreatedAt = time.Now()
+	r.accounts[created.ID] = &created
+
+	found := created
+
+	return &found, nil
+}
+
+func (r *ServiceAccountRepositoryMock) FindByID(
+	_ context.Context,
+	id string,
+) (*domain.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("ServiceAccount", "id="+id)
+	}
+
+	found := *account
+
+	return &found, nil
+}
+
+func (r *ServiceAccountRepositoryMock) FindByClientID(
+	_ context.Context,
+	clientID string,
+) (*domain.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			found := *account
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("ServiceAccount", "client_id="+clientID)
+}
+
+func (r *ServiceAccountRepositoryMock) FindAll(_ context.Context) ([]*domain.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	accounts := make([]*domain.ServiceAccount, 0, len(r.accounts))
+
+	for _, account := range r.accounts {
+		found := *account
+		accounts = append(accounts, &found)
+	}
+
+	return accounts, nil
+}
+
+func (r *ServiceAccountRepositoryMock) Touch(
+	_ context.Context,
+	id string,
+	usedAt time.Time,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[id]; ok {
+		account.LastUsedAt = &usedAt
+	}
+
+	return nil
+}
+
+func (r *ServiceAccountRepositoryMock) Delete(
+	_ context.Context,
+	id string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[id]; !ok {
+		return domain.NewResourceNotFoundError("ServiceAccount", "id="+id)
+	}
+
+	delete(r.accounts, id)
+
+	return nil
+}
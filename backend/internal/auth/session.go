@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/cache"
+	"goadmin-backend/internal/platform/random"
+)
+
+var ErrSessionsNotSupported = errors.New("sessions are not enabled")
+
+const clientInfoKey = contextKey("client_info")
+
+// ContextWithClientInfo returns a copy of ctx carrying the client a session
+// is about to be created for.
+func ContextWithClientInfo(ctx context.Context, info domain.ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey, info)
+}
+
+func clientInfoFromContext(ctx context.Context) domain.ClientInfo {
+	info, _ := ctx.Value(clientInfoKey).(domain.ClientInfo)
+
+	return info
+}
+
+// WithSessionRepo enables session tracking. Every sign-in creates a session
+// and tokens are only accepted while their session is active.
+func WithSessionRepo(repo domain.SessionRepository) Option {
+	return func(a *authService) {
+		a.sessionRepo = repo
+		a.activeSessions = cache.NewLRU[string, bool](DefaultRevocationCacheSize)
+	}
+}
+
+// startSession records a new sign-in of user. It returns an empty session ID
+// when sessions are not enabled.
+func (a *authService) startSession(ctx context.Context, user *domain.User) (string, error) {
+	if a.sessionRepo == nil {
+		return "", nil
+	}
+
+	sessionID, err := random.Token(tokenIDSize)
+	if err != nil {
+		return "", fmt.Errorf("generate session id error %w", err)
+	}
+
+	info := clientInfoFromContext(ctx)
+
+	err = a.sessionRepo.Create(ctx, &domain.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: info.UserAgent,
+		IPAddress: info.IPAddress,
+		ExpiresAt: time.Now().Add(a.sessionDuration()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create session error %w", err)
+	}
+
+	return sessionID, nil
+}
+
+// sessionDuration is how long a session lasts without being used: as long
+// as the tokens issued for it.
+func (a *authService) sessionDuration() time.Duration {
+	if a.refreshTokenRepo != nil {
+		return DefaultRefreshTokenDuration
+	}
+
+	return DefaultTokenDuration
+}
+
+// signIn starts a new session for user and issues its first tokens.
+func (a *authService) signIn(ctx context.Context, user *domain.User) (*domain.JWTToken, error) {
+	sessionID, err := a.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.generateToken(ctx, user, "", sessionID)
+}
+
+// checkSession makes sure the session a token was issued for is still
+// active. Tokens issued before sessions were tracked carry no session ID and
+// are only subject to their own expiry.
+func (a *authService) checkSession(ctx context.Context, sessionID string) error {
+	if a.sessionRepo == nil || sessionID == "" {
+		return nil
+	}
+
+	if active, ok := a.activeSessions.Get(sessionID); ok {
+		if !active {
+			return ErrInvalidToken
+		}
+
+		return nil
+	}
+
+	session, err := a.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		var notFoundErr *domain.ResourceNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidToken
+		}
+
+		return fmt.Errorf("find session error %w", err)
+	}
+
+	active := session.Active(time.Now())
+	a.activeSessions.Set(sessionID, active, DefaultRevocationCacheTTL)
+
+	if !active {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// endSession revokes a session and forgets its cached state.
+func (a *authService) endSession(ctx context.Context, sessionID string) error {
+	if err := a.sessionRepo.Revoke(ctx, sessionID); err != nil {
+		return fmt.Errorf("revoke session error %w", err)
+	}
+
+	a.activeSessions.Set(sessionID, false, DefaultRevocationCacheTTL)
+
+	return nil
+}
+
+// ListSessions returns the active sessions of a user
+func (a *authService) ListSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	if a.sessionRepo == nil {
+		return nil, ErrSessionsNotSupported
+	}
+
+	sessions, err := a.sessionRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find sessions error %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs a user out of one of their sessions. Sessions of other
+// users are reported as not found.
+func (a *authService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if a.sessionRepo == nil {
+		return ErrSessionsNotSupported
+	}
+
+	session, err := a.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("find session error %w", err)
+	}
+
+	if session.UserID != userID {
+		return domain.NewResourceNotFoundError("Session", "id="+sessionID)
+	}
+
+	return a.endSession(ctx, sessionID)
+}
+
+// LogoutAll signs a user out everywhere: every session is revoked and with
+// it every access and refresh token issued for them.
+func (a *authService) LogoutAll(ctx context.Context, userID string) error {
+	if a.sessionRepo == nil {
+		return ErrSessionsNotSupported
+	}
+
+	sessions, err := a.sessionRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find sessions error %w", err)
+	}
+
+	if err := a.sessionRepo.RevokeAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("revoke sessions error %w", err)
+	}
+
+	for _, session := range sessions {
+		a.activeSessions.Set(session.ID, false, DefaultRevocationCacheTTL)
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func newSessionAuthService(sessionRepo *SessionRepositoryMock) *authService {
+	a := &authService{
+		userRepo:         &UserRepositoryMock{},
+		revokedTokenRepo: &RevokedTokenRepositoryMock{},
+		refreshTokenRepo: &RefreshTokenRepositoryMock{},
+		keyRing:          NewHMACKeyRing([]byte("secret")),
+		idTokenValidator: &GoogleIDTokenValidatorMock{},
+	}
+
+	WithSessionRepo(sessionRepo)(a)
+
+	return a
+}
+
+func Test_authService_signIn(t *testing.T) {
+	t.Parallel()
+
+	sessionRepo := &SessionRepositoryMock{}
+	a := newSessionAuthService(sessionRepo)
+
+	ctx := ContextWithClientInfo(context.Background(), domain.ClientInfo{
+		UserAgent: "Mozilla/5.0",
+		IPAddress: "127.0.0.1",
+	})
+
+	token, err := a.signIn(ctx, &domain.User{ID: "1", Username: "username"})
+	if err != nil {
+		t.Fatalf("authService.signIn() error = %v", err)
+	}
+
+	sessions, _ := a.ListSessions(ctx, "1")
+	if len(sessions) != 1 || sessions[0].UserAgent != "Mozilla/5.0" || sessions[0].IPAddress != "127.0.0.1" {
+		t.Fatalf("authService.ListSessions() = %v, want one session of the client", sessions)
+	}
+
+	claims, err := a.parseToken(token.RefreshToken, domain.TokenTypeRefresh, RefreshTokenAudience)
+	if err != nil || claims.SessionID != sessions[0].ID {
+		t.Errorf("refresh token sid = %v, want %v (error = %v)", claims, sessions[0].ID, err)
+	}
+
+	refreshed, err := a.RefreshToken(ctx, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("authService.RefreshToken() error = %v", err)
+	}
+
+	if claims, _ := a.parseToken(refreshed.AccessToken, domain.TokenTypeAccess, AccessTokenAudience); claims.SessionID != sessions[0].ID {
+		t.Errorf("refreshed access token sid = %v, want %v", claims.SessionID, sessions[0].ID)
+	}
+}
+
+func Test_authService_LogoutAll(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		sessionRepo *SessionRepositoryMock
+		wantErr     bool
+	}{
+		{
+			name:        "Success",
+			sessionRepo: &SessionRepositoryMock{},
+		},
+		{
+			name:        "Error",
+			sessionRepo: &SessionRepositoryMock{hasError: true},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := newSessionAuthService(&SessionRepositoryMock{})
+			user := &domain.User{ID: "1", Username: "username"}
+			ctx := context.Background()
+
+			first, _ := a.signIn(ctx, user)
+			second, _ := a.signIn(ctx, user)
+
+			// warm up the session cache
+			if _, err := a.VerifyToken(ctx, first.AccessToken); err != nil {
+				t.Fatalf("authService.VerifyToken() error = %v", err)
+			}
+
+			if tt.wantErr {
+				a.sessionRepo = tt.sessionRepo
+			}
+
+			if err := a.LogoutAll(ctx, user.ID); (err != nil) != tt.wantErr {
+				t.Fatalf("authService.LogoutAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			for _, token := range []*domain.JWTToken{first, second} {
+				if _, err := a.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("authService.VerifyToken() error = %v, want %v", err, ErrInvalidToken)
+				}
+
+				if _, err := a.RefreshToken(ctx, token.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("authService.RefreshToken() error = %v, want %v", err, ErrInvalidToken)
+				}
+			}
+		})
+	}
+}
+
+func Test_authService_RevokeSession(t *testing.T) {
+	t.Parallel()
+
+	a := newSessionAuthService(&SessionRepositoryMock{})
+	ctx := context.Background()
+
+	token, _ := a.signIn(ctx, &domain.User{ID: "1", Username: "username"})
+	claims, _ := a.parseToken(token.AccessToken, domain.TokenTypeAccess, AccessTokenAudience)
+
+	tests := []struct {
+		name      string
+		userID    string
+		sessionID string
+		wantErr   bool
+	}{
+		{name: "Session Of Another User", userID: "2", sessionID: claims.SessionID, wantErr: true},
+		{name: "Unknown Session", userID: "1", sessionID: "unknown", wantErr: true},
+		{name: "Success", userID: "1", sessionID: claims.SessionID},
+	}
+	for _, tt := range tests {
+		if err := a.RevokeSession(ctx, tt.userID, tt.sessionID); (err != nil) != tt.wantErr {
+			t.Errorf("%s: authService.RevokeSession() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := a.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authService.VerifyToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if err := (&authService{}).RevokeSession(ctx, "1", "1"); !errors.Is(err, ErrSessionsNotSupported) {
+		t.Errorf("authService.RevokeSession() error = %v, want %v", err, ErrSessionsNotSupported)
+	}
+}
+
+var _ domain.SessionRepository = &SessionRepositoryMock{}
+
+// SessionRepositoryMock keeps sessions in memory.
+type SessionRepositoryMock struct {
+	hasError bool
+
+	mu       sync.Mutex
+	sessions map[string]*domain.Session
+}
+
+func (s *SessionRepositoryMock) Create(_ context.Context, session *domain.Session) error {
+	if s.hasError {
+		return errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions == nil {
+		s.sessions = make(map[string]*domain.Session)
+	}
+
+	stored := *session
+	stored.CreatedAt, stored.LastUsedAt = time.Now(), time.Now()
+	s.sessions[session.ID] = &stored
+
+	return nil
+}
+
+func (s *SessionRepositoryMock) FindByID(_ context.Context, id string) (*domain.Session, error) {
+	if s.hasError {
+		return nil, errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, domain.NewResourceNotFoundError("Session", "id="+id)
+	}
+
+	found := *session
+
+	return &found, nil
+}
+
+func (s *SessionRepositoryMock) FindActiveByUserID(
+	_ context.Context,
+	userID string,
+) ([]*domain.Session, error) {
+	if s.hasError {
+		return nil, errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []*domain.Session
+
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.Active(time.Now()) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionRepositoryMock) Touch(_ context.Context, id string, expiresAt time.Time) error {
+	if s.hasError {
+		return errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.LastUsedAt, session.ExpiresAt = time.Now(), expiresAt
+	}
+
+	return nil
+}
+
+func (s *SessionRepositoryMock) Revoke(_ context.Context, id string) error {
+	if s.hasError {
+		return errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+
+	return nil
+}
+
+func (s *SessionRepositoryMock) RevokeAllByUserID(_ context.Context, userID string) error {
+	if s.hasError {
+		return errors.New("error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
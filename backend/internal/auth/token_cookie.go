@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+	"goadmin-backend/internal/platform/random"
+)
+
+const (
+	// accessTokenCookie is where TokenFromCookie looks for access tokens.
+	accessTokenCookie = "jwt"
+	// refreshTokenCookie is only sent to /auth/refresh.
+	refreshTokenCookie = "goadmin_refresh"
+	// csrfCookie is readable by scripts of the frontend, which send its
+	// value back in the CSRFHeader.
+	csrfCookie = "goadmin_csrf"
+
+	// CSRFHeader carries the CSRF token in requests authenticated with the
+	// access token cookie.
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfTokenSize = 32
+)
+
+var ErrInvalidCSRFToken = errors.New("invalid csrf token")
+
+// TokenCookieConfig has the attributes of the cookies tokens are set in.
+// Path is where the API is mounted, "/" by default, and SameSite defaults to
+// Lax. The cookies are always Secure; browsers accept them on
+// http://localhost as well.
+type TokenCookieConfig struct {
+	Domain   string
+	Path     string
+	SameSite http.SameSite
+}
+
+// WithTokenCookies sets the tokens issued to browsers in HttpOnly cookies
+// instead of response bodies, along with a CSRF token cookie for the
+// double-submit check of RequireCSRFToken.
+func WithTokenCookies(cfg TokenCookieConfig) HandlerOption {
+	return func(h *Handler) {
+		if cfg.Path == "" {
+			cfg.Path = "/"
+		}
+
+		if cfg.SameSite == 0 {
+			cfg.SameSite = http.SameSiteLaxMode
+		}
+
+		h.cookies = &cfg
+	}
+}
+
+// respondToken answers a sign-in with the token pair, in the body or, in
+// cookie mode, in cookies with only the CSRF token in the body. MFA
+// challenges are always answered in the body.
+func (h *Handler) respondToken(res http.ResponseWriter, req *http.Request, token *domain.JWTToken) {
+	if h.cookies == nil || token.MFAToken != "" {
+		h.RespondJSON(res, token, http.StatusOK)
+
+		return
+	}
+
+	csrfToken, err := h.setTokenCookies(res, token)
+	if err != nil {
+		h.Logger.Error("error setting token cookies", slog.Any("err", err))
+
+		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		return
+	}
+
+	h.RespondJSON(res, CookieTokenResponse{
+		CSRFToken: csrfToken,
+		ExpiresIn: int(DefaultTokenDuration.Seconds()),
+	}, http.StatusOK)
+}
+
+// setTokenCookies sets the token pair and a new CSRF token in cookies, and
+// returns the CSRF token.
+func (h *Handler) setTokenCookies(res http.ResponseWriter, token *domain.JWTToken) (string, error) {
+	csrfToken, err := random.Token(csrfTokenSize)
+	if err != nil {
+		return "", err //nolint:wrapcheck // tells what failed already
+	}
+
+	http.SetCookie(res, h.tokenCookie(accessTokenCookie, token.AccessToken, h.cookies.Path, DefaultTokenDuration))
+
+	if token.RefreshToken != "" {
+		http.SetCookie(res, h.tokenCookie(
+			refreshTokenCookie,
+			token.RefreshToken,
+			h.refreshCookiePath(),
+			DefaultRefreshTokenDuration,
+		))
+	}
+
+	csrf := h.tokenCookie(csrfCookie, csrfToken, h.cookies.Path, DefaultRefreshTokenDuration)
+	csrf.HttpOnly = false
+
+	http.SetCookie(res, csrf)
+
+	return csrfToken, nil
+}
+
+// clearTokenCookies removes the cookies set by setTokenCookies.
+func (h *Handler) clearTokenCookies(res http.ResponseWriter) {
+	if h.cookies == nil {
+		return
+	}
+
+	http.SetCookie(res, h.tokenCookie(accessTokenCookie, "", h.cookies.Path, -1))
+	http.SetCookie(res, h.tokenCookie(refreshTokenCookie, "", h.refreshCookiePath(), -1))
+	http.SetCookie(res, h.tokenCookie(csrfCookie, "", h.cookies.Path, -1))
+}
+
+// tokenCookie returns an HttpOnly cookie lasting maxAge, or deleting the
+// cookie when maxAge is negative.
+func (h *Handler) tokenCookie(name, value, cookiePath string, maxAge time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cookiePath,
+		Domain:   h.cookies.Domain,
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: h.cookies.SameSite,
+	}
+
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+
+	return cookie
+}
+
+func (h *Handler) refreshCookiePath() string {
+	return path.Join(h.cookies.Path, "auth/refresh")
+}
+
+// RequireCSRFToken only lets through requests authenticated with the access
+// token cookie that either do not change anything or carry the CSRF token of
+// the cookie in the CSRFHeader. It has to run after the Authenticator
+// middleware.
+func (h *Handler) RequireCSRFToken() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			source, _ := tokenSourceFromContext(req.Context())
+
+			if source == TokenSourceCookie && !safeMethod(req.Method) && !validCSRFToken(req) {
+				httperr.JSONError(res, ErrInvalidCSRFToken, http.StatusForbidden, req.URL.Path)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// safeMethod reports whether requests with method are read-only.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRFToken checks the double-submit CSRF token: the header sent must
+// match the cookie, which other sites cannot read.
+func validCSRFToken(req *http.Request) bool {
+	cookie, err := req.Cookie(csrfCookie)
+	token := req.Header.Get(CSRFHeader)
+
+	if err != nil || cookie.Value == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/logging"
+)
+
+func newCookieHandler() *Handler {
+	return NewHandler(&ServiceMock{}, logging.NewLogger(), WithTokenCookies(TokenCookieConfig{Path: "/api"}))
+}
+
+// responseCookies returns the cookies set by a response by name.
+func responseCookies(res *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+
+	for _, cookie := range res.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	return cookies
+}
+
+func TestHandler_tokenCookies(t *testing.T) {
+	t.Parallel()
+
+	h := newCookieHandler()
+
+	res := httptest.NewRecorder()
+	h.Login(res, newRequest(http.MethodPost, "/api/auth/login", LoginRequest{Username: "user", Password: "password"}))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("Handler.Login() = %v, want %v", res.Code, http.StatusOK)
+	}
+
+	if strings.Contains(res.Body.String(), "good_token") {
+		t.Errorf("Handler.Login() body = %s, want no token", res.Body.String())
+	}
+
+	var body CookieTokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Handler.Login() body error = %v", err)
+	}
+
+	cookies := responseCookies(res)
+
+	access, csrf := cookies[accessTokenCookie], cookies[csrfCookie]
+	if access == nil || access.Value != "good_token" || !access.HttpOnly || !access.Secure ||
+		access.SameSite != http.SameSiteLaxMode || access.Path != "/api" {
+		t.Errorf("Handler.Login() access token cookie = %+v, want a secure HttpOnly cookie", access)
+	}
+
+	if csrf == nil || csrf.HttpOnly || csrf.Value == "" || csrf.Value != body.CSRFToken {
+		t.Fatalf("Handler.Login() csrf cookie = %+v, want a script readable cookie of %q", csrf, body.CSRFToken)
+	}
+
+	refresh := func(csrfToken string) *httptest.ResponseRecorder {
+		req := newRequest(http.MethodPost, "/api/auth/refresh", RefreshTokenRequest{})
+		req.AddCookie(&http.Cookie{Name: refreshTokenCookie, Value: "refresh_token"})
+		req.AddCookie(csrf)
+		req.Header.Set(CSRFHeader, csrfToken)
+
+		res := httptest.NewRecorder()
+		h.Refresh(res, req)
+
+		return res
+	}
+
+	if res := refresh(""); res.Code != http.StatusForbidden {
+		t.Errorf("Handler.Refresh() without csrf token = %v, want %v", res.Code, http.StatusForbidden)
+	}
+
+	if res := refresh(csrf.Value); res.Code != http.StatusOK || responseCookies(res)[accessTokenCookie] == nil {
+		t.Errorf("Handler.Refresh() = %v, want %v with new cookies", res.Code, http.StatusOK)
+	}
+
+	res = httptest.NewRecorder()
+	h.Logout(res, newRequestWithToken("good_token", "cookie"))
+
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie, csrfCookie} {
+		if cookie := responseCookies(res)[name]; cookie == nil || cookie.MaxAge >= 0 {
+			t.Errorf("Handler.Logout() cookie %s = %+v, want it deleted", name, cookie)
+		}
+	}
+}
+
+func TestHandler_redirectLogin_cookies(t *testing.T) {
+	t.Parallel()
+
+	h := newCookieHandler()
+	res := httptest.NewRecorder()
+
+	h.redirectLogin(
+		res,
+		httptest.NewRequest(http.MethodGet, "/api/auth/okta/callback", nil),
+		&domain.JWTToken{AccessToken: "good_token", RefreshToken: "refresh_token"},
+		"/users",
+		"",
+	)
+
+	if location := res.Header().Get("Location"); strings.Contains(location, "token") {
+		t.Errorf("Handler.redirectLogin() location = %s, want no token", location)
+	}
+
+	cookies := responseCookies(res)
+	if cookies[accessTokenCookie] == nil || cookies[refreshTokenCookie] == nil || cookies[csrfCookie] == nil {
+		t.Fatalf("Handler.redirectLogin() cookies = %v, want the token pair and the csrf token", cookies)
+	}
+
+	if got := cookies[refreshTokenCookie].Path; got != "/api/auth/refresh" {
+		t.Errorf("Handler.redirectLogin() refresh token cookie path = %s, want /api/auth/refresh", got)
+	}
+}
+
+func TestHandler_RequireCSRFToken(t *testing.T) {
+	t.Parallel()
+
+	withSource := func(method string, source TokenSource, csrfToken string) *http.Request {
+		req := httptest.NewRequest(method, "/auth/logout", nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf-token"})
+
+		if csrfToken != "" {
+			req.Header.Set(CSRFHeader, csrfToken)
+		}
+
+		return req.WithContext(context.WithValue(req.Context(), tokenKey, requestToken{value: "good_token", source: source}))
+	}
+
+	ok := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+	}{
+		{name: "Cookie Read", req: withSource(http.MethodGet, TokenSourceCookie, ""), wantCode: http.StatusOK},
+		{name: "Cookie Write", req: withSource(http.MethodPost, TokenSourceCookie, "csrf-token"), wantCode: http.StatusOK},
+		{name: "Cookie Write Without Token", req: withSource(http.MethodPost, TokenSourceCookie, ""), wantCode: http.StatusForbidden},
+		{name: "Cookie Write Wrong Token", req: withSource(http.MethodDelete, TokenSourceCookie, "other"), wantCode: http.StatusForbidden},
+		{name: "Header Write", req: withSource(http.MethodPost, TokenSourceHeader, ""), wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+
+			newCookieHandler().RequireCSRFToken()(ok).ServeHTTP(res, tt.req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler.RequireCSRFToken() = %v, want %v", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+// GoogleProvider names Google in the identities of users signed in with a
+// Google ID token.
+const GoogleProvider = "google"
+
+var (
+	ErrIdentitiesNotSupported = errors.New("linking identities is not enabled")
+	ErrIdentityLinked         = errors.New("the identity is linked to a user already")
+	ErrLastSignInMethod       = errors.New("the identity is the only way the user can sign in")
+	// ErrIdentityNotLinked is returned when a user with the e-mail address of
+	// an identity exists but has not linked it; they have to sign in and link
+	// it first.
+	ErrIdentityNotLinked = errors.New("the identity is not linked to the user with its e-mail address")
+)
+
+// ProvisioningPolicy decides what happens to users signing in with a
+// provider for the first time: whether they get an account created from the
+// claims of their ID token, and whether they are linked to an existing user
+// by e-mail address.
+type ProvisioningPolicy struct {
+	Enabled bool
+	// AllowedDomains limits provisioning and linking by e-mail address to
+	// addresses of these domains; any verified address is allowed when
+	// empty.
+	AllowedDomains []string
+	// LinkByEmail links identities to the existing user with their verified
+	// e-mail address. Only set it for providers trusted with the addresses
+	// of these domains: they can sign in as any user of them. Otherwise
+	// users link identities signed in, with LinkIdentity.
+	LinkByEmail bool
+}
+
+// allowsProvisioning reports whether a user with the verified e-mail
+// address may be provisioned.
+func (p ProvisioningPolicy) allowsProvisioning(email string) bool {
+	return p.Enabled && p.allowsDomain(email)
+}
+
+// allowsLinking reports whether an identity with the verified e-mail
+// address may be linked to the user with the address.
+func (p ProvisioningPolicy) allowsLinking(email string) bool {
+	return p.LinkByEmail && p.allowsDomain(email)
+}
+
+func (p ProvisioningPolicy) allowsDomain(email string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+
+	_, domain, ok := strings.Cut(email, "@")
+
+	return ok && slices.ContainsFunc(p.AllowedDomains, func(allowed string) bool {
+		return strings.EqualFold(allowed, domain)
+	})
+}
+
+// WithUserIdentityRepo enables linking users to their accounts at external
+// identity providers. Linked users are signed in by the subject of their ID
+// tokens rather than by e-mail address, and can link more providers.
+func WithUserIdentityRepo(repo domain.UserIdentityRepository) Option {
+	return func(a *authService) {
+		a.identityRepo = repo
+	}
+}
+
+// signInWithIdentity signs in the user a provider asserted. Users with a
+// second factor get an MFA challenge, as with a password: the provider only
+// stands in for the password.
+func (a *authService) signInWithIdentity(ctx context.Context, identity *OIDCIdentity) (*domain.JWTToken, error) {
+	user, err := a.identityUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	mfaRequired, err := a.mfaRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfaRequired {
+		return a.issueMFAChallenge(user)
+	}
+
+	return a.signIn(ctx, user)
+}
+
+// identityUser returns the user an identity is linked to. Identities not
+// linked yet are linked to a user created from them, or to the user with
+// their verified e-mail address, as far as the provisioning policy of the
+// provider allows it.
+func (a *authService) identityUser(ctx context.Context, identity *OIDCIdentity) (*domain.User, error) {
+	var notFoundErr *domain.ResourceNotFoundError
+
+	if a.identityRepo != nil {
+		linked, err := a.identityRepo.Find(ctx, identity.Provider, identity.Subject)
+		if err == nil {
+			if err := a.identityRepo.Touch(ctx, linked.ID, time.Now()); err != nil {
+				return nil, fmt.Errorf("touch user identity error %w", err)
+			}
+
+			user, err := a.userRepo.FindByID(ctx, linked.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("find user by id error %w", err)
+			}
+
+			return user, nil
+		}
+
+		if !errors.As(err, &notFoundErr) {
+			return nil, fmt.Errorf("find user identity error %w", err)
+		}
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, fmt.Errorf("%w: the e-mail address is not verified", ErrInvalidIDToken)
+	}
+
+	policy := a.provisioningPolicy(identity.Provider)
+
+	user, err := a.userRepo.FindByEmail(ctx, identity.Email)
+
+	switch {
+	case errors.As(err, &notFoundErr) && policy.allowsProvisioning(identity.Email):
+		user, err = a.provisionUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("find user by email error %w", err)
+	case !policy.allowsLinking(identity.Email):
+		// anyone the provider vouches for could claim the address
+		return nil, ErrIdentityNotLinked
+	}
+
+	if a.identityRepo != nil {
+		if _, err := a.identityRepo.Create(ctx, &domain.UserIdentity{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}); err != nil {
+			return nil, fmt.Errorf("link user identity error %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (a *authService) provisioningPolicy(providerName string) ProvisioningPolicy {
+	if provider, ok := a.oidcProviders[providerName]; ok {
+		return provider.cfg.Provisioning
+	}
+
+	if connection, ok := a.samlConnections[providerName]; ok {
+		return connection.cfg.Provisioning
+	}
+
+	return ProvisioningPolicy{}
+}
+
+// provisionUser creates the user of an identity from its claims. The user
+// has no password; they can set one with a password reset.
+func (a *authService) provisionUser(ctx context.Context, identity *OIDCIdentity) (*domain.User, error) {
+	username := identity.Username
+	if username == "" {
+		username = identity.Email
+	}
+
+	if _, err := a.userRepo.FindByUsername(ctx, username); err == nil {
+		username = identity.Email
+	}
+
+	user, err := a.userRepo.Create(ctx, &domain.User{
+		Username:  username,
+		Email:     identity.Email,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Picture:   identity.Picture,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create user error %w", err)
+	}
+
+	// the provider verified the address
+	if err := a.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("mark email verified error %w", err)
+	}
+
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+
+	return user, nil
+}
+
+// UserIdentities returns the identities linked to a user.
+func (a *authService) UserIdentities(ctx context.Context, userID string) ([]*domain.UserIdentity, error) {
+	if a.identityRepo == nil {
+		return nil, ErrIdentitiesNotSupported
+	}
+
+	identities, err := a.identityRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find user identities error %w", err)
+	}
+
+	return identities, nil
+}
+
+// LinkIdentity links the identity an ID token of a provider asserts to a
+// user, so that they can sign in with the provider too.
+func (a *authService) LinkIdentity(
+	ctx context.Context,
+	userID, providerName, idToken, nonce string,
+) (*domain.UserIdentity, error) {
+	if a.identityRepo == nil {
+		return nil, ErrIdentitiesNotSupported
+	}
+
+	provider, err := a.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, idToken, nonce)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidIDToken, err)
+	}
+
+	linked, err := a.identityRepo.Create(ctx, &domain.UserIdentity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		var existsErr *domain.ResourceExistsError
+		if errors.As(err, &existsErr) {
+			return nil, ErrIdentityLinked
+		}
+
+		return nil, fmt.Errorf("link user identity error %w", err)
+	}
+
+	return linked, nil
+}
+
+// UnlinkIdentity unlinks an identity of a user. The last identity of a user
+// without a password is kept, as they could not sign in anymore.
+func (a *authService) UnlinkIdentity(ctx context.Context, userID, identityID string) error {
+	if a.identityRepo == nil {
+		return ErrIdentitiesNotSupported
+	}
+
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find user by id error %w", err)
+	}
+
+	if user.Password == "" {
+		identities, err := a.identityRepo.FindByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("find user identities error %w", err)
+		}
+
+		if len(identities) <= 1 {
+			return ErrLastSignInMethod
+		}
+	}
+
+	if err := a.identityRepo.Delete(ctx, userID, identityID); err != nil {
+		return fmt.Errorf("unlink user identity error %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestProvisioningPolicy_allowsProvisioning(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy ProvisioningPolicy
+		email  string
+		want   bool
+	}{
+		{name: "Disabled", email: "user@example.com"},
+		{name: "Linking Only", policy: ProvisioningPolicy{LinkByEmail: true}, email: "user@example.com"},
+		{name: "Any Domain", policy: ProvisioningPolicy{Enabled: true}, email: "user@example.com", want: true},
+		{
+			name:   "Allowed Domain",
+			policy: ProvisioningPolicy{Enabled: true, AllowedDomains: []string{"example.com"}},
+			email:  "user@Example.com",
+			want:   true,
+		},
+		{
+			name:   "Other Domain",
+			policy: ProvisioningPolicy{Enabled: true, AllowedDomains: []string{"example.com"}},
+			email:  "user@example.com.evil.org",
+		},
+		{
+			name:   "Subdomain",
+			policy: ProvisioningPolicy{Enabled: true, AllowedDomains: []string{"example.com"}},
+			email:  "user@mail.example.com",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.policy.allowsProvisioning(tt.email); got != tt.want {
+				t.Errorf("ProvisioningPolicy.allowsProvisioning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_authService_identityUser(t *testing.T) {
+	t.Parallel()
+
+	newService := func(policy ProvisioningPolicy) (*authService, *UserIdentityRepositoryMock) {
+		provider, _ := NewOIDCProvider(OIDCProviderConfig{
+			Name:         "okta",
+			Issuer:       "https://example.okta.com",
+			ClientID:     "goadmin",
+			Provisioning: policy,
+		}, nil)
+
+		identityRepo := &UserIdentityRepositoryMock{}
+		a := &authService{userRepo: &UserRepositoryMock{}}
+		WithOIDCProviders(provider)(a)
+		WithUserIdentityRepo(identityRepo)(a)
+
+		return a, identityRepo
+	}
+
+	identity := func(subject, email string, verified bool) *OIDCIdentity {
+		return &OIDCIdentity{Provider: "okta", Subject: subject, Email: email, EmailVerified: verified}
+	}
+
+	t.Run("Linked", func(t *testing.T) {
+		t.Parallel()
+
+		a, identityRepo := newService(ProvisioningPolicy{})
+		_, _ = identityRepo.Create(context.Background(), &domain.UserIdentity{UserID: "1", Provider: "okta", Subject: "s-1"})
+
+		// the identity is enough, whatever the e-mail address is now
+		user, err := a.identityUser(context.Background(), identity("s-1", "", false))
+		if err != nil || user.ID != "1" {
+			t.Fatalf("authService.identityUser() = %v, %v, want the linked user", user, err)
+		}
+
+		if linked, _ := identityRepo.Find(context.Background(), "okta", "s-1"); linked.LastUsedAt == nil {
+			t.Errorf("authService.identityUser() did not record the sign-in")
+		}
+	})
+
+	t.Run("Linked By E-mail", func(t *testing.T) {
+		t.Parallel()
+
+		a, identityRepo := newService(ProvisioningPolicy{LinkByEmail: true})
+
+		user, err := a.identityUser(context.Background(), identity("s-2", "user@example.com", true))
+		if err != nil || user.ID != "1" {
+			t.Fatalf("authService.identityUser() = %v, %v, want the user with the e-mail address", user, err)
+		}
+
+		if linked, err := identityRepo.Find(context.Background(), "okta", "s-2"); err != nil || linked.UserID != "1" {
+			t.Errorf("authService.identityUser() linked %v, %v, want the identity linked", linked, err)
+		}
+	})
+
+	t.Run("Not Linked", func(t *testing.T) {
+		t.Parallel()
+
+		// the provider is not trusted with the address of the user
+		for _, policy := range []ProvisioningPolicy{
+			{Enabled: true},
+			{LinkByEmail: true, AllowedDomains: []string{"example.org"}},
+		} {
+			a, identityRepo := newService(policy)
+
+			if _, err := a.identityUser(context.Background(), identity("s-6", "user@example.com", true)); !errors.Is(err, ErrIdentityNotLinked) {
+				t.Errorf("authService.identityUser() error = %v, want %v", err, ErrIdentityNotLinked)
+			}
+
+			if _, err := identityRepo.Find(context.Background(), "okta", "s-6"); err == nil {
+				t.Errorf("authService.identityUser() linked the identity")
+			}
+		}
+	})
+
+	t.Run("Unverified E-mail", func(t *testing.T) {
+		t.Parallel()
+
+		a, _ := newService(ProvisioningPolicy{Enabled: true})
+
+		if _, err := a.identityUser(context.Background(), identity("s-3", "user@example.com", false)); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("authService.identityUser() error = %v, want %v", err, ErrInvalidIDToken)
+		}
+	})
+
+	t.Run("Provisioned", func(t *testing.T) {
+		t.Parallel()
+
+		a, identityRepo := newService(ProvisioningPolicy{Enabled: true, AllowedDomains: []string{"example.org"}})
+
+		user, err := a.identityUser(context.Background(), identity("s-4", "new@example.org", true))
+		if err != nil || user.ID == "" {
+			t.Fatalf("authService.identityUser() = %v, %v, want a new user", user, err)
+		}
+
+		if _, err := identityRepo.Find(context.Background(), "okta", "s-4"); err != nil {
+			t.Errorf("authService.identityUser() did not link the new user: %v", err)
+		}
+	})
+
+	t.Run("Domain Not Allowed", func(t *testing.T) {
+		t.Parallel()
+
+		a, _ := newService(ProvisioningPolicy{Enabled: true, AllowedDomains: []string{"example.org"}})
+
+		var notFoundErr *domain.ResourceNotFoundError
+		if _, err := a.identityUser(context.Background(), identity("s-5", "new@example.net", true)); !errors.As(err, &notFoundErr) {
+			t.Errorf("authService.identityUser() error = %v, want not found", err)
+		}
+	})
+}
+
+func Test_authService_signInWithIdentity(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	identity := &OIDCIdentity{Provider: "okta", Subject: "s-1"}
+
+	tests := []struct {
+		name    string
+		factors map[string]*domain.TOTPFactor
+		wantMFA bool
+	}{
+		{name: "Without Second Factor"},
+		{
+			name: "With Second Factor",
+			factors: map[string]*domain.TOTPFactor{
+				"1": {UserID: "1", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", ConfirmedAt: &time.Time{}},
+			},
+			wantMFA: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			identityRepo := &UserIdentityRepositoryMock{}
+			_, _ = identityRepo.Create(ctx, &domain.UserIdentity{UserID: "1", Provider: "okta", Subject: "s-1"})
+
+			a := newMFAService(&MFARepositoryMock{factors: tt.factors})
+			WithUserIdentityRepo(identityRepo)(a)
+
+			token, err := a.signInWithIdentity(ctx, identity)
+			if err != nil {
+				t.Fatalf("authService.signInWithIdentity() error = %v", err)
+			}
+
+			// the provider stands in for the password, not for the second factor
+			if (token.MFAToken != "") != tt.wantMFA || (token.AccessToken != "") == tt.wantMFA {
+				t.Errorf("authService.signInWithIdentity() = %+v, want an MFA challenge %v", token, tt.wantMFA)
+			}
+		})
+	}
+}
+
+func Test_authService_LinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	issuer := newStubIssuer(t)
+	identityRepo := &UserIdentityRepositoryMock{}
+	a := &authService{userRepo: &passwordlessUserRepositoryMock{}}
+	WithOIDCProviders(newStubProvider(t, issuer, OIDCClaimMapping{}))(a)
+
+	if _, err := a.UserIdentities(ctx, "1"); !errors.Is(err, ErrIdentitiesNotSupported) {
+		t.Fatalf("authService.UserIdentities() error = %v, want %v", err, ErrIdentitiesNotSupported)
+	}
+
+	WithUserIdentityRepo(identityRepo)(a)
+
+	linked, err := a.LinkIdentity(ctx, "1", "okta", issuer.idToken(t, "goadmin", nil), "")
+	if err != nil || linked.UserID != "1" || linked.Subject != "okta-user-1" {
+		t.Fatalf("authService.LinkIdentity() = %+v, %v, want the identity linked", linked, err)
+	}
+
+	if _, err := a.LinkIdentity(ctx, "2", "okta", issuer.idToken(t, "goadmin", nil), ""); !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("authService.LinkIdentity() error = %v, want %v", err, ErrIdentityLinked)
+	}
+
+	if _, err := a.LinkIdentity(ctx, "1", "okta", issuer.idToken(t, "other", nil), ""); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("authService.LinkIdentity() error = %v, want %v", err, ErrInvalidIDToken)
+	}
+
+	// the only way a user without a password signs in is kept
+	if err := a.UnlinkIdentity(ctx, "1", linked.ID); !errors.Is(err, ErrLastSignInMethod) {
+		t.Errorf("authService.UnlinkIdentity() error = %v, want %v", err, ErrLastSignInMethod)
+	}
+
+	if _, err := a.LinkIdentity(ctx, "1", "okta", issuer.idToken(t, "goadmin", jwt.MapClaims{"sub": "okta-user-2"}), ""); err != nil {
+		t.Fatalf("authService.LinkIdentity() error = %v", err)
+	}
+
+	if err := a.UnlinkIdentity(ctx, "1", linked.ID); err != nil {
+		t.Errorf("authService.UnlinkIdentity() error = %v", err)
+	}
+
+	identities, err := a.UserIdentities(ctx, "1")
+	if err != nil || len(identities) != 1 || identities[0].Subject != "okta-user-2" {
+		t.Errorf("authService.UserIdentities() = %v, %v, want the second identity", identities, err)
+	}
+}
+
+// passwordlessUserRepositoryMock returns users who were provisioned, and
+// have no password.
+type passwordlessUserRepositoryMock struct {
+	UserRepositoryMock
+}
+
+func (u *passwordlessUserRepositoryMock) FindByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := u.UserRepositoryMock.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+
+	return user, nil
+}
+
+var _ domain.UserIdentityRepository = &UserIdentityRepositoryMock{}
+
+type UserIdentityRepositoryMock struct {
+	mu         sync.Mutex
+	nextID     int
+	identities map[string]*domain.UserIdentity
+}
+
+func (r *UserIdentityRepositoryMock) Create(
+	_ context.Context,
+	identity *domain.UserIdentity,
+) (*domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.identities == nil {
+		r.identities = make(map[string]*domain.UserIdentity)
+	}
+
+	for _, linked := range r.identities {
+		if linked.Provider == identity.Provider && linked.Subject == identity.Subject {
+			return nil, domain.NewResourceExistsError("UserIdentity", "provider,subject")
+		}
+	}
+
+	r.nextID++
+
+	created := *identity
+	created.ID = strconv.Itoa(r.nextID)
+	created.CreatedAt = time.Now()
+	r.identities[created.ID] = &created
+
+	found := created
+
+	return &found, nil
+}
+
+func (r *UserIdentityRepositoryMock) Find(
+	_ context.Context,
+	provider, subject string,
+) (*domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			found := *identity
+
+			return &found, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("UserIdentity", "provider="+provider)
+}
+
+func (r *UserIdentityRepositoryMock) FindByUserID(
+	_ context.Context,
+	userID string,
+) ([]*domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identities := []*domain.UserIdentity{}
+
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			found := *identity
+			identities = append(identities, &found)
+		}
+	}
+
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+
+	return identities, nil
+}
+
+func (r *UserIdentityRepositoryMock) Touch(_ context.Context, id string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if identity, ok := r.identities[id]; ok {
+		identity.LastUsedAt = &usedAt
+	}
+
+	return nil
+}
+
+func (r *UserIdentityRepositoryMock) Delete(_ context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[id]
+	if !ok || identity.UserID != userID {
+		return domain.NewResourceNotFoundError("UserIdentity", "id="+id)
+	}
+
+	delete(r.identities, id)
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"goadmin-backend/internal/auth"
+	"goadmin-backend/internal/rebac"
 	"goadmin-backend/internal/user"
 )
 
@@ -12,6 +13,7 @@ import (
 type Handlers struct {
 	AuthHandler   *auth.Handler
 	UserHandler   *user.Handler
+	ReBACHandler  *rebac.Handler
 	HealthHandler *HealthHandler
 }
 
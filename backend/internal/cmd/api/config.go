@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/providers/env"
@@ -14,9 +15,157 @@ import (
 // ServerConfig is the configuration for the API server.
 type ServerConfig struct {
 	Port int `json:"port"`
-	Auth struct {
-		JWTSecret string `json:"jwt_secret"`
-	} `json:"auth"`
+	// URL is the public URL of the API, which OpenID Connect providers
+	// redirect back to after signing in.
+	URL  string     `json:"url"`
+	Auth AuthConfig `json:"auth"`
+}
+
+// AuthConfig is the configuration for token signing.
+//
+// Tokens are signed with the shared JWTSecret (HS256) unless Keys are
+// configured, in which case they are signed with the key named by
+// SigningKeyID and verified with any of Keys.
+type AuthConfig struct {
+	JWTSecret    string         `json:"jwt_secret"`
+	SigningKeyID string         `json:"signing_key_id"`
+	Keys         []JWTKeyConfig `json:"keys"`
+
+	// RequireVerifiedEmail refuses to sign in users before they verified
+	// their e-mail address.
+	RequireVerifiedEmail bool `json:"require_verified_email"`
+
+	// TOTPIssuer names the account in authenticator apps.
+	TOTPIssuer string `json:"totp_issuer"`
+
+	Lockout LockoutConfig `json:"lockout"`
+
+	PasswordHash PasswordHashConfig `json:"password_hash"`
+
+	PasswordPolicy PasswordPolicyConfig `json:"password_policy"`
+
+	OIDC OIDCConfig `json:"oidc"`
+
+	// LDAP are the directories passwords are verified against, in order,
+	// when they do not match a password of the user table.
+	LDAP []LDAPConfig `json:"ldap"`
+
+	// TokenSources are where access tokens and API keys are read from:
+	// "header", "api_key_header", "cookie" and "query". All but "query"
+	// when left out.
+	TokenSources []string `json:"token_sources"`
+
+	Cookies CookieConfig `json:"cookies"`
+}
+
+// CookieConfig sets the tokens issued to browsers in HttpOnly cookies
+// instead of response bodies. Path is where the API is mounted, "/" by
+// default, and SameSite is "lax" (the default), "strict" or "none".
+type CookieConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	SameSite string `json:"same_site"`
+}
+
+// LDAPConfig is the configuration of an LDAP or Active Directory server.
+// Users are searched with the service account (BindDN), then bound as with
+// their password.
+type LDAPConfig struct {
+	Name string `json:"name"`
+	// URL is the ldap:// or ldaps:// URL of the server.
+	URL      string `json:"url"`
+	StartTLS bool   `json:"start_tls"`
+	// CAFile is a PEM bundle of the CAs the certificate of the server is
+	// verified against, instead of the system roots.
+	CAFile       string `json:"ca_file"`
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	// UserFilter finds the entry of a username, which replaces %s, e.g.
+	// "(&(objectClass=user)(sAMAccountName=%s))".
+	UserFilter string `json:"user_filter"`
+
+	// Attributes names the attributes the profile is read from, for
+	// directories not using the inetOrgPerson ones.
+	Attributes struct {
+		Username  string `json:"username"`
+		Email     string `json:"email"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Groups    string `json:"groups"`
+	} `json:"attributes"`
+
+	// GroupRoles maps the DNs of groups to the roles their members get.
+	GroupRoles map[string]string `json:"group_roles"`
+	// LinkByUsername links directory users on their first sign-in to
+	// existing users with their username, but for users with a password or
+	// the admin role.
+	LinkByUsername bool `json:"link_by_username"`
+
+	Timeout time.Duration `json:"timeout"`
+}
+
+// OIDCConfig is the configuration of the OAuth2/OpenID Connect
+// authorization server. It is disabled unless Issuer is set, which also
+// takes an asymmetric signing key, as clients verify ID tokens against the
+// JWKS.
+type OIDCConfig struct {
+	// Issuer is the public base URL of the API, e.g. "https://api.example.com".
+	Issuer string `json:"issuer"`
+	// AuthorizationEndpoint is the consent page of the frontend. It
+	// defaults to "/oauth/authorize" under AppURL.
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+}
+
+// PasswordPolicyConfig defines which passwords users may choose. Unset
+// fields fall back to auth.DefaultPasswordPolicy.
+type PasswordPolicyConfig struct {
+	MinLength      int `json:"min_length"`
+	MinCharClasses int `json:"min_char_classes"`
+	// AllowUserInfo lets passwords contain the username or e-mail address.
+	AllowUserInfo bool `json:"allow_user_info"`
+	// BreachListFile is a list of SHA-1 hashes of breached passwords sorted
+	// by hash, like the "ordered by hash" Have I Been Pwned downloads. It is
+	// searched on disk.
+	BreachListFile string `json:"breach_list_file"`
+}
+
+// PasswordHashConfig selects how passwords are hashed. Stored hashes made
+// with another algorithm or weaker parameters are replaced on sign-in.
+type PasswordHashConfig struct {
+	// Algorithm is "argon2id" (the default) or "bcrypt".
+	Algorithm string `json:"algorithm"`
+	// BCryptCost defaults to auth.DefaultBCryptCost.
+	BCryptCost int `json:"bcrypt_cost"`
+	// Argon2Memory (in KiB), Argon2Iterations and Argon2Parallelism default
+	// to auth.DefaultArgon2idParams.
+	Argon2Memory      uint32 `json:"argon2_memory"`
+	Argon2Iterations  uint32 `json:"argon2_iterations"`
+	Argon2Parallelism uint8  `json:"argon2_parallelism"`
+}
+
+// LockoutConfig is the brute-force protection of password sign-ins. Unset
+// fields fall back to auth.DefaultLockoutPolicy.
+type LockoutConfig struct {
+	// MaxAttempts is the number of failures in a row locking a username.
+	MaxAttempts int `json:"max_attempts"`
+	// MaxIPAttempts is the number of failures blocking a client IP address.
+	MaxIPAttempts int `json:"max_ip_attempts"`
+	// BaseDelay is the back-off after the first failure, doubled with
+	// every further failure.
+	BaseDelay time.Duration `json:"base_delay"`
+	// Duration is how long a lockout lasts.
+	Duration time.Duration `json:"duration"`
+}
+
+// JWTKeyConfig describes a single key of the key ring. A key without a
+// private key file is only used to verify tokens, e.g. after a rotation.
+type JWTKeyConfig struct {
+	ID             string `json:"id"`
+	Algorithm      string `json:"algorithm"`
+	PrivateKeyFile string `json:"private_key_file"`
+	PublicKeyFile  string `json:"public_key_file"`
 }
 
 // Config is the configuration for the application.
@@ -39,10 +188,137 @@ type Config struct {
 
 	Observability ObservabilityConfig `json:"observability"`
 
-	Google struct {
-		ClientID     string `json:"client_id"`
-		ClientSecret string `json:"client_secret"`
-	} `json:"google"`
+	Google GoogleConfig `json:"google"`
+
+	// OIDCProviders are the OpenID Connect providers users can sign in with.
+	OIDCProviders []OIDCProviderConfig `json:"oidc_providers"`
+
+	// SAML is the configuration of the API as a SAML service provider, and
+	// SAMLConnections the identity providers users can sign in with.
+	SAML            SAMLConfig             `json:"saml"`
+	SAMLConnections []SAMLConnectionConfig `json:"saml_connections"`
+
+	// AppURL is the URL of the frontend, which links in e-mails point to.
+	AppURL string `json:"app_url"`
+
+	Mail MailConfig `json:"mail"`
+
+	ReBAC ReBACConfig `json:"rebac"`
+}
+
+// ReBACConfig is the configuration of relationship-based access control.
+// SchemaFile is the authorization schema the relation definitions are
+// loaded from at startup. MaxConcurrency is how many branches a check
+// evaluates concurrently, rebac.DefaultMaxConcurrency when zero.
+type ReBACConfig struct {
+	SchemaFile     string `json:"schema_file"`
+	MaxConcurrency int    `json:"max_concurrency"`
+}
+
+// OIDCProviderConfig is the configuration of an OpenID Connect provider,
+// such as Okta, Azure AD, Keycloak or GitLab.
+type OIDCProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+
+	// Claims maps profile fields to the claims of the provider's ID tokens,
+	// for those not using the standard claim names.
+	Claims struct {
+		Subject       string `json:"subject"`
+		Email         string `json:"email"`
+		EmailVerified string `json:"email_verified"`
+		Username      string `json:"username"`
+		FirstName     string `json:"first_name"`
+		LastName      string `json:"last_name"`
+		Picture       string `json:"picture"`
+	} `json:"claims"`
+
+	// CacheTTL is how long the discovery document and the keys are cached.
+	CacheTTL time.Duration `json:"cache_ttl"`
+
+	// RedirectURL is the callback registered with the provider; it defaults
+	// to /auth/{name}/callback under the URL of the API.
+	RedirectURL string `json:"redirect_url"`
+
+	Provisioning ProvisioningConfig `json:"provisioning"`
+}
+
+// GoogleConfig is the configuration of the Google OAuth client.
+type GoogleConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	Provisioning ProvisioningConfig `json:"provisioning"`
+}
+
+// SAMLConfig is the configuration of the API as a SAML service provider.
+type SAMLConfig struct {
+	// KeyFile and CertificateFile are the PEM encoded RSA key authentication
+	// requests are signed with, and its certificate, published in our
+	// metadata.
+	KeyFile         string `json:"key_file"`
+	CertificateFile string `json:"certificate_file"`
+}
+
+// SAMLConnectionConfig is the configuration of a SAML 2.0 identity provider,
+// such as ADFS, Okta or Shibboleth.
+type SAMLConnectionConfig struct {
+	Name string `json:"name"`
+	// EntityID identifies us to the identity provider; it defaults to the URL
+	// of our metadata, /auth/saml/{name}/metadata under the URL of the API.
+	EntityID string `json:"entity_id"`
+
+	// IDPMetadataURL is where the metadata of the identity provider is
+	// fetched from, unless it is read from IDPMetadataFile.
+	IDPMetadataURL  string        `json:"idp_metadata_url"`
+	IDPMetadataFile string        `json:"idp_metadata_file"`
+	MetadataTTL     time.Duration `json:"metadata_ttl"`
+
+	// NameIDFormat is the format of the NameIDs identifying users; it
+	// defaults to persistent.
+	NameIDFormat string `json:"name_id_format"`
+
+	// Attributes maps profile fields to the assertion attributes of the
+	// identity provider.
+	Attributes struct {
+		Username  string `json:"username"`
+		Email     string `json:"email"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	} `json:"attributes"`
+
+	Provisioning ProvisioningConfig `json:"provisioning"`
+}
+
+// ProvisioningConfig is the configuration of the accounts of users signing
+// in with a provider for the first time.
+type ProvisioningConfig struct {
+	Enabled bool `json:"enabled"`
+	// AllowedDomains limits provisioning and linking by e-mail address to
+	// addresses of these domains; any verified address is allowed when
+	// empty.
+	AllowedDomains []string `json:"allowed_domains"`
+	// LinkByEmail links users to existing accounts with their e-mail
+	// address, trusting the provider with the addresses of AllowedDomains.
+	LinkByEmail bool `json:"link_by_email"`
+}
+
+// MailConfig is the configuration for sending e-mails.
+type MailConfig struct {
+	// Driver is "smtp", "file" (write .eml files to Dir) or "memory". No
+	// e-mails are sent when it is empty.
+	Driver string `json:"driver"`
+	From   string `json:"from"`
+	Dir    string `json:"dir"`
+	SMTP   struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"smtp"`
 }
 
 // NewConfig returns a new configuration.
@@ -41,9 +41,7 @@ func TestNewConfig(t *testing.T) {
 				},
 				API: ServerConfig{
 					Port: 8080,
-					Auth: struct {
-						JWTSecret string `json:"jwt_secret"`
-					}{
+					Auth: AuthConfig{
 						JWTSecret: "secret",
 					},
 				},
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"goadmin-backend/internal/auth"
+)
+
+// NewKeyRing builds the token key ring from the auth configuration. It falls
+// back to the shared JWT secret when no keys are configured.
+func NewKeyRing(cfg AuthConfig) (*auth.KeyRing, error) {
+	if len(cfg.Keys) == 0 {
+		return auth.NewHMACKeyRing([]byte(cfg.JWTSecret)), nil
+	}
+
+	keys := make([]*auth.SigningKey, 0, len(cfg.Keys))
+
+	for _, keyCfg := range cfg.Keys {
+		privatePEM, err := readKeyFile(keyCfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		publicPEM, err := readKeyFile(keyCfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := auth.ParseSigningKey(keyCfg.ID, keyCfg.Algorithm, privatePEM, publicPEM)
+		if err != nil {
+			return nil, fmt.Errorf("error loading key %q: %w", keyCfg.ID, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	keyRing, err := auth.NewKeyRing(cfg.SigningKeyID, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating key ring: %w", err)
+	}
+
+	return keyRing, nil
+}
+
+func readKeyFile(name string) ([]byte, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeEd25519Key(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+
+	privateDER, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+
+	privateFile := filepath.Join(dir, "private.pem")
+	publicFile := filepath.Join(dir, "public.pem")
+
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	return privateFile, publicFile
+}
+
+func TestNewKeyRing(t *testing.T) {
+	t.Parallel()
+
+	privateFile, publicFile := writeEd25519Key(t, t.TempDir())
+
+	tests := []struct {
+		name     string
+		cfg      AuthConfig
+		wantKeys int
+		wantErr  bool
+	}{
+		{
+			name:     "Shared Secret",
+			cfg:      AuthConfig{JWTSecret: "secret"},
+			wantKeys: 0,
+		},
+		{
+			name: "Key Files",
+			cfg: AuthConfig{
+				SigningKeyID: "2024-06",
+				Keys: []JWTKeyConfig{
+					{ID: "2024-06", Algorithm: "EdDSA", PrivateKeyFile: privateFile},
+					{ID: "2024-01", Algorithm: "EdDSA", PublicKeyFile: publicFile},
+				},
+			},
+			wantKeys: 2,
+		},
+		{
+			name: "Missing Key File",
+			cfg: AuthConfig{
+				SigningKeyID: "2024-06",
+				Keys: []JWTKeyConfig{
+					{ID: "2024-06", Algorithm: "EdDSA", PrivateKeyFile: "missing.pem"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Signing Key Without Private Key",
+			cfg: AuthConfig{
+				SigningKeyID: "2024-01",
+				Keys: []JWTKeyConfig{
+					{ID: "2024-01", Algorithm: "EdDSA", PublicKeyFile: publicFile},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewKeyRing(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyRing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if keys := got.JWKS().Keys; len(keys) != tt.wantKeys {
+				t.Errorf("NewKeyRing().JWKS() = %v keys, want %v", len(keys), tt.wantKeys)
+			}
+		})
+	}
+}
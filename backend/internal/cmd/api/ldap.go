@@ -0,0 +1,91 @@
+package api
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"goadmin-backend/internal/auth"
+)
+
+var (
+	ErrInvalidCAFile          = errors.New("no certificates in CA file")
+	ErrDuplicateLDAPDirectory = errors.New("duplicate LDAP directory")
+)
+
+// UsernameLinkingDirectories returns the names of the LDAP directories
+// linking their users to existing users by username.
+func UsernameLinkingDirectories(cfgs []LDAPConfig) []string {
+	var names []string
+
+	for _, cfg := range cfgs {
+		if cfg.LinkByUsername {
+			names = append(names, cmp.Or(cfg.Name, auth.DefaultLDAPName))
+		}
+	}
+
+	return names
+}
+
+// NewCredentialVerifiers returns the LDAP directories passwords are
+// verified against, in the order of the configuration. Their names, which
+// link users to them, have to be unique.
+func NewCredentialVerifiers(cfgs []LDAPConfig) ([]auth.CredentialVerifier, error) {
+	verifiers := make([]auth.CredentialVerifier, 0, len(cfgs))
+	names := make(map[string]bool, len(cfgs))
+
+	for _, cfg := range cfgs {
+		name := cmp.Or(cfg.Name, auth.DefaultLDAPName)
+		if names[name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateLDAPDirectory, name)
+		}
+
+		names[name] = true
+
+		var tlsConfig *tls.Config
+
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read LDAP CA file error %w", err)
+			}
+
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidCAFile, cfg.CAFile)
+			}
+
+			tlsConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		}
+
+		verifier, err := auth.NewLDAPVerifier(auth.LDAPConfig{
+			Name:         name,
+			URL:          cfg.URL,
+			StartTLS:     cfg.StartTLS,
+			TLSConfig:    tlsConfig,
+			BindDN:       cfg.BindDN,
+			BindPassword: cfg.BindPassword,
+			BaseDN:       cfg.BaseDN,
+			UserFilter:   cfg.UserFilter,
+			Attributes: auth.LDAPAttributeMapping{
+				Username:  cfg.Attributes.Username,
+				Email:     cfg.Attributes.Email,
+				FirstName: cfg.Attributes.FirstName,
+				LastName:  cfg.Attributes.LastName,
+				Groups:    cfg.Attributes.Groups,
+			},
+			GroupRoles: cfg.GroupRoles,
+			Timeout:    cfg.Timeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error configuring LDAP directory %q: %w", name, err)
+		}
+
+		verifiers = append(verifiers, verifier)
+	}
+
+	return verifiers, nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewCredentialVerifiers(t *testing.T) {
+	t.Parallel()
+
+	directory := LDAPConfig{
+		URL:        "ldaps://ad.example.com",
+		BaseDN:     "dc=example,dc=com",
+		UserFilter: "(sAMAccountName=%s)",
+		CAFile:     "testdata/ldap_ca.pem",
+	}
+
+	renamed := directory
+	renamed.Name = "ad"
+
+	tests := []struct {
+		name    string
+		cfgs    []LDAPConfig
+		want    []string
+		wantErr error
+	}{
+		{name: "None"},
+		{name: "Directories", cfgs: []LDAPConfig{directory, renamed}, want: []string{"ldap", "ad"}},
+		{name: "Duplicate", cfgs: []LDAPConfig{directory, directory}, wantErr: ErrDuplicateLDAPDirectory},
+		{name: "Invalid CA File", cfgs: []LDAPConfig{{CAFile: "testdata/breach_list.txt"}}, wantErr: ErrInvalidCAFile},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewCredentialVerifiers(tt.cfgs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewCredentialVerifiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("NewCredentialVerifiers() = %d verifiers, want %d", len(got), len(tt.want))
+			}
+
+			for i, verifier := range got {
+				if verifier.Name() != tt.want[i] {
+					t.Errorf("NewCredentialVerifiers()[%d] = %s, want %s", i, verifier.Name(), tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := NewCredentialVerifiers([]LDAPConfig{{URL: "ldap://ad.example.com"}}); err == nil {
+		t.Errorf("NewCredentialVerifiers() error = nil, want the configuration refused")
+	}
+}
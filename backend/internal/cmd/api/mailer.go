@@ -0,0 +1,33 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"goadmin-backend/internal/platform/mail"
+)
+
+var ErrUnknownMailDriver = errors.New("unknown mail driver")
+
+// NewMailer returns the mailer selected by the configuration, or nil when
+// sending e-mails is disabled.
+func NewMailer(cfg MailConfig) (mail.Mailer, error) { //nolint:ireturn // the driver is picked at runtime
+	switch cfg.Driver {
+	case "":
+		return nil, nil //nolint:nilnil // disabled
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}), nil
+	case "file":
+		return mail.NewFileMailer(cfg.Dir, cfg.From), nil
+	case "memory":
+		return mail.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("%q: %w", cfg.Driver, ErrUnknownMailDriver)
+	}
+}
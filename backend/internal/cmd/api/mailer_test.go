@@ -0,0 +1,41 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"goadmin-backend/internal/platform/mail"
+)
+
+func TestNewMailer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      MailConfig
+		wantType mail.Mailer
+		wantErr  bool
+	}{
+		{name: "Disabled", cfg: MailConfig{}},
+		{name: "SMTP", cfg: MailConfig{Driver: "smtp"}, wantType: &mail.SMTPMailer{}},
+		{name: "File", cfg: MailConfig{Driver: "file", Dir: "tmp/mail"}, wantType: &mail.FileMailer{}},
+		{name: "Memory", cfg: MailConfig{Driver: "memory"}, wantType: &mail.MemoryMailer{}},
+		{name: "Unknown", cfg: MailConfig{Driver: "pigeon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewMailer(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMailer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("NewMailer() = %T, want %T", got, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"goadmin-backend/internal/auth"
+)
+
+var ErrOIDCSigningKey = errors.New("the OpenID Connect issuer needs a signing key published in the JWKS")
+
+// NewOAuthServerConfig returns the configuration of the authorization
+// server. The zero value, which disables the server, is returned when no
+// issuer is configured.
+func NewOAuthServerConfig(
+	cfg OIDCConfig,
+	appURL string,
+	keyRing *auth.KeyRing,
+) (auth.OAuthServerConfig, error) {
+	if cfg.Issuer == "" {
+		return auth.OAuthServerConfig{}, nil
+	}
+
+	// ID tokens signed with a shared secret could not be verified by clients
+	if !keyRing.SigningKeyPublished() {
+		return auth.OAuthServerConfig{}, ErrOIDCSigningKey
+	}
+
+	authorizationEndpoint := cfg.AuthorizationEndpoint
+	if authorizationEndpoint == "" {
+		authorizationEndpoint = strings.TrimSuffix(appURL, "/") + "/oauth/authorize"
+	}
+
+	return auth.OAuthServerConfig{
+		Issuer:                cfg.Issuer,
+		AuthorizationEndpoint: authorizationEndpoint,
+	}, nil
+}
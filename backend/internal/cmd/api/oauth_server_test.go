@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"goadmin-backend/internal/auth"
+)
+
+func TestNewOAuthServerConfig(t *testing.T) {
+	t.Parallel()
+
+	privateFile, _ := writeEd25519Key(t, t.TempDir())
+
+	keyRing, err := NewKeyRing(AuthConfig{
+		SigningKeyID: "2024-06",
+		Keys:         []JWTKeyConfig{{ID: "2024-06", Algorithm: "EdDSA", PrivateKeyFile: privateFile}},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     OIDCConfig
+		keyRing *auth.KeyRing
+		want    auth.OAuthServerConfig
+		wantErr error
+	}{
+		{name: "Disabled", keyRing: keyRing},
+		{
+			name:    "Default Authorization Endpoint",
+			cfg:     OIDCConfig{Issuer: "https://api.example.com"},
+			keyRing: keyRing,
+			want: auth.OAuthServerConfig{
+				Issuer:                "https://api.example.com",
+				AuthorizationEndpoint: "https://app.example.com/oauth/authorize",
+			},
+		},
+		{
+			name: "Authorization Endpoint",
+			cfg: OIDCConfig{
+				Issuer:                "https://api.example.com",
+				AuthorizationEndpoint: "https://login.example.com/consent",
+			},
+			keyRing: keyRing,
+			want: auth.OAuthServerConfig{
+				Issuer:                "https://api.example.com",
+				AuthorizationEndpoint: "https://login.example.com/consent",
+			},
+		},
+		{
+			name:    "Shared Secret",
+			cfg:     OIDCConfig{Issuer: "https://api.example.com"},
+			keyRing: auth.NewHMACKeyRing([]byte("secret")),
+			wantErr: ErrOIDCSigningKey,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewOAuthServerConfig(tt.cfg, "https://app.example.com/", tt.keyRing)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewOAuthServerConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("NewOAuthServerConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"goadmin-backend/internal/auth"
+)
+
+const (
+	// oidcHTTPTimeout bounds the requests made to OpenID Connect providers.
+	oidcHTTPTimeout = 10 * time.Second
+
+	// googleIssuer is the issuer of Google ID tokens.
+	googleIssuer = "https://accounts.google.com"
+)
+
+var ErrDuplicateOIDCProvider = errors.New("duplicate OpenID Connect provider")
+
+// NewOIDCProviders returns the OpenID Connect providers users can sign in
+// with. The discovery document and the keys of each provider are fetched on
+// first use, so that a provider being down does not keep the API from
+// starting. Google is added as "google" when its client secret is set,
+// unless configured otherwise. Callbacks default to /auth/{name}/callback
+// under apiURL.
+func NewOIDCProviders(
+	cfgs []OIDCProviderConfig,
+	google GoogleConfig,
+	apiURL string,
+) ([]*auth.OIDCProvider, error) {
+	if google.ClientSecret != "" && !slices.ContainsFunc(cfgs, func(cfg OIDCProviderConfig) bool {
+		return cfg.Name == "google"
+	}) {
+		cfgs = append(slices.Clip(cfgs), OIDCProviderConfig{
+			Name:         "google",
+			Issuer:       googleIssuer,
+			ClientID:     google.ClientID,
+			ClientSecret: google.ClientSecret,
+			Scopes:       []string{auth.ScopeEmail, auth.ScopeProfile},
+			Provisioning: google.Provisioning,
+		})
+	}
+
+	client := &http.Client{Timeout: oidcHTTPTimeout}
+	providers := make([]*auth.OIDCProvider, 0, len(cfgs))
+	names := make(map[string]bool, len(cfgs))
+
+	for _, cfg := range cfgs {
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateOIDCProvider, cfg.Name)
+		}
+
+		names[cfg.Name] = true
+
+		redirectURL := cfg.RedirectURL
+		if redirectURL == "" && apiURL != "" {
+			redirectURL = strings.TrimSuffix(apiURL, "/") + "/auth/" + url.PathEscape(cfg.Name) + "/callback"
+		}
+
+		provider, err := auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Scopes:       cfg.Scopes,
+			Claims: auth.OIDCClaimMapping{
+				Subject:       cfg.Claims.Subject,
+				Email:         cfg.Claims.Email,
+				EmailVerified: cfg.Claims.EmailVerified,
+				Username:      cfg.Claims.Username,
+				FirstName:     cfg.Claims.FirstName,
+				LastName:      cfg.Claims.LastName,
+				Picture:       cfg.Claims.Picture,
+			},
+			CacheTTL:    cfg.CacheTTL,
+			RedirectURL: redirectURL,
+			Provisioning: auth.ProvisioningPolicy{
+				Enabled:        cfg.Provisioning.Enabled,
+				AllowedDomains: cfg.Provisioning.AllowedDomains,
+				LinkByEmail:    cfg.Provisioning.LinkByEmail,
+			},
+		}, client)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring OpenID Connect provider %q: %w", cfg.Name, err)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// NewLoginRedirectURL returns the frontend page users land on after signing
+// in with redirects, or "" to disable signing in with redirects when the
+// URL of the frontend is not configured.
+func NewLoginRedirectURL(appURL string) string {
+	if appURL == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(appURL, "/") + "/auth/callback"
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/auth"
+)
+
+func TestNewOIDCProviders(t *testing.T) {
+	t.Parallel()
+
+	keycloak := OIDCProviderConfig{
+		Name:     "keycloak",
+		Issuer:   "https://sso.example.com/realms/staff",
+		ClientID: "goadmin",
+		Scopes:   []string{"email", "profile"},
+		CacheTTL: 10 * time.Minute,
+	}
+	keycloak.Claims.Username = "nickname"
+	keycloak.Provisioning = ProvisioningConfig{Enabled: true, AllowedDomains: []string{"example.com"}, LinkByEmail: true}
+
+	google := GoogleConfig{ClientID: "google-client", ClientSecret: "google-secret"}
+
+	tests := []struct {
+		name    string
+		cfgs    []OIDCProviderConfig
+		google  GoogleConfig
+		want    []string
+		wantErr error
+	}{
+		{name: "None"},
+		{
+			name: "Providers",
+			cfgs: []OIDCProviderConfig{
+				keycloak,
+				{Name: "gitlab", Issuer: "https://gitlab.com", ClientID: "client"},
+			},
+			want: []string{"keycloak", "gitlab"},
+		},
+		{
+			name:   "Google",
+			cfgs:   []OIDCProviderConfig{keycloak},
+			google: google,
+			want:   []string{"keycloak", "google"},
+		},
+		{
+			name: "Google Configured",
+			cfgs: []OIDCProviderConfig{
+				{Name: "google", Issuer: "https://accounts.google.com", ClientID: "client"},
+			},
+			google: google,
+			want:   []string{"google"},
+		},
+		{name: "Google Without Secret", google: GoogleConfig{ClientID: "google-client"}},
+		{
+			name:    "Duplicate",
+			cfgs:    []OIDCProviderConfig{keycloak, keycloak},
+			wantErr: ErrDuplicateOIDCProvider,
+		},
+		{
+			name:    "Invalid",
+			cfgs:    []OIDCProviderConfig{{Name: "gitlab", Issuer: "https://gitlab.com"}},
+			wantErr: auth.ErrInvalidProvider,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewOIDCProviders(tt.cfgs, tt.google, "https://api.example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewOIDCProviders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("NewOIDCProviders() = %d providers, want %d", len(got), len(tt.want))
+			}
+
+			for i, provider := range got {
+				if provider.Name() != tt.want[i] {
+					t.Errorf("NewOIDCProviders()[%d] = %s, want %s", i, provider.Name(), tt.want[i])
+				}
+			}
+		})
+	}
+
+	providers, _ := NewOIDCProviders([]OIDCProviderConfig{keycloak}, GoogleConfig{}, "https://api.example.com/")
+	if cfg := providers[0].Config(); cfg.Claims.Username != "nickname" || cfg.Claims.Email != "email" ||
+		cfg.CacheTTL != keycloak.CacheTTL || cfg.RedirectURL != "https://api.example.com/auth/keycloak/callback" ||
+		!cfg.Provisioning.Enabled || len(cfg.Provisioning.AllowedDomains) != 1 || !cfg.Provisioning.LinkByEmail {
+		t.Errorf("NewOIDCProviders() config = %+v, want the claim mapping, cache TTL, callback and provisioning", cfg)
+	}
+
+	keycloak.RedirectURL = "https://login.example.com/callback"
+
+	providers, _ = NewOIDCProviders([]OIDCProviderConfig{keycloak}, GoogleConfig{}, "")
+	if cfg := providers[0].Config(); cfg.RedirectURL != keycloak.RedirectURL {
+		t.Errorf("NewOIDCProviders() redirect URL = %q, want %q", cfg.RedirectURL, keycloak.RedirectURL)
+	}
+}
+
+func TestNewLoginRedirectURL(t *testing.T) {
+	t.Parallel()
+
+	if got := NewLoginRedirectURL("http://localhost:3000/"); got != "http://localhost:3000/auth/callback" {
+		t.Errorf("NewLoginRedirectURL() = %q, want the callback page of the frontend", got)
+	}
+
+	if got := NewLoginRedirectURL(""); got != "" {
+		t.Errorf("NewLoginRedirectURL() = %q, want none without a frontend", got)
+	}
+}
@@ -6,13 +6,19 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 
 	"github.com/pb33f/libopenapi"
 	openapivalidator "github.com/pb33f/libopenapi-validator"
+	validatorerrors "github.com/pb33f/libopenapi-validator/errors"
 
 	"goadmin-backend/internal/platform/httperr"
 )
 
+// securityValidation is the validation type of errors about missing
+// credentials.
+const securityValidation = "security"
+
 type OpenAPIValidator struct {
 	validator openapivalidator.Validator
 	logger    *slog.Logger
@@ -53,8 +59,16 @@ func NewOpenAPIValidator(
 // Middleware returns a middleware that validates incoming requests against the OpenAPI 3+ document.
 func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		valid, validationErrs := v.validator.ValidateHttpRequest(req)
-		if !valid {
+		_, validationErrs := v.validator.ValidateHttpRequest(req)
+
+		// The validator requires every alternative security scheme of an
+		// operation at once. Credentials are checked by the Authenticator
+		// middleware anyway, which tells a missing one with a 401.
+		validationErrs = slices.DeleteFunc(validationErrs, func(err *validatorerrors.ValidationError) bool {
+			return err.ValidationType == securityValidation
+		})
+
+		if len(validationErrs) > 0 {
 			validationErrItems := make([]httperr.ValidationErrorItem, 0)
 
 			var detail string
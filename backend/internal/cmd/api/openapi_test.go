@@ -81,6 +81,17 @@ func newRequest(method, path string, body []byte) *http.Request {
 	return req
 }
 
+func newRequestWithHeader(method, path, body, header, value string) *http.Request {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set(header, value)
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return req
+}
+
 func TestOpenAPIValidator_Middleware(t *testing.T) {
 	t.Parallel()
 
@@ -121,6 +132,138 @@ func TestOpenAPIValidator_Middleware(t *testing.T) {
 			wantStatus: http.StatusUnprocessableEntity,
 			wantBody:   `{"type":"/errors/validation-error","title":"Validation Error","status":422,"detail":"Error: POST request body for '/auth/login' failed to validate schema, Reason: The request body is defined as an object. However, it does not meet the schema requirements of the specification, Validation Errors: [Reason: missing properties: 'password', Location: /required], Line: 35, Column: 15","instance":"/auth/login","errors":[{"detail":"missing properties: 'password'","pointer":"/required"}]}` + "\n",
 		},
+		{
+			name: "/v1/users with bearer token",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req:        newRequestWithHeader(http.MethodGet, "/v1/users", "", "Authorization", "Bearer token"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/v1/users with api key header",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req:        newRequestWithHeader(http.MethodGet, "/v1/users", "", "X-API-Key", "gak_key"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/oauth/token form",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req: newRequestWithHeader(
+				http.MethodPost,
+				"/oauth/token",
+				"grant_type=client_credentials&client_id=sa_client&client_secret=secret",
+				"Accept",
+				"application/json",
+			),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/oauth/authorize query",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req: newRequestWithHeader(
+				http.MethodGet,
+				"/oauth/authorize?response_type=code&client_id=client&code_challenge_method=S256",
+				"",
+				"Authorization",
+				"Bearer token",
+			),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/.well-known/openid-configuration",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req:        newRequest(http.MethodGet, "/.well-known/openid-configuration", nil),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/auth/signin-with-google one tap form",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusSeeOther)
+			}),
+			req: newRequestWithHeader(
+				http.MethodPost,
+				"/auth/signin-with-google",
+				"credential=id-token&g_csrf_token=csrf",
+				"Cookie",
+				"g_csrf_token=csrf",
+			),
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name: "/auth/{provider}/callback",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusSeeOther)
+			}),
+			req:        newRequest(http.MethodGet, "/auth/okta/callback?state=state&code=code", nil),
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name: "/auth/saml/{connection}/acs form",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusSeeOther)
+			}),
+			req: newRequestWithHeader(
+				http.MethodPost,
+				"/auth/saml/adfs/acs",
+				"SAMLResponse=PHNhbWxwOlJlc3BvbnNlLz4%3D",
+				"Cookie",
+				"goadmin_saml_flow=flow-token",
+			),
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name: "/auth/identities link",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusCreated)
+			}),
+			req:        newRequest(http.MethodPost, "/auth/identities", []byte(`{"provider":"okta","id_token":"id-token"}`)),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "/v1/users/{id}/impersonate",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusCreated)
+			}),
+			req:        newRequest(http.MethodPost, "/v1/users/abc/impersonate", []byte(`{"reason":"support ticket 42"}`)),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "/auth/refresh with the refresh token cookie",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req:        newRequest(http.MethodPost, "/auth/refresh", []byte(`{}`)),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/v1/authz/check explain",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req: newRequestWithHeader(http.MethodGet,
+				"/v1/authz/check?user_id=u1&resource_type=document&resource_id=1&permission=view&explain=true",
+				"", "Authorization", "Bearer token"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "/v1/authz/lookup-subjects page",
+			next: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				res.WriteHeader(http.StatusOK)
+			}),
+			req: newRequestWithHeader(http.MethodGet,
+				"/v1/authz/lookup-subjects?resource_type=document&resource_id=1&permission=view&after=2&limit=10",
+				"", "Authorization", "Bearer token"),
+			wantStatus: http.StatusOK,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
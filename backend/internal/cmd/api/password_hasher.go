@@ -0,0 +1,27 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"goadmin-backend/internal/auth"
+)
+
+var ErrUnknownPasswordHashAlgorithm = errors.New("unknown password hash algorithm")
+
+// NewPasswordHasher returns the password hasher selected by the
+// configuration.
+func NewPasswordHasher(cfg PasswordHashConfig) (auth.PasswordHasher, error) { //nolint:ireturn // the algorithm is picked at runtime
+	switch cfg.Algorithm {
+	case "", "argon2id":
+		return auth.NewArgon2idHasher(auth.Argon2idParams{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		}), nil
+	case "bcrypt":
+		return auth.NewBCryptHasher(cfg.BCryptCost), nil
+	default:
+		return nil, fmt.Errorf("%q: %w", cfg.Algorithm, ErrUnknownPasswordHashAlgorithm)
+	}
+}
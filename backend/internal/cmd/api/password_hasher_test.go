@@ -0,0 +1,40 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"goadmin-backend/internal/auth"
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      PasswordHashConfig
+		wantType auth.PasswordHasher
+		wantErr  bool
+	}{
+		{name: "Default", cfg: PasswordHashConfig{}, wantType: &auth.Argon2idHasher{}},
+		{name: "Argon2id", cfg: PasswordHashConfig{Algorithm: "argon2id", Argon2Memory: 1024}, wantType: &auth.Argon2idHasher{}},
+		{name: "BCrypt", cfg: PasswordHashConfig{Algorithm: "bcrypt", BCryptCost: 12}, wantType: &auth.BCryptHasher{}},
+		{name: "Unknown", cfg: PasswordHashConfig{Algorithm: "md5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewPasswordHasher(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPasswordHasher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("NewPasswordHasher() = %T, want %T", got, tt.wantType)
+			}
+		})
+	}
+}
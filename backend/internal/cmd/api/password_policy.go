@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+
+	"goadmin-backend/internal/auth"
+)
+
+// NewPasswordPolicy returns the password policy of the configuration, with
+// its breach list loaded.
+func NewPasswordPolicy(cfg PasswordPolicyConfig) (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{
+		MinLength:      cfg.MinLength,
+		MinCharClasses: cfg.MinCharClasses,
+		AllowUserInfo:  cfg.AllowUserInfo,
+	}
+
+	if cfg.BreachListFile == "" {
+		return policy, nil
+	}
+
+	breachList, err := auth.LoadBreachList(cfg.BreachListFile)
+	if err != nil {
+		return policy, fmt.Errorf("load breach list error %w", err)
+	}
+
+	policy.BreachList = breachList
+
+	return policy, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestNewPasswordPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		cfg            PasswordPolicyConfig
+		wantBreachList bool
+		wantErr        bool
+	}{
+		{name: "Default", cfg: PasswordPolicyConfig{}},
+		{
+			name:           "Breach List",
+			cfg:            PasswordPolicyConfig{MinLength: 12, BreachListFile: "testdata/breach_list.txt"},
+			wantBreachList: true,
+		},
+		{name: "Missing Breach List", cfg: PasswordPolicyConfig{BreachListFile: "testdata/missing.txt"}, wantErr: true},
+		{name: "Invalid Breach List", cfg: PasswordPolicyConfig{BreachListFile: "testdata/invalid_oas.yml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewPasswordPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPasswordPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if (got.BreachList != nil) != tt.wantBreachList {
+				t.Errorf("NewPasswordPolicy() breach list = %v, want %v", got.BreachList != nil, tt.wantBreachList)
+			}
+
+			if !tt.wantErr && got.MinLength != tt.cfg.MinLength {
+				t.Errorf("NewPasswordPolicy() min length = %v, want %v", got.MinLength, tt.cfg.MinLength)
+			}
+
+			if got.BreachList != nil && !got.BreachList.Contains("password1") {
+				t.Errorf("NewPasswordPolicy() breach list misses %q", "password1")
+			}
+		})
+	}
+}
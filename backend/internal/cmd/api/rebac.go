@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/rebac"
+)
+
+// LoadReBACSchema parses the authorization schema of the config and makes
+// its relation definitions those of repo. Without a schema file the
+// definitions are left as they are and the schema is nil.
+func LoadReBACSchema(
+	ctx context.Context,
+	cfg ReBACConfig,
+	repo domain.RelationDefinitionRepository,
+) (*rebac.Schema, error) {
+	if cfg.SchemaFile == "" {
+		return nil, nil //nolint:nilnil // no schema is not an error
+	}
+
+	src, err := os.ReadFile(cfg.SchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file error %w", err)
+	}
+
+	schema, err := rebac.ParseSchema(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.SchemaFile, err)
+	}
+
+	if err := rebac.SyncRelationDefinitions(ctx, repo, schema); err != nil {
+		return nil, err //nolint:wrapcheck // tells what failed already
+	}
+
+	return schema, nil
+}
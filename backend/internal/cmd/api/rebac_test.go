@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/rebac"
+)
+
+func TestLoadReBACSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     ReBACConfig
+		wantErr error
+	}{
+		{name: "None"},
+		{name: "Schema", cfg: ReBACConfig{SchemaFile: "../../../config/api/schema.rebac"}},
+		{name: "Missing File", cfg: ReBACConfig{SchemaFile: "testdata/missing.rebac"}, wantErr: os.ErrNotExist},
+		{name: "Invalid Schema", cfg: ReBACConfig{SchemaFile: "testdata/invalid_schema.rebac"}, wantErr: rebac.ErrInvalidSchema},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &relationDefinitionRepo{}
+
+			got, err := LoadReBACSchema(context.Background(), tt.cfg, repo)
+			if tt.wantErr != nil || err != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("LoadReBACSchema() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if (got == nil) != (tt.cfg.SchemaFile == "") {
+				t.Fatalf("LoadReBACSchema() = %v, want a schema of %q", got, tt.cfg.SchemaFile)
+			}
+
+			if got != nil && len(repo.definitions) != len(got.RelationDefinitions()) {
+				t.Errorf("LoadReBACSchema() stored %d definitions, want %d", len(repo.definitions), len(got.RelationDefinitions()))
+			}
+		})
+	}
+}
+
+// relationDefinitionRepo keeps the definitions created.
+type relationDefinitionRepo struct {
+	domain.RelationDefinitionRepository
+	definitions []*domain.RelationDefinition
+}
+
+func (r *relationDefinitionRepo) FindAll(context.Context) ([]*domain.RelationDefinition, error) {
+	return r.definitions, nil
+}
+
+func (r *relationDefinitionRepo) Create(
+	_ context.Context,
+	definition *domain.RelationDefinition,
+) (*domain.RelationDefinition, error) {
+	r.definitions = append(r.definitions, definition)
+
+	return definition, nil
+}
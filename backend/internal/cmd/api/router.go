@@ -4,10 +4,23 @@ import (
 	"log/slog"
 	"net/http"
 
+	"goadmin-backend/internal/auth"
 	"goadmin-backend/internal/cmd/api/routers"
+	"goadmin-backend/internal/domain"
 	"goadmin-backend/internal/platform/httproute"
 )
 
+// Token sources of the routes, among the configured ones.
+var (
+	sessionTokenSources = []auth.TokenSource{
+		auth.TokenSourceHeader,
+		auth.TokenSourceAPIKeyHeader,
+		auth.TokenSourceCookie,
+		auth.TokenSourceQuery,
+	}
+	headerTokenSources = []auth.TokenSource{auth.TokenSourceHeader, auth.TokenSourceAPIKeyHeader}
+)
+
 // NewRouter returns a new router with all routes defined
 func NewRouter(validator *OpenAPIValidator, handlers *Handlers, logger *slog.Logger) http.Handler {
 	// use chi router
@@ -19,27 +32,179 @@ func NewRouter(validator *OpenAPIValidator, handlers *Handlers, logger *slog.Log
 	router.Get("/health", handlers.HealthHandler.healthCheck)
 	router.Post("/auth/login", handlers.AuthHandler.Login)
 	router.Post("/auth/signup", handlers.AuthHandler.Register)
+	router.Post("/auth/refresh", handlers.AuthHandler.Refresh)
+	router.Post("/oauth/token", handlers.AuthHandler.Token)
+	router.Get("/oauth/userinfo", handlers.AuthHandler.UserInfo)
+	router.Post("/auth/mfa/verify", handlers.AuthHandler.VerifyMFA)
+	router.Post("/auth/password/forgot", handlers.AuthHandler.ForgotPassword)
+	router.Post("/auth/password/reset", handlers.AuthHandler.ResetPassword)
+	router.Get("/auth/verify-email", handlers.AuthHandler.VerifyEmail)
+	router.Post("/auth/verify-email/resend", handlers.AuthHandler.ResendVerificationEmail)
 	router.Post("/auth/signin-with-google", handlers.AuthHandler.SignInWithGoogle)
+	router.Get("/auth/oidc/providers", handlers.AuthHandler.OIDCProviders)
+	router.Post("/auth/oidc/{provider}/signin", handlers.AuthHandler.SignInWithOIDC)
+	router.Get("/auth/{provider}/start", handlers.AuthHandler.StartOIDCLogin)
+	router.Get("/auth/{provider}/callback", handlers.AuthHandler.FinishOIDCLogin)
+	router.Get("/auth/saml/{connection}/metadata", handlers.AuthHandler.SAMLMetadata)
+	router.Get("/auth/saml/{connection}/start", handlers.AuthHandler.StartSAMLLogin)
+	router.Post("/auth/saml/{connection}/acs", handlers.AuthHandler.FinishSAMLLogin)
+	router.Get("/.well-known/jwks.json", handlers.AuthHandler.JWKS)
+	router.Get("/.well-known/openid-configuration", handlers.AuthHandler.OpenIDConfiguration)
 
-	// private routes (require authentication)
+	// private routes (require authentication) of browsers, which may send
+	// the access token cookie and then the CSRF token
 	router.Group(func(grt httproute.Router) {
-		grt.Use(handlers.AuthHandler.Authenticator())
+		grt.Use(
+			handlers.AuthHandler.Authenticator(sessionTokenSources...),
+			handlers.AuthHandler.RequireCSRFToken(),
+		)
+
+		// account routes, not for API keys
+		grt.Group(func(acc httproute.Router) {
+			acc.Use(handlers.AuthHandler.RejectAPIKeys())
+
+			// signing out ends an impersonation too
+			acc.Route("/auth/logout", func(r httproute.Router) {
+				r.Post("/", handlers.AuthHandler.Logout)
+			})
 
-		grt.Route("/auth/logout", func(r httproute.Router) {
-			r.Post("/", handlers.AuthHandler.Logout)
+			acc.Route("/auth/profile", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.Profile)
+			})
 		})
 
-		grt.Route("/auth/profile", func(r httproute.Router) {
-			r.Get("/", handlers.AuthHandler.Profile)
+		// sensitive account routes, neither for API keys nor for admins
+		// impersonating the user
+		grt.Group(func(acc httproute.Router) {
+			acc.Use(handlers.AuthHandler.RejectAPIKeys(), handlers.AuthHandler.RejectImpersonation())
+
+			acc.Route("/auth/logout-all", func(r httproute.Router) {
+				r.Post("/", handlers.AuthHandler.LogoutAll)
+			})
+
+			acc.Route("/auth/sessions", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.Sessions)
+			})
+
+			acc.Route("/auth/sessions/{id}", func(r httproute.Router) {
+				r.Delete("/", handlers.AuthHandler.RevokeSession)
+			})
+
+			acc.Route("/auth/mfa/totp", func(r httproute.Router) {
+				r.Post("/enroll", handlers.AuthHandler.EnrollTOTP)
+				r.Post("/confirm", handlers.AuthHandler.ConfirmTOTP)
+				r.Post("/disable", handlers.AuthHandler.DisableTOTP)
+			})
+
+			acc.Route("/auth/mfa/recovery-codes", func(r httproute.Router) {
+				r.Post("/", handlers.AuthHandler.RegenerateRecoveryCodes)
+			})
+
+			acc.Route("/auth/api-keys", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.APIKeys)
+				r.Post("/", handlers.AuthHandler.CreateAPIKey)
+			})
+
+			acc.Route("/auth/api-keys/{id}", func(r httproute.Router) {
+				r.Delete("/", handlers.AuthHandler.RevokeAPIKey)
+			})
+
+			acc.Route("/auth/identities", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.UserIdentities)
+				r.Post("/", handlers.AuthHandler.LinkIdentity)
+			})
+
+			acc.Route("/auth/identities/{id}", func(r httproute.Router) {
+				r.Delete("/", handlers.AuthHandler.UnlinkIdentity)
+			})
+
+			acc.Route("/oauth/authorize", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.Authorize)
+				r.Post("/", handlers.AuthHandler.Consent)
+			})
 		})
 
 		grt.Route("/v1/users", func(r httproute.Router) {
+			r.Use(handlers.AuthHandler.RequireScope(auth.ScopeUsersRead))
 			r.Get("/", handlers.UserHandler.List)
 		})
 
 		grt.Route("/v1/users/{id}", func(r httproute.Router) {
-			r.Get("/", handlers.UserHandler.GetByID)
-			r.Patch("/", handlers.UserHandler.Update)
+			r.Group(func(rd httproute.Router) {
+				rd.Use(handlers.AuthHandler.RequireScope(auth.ScopeUsersRead))
+				rd.Get("/", handlers.UserHandler.GetByID)
+			})
+
+			// admins impersonating a user cannot change their password or
+			// e-mail address
+			r.Group(func(wr httproute.Router) {
+				wr.Use(
+					handlers.AuthHandler.RejectImpersonation(),
+					handlers.AuthHandler.RequireScope(auth.ScopeUsersWrite),
+				)
+				wr.Patch("/", handlers.UserHandler.Update)
+			})
+		})
+	})
+
+	// private routes of the admin and authorization APIs, which only take
+	// credentials in headers
+	router.Group(func(grt httproute.Router) {
+		grt.Use(handlers.AuthHandler.Authenticator(headerTokenSources...))
+
+		grt.Group(func(acc httproute.Router) {
+			acc.Use(handlers.AuthHandler.RejectAPIKeys())
+
+			acc.Route("/v1/authz/lookup-resources", func(r httproute.Router) {
+				r.Get("/", handlers.ReBACHandler.LookupResources)
+			})
+		})
+
+		// admin routes
+		grt.Group(func(adm httproute.Router) {
+			adm.Use(
+				handlers.AuthHandler.RejectAPIKeys(),
+				handlers.AuthHandler.RejectImpersonation(),
+				handlers.AuthHandler.RequireRole(domain.RoleAdmin),
+			)
+
+			adm.Route("/v1/users/{id}/unlock", func(r httproute.Router) {
+				r.Post("/", handlers.AuthHandler.UnlockUser)
+			})
+
+			adm.Route("/v1/users/{id}/impersonate", func(r httproute.Router) {
+				r.Post("/", handlers.AuthHandler.Impersonate)
+			})
+
+			adm.Route("/v1/service-accounts", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.ServiceAccounts)
+				r.Post("/", handlers.AuthHandler.CreateServiceAccount)
+			})
+
+			adm.Route("/v1/service-accounts/{id}", func(r httproute.Router) {
+				r.Delete("/", handlers.AuthHandler.DeleteServiceAccount)
+			})
+
+			adm.Route("/v1/oauth-clients", func(r httproute.Router) {
+				r.Get("/", handlers.AuthHandler.OAuthClients)
+				r.Post("/", handlers.AuthHandler.CreateOAuthClient)
+			})
+
+			adm.Route("/v1/oauth-clients/{id}", func(r httproute.Router) {
+				r.Delete("/", handlers.AuthHandler.DeleteOAuthClient)
+			})
+
+			adm.Route("/v1/authz/check", func(r httproute.Router) {
+				r.Get("/", handlers.ReBACHandler.Check)
+			})
+
+			adm.Route("/v1/authz/expand", func(r httproute.Router) {
+				r.Get("/", handlers.ReBACHandler.Expand)
+			})
+
+			adm.Route("/v1/authz/lookup-subjects", func(r httproute.Router) {
+				r.Get("/", handlers.ReBACHandler.LookupSubjects)
+			})
 		})
 	})
 
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goadmin-backend/internal/auth"
+)
+
+var (
+	ErrDuplicateSAMLConnection = errors.New("duplicate SAML connection")
+	ErrSAMLServiceProvider     = errors.New("the SAML service provider needs a key, a certificate and the URL of the API")
+	ErrInvalidCertificateFile  = errors.New("no certificate in certificate file")
+)
+
+// NewSAMLConnections returns the SAML identity providers users can sign in
+// with. Our metadata and assertion consumer service for each are
+// /auth/saml/{name}/metadata and /auth/saml/{name}/acs under apiURL.
+// Metadata read from a URL is fetched on first use.
+func NewSAMLConnections(
+	cfgs []SAMLConnectionConfig,
+	sp SAMLConfig,
+	apiURL string,
+) ([]*auth.SAMLConnection, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	if sp.KeyFile == "" || sp.CertificateFile == "" || apiURL == "" {
+		return nil, ErrSAMLServiceProvider
+	}
+
+	keyPEM, err := os.ReadFile(sp.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read SAML key file error %w", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse SAML key error %w", err)
+	}
+
+	certPEM, err := os.ReadFile(sp.CertificateFile)
+	if err != nil {
+		return nil, fmt.Errorf("read SAML certificate file error %w", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCertificateFile, sp.CertificateFile)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse SAML certificate error %w", err)
+	}
+
+	client := &http.Client{Timeout: oidcHTTPTimeout}
+	connections := make([]*auth.SAMLConnection, 0, len(cfgs))
+	names := make(map[string]bool, len(cfgs))
+
+	for _, cfg := range cfgs {
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateSAMLConnection, cfg.Name)
+		}
+
+		names[cfg.Name] = true
+
+		var idpMetadata []byte
+
+		if cfg.IDPMetadataFile != "" {
+			if idpMetadata, err = os.ReadFile(cfg.IDPMetadataFile); err != nil {
+				return nil, fmt.Errorf("read SAML metadata file error %w", err)
+			}
+		}
+
+		endpoint := strings.TrimSuffix(apiURL, "/") + "/auth/saml/" + url.PathEscape(cfg.Name)
+
+		connection, err := auth.NewSAMLConnection(auth.SAMLConnectionConfig{
+			Name:           cfg.Name,
+			EntityID:       cfg.EntityID,
+			MetadataURL:    endpoint + "/metadata",
+			ACSURL:         endpoint + "/acs",
+			Key:            key,
+			Certificate:    cert,
+			IDPMetadata:    idpMetadata,
+			IDPMetadataURL: cfg.IDPMetadataURL,
+			MetadataTTL:    cfg.MetadataTTL,
+			NameIDFormat:   cfg.NameIDFormat,
+			Attributes: auth.SAMLAttributeMapping{
+				Username:  cfg.Attributes.Username,
+				Email:     cfg.Attributes.Email,
+				FirstName: cfg.Attributes.FirstName,
+				LastName:  cfg.Attributes.LastName,
+			},
+			Provisioning: auth.ProvisioningPolicy{
+				Enabled:        cfg.Provisioning.Enabled,
+				AllowedDomains: cfg.Provisioning.AllowedDomains,
+				LinkByEmail:    cfg.Provisioning.LinkByEmail,
+			},
+		}, client)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring SAML connection %q: %w", cfg.Name, err)
+		}
+
+		connections = append(connections, connection)
+	}
+
+	return connections, nil
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSAMLKeyPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "goadmin"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+
+	keyFile := filepath.Join(dir, "saml.key")
+	certFile := filepath.Join(dir, "saml.crt")
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	return keyFile, certFile
+}
+
+func TestNewSAMLConnections(t *testing.T) {
+	t.Parallel()
+
+	keyFile, certFile := writeSAMLKeyPair(t, t.TempDir())
+	sp := SAMLConfig{KeyFile: keyFile, CertificateFile: certFile}
+
+	adfs := SAMLConnectionConfig{Name: "adfs", IDPMetadataFile: "testdata/saml_idp_metadata.xml"}
+	okta := SAMLConnectionConfig{Name: "okta", IDPMetadataURL: "https://example.okta.com/app/1/sso/saml/metadata"}
+
+	tests := []struct {
+		name    string
+		cfgs    []SAMLConnectionConfig
+		sp      SAMLConfig
+		apiURL  string
+		want    []string
+		wantErr error
+	}{
+		{name: "None"},
+		{name: "Connections", cfgs: []SAMLConnectionConfig{adfs, okta}, sp: sp, apiURL: "https://api.example.com/", want: []string{"adfs", "okta"}},
+		{name: "Duplicate", cfgs: []SAMLConnectionConfig{adfs, adfs}, sp: sp, apiURL: "https://api.example.com", wantErr: ErrDuplicateSAMLConnection},
+		{name: "No API URL", cfgs: []SAMLConnectionConfig{adfs}, sp: sp, wantErr: ErrSAMLServiceProvider},
+		{name: "No Key", cfgs: []SAMLConnectionConfig{adfs}, apiURL: "https://api.example.com", wantErr: ErrSAMLServiceProvider},
+		{
+			name:    "Invalid Certificate File",
+			cfgs:    []SAMLConnectionConfig{adfs},
+			sp:      SAMLConfig{KeyFile: keyFile, CertificateFile: keyFile},
+			apiURL:  "https://api.example.com",
+			wantErr: ErrInvalidCertificateFile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewSAMLConnections(tt.cfgs, tt.sp, tt.apiURL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewSAMLConnections() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("NewSAMLConnections() = %d connections, want %d", len(got), len(tt.want))
+			}
+
+			for i, connection := range got {
+				if connection.Name() != tt.want[i] {
+					t.Errorf("NewSAMLConnections()[%d] = %s, want %s", i, connection.Name(), tt.want[i])
+				}
+			}
+		})
+	}
+
+	invalid := []SAMLConnectionConfig{{Name: "adfs", IDPMetadataFile: "testdata/ldap_ca.pem"}}
+	if _, err := NewSAMLConnections(invalid, sp, "https://api.example.com"); err == nil {
+		t.Errorf("NewSAMLConnections() error = nil, want the metadata refused")
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"goadmin-backend/internal/auth"
+)
+
+var ErrUnknownSameSite = errors.New("unknown same_site mode")
+
+// NewTokenTransport returns the options of the auth handler telling where
+// tokens are read from and whether they are set in cookies.
+func NewTokenTransport(cfg AuthConfig) ([]auth.HandlerOption, error) {
+	var opts []auth.HandlerOption
+
+	if len(cfg.TokenSources) > 0 {
+		sources := make([]auth.TokenSource, 0, len(cfg.TokenSources))
+
+		for _, name := range cfg.TokenSources {
+			source, err := auth.ParseTokenSource(name)
+			if err != nil {
+				return nil, err //nolint:wrapcheck // names the source already
+			}
+
+			sources = append(sources, source)
+		}
+
+		opts = append(opts, auth.WithTokenSources(sources...))
+	}
+
+	if !cfg.Cookies.Enabled {
+		return opts, nil
+	}
+
+	var sameSite http.SameSite
+
+	switch cfg.Cookies.SameSite {
+	case "", "lax":
+		sameSite = http.SameSiteLaxMode
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("%q: %w", cfg.Cookies.SameSite, ErrUnknownSameSite)
+	}
+
+	return append(opts, auth.WithTokenCookies(auth.TokenCookieConfig{
+		Domain:   cfg.Cookies.Domain,
+		Path:     cfg.Cookies.Path,
+		SameSite: sameSite,
+	})), nil
+}
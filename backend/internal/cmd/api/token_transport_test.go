@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"goadmin-backend/internal/auth"
+)
+
+func TestNewTokenTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      AuthConfig
+		wantOpts int
+		wantErr  error
+	}{
+		{name: "Default", cfg: AuthConfig{}, wantOpts: 0},
+		{name: "Token Sources", cfg: AuthConfig{TokenSources: []string{"header", "cookie"}}, wantOpts: 1},
+		{name: "Unknown Token Source", cfg: AuthConfig{TokenSources: []string{"body"}}, wantErr: auth.ErrUnknownTokenSource},
+		{name: "Cookies", cfg: AuthConfig{Cookies: CookieConfig{Enabled: true, SameSite: "strict"}}, wantOpts: 1},
+		{
+			name:     "Cookies And Token Sources",
+			cfg:      AuthConfig{TokenSources: []string{"cookie"}, Cookies: CookieConfig{Enabled: true}},
+			wantOpts: 2,
+		},
+		{
+			name:    "Unknown Same Site",
+			cfg:     AuthConfig{Cookies: CookieConfig{Enabled: true, SameSite: "always"}},
+			wantErr: ErrUnknownSameSite,
+		},
+		// disabled cookies are not looked at
+		{name: "Cookies Disabled", cfg: AuthConfig{Cookies: CookieConfig{SameSite: "always"}}, wantOpts: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewTokenTransport(tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewTokenTransport() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(got) != tt.wantOpts {
+				t.Errorf("NewTokenTransport() = %d options, want %d", len(got), tt.wantOpts)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey is a long-lived credential a user creates for scripts and
+// integrations. Only the SHA-256 hash of the key is stored; Prefix is kept
+// in clear to tell keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the key may no longer be used.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// APIKeyRepository defines the methods that an API key repository should
+// implement
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) (*APIKey, error)
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	// FindByUserID returns the keys of a user, newest first.
+	FindByUserID(ctx context.Context, userID string) ([]*APIKey, error)
+	// Touch records a use of the key.
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	// Delete removes a key of a user; it returns a ResourceNotFoundError
+	// when the user has no such key.
+	Delete(ctx context.Context, userID, id string) error
+}
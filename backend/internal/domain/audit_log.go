@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditActionImpersonationStart = "impersonation.start"
+	AuditActionImpersonationEnd   = "impersonation.end"
+)
+
+// AuditEvent records an action of a user, the actor, on behalf of or
+// affecting another user, the subject, along with the client it came from.
+type AuditEvent struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	ActorID   string    `json:"actor_id"`
+	SubjectID string    `json:"subject_id"`
+	Reason    string    `json:"reason"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditLogRepository defines the methods that an audit log repository
+// should implement. Events are only ever appended.
+type AuditLogRepository interface {
+	Create(ctx context.Context, event *AuditEvent) (*AuditEvent, error)
+}
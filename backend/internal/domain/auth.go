@@ -2,15 +2,52 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Token types carried in the "typ" claim so that a refresh token can never
+// be used where an access token is expected and vice versa.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 type JWTClaims struct {
-	Username string `json:"username"`
+	Username  string `json:"username"`
+	TokenType string `json:"typ,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	// PrincipalType tells tokens of service accounts, whose ID is the
+	// subject, from tokens of users. It is left out for users.
+	PrincipalType string `json:"pty,omitempty"`
+	// Scope is the space separated list of scopes a service account token
+	// was granted.
+	Scope string `json:"scope,omitempty"`
+	// ClientID names the OAuth client a token was issued to on behalf of
+	// the user.
+	ClientID string `json:"client_id,omitempty"`
+	// Actor is the admin impersonating the user of the token, as in the
+	// "act" claim of RFC 8693. It is left out when users act themselves.
+	Actor *TokenActor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenActor identifies who is acting on behalf of the user of a token.
+type TokenActor struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username"`
+}
+
+// Principal returns the principal type of the token.
+func (c *JWTClaims) Principal() string {
+	if c.PrincipalType == "" {
+		return PrincipalTypeUser
+	}
+
+	return c.PrincipalType
+}
+
 type Credentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -19,10 +56,52 @@ type Credentials struct {
 type JWTToken struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+
+	// MFAToken is set instead of the token pair when the user still has to
+	// pass a second factor; it is exchanged for the pair once they did.
+	MFAToken string `json:"mfa_token,omitempty"`
+
+	// IDToken is the OpenID Connect ID token issued to OAuth clients along
+	// with the access token.
+	IDToken string `json:"id_token,omitempty"`
 }
 
-// RoleRepository defines the methods that a role repository should implement
+// RefreshToken is the server-side record of an issued refresh token.
+//
+// Every refresh token belongs to a family which starts at login; each
+// rotation issues a new token in the same family and marks the old one as
+// rotated. Presenting a rotated token again revokes the whole family.
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	FamilyID  string     `json:"family_id"`
+	UserID    string     `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedTokenRepository defines the methods that a revoked token repository
+// should implement. Tokens are identified by their "jti" claim; an entry is
+// only needed until the token expires.
 type RevokedTokenRepository interface {
-	AddRevokedToken(ctx context.Context, token string) error
-	IsRevoked(ctx context.Context, token string) (bool, error)
+	AddRevokedToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	// DeleteExpired removes entries of tokens that have expired anyway and
+	// returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// RefreshTokenRepository defines the methods that a refresh token repository
+// should implement
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	FindByID(ctx context.Context, id string) (*RefreshToken, error)
+	// Rotate flags the token as used and stores the next token of its family
+	// at once. It reports false, storing nothing, when the token had already
+	// been rotated or revoked, which signals a reuse.
+	Rotate(ctx context.Context, id string, next *RefreshToken) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeAllByUserID revokes every refresh token of a user.
+	RevokeAllByUserID(ctx context.Context, userID string) error
 }
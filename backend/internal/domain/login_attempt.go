@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttempt tracks the recent failed sign-ins of a username or of a
+// client IP address, identified by Key (e.g. "user:alice", "ip:192.0.2.1").
+type LoginAttempt struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// Locked reports whether sign-ins for the key are refused at now.
+func (a *LoginAttempt) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// LoginAttemptRepository defines the methods that a login attempt repository
+// should implement
+type LoginAttemptRepository interface {
+	FindByKey(ctx context.Context, key string) (*LoginAttempt, error)
+	// RecordFailure counts a failed sign-in and returns the updated attempt.
+	// Failures older than window are forgotten, so the count restarts at 1.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error)
+	// Lock refuses sign-ins for the key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+}
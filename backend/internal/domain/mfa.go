@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TokenTypeMFAChallenge is the "typ" of the short-lived token Login hands
+// out instead of a token pair when the user has to pass a second factor.
+const TokenTypeMFAChallenge = "mfa_challenge"
+
+// TOTPFactor is the TOTP (RFC 6238) second factor of a user. It only takes
+// effect once the user proved their authenticator app works by confirming
+// it with a code.
+type TOTPFactor struct {
+	UserID      string     `json:"user_id"`
+	Secret      string     `json:"secret"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code; codes of
+	// that step or earlier are refused so that a code cannot be replayed.
+	LastUsedStep int64     `json:"last_used_step"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Confirmed reports whether the factor is required at sign-in.
+func (f *TOTPFactor) Confirmed() bool {
+	return f.ConfirmedAt != nil
+}
+
+// RecoveryCode is a single-use code signing a user in when their
+// authenticator app is lost. Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	CodeHash  string     `json:"code_hash"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MFARepository defines the methods that a multi-factor authentication
+// repository should implement
+type MFARepository interface {
+	FindTOTP(ctx context.Context, userID string) (*TOTPFactor, error)
+	// SaveTOTP stores a new unconfirmed factor, replacing an unconfirmed
+	// one the user may have enrolled before.
+	SaveTOTP(ctx context.Context, factor *TOTPFactor) error
+	ConfirmTOTP(ctx context.Context, userID string, step int64) error
+	// UseTOTPStep records an accepted code. It reports false when a code of
+	// the same or a later step was accepted already.
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	// DeleteTOTP removes the factor and the recovery codes of a user.
+	DeleteTOTP(ctx context.Context, userID string) error
+	// ReplaceRecoveryCodes discards the recovery codes of a user and stores
+	// the given ones instead.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used. It reports
+	// false when the user has no such unused code.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// OAuthClient is an application signing its users in with goadmin as the
+// OAuth2/OpenID Connect provider. Public clients, e.g. single page apps,
+// have no secret and rely on PKCE alone. Only the SHA-256 hash of the
+// secret of a confidential client is stored.
+type OAuthClient struct {
+	ID               string    `json:"id"`
+	ClientID         string    `json:"client_id"`
+	Name             string    `json:"name"`
+	ClientSecretHash *string   `json:"-"`
+	RedirectURIs     []string  `json:"redirect_uris"`
+	Scopes           []string  `json:"scopes"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Public reports whether the client has no secret.
+func (c *OAuthClient) Public() bool {
+	return c.ClientSecretHash == nil
+}
+
+// OAuthConsent records the scopes a user allowed a client to get, so that
+// they are only asked again for new scopes.
+type OAuthConsent struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuthorizationCode is the server-side record of an issued OAuth2
+// authorization code. Only the SHA-256 hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash string `json:"-"`
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id"`
+	// RedirectURI is the redirect_uri of the authorization request, empty
+	// when it was left out for the only one of the client.
+	RedirectURI   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"code_challenge"`
+	AuthTime      time.Time `json:"auth_time"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// OAuthClientRepository defines the methods that an OAuth client repository
+// should implement
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client *OAuthClient) (*OAuthClient, error)
+	FindByClientID(ctx context.Context, clientID string) (*OAuthClient, error)
+	// FindAll returns every client, newest first.
+	FindAll(ctx context.Context) ([]*OAuthClient, error)
+	// Delete removes a client with its consents and codes.
+	Delete(ctx context.Context, id string) error
+}
+
+// OAuthGrantRepository defines the methods that a repository of consents
+// and authorization codes should implement
+type OAuthGrantRepository interface {
+	FindConsent(ctx context.Context, userID, clientID string) (*OAuthConsent, error)
+	// SaveConsent creates or replaces the consent of a user for a client.
+	SaveConsent(ctx context.Context, consent *OAuthConsent) error
+	CreateCode(ctx context.Context, code *AuthorizationCode) error
+	// ConsumeCode removes a code and returns it, so that it can be used only
+	// once; it returns a ResourceNotFoundError when there is no such code.
+	ConsumeCode(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+}
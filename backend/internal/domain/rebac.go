@@ -1,5 +1,15 @@
 package domain
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTupleWrittenAndDeleted is returned for a bulk write both writing and
+// deleting a tuple, whose outcome would be undefined.
+var ErrTupleWrittenAndDeleted = errors.New("relation tuple both written and deleted")
+
 type Entity struct {
 	EntityType  string `json:"entity_type"`
 	EntityID    string `json:"entity_id"`
@@ -14,7 +24,8 @@ type Entity struct {
 //   - member (the subject is a member of the entity;
 //     a member has access to the entity based on the entity's
 //     permissions)
-//   - parent (the subject is the parent of the entity, the entity inherits relations and permissions from the parent)
+//   - parent (the subject is the parent of the entity; permissions can
+//     grant relations of the parent with parent->)
 //   - viewer (the subject can view the entity)
 //   - editor (the subject can edit the entity)
 //
@@ -22,30 +33,91 @@ type Entity struct {
 // - document:1#owner@user:1 (user with ID 1 is the owner of the document with ID 1)
 // - document:1#viewer@group:1#member (members of group with ID 1 is a viewer of the document with ID 1)
 type RelationTuple struct {
+	ID              string    `json:"id"`
+	EntityType      string    `json:"entity_type"`
+	EntityID        string    `json:"entity_id"`
+	Relation        string    `json:"relation"`
+	SubjectType     string    `json:"subject_type"`
+	SubjectID       string    `json:"subject_id"`
+	SubjectRelation string    `json:"subject_relation"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RelationTupleFilter selects relation tuples by the fields set, and pages
+// through them: After is the ID of the last tuple of the previous page, and
+// Limit the size of a page, DefaultRelationTupleLimit by default.
+type RelationTupleFilter struct {
 	EntityType      string `json:"entity_type"`
 	EntityID        string `json:"entity_id"`
 	Relation        string `json:"relation"`
 	SubjectType     string `json:"subject_type"`
 	SubjectID       string `json:"subject_id"`
 	SubjectRelation string `json:"subject_relation"`
+	After           string `json:"after"`
+	Limit           int    `json:"limit"`
 }
 
+const DefaultRelationTupleLimit = 100
+
+// RelationDefinition grants a relation or permission of an entity type.
+// The subject is a type tuples of the relation may name, with the relation
+// of usersets, unless it is a relation of the entity type itself: then the
+// relation is computed from it, or with a subject relation, granted by that
+// relation of the entities it names (an arrow, like parent->member).
+//
 // Example:
 // - document#owner@user
 // - document#viewer@group#member
 // - document#view@owner
 // - document#edit@owner
 // - document#delete@owner
-// - document#view@project#member
-
+// - document#view@parent#member
 type RelationDefinition struct {
-	EntityType      string `json:"entity_type"`
-	RelationType    string `json:"relation_type"`
-	SubjectType     string `json:"subject_type"`
-	SubjectRelation string `json:"subject_relation"`
+	ID              string    `json:"id"`
+	EntityType      string    `json:"entity_type"`
+	RelationType    string    `json:"relation_type"`
+	SubjectType     string    `json:"subject_type"`
+	SubjectRelation string    `json:"subject_relation"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 type Permission struct {
 	Relation string `json:"relation"`
 	Action   string `json:"action"`
 }
+
+// RelationTupleRepository defines the methods that a relation tuple
+// repository should implement.
+type RelationTupleRepository interface {
+	// FindRelationTuple returns the tuples of an entity with a relation.
+	FindRelationTuple(ctx context.Context, entity *Entity, relation string) ([]*RelationTuple, error)
+	// FindAll returns a page of the tuples matching the filter, in the
+	// order they were written.
+	FindAll(ctx context.Context, filter *RelationTupleFilter) ([]*RelationTuple, error)
+	// Write stores a tuple; writing a tuple stored already does nothing.
+	Write(ctx context.Context, tuple *RelationTuple) error
+	// Delete removes a tuple; it returns a ResourceNotFoundError when there
+	// is no such tuple.
+	Delete(ctx context.Context, tuple *RelationTuple) error
+	// BulkWrite stores the writes and removes the deletes at once, so that
+	// either all of them or none are applied. Missing deletes are ignored,
+	// and batches writing and deleting the same tuple fail with
+	// ErrTupleWrittenAndDeleted.
+	BulkWrite(ctx context.Context, writes, deletes []*RelationTuple) error
+}
+
+// RelationDefinitionRepository defines the methods that a relation
+// definition repository should implement.
+type RelationDefinitionRepository interface {
+	// FindRelationDefinition returns the definitions of the relations and
+	// permissions of an entity type.
+	FindRelationDefinition(ctx context.Context, entityType string) ([]*RelationDefinition, error)
+	// FindAll returns the definitions of every entity type.
+	FindAll(ctx context.Context) ([]*RelationDefinition, error)
+	// Create stores a definition; it returns a ResourceExistsError when
+	// it is defined already.
+	Create(ctx context.Context, definition *RelationDefinition) (*RelationDefinition, error)
+	// Delete removes a definition; it returns a ResourceNotFoundError when
+	// there is no such definition.
+	Delete(ctx context.Context, definition *RelationDefinition) error
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// RoleAdmin is the role allowed to manage other users.
+const RoleAdmin = "admin"
+
+type Role struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RoleRepository defines the methods that a role repository should implement
+type RoleRepository interface {
+	FindByUserID(ctx context.Context, userID string) ([]*Role, error)
+	// GrantRole grants the role with the name to a user; granting a role the
+	// user has already, or one that does not exist, does nothing.
+	GrantRole(ctx context.Context, userID, roleName string) error
+	RevokeRole(ctx context.Context, userID, roleName string) error
+}
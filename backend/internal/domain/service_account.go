@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Principal types carried in the "pty" claim of access tokens, so that
+// tokens of machine clients are never mistaken for tokens of users. Tokens
+// without the claim belong to users.
+const (
+	PrincipalTypeUser           = "user"
+	PrincipalTypeServiceAccount = "service_account"
+)
+
+// ServiceAccount is a machine client. It signs in with the OAuth2 client
+// credentials grant and is limited to the scopes it was granted. Only the
+// SHA-256 hash of its client secret is stored.
+type ServiceAccount struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	ClientID         string     `json:"client_id"`
+	ClientSecretHash string     `json:"-"`
+	Scopes           []string   `json:"scopes"`
+	CreatedBy        *string    `json:"created_by"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// ServiceAccountRepository defines the methods that a service account
+// repository should implement
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, account *ServiceAccount) (*ServiceAccount, error)
+	FindByID(ctx context.Context, id string) (*ServiceAccount, error)
+	FindByClientID(ctx context.Context, clientID string) (*ServiceAccount, error)
+	// FindAll returns every service account, newest first.
+	FindAll(ctx context.Context) ([]*ServiceAccount, error)
+	// Touch records a use of the account.
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	Delete(ctx context.Context, id string) error
+}
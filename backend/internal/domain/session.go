@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Session is a sign-in of a user on a device. Every token issued for the
+// sign-in, including the ones obtained by refreshing, carries the session ID
+// in its "sid" claim; revoking the session invalidates all of them.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Active reports whether tokens of the session are still accepted.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// ClientInfo describes the client a session is created for.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionRepository defines the methods that a session repository should
+// implement
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	FindByID(ctx context.Context, id string) (*Session, error)
+	// FindActiveByUserID returns the sessions of the user that are neither
+	// revoked nor expired, most recently used first.
+	FindActiveByUserID(ctx context.Context, userID string) ([]*Session, error)
+	// Touch records a use of the session and extends it until expiresAt.
+	Touch(ctx context.Context, id string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllByUserID(ctx context.Context, userID string) error
+}
@@ -20,6 +20,8 @@ type User struct {
 	DeletedAt *time.Time `json:"deleted_at"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 type UserFilter struct {
@@ -42,8 +44,11 @@ type UserRepository interface {
 	FindAll(ctx context.Context, filter *UserFilter) ([]User, error)
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByUsername(ctx context.Context, username string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
 	Create(ctx context.Context, user *User) (*User, error)
 	Update(ctx context.Context, user *User) (*User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id string) error
 	SoftDelete(ctx context.Context, id string) error
 	Delete(ctx context.Context, id string) error
 }
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// UserIdentity links a user to their account at an external identity
+// provider, which is known by the subject of its ID tokens. Email is the
+// address the provider asserted when the identity was linked.
+type UserIdentity struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Provider   string     `json:"provider"`
+	Subject    string     `json:"subject"`
+	Email      string     `json:"email"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// UserIdentityRepository defines the methods that a user identity
+// repository should implement
+type UserIdentityRepository interface {
+	// Create links an identity to a user; it returns a
+	// ResourceExistsError when the identity is linked already.
+	Create(ctx context.Context, identity *UserIdentity) (*UserIdentity, error)
+	// Find returns the identity a provider knows by subject.
+	Find(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	// FindByUserID returns the identities of a user, oldest first.
+	FindByUserID(ctx context.Context, userID string) ([]*UserIdentity, error)
+	// Touch records a sign-in with the identity.
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	// Delete unlinks an identity of a user; it returns a
+	// ResourceNotFoundError when the user has no such identity.
+	Delete(ctx context.Context, userID, id string) error
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes of user tokens.
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+)
+
+// UserToken is a single-use secret sent to a user out of band, e.g. in a
+// password reset e-mail. Only the SHA-256 hash of the secret is stored.
+type UserToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserTokenRepository defines the methods that a user token repository
+// should implement
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *UserToken) error
+	// Find returns the unused, unexpired token with the given purpose and
+	// hash without using it, or a ResourceNotFoundError.
+	Find(ctx context.Context, purpose, tokenHash string) (*UserToken, error)
+	// Consume marks the unused, unexpired token with the given purpose and
+	// hash as used and returns it. Only the first caller gets the token; it
+	// returns a ResourceNotFoundError otherwise.
+	Consume(ctx context.Context, purpose, tokenHash string) (*UserToken, error)
+	// DeleteByUserID removes the tokens of a user for the given purpose, so
+	// that only the most recently issued one is valid.
+	DeleteByUserID(ctx context.Context, userID, purpose string) error
+}
@@ -0,0 +1,114 @@
+// Package cache provides small in-process caches.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded, concurrency-safe cache whose entries also expire
+// after their own time to live. When full, the least recently used entry is
+// evicted.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	entries map[K]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU returns an LRU cache holding at most size entries.
+func NewLRU[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    size,
+		entries: make(map[K]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value stored for key unless it is missing or expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+
+	ent := elem.Value.(*entry[K, V]) //nolint:forcetypeassert // only entries are stored
+
+	if !c.now().Before(ent.expiresAt) {
+		c.removeElement(elem)
+
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return ent.value, true
+}
+
+// Set stores value for key for the duration of ttl.
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	if c.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		ent := elem.Value.(*entry[K, V]) //nolint:forcetypeassert // only entries are stored
+		ent.value, ent.expiresAt = value, expiresAt
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Delete removes key from the cache.
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Len returns the number of entries, including expired ones that have not
+// been evicted yet.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry[K, V]).key) //nolint:forcetypeassert // only entries are stored
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_GetSet(t *testing.T) {
+	t.Parallel()
+
+	c := NewLRU[string, bool](2)
+
+	c.Set("a", true, time.Minute)
+	c.Set("b", false, time.Minute)
+
+	if got, ok := c.Get("a"); !ok || !got {
+		t.Errorf("LRU.Get(a) = %v, %v, want true, true", got, ok)
+	}
+
+	// "b" is now the least recently used entry
+	c.Set("c", true, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("LRU.Get(b) found an evicted entry")
+	}
+
+	if got, ok := c.Get("c"); !ok || !got {
+		t.Errorf("LRU.Get(c) = %v, %v, want true, true", got, ok)
+	}
+
+	c.Delete("c")
+
+	if _, ok := c.Get("c"); ok {
+		t.Errorf("LRU.Get(c) found a deleted entry")
+	}
+
+	if got := c.Len(); got != 1 {
+		t.Errorf("LRU.Len() = %v, want 1", got)
+	}
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	c := NewLRU[string, int](10)
+	c.now = func() time.Time { return now }
+
+	c.Set("short", 1, time.Second)
+	c.Set("long", 2, time.Hour)
+	c.Set("never", 3, 0)
+
+	now = now.Add(time.Minute)
+
+	tests := []struct {
+		key    string
+		want   int
+		wantOK bool
+	}{
+		{key: "short", wantOK: false},
+		{key: "long", want: 2, wantOK: true},
+		{key: "never", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := c.Get(tt.key)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LRU.Get(%v) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got := c.Len(); got != 1 {
+		t.Errorf("LRU.Len() = %v, want 1", got)
+	}
+}
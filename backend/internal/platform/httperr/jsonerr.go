@@ -128,6 +128,20 @@ func WithStatus(status int) *RESTAPIError {
 			Status: http.StatusConflict,
 			Detail: "A conflict occurred while processing the request",
 		}
+	case http.StatusLocked: // 423
+		return &RESTAPIError{
+			Type:   "/errors/locked",
+			Title:  "Locked",
+			Status: http.StatusLocked,
+			Detail: "The resource is temporarily locked",
+		}
+	case http.StatusTooManyRequests: // 429
+		return &RESTAPIError{
+			Type:   "/errors/too-many-requests",
+			Title:  "Too Many Requests",
+			Status: http.StatusTooManyRequests,
+			Detail: "Too many requests were sent, retry later",
+		}
 	case http.StatusInternalServerError: // 500
 		return &RESTAPIError{
 			Type:   "/errors/internal-server-error",
@@ -0,0 +1,144 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testMessage = Message{
+	To:      "jane@example.com",
+	Subject: "Reset your password",
+	Body:    "Hello\nfollow the link",
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      SMTPConfig
+		msg      Message
+		sendErr  error
+		wantAuth bool
+		wantErr  bool
+	}{
+		{
+			name: "Success",
+			cfg:  SMTPConfig{Host: "smtp.example.com", Port: 587, From: "noreply@example.com"},
+			msg:  testMessage,
+		},
+		{
+			name:     "With Auth",
+			cfg:      SMTPConfig{Host: "smtp.example.com", Port: 587, Username: "user", Password: "pass"},
+			msg:      testMessage,
+			wantAuth: true,
+		},
+		{
+			name:    "Header Injection",
+			cfg:     SMTPConfig{Host: "smtp.example.com", Port: 587},
+			msg:     Message{To: "jane@example.com", Subject: "hi\r\nBcc: eve@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid Recipient",
+			cfg:     SMTPConfig{Host: "smtp.example.com", Port: 587},
+			msg:     Message{To: "not an address"},
+			wantErr: true,
+		},
+		{
+			name:    "Send Error",
+			cfg:     SMTPConfig{Host: "smtp.example.com", Port: 587},
+			msg:     testMessage,
+			sendErr: errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				gotAddr string
+				gotAuth smtp.Auth
+				gotData []byte
+			)
+
+			m := NewSMTPMailer(tt.cfg)
+			m.sendMail = func(addr string, a smtp.Auth, _ string, _ []string, msg []byte) error {
+				gotAddr, gotAuth, gotData = addr, a, msg
+
+				return tt.sendErr
+			}
+
+			err := m.Send(context.Background(), tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SMTPMailer.Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if gotAddr != "smtp.example.com:587" {
+				t.Errorf("SMTPMailer.Send() addr = %v", gotAddr)
+			}
+
+			if (gotAuth != nil) != tt.wantAuth {
+				t.Errorf("SMTPMailer.Send() auth = %v, want %v", gotAuth, tt.wantAuth)
+			}
+
+			if !strings.Contains(string(gotData), "Subject: Reset your password\r\n") ||
+				!strings.HasSuffix(string(gotData), "Hello\r\nfollow the link") {
+				t.Errorf("SMTPMailer.Send() data = %q", gotData)
+			}
+		})
+	}
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	if err := NewFileMailer(dir, "noreply@example.com").Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("FileMailer.Send() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("FileMailer.Send() wrote %v files, want 1", len(files))
+	}
+
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: jane@example.com\r\n") {
+		t.Errorf("FileMailer.Send() wrote %q", data)
+	}
+}
+
+func TestMemoryMailer_Send(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemoryMailer()
+
+	_ = m.Send(context.Background(), testMessage)
+	_ = m.Send(context.Background(), Message{To: "john@example.com", Subject: "other"})
+
+	if got := len(m.Messages()); got != 2 {
+		t.Errorf("MemoryMailer.Messages() = %v messages, want 2", got)
+	}
+
+	if got, ok := m.Last("jane@example.com"); !ok || got.Subject != testMessage.Subject {
+		t.Errorf("MemoryMailer.Last() = %v, %v", got, ok)
+	}
+
+	if _, ok := m.Last("nobody@example.com"); ok {
+		t.Errorf("MemoryMailer.Last() found a message that was never sent")
+	}
+}
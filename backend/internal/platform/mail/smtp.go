@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+var _ Mailer = &SMTPMailer{}
+
+// SMTPConfig is the configuration of an SMTP relay.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends e-mails through an SMTP relay. The connection is upgraded
+// with STARTTLS when the server supports it.
+type SMTPMailer struct {
+	cfg      SMTPConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{
+		cfg:      cfg,
+		sendMail: smtp.SendMail,
+	}
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	data, err := render(m.cfg.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	if err := m.sendMail(addr, auth, m.cfg.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("send mail error: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package random
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"math/big"
 	mrand "math/rand"
@@ -42,3 +43,15 @@ func String(length int) string {
 	// Convert the byte slice to a string and return
 	return string(randomBytes)
 }
+
+// Token generates a cryptographically secure random token of size bytes,
+// encoded as unpadded base64url so it is safe in URLs, headers and cookies.
+func Token(size int) (string, error) {
+	buffer := make([]byte, size)
+
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("rand.Read err: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
@@ -77,3 +77,51 @@ func TestString(t *testing.T) {
 		})
 	}
 }
+
+func TestToken(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		size int
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		wantLen int
+	}{
+		{
+			name:    "16 bytes",
+			args:    args{size: 16},
+			wantLen: 22,
+		},
+		{
+			name:    "32 bytes",
+			args:    args{size: 32},
+			wantLen: 43,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got1, err := Token(tt.args.size)
+			if err != nil {
+				t.Errorf("Token() error = %v", err)
+				return
+			}
+
+			got2, _ := Token(tt.args.size)
+
+			if len(got1) != tt.wantLen {
+				t.Errorf("Token() len = %v, want %v", len(got1), tt.wantLen)
+			}
+
+			if got1 == got2 {
+				t.Errorf("Token() = %v, want different from %v", got1, got2)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	// RFC 6238 appendix B, truncated to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Code() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Errorf("Code() error = nil, want ErrInvalidSecret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1111111111, 0)
+
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	tooOld, _ := Code(rfcSecret, Step(now)-2)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "Current Step", code: "050471", wantStep: Step(now), wantOK: true},
+		{name: "Previous Step", code: previous, wantStep: Step(now) - 1, wantOK: true},
+		{name: "Outside Skew", code: tooOld},
+		{name: "Wrong Code", code: "123456"},
+		{name: "Wrong Length", code: "50471"},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			step, ok := Validate(rfcSecret, tt.code, now, 1)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = %v, %v, want %v, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	t.Parallel()
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+
+	if len(secret) != 32 {
+		t.Errorf("GenerateSecret() = %v, want 32 base32 characters", secret)
+	}
+
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("Code() error = %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	t.Parallel()
+
+	got, err := url.Parse(ProvisioningURI("GoAdmin", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	if got.Scheme != "otpauth" || got.Host != "totp" || got.Path != "/GoAdmin:alice@example.com" {
+		t.Errorf("ProvisioningURI() = %v", got)
+	}
+
+	if got.Query().Get("secret") != rfcSecret || got.Query().Get("issuer") != "GoAdmin" {
+		t.Errorf("ProvisioningURI() query = %v", got.Query())
+	}
+}
@@ -0,0 +1,217 @@
+package rebac
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"goadmin-backend/internal/auth"
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+	"goadmin-backend/internal/platform/httpjson"
+)
+
+// maxLookupLimit bounds the limit query parameter of lookups.
+const maxLookupLimit = 1000
+
+var errInvalidQuery = errors.New("invalid query")
+
+type Handler struct {
+	httpjson.Handler
+	rebacService Service
+}
+
+func NewHandler(rebacService Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		Handler: httpjson.Handler{
+			Logger: logger,
+		},
+		rebacService: rebacService,
+	}
+}
+
+// Check tells whether a user has a permission on a resource. With
+// explain=true it also returns the path the check evaluated.
+func (h *Handler) Check(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	resource, err := resourceFromQuery(query.Get("resource_type"), query.Get("resource_id"))
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	userID, permission := query.Get("user_id"), query.Get("permission")
+	if userID == "" || permission == "" {
+		httperr.JSONError(res, fmt.Errorf("%w: user_id and permission are required", errInvalidQuery),
+			http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	explain, err := strconv.ParseBool(query.Get("explain"))
+	if err != nil && query.Get("explain") != "" {
+		httperr.JSONError(res, fmt.Errorf("%w: explain must be a boolean", errInvalidQuery), http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	check := h.rebacService.Check
+	if explain {
+		check = h.rebacService.Explain
+	}
+
+	result, err := check(req.Context(), &domain.User{ID: userID}, resource, permission)
+	if err != nil {
+		h.lookupError(res, req, err)
+
+		return
+	}
+
+	h.RespondJSON(res, result, http.StatusOK)
+}
+
+// Expand returns the userset tree of the relation of a resource.
+func (h *Handler) Expand(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	resource, err := resourceFromQuery(query.Get("resource_type"), query.Get("resource_id"))
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	relation := query.Get("relation")
+	if relation == "" {
+		httperr.JSONError(res, fmt.Errorf("%w: relation is required", errInvalidQuery), http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	tree, err := h.rebacService.Expand(req.Context(), resource, relation)
+	if err != nil {
+		h.lookupError(res, req, err)
+
+		return
+	}
+
+	h.RespondJSON(res, tree, http.StatusOK)
+}
+
+// LookupResources returns a page of the IDs of the resources of a type the
+// current user has a permission on.
+func (h *Handler) LookupResources(res http.ResponseWriter, req *http.Request) {
+	user, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		httperr.JSONError(res, errors.New("unauthorized"), http.StatusUnauthorized, req.URL.Path)
+
+		return
+	}
+
+	query := req.URL.Query()
+
+	resourceType, permission := query.Get("resource_type"), query.Get("permission")
+	if resourceType == "" || permission == "" {
+		httperr.JSONError(res, fmt.Errorf("%w: resource_type and permission are required", errInvalidQuery),
+			http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	page, err := pageFromQuery(query.Get("after"), query.Get("limit"))
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	result, err := h.rebacService.LookupResources(req.Context(), &user, resourceType, permission, page)
+	if err != nil {
+		h.lookupError(res, req, err)
+
+		return
+	}
+
+	h.RespondJSON(res, result, http.StatusOK)
+}
+
+// LookupSubjects returns a page of the IDs of the subjects of a type having
+// a permission on a resource, users by default.
+func (h *Handler) LookupSubjects(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	resource, err := resourceFromQuery(query.Get("resource_type"), query.Get("resource_id"))
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	permission := query.Get("permission")
+	if permission == "" {
+		httperr.JSONError(res, fmt.Errorf("%w: permission is required", errInvalidQuery), http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	subjectType := query.Get("subject_type")
+	if subjectType == "" {
+		subjectType = SubjectTypeUser
+	}
+
+	page, err := pageFromQuery(query.Get("after"), query.Get("limit"))
+	if err != nil {
+		httperr.JSONError(res, err, http.StatusBadRequest, req.URL.Path)
+
+		return
+	}
+
+	result, err := h.rebacService.LookupSubjects(req.Context(), resource, permission, subjectType, page)
+	if err != nil {
+		h.lookupError(res, req, err)
+
+		return
+	}
+
+	h.RespondJSON(res, result, http.StatusOK)
+}
+
+func (h *Handler) lookupError(res http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, ErrMaxDepthExceeded) {
+		httperr.JSONError(res, err, http.StatusUnprocessableEntity, req.URL.Path)
+
+		return
+	}
+
+	h.Logger.Error("error walking the relation graph", slog.Any("err", err))
+	httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+}
+
+func resourceFromQuery(resourceType, resourceID string) (*domain.Entity, error) {
+	if resourceType == "" || resourceID == "" {
+		return nil, fmt.Errorf("%w: resource_type and resource_id are required", errInvalidQuery)
+	}
+
+	return &domain.Entity{EntityType: resourceType, EntityID: resourceID}, nil
+}
+
+func pageFromQuery(after, limit string) (Page, error) {
+	page := Page{After: after}
+
+	if limit == "" {
+		return page, nil
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > maxLookupLimit {
+		return Page{}, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidQuery, maxLookupLimit)
+	}
+
+	page.Limit = n
+
+	return page, nil
+}
@@ -0,0 +1,168 @@
+package rebac
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+func newTestHandler(opts ...Option) *Handler {
+	return NewHandler(newTestService(opts...), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+func TestHandler_Expand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		opts     []Option
+		wantCode int
+	}{
+		{name: "OK", query: "resource_type=document&resource_id=1&relation=view", wantCode: http.StatusOK},
+		{name: "No Relation", query: "resource_type=document&resource_id=1", wantCode: http.StatusBadRequest},
+		{name: "No Resource", query: "relation=view", wantCode: http.StatusBadRequest},
+		{
+			name: "Max Depth", query: "resource_type=document&resource_id=1&relation=view",
+			opts: []Option{WithMaxDepth(2)}, wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+			newTestHandler(tt.opts...).Expand(res, httptest.NewRequest(http.MethodGet, "/v1/authz/expand?"+tt.query, nil))
+
+			if res.Code != tt.wantCode {
+				t.Errorf("Handler.Expand() code = %v, want %v", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandler_LookupSubjects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []string
+		wantNext string
+	}{
+		{name: "OK", query: "resource_type=document&resource_id=1&permission=view", wantCode: http.StatusOK, wantIDs: []string{"1", "2", "3"}},
+		{
+			name: "Page", query: "resource_type=document&resource_id=1&permission=view&after=1&limit=1",
+			wantCode: http.StatusOK, wantIDs: []string{"2"}, wantNext: "2",
+		},
+		{name: "Groups", query: "resource_type=document&resource_id=1&permission=view&subject_type=group", wantCode: http.StatusOK, wantIDs: []string{}},
+		{name: "Invalid Limit", query: "resource_type=document&resource_id=1&permission=view&limit=0", wantCode: http.StatusBadRequest},
+		{name: "No Permission", query: "resource_type=document&resource_id=1", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+			newTestHandler().LookupSubjects(res, httptest.NewRequest(http.MethodGet, "/v1/authz/lookup-subjects?"+tt.query, nil))
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("Handler.LookupSubjects() code = %v, want %v", res.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got LookupResult
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("Handler.LookupSubjects() decode error = %v", err)
+			}
+
+			if !slices.Equal(got.IDs, tt.wantIDs) || got.Next != tt.wantNext {
+				t.Errorf("Handler.LookupSubjects() = %+v, want %v next %q", got, tt.wantIDs, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestHandler_LookupResources_unauthenticated(t *testing.T) {
+	t.Parallel()
+
+	res := httptest.NewRecorder()
+	newTestHandler().LookupResources(res, httptest.NewRequest(http.MethodGet, "/v1/authz/lookup-resources?resource_type=document&permission=view", nil))
+
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("Handler.LookupResources() code = %v, want %v", res.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandler_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		query       string
+		failEntity  string
+		wantCode    int
+		wantAllowed bool
+		wantTrace   bool
+	}{
+		{name: "Allowed", query: "user_id=3&resource_type=document&resource_id=1&permission=view", wantCode: http.StatusOK, wantAllowed: true},
+		{name: "Denied", query: "user_id=9&resource_type=document&resource_id=1&permission=view", wantCode: http.StatusOK},
+		{
+			name: "Explain", query: "user_id=9&resource_type=document&resource_id=4&permission=view&explain=true",
+			wantCode: http.StatusOK, wantTrace: true,
+		},
+		{
+			name: "Explain Error", query: "user_id=9&resource_type=document&resource_id=1&permission=view&explain=true",
+			failEntity: "group:backend", wantCode: http.StatusInternalServerError,
+		},
+		{name: "Invalid Explain", query: "user_id=3&resource_type=document&resource_id=1&permission=view&explain=yes", wantCode: http.StatusBadRequest},
+		{name: "No User", query: "resource_type=document&resource_id=1&permission=view", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newTestHandler()
+			if tt.failEntity != "" {
+				h = NewHandler(NewService(
+					&RelationTupleRepositoryMock{tuples: tuples, failEntity: tt.failEntity},
+					&RelationDefinitionRepositoryMock{definitions: definitions},
+				), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+			}
+
+			res := httptest.NewRecorder()
+			h.Check(res, httptest.NewRequest(http.MethodGet, "/v1/authz/check?"+tt.query, nil))
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("Handler.Check() code = %v, want %v", res.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got ReBACCheckResult
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("Handler.Check() decode error = %v", err)
+			}
+
+			if got.Allowed != tt.wantAllowed || (got.Trace != nil) != tt.wantTrace {
+				t.Errorf("Handler.Check() = %+v, want allowed %v and trace %v", got, tt.wantAllowed, tt.wantTrace)
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package rebac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+const (
+	// DefaultLookupLimit is the size of a page of lookup results.
+	DefaultLookupLimit = 100
+
+	// DefaultLookupCacheTTL is how long the results of a lookup are kept for
+	// its following pages.
+	DefaultLookupCacheTTL = time.Minute
+	// DefaultLookupCacheSize is how many lookups are kept.
+	DefaultLookupCacheSize = 1000
+)
+
+// UsersetTree is the tree of the subjects having a relation of an entity:
+// the subjects its tuples name, and a child for each userset, relation or
+// parent the relation is granted by in turn.
+type UsersetTree struct {
+	EntityType string         `json:"entity_type"`
+	EntityID   string         `json:"entity_id"`
+	Relation   string         `json:"relation"`
+	Subjects   []Subject      `json:"subjects"`
+	Children   []*UsersetTree `json:"children"`
+}
+
+type Subject struct {
+	SubjectType string `json:"subject_type"`
+	SubjectID   string `json:"subject_id"`
+}
+
+// Page selects the IDs of a lookup after After, at most Limit of them,
+// DefaultLookupLimit by default.
+type Page struct {
+	After string
+	Limit int
+}
+
+// LookupResult is a page of IDs, in order. Next is the After of the next
+// page, empty on the last one.
+type LookupResult struct {
+	IDs  []string `json:"ids"`
+	Next string   `json:"next,omitempty"`
+}
+
+// Expand returns the userset tree of the relation of the resource, built by
+// the rules of Check. Usersets met again down a branch are not expanded
+// twice.
+func (s *rebacService) Expand(
+	ctx context.Context,
+	resource *domain.Entity,
+	relation string,
+) (*UsersetTree, error) {
+	c := &check{
+		rebacService: s,
+		definitions:  make(map[string][]*domain.RelationDefinition),
+	}
+
+	return c.expand(ctx, object{resource.EntityType, resource.EntityID, relation}, nil, 1)
+}
+
+func (c *check) expand(ctx context.Context, obj object, from *path, depth int) (*UsersetTree, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // the caller's context
+	}
+
+	if depth > c.maxDepth {
+		return nil, ErrMaxDepthExceeded
+	}
+
+	tree := &UsersetTree{
+		EntityType: obj.entityType,
+		EntityID:   obj.entityID,
+		Relation:   obj.relation,
+		Subjects:   []Subject{},
+		Children:   []*UsersetTree{},
+	}
+
+	if from.contains(obj) {
+		return tree, nil
+	}
+
+	tuples, err := c.tupleRepo.FindRelationTuple(ctx, obj.entity(), obj.relation)
+	if err != nil {
+		return nil, fmt.Errorf("find relation tuples error %w", err)
+	}
+
+	next := make([]edge, 0, len(tuples))
+
+	for _, tuple := range tuples {
+		if tuple.SubjectRelation != "" {
+			next = append(next, usersetEdge(tuple))
+
+			continue
+		}
+
+		tree.Subjects = append(tree.Subjects, Subject{SubjectType: tuple.SubjectType, SubjectID: tuple.SubjectID})
+	}
+
+	rewrites, err := c.rewrites(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	from = &path{object: obj, parent: from}
+	seen := make(map[object]bool)
+
+	for _, child := range append(next, rewrites...) {
+		if seen[child.object] {
+			continue
+		}
+
+		seen[child.object] = true
+
+		subtree, err := c.expand(ctx, child.object, from, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		tree.Children = append(tree.Children, subtree)
+	}
+
+	return tree, nil
+}
+
+// LookupSubjects returns the IDs of the subjects of subjectType having the
+// permission on the resource. It walks the relation graph from the resource
+// by the rules of Check, leaving out what lies deeper than the max depth.
+func (s *rebacService) LookupSubjects(
+	ctx context.Context,
+	resource *domain.Entity,
+	permission, subjectType string,
+	page Page,
+) (*LookupResult, error) {
+	start := object{resource.EntityType, resource.EntityID, permission}
+	key := lookupKey{lookup: "subjects", from: start, resultType: subjectType}
+
+	return s.lookup(key, page, func() (map[string]bool, error) {
+		return s.lookupSubjects(ctx, start, subjectType)
+	})
+}
+
+func (s *rebacService) lookupSubjects(ctx context.Context, start object, subjectType string) (map[string]bool, error) {
+	c := &check{
+		rebacService: s,
+		definitions:  make(map[string][]*domain.RelationDefinition),
+	}
+
+	ids := make(map[string]bool)
+	visited := map[object]bool{start: true}
+	frontier := []object{start}
+
+	// a subject found at a depth is allowed by a check of the same depth
+	for depth := 1; len(frontier) > 0 && depth <= s.maxDepth; depth++ {
+		var next []object
+
+		for _, obj := range frontier {
+			tuples, err := s.tupleRepo.FindRelationTuple(ctx, obj.entity(), obj.relation)
+			if err != nil {
+				return nil, fmt.Errorf("find relation tuples error %w", err)
+			}
+
+			edges, err := c.rewrites(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, tuple := range tuples {
+				switch {
+				case tuple.SubjectRelation != "":
+					edges = append(edges, usersetEdge(tuple))
+				case tuple.SubjectType == subjectType:
+					ids[tuple.SubjectID] = true
+				}
+			}
+
+			for _, e := range edges {
+				if !visited[e.object] {
+					visited[e.object] = true
+
+					next = append(next, e.object)
+				}
+			}
+		}
+
+		frontier = next
+	}
+
+	return ids, nil
+}
+
+// LookupResources returns the IDs of the entities of resourceType the user
+// has the permission on. It walks the relation graph backwards from the
+// tuples naming the user, reversing each rule of Check, and leaves out the
+// entities deeper than the max depth.
+func (s *rebacService) LookupResources(
+	ctx context.Context,
+	user *domain.User,
+	resourceType, permission string,
+	page Page,
+) (*LookupResult, error) {
+	key := lookupKey{
+		lookup:     "resources",
+		from:       object{SubjectTypeUser, user.ID, permission},
+		resultType: resourceType,
+	}
+
+	return s.lookup(key, page, func() (map[string]bool, error) {
+		return s.lookupResources(ctx, user, resourceType, permission)
+	})
+}
+
+func (s *rebacService) lookupResources(
+	ctx context.Context,
+	user *domain.User,
+	resourceType, permission string,
+) (map[string]bool, error) {
+	definitions, err := s.definitionRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find relation definitions error %w", err)
+	}
+
+	direct, err := s.allTuples(ctx, &domain.RelationTupleFilter{SubjectType: SubjectTypeUser, SubjectID: user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	relations := relationsByType(definitions)
+	visited := make(map[object]bool)
+
+	var frontier []object
+
+	for _, tuple := range direct {
+		if obj := tupleObject(tuple); tuple.SubjectRelation == "" && !visited[obj] {
+			visited[obj] = true
+
+			frontier = append(frontier, obj)
+		}
+	}
+
+	ids := make(map[string]bool)
+
+	// an object found at a depth allows the user by a check of that depth
+	for depth := 1; len(frontier) > 0 && depth <= s.maxDepth; depth++ {
+		var next []object
+
+		for _, obj := range frontier {
+			if obj.entityType == resourceType && obj.relation == permission {
+				ids[obj.entityID] = true
+			}
+
+			// nothing found from here on would be close enough
+			if depth == s.maxDepth {
+				continue
+			}
+
+			granted, err := s.grantedBy(ctx, obj, definitions, relations)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, g := range granted {
+				if !visited[g] {
+					visited[g] = true
+
+					next = append(next, g)
+				}
+			}
+		}
+
+		frontier = next
+	}
+
+	return ids, nil
+}
+
+// grantedBy returns the objects the subjects of obj have by it, the
+// reverse of what Check follows from an object. relations are the
+// relations of each type.
+func (s *rebacService) grantedBy(
+	ctx context.Context,
+	obj object,
+	definitions []*domain.RelationDefinition,
+	relations map[string]map[string]bool,
+) ([]object, error) {
+	var granted []object
+
+	// usersets: document:1#viewer@group:1#member
+	usersets, err := s.allTuples(ctx, &domain.RelationTupleFilter{
+		SubjectType:     obj.entityType,
+		SubjectID:       obj.entityID,
+		SubjectRelation: obj.relation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tuple := range usersets {
+		if tuple.SubjectRelation == obj.relation {
+			granted = append(granted, tupleObject(tuple))
+		}
+	}
+
+	for _, def := range definitions {
+		// definitions naming a type only restrict tuples
+		if !relations[def.EntityType][def.SubjectType] {
+			continue
+		}
+
+		switch {
+		// computed: document#view@owner
+		case def.SubjectRelation == "":
+			if def.EntityType == obj.entityType && def.SubjectType == obj.relation && def.RelationType != def.SubjectType {
+				granted = append(granted, object{obj.entityType, obj.entityID, def.RelationType})
+			}
+		// arrows: document#view@parent#member, from the entities naming obj
+		// by parent
+		case def.SubjectRelation == obj.relation:
+			named, err := s.allTuples(ctx, &domain.RelationTupleFilter{
+				EntityType:  def.EntityType,
+				Relation:    def.SubjectType,
+				SubjectType: obj.entityType,
+				SubjectID:   obj.entityID,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, tuple := range named {
+				if tuple.SubjectRelation == "" {
+					granted = append(granted, object{tuple.EntityType, tuple.EntityID, def.RelationType})
+				}
+			}
+		}
+	}
+
+	return granted, nil
+}
+
+// relationsByType returns the relations and permissions the definitions
+// grant, by entity type.
+func relationsByType(definitions []*domain.RelationDefinition) map[string]map[string]bool {
+	relations := make(map[string]map[string]bool)
+
+	for _, def := range definitions {
+		if relations[def.EntityType] == nil {
+			relations[def.EntityType] = make(map[string]bool)
+		}
+
+		relations[def.EntityType][def.RelationType] = true
+	}
+
+	return relations
+}
+
+// allTuples returns every tuple matching the filter, page by page.
+func (s *rebacService) allTuples(
+	ctx context.Context,
+	filter *domain.RelationTupleFilter,
+) ([]*domain.RelationTuple, error) {
+	filter.Limit = domain.DefaultRelationTupleLimit
+
+	var tuples []*domain.RelationTuple
+
+	for {
+		page, err := s.tupleRepo.FindAll(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("find relation tuples error %w", err)
+		}
+
+		tuples = append(tuples, page...)
+
+		if len(page) < filter.Limit {
+			return tuples, nil
+		}
+
+		filter.After = page[len(page)-1].ID
+	}
+}
+
+// lookupKey identifies the results of a lookup: the resources of
+// resultType a user has a permission on, from user:ID#permission, or the
+// subjects of resultType having the permission on from.
+type lookupKey struct {
+	lookup     string
+	from       object
+	resultType string
+}
+
+// lookup returns a page of the IDs find returns. The first page walks the
+// relation graph, and the following ones are cut from its results while
+// they are cached, so that paging does not walk it again.
+func (s *rebacService) lookup(key lookupKey, page Page, find func() (map[string]bool, error)) (*LookupResult, error) {
+	if page.After != "" {
+		if ids, ok := s.lookups.Get(key); ok {
+			return paginate(ids, page), nil
+		}
+	}
+
+	found, err := find()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	s.lookups.Set(key, ids, s.lookupCacheTTL)
+
+	return paginate(ids, page), nil
+}
+
+// paginate returns the page of the sorted ids.
+func paginate(ids []string, page Page) *LookupResult {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultLookupLimit
+	}
+
+	start := sort.SearchStrings(ids, page.After)
+	if start < len(ids) && page.After != "" && ids[start] == page.After {
+		start++
+	}
+
+	result := &LookupResult{IDs: ids[start:]}
+
+	if len(result.IDs) > limit {
+		result.IDs = result.IDs[:limit]
+		result.Next = result.IDs[limit-1]
+	}
+
+	return result
+}
+
+// tupleObject returns the object a tuple relates its subject to.
+func tupleObject(tuple *domain.RelationTuple) object {
+	return object{tuple.EntityType, tuple.EntityID, tuple.Relation}
+}
@@ -0,0 +1,190 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+)
+
+func newTestService(opts ...Option) Service {
+	return NewService(
+		&RelationTupleRepositoryMock{tuples: tuples},
+		&RelationDefinitionRepositoryMock{definitions: definitions},
+		opts...,
+	)
+}
+
+// allDefinitionsRepo returns the test definitions of every type.
+type allDefinitionsRepo struct {
+	RelationDefinitionRepositoryMock
+}
+
+func (r *allDefinitionsRepo) FindAll(ctx context.Context) ([]*domain.RelationDefinition, error) {
+	var all []*domain.RelationDefinition
+
+	for _, entityType := range []string{"document", "folder", "group", "project"} {
+		definitions, _ := r.FindRelationDefinition(ctx, entityType)
+		all = append(all, definitions...)
+	}
+
+	return all, nil
+}
+
+func TestService_Expand(t *testing.T) {
+	t.Parallel()
+
+	tree, err := newTestService().Expand(context.Background(), &domain.Entity{EntityType: "document", EntityID: "1"}, "viewer")
+	if err != nil {
+		t.Fatalf("Service.Expand() error = %v", err)
+	}
+
+	if len(tree.Subjects) != 0 || len(tree.Children) != 1 {
+		t.Fatalf("Service.Expand() = %+v, want the group userset only", tree)
+	}
+
+	eng := tree.Children[0]
+	if eng.EntityType != "group" || eng.EntityID != "eng" || eng.Relation != "member" ||
+		!slices.Contains(eng.Subjects, Subject{SubjectType: "user", SubjectID: "2"}) {
+		t.Errorf("Service.Expand() group = %+v, want the members of group:eng", eng)
+	}
+
+	if len(eng.Children) != 1 || !slices.Contains(eng.Children[0].Subjects, Subject{SubjectType: "user", SubjectID: "3"}) {
+		t.Errorf("Service.Expand() nested group = %+v, want the members of group:backend", eng.Children)
+	}
+
+	// cycles end
+	if _, err := newTestService().Expand(context.Background(), &domain.Entity{EntityType: "document", EntityID: "4"}, "view"); err != nil {
+		t.Errorf("Service.Expand() error = %v", err)
+	}
+
+	_, err = newTestService(WithMaxDepth(2)).Expand(context.Background(), &domain.Entity{EntityType: "document", EntityID: "1"}, "view")
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Service.Expand() error = %v, want %v", err, ErrMaxDepthExceeded)
+	}
+}
+
+func TestService_LookupSubjects(t *testing.T) {
+	t.Parallel()
+
+	s := newTestService()
+	document := &domain.Entity{EntityType: "document", EntityID: "1"}
+
+	got, err := s.LookupSubjects(context.Background(), document, "view", "user", Page{Limit: 2})
+	if err != nil || !slices.Equal(got.IDs, []string{"1", "2"}) || got.Next != "2" {
+		t.Fatalf("Service.LookupSubjects() = %+v, %v, want users 1 and 2 and more", got, err)
+	}
+
+	got, err = s.LookupSubjects(context.Background(), document, "view", "user", Page{After: got.Next, Limit: 2})
+	if err != nil || !slices.Equal(got.IDs, []string{"3"}) || got.Next != "" {
+		t.Errorf("Service.LookupSubjects() = %+v, %v, want the last page with user 3", got, err)
+	}
+}
+
+func TestService_LookupSubjects_maxDepth(t *testing.T) {
+	t.Parallel()
+
+	// group:eng#member is too deep, the owner is not
+	got, err := newTestService(WithMaxDepth(2)).LookupSubjects(
+		context.Background(), &domain.Entity{EntityType: "document", EntityID: "1"}, "view", "user", Page{},
+	)
+	if err != nil || !slices.Equal(got.IDs, []string{"1"}) {
+		t.Errorf("Service.LookupSubjects() = %+v, %v, want user 1 only", got, err)
+	}
+}
+
+func TestService_lookupCache(t *testing.T) {
+	t.Parallel()
+
+	document := &domain.Entity{EntityType: "document", EntityID: "1"}
+
+	for _, tt := range []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "Cached"},
+		{name: "Not Cached", opts: []Option{WithLookupCacheTTL(0)}, wantErr: true},
+	} {
+		tupleRepo := &RelationTupleRepositoryMock{tuples: tuples}
+		s := NewService(tupleRepo, &RelationDefinitionRepositoryMock{definitions: definitions}, tt.opts...)
+
+		first, err := s.LookupSubjects(context.Background(), document, "view", "user", Page{Limit: 1})
+		if err != nil {
+			t.Fatalf("%s: Service.LookupSubjects() error = %v", tt.name, err)
+		}
+
+		// the following pages do not walk the relation graph again
+		tupleRepo.hasError = true
+
+		got, err := s.LookupSubjects(context.Background(), document, "view", "user", Page{After: first.Next, Limit: 1})
+		if (err != nil) != tt.wantErr || (err == nil && !slices.Equal(got.IDs, []string{"2"})) {
+			t.Errorf("%s: Service.LookupSubjects() = %+v, %v, want the second page %v", tt.name, got, err, !tt.wantErr)
+		}
+	}
+}
+
+func TestService_LookupResources(t *testing.T) {
+	t.Parallel()
+
+	tupleRepo := &RelationTupleRepositoryMock{tuples: tuples}
+	definitionRepo := &allDefinitionsRepo{RelationDefinitionRepositoryMock{definitions: definitions}}
+	s := NewService(tupleRepo, definitionRepo)
+	ctx := context.Background()
+
+	tests := []struct {
+		userID string
+		want   []string
+	}{
+		{userID: "1", want: []string{"1"}},
+		{userID: "3", want: []string{"1"}},
+		{userID: "4", want: []string{"2"}},
+		{userID: "5", want: []string{"3"}},
+		{userID: "9", want: []string{}},
+	}
+	for _, tt := range tests {
+		user := &domain.User{ID: tt.userID}
+
+		got, err := s.LookupResources(ctx, user, "document", "view", Page{})
+		if err != nil || !slices.Equal(got.IDs, tt.want) {
+			t.Errorf("Service.LookupResources(user %s) = %+v, %v, want %v", tt.userID, got, err, tt.want)
+		}
+
+		// lookups agree with checks
+		for id := 1; id <= 4; id++ {
+			documentID := fmt.Sprint(id)
+
+			check, err := s.Check(ctx, user, &domain.Entity{EntityType: "document", EntityID: documentID}, "view")
+			if err != nil || check.Allowed != slices.Contains(tt.want, documentID) {
+				t.Errorf("Service.Check(user %s, document %s) = %+v, %v, want it to agree with the lookup", tt.userID, documentID, check, err)
+			}
+		}
+	}
+
+	// folders deeper than the max depth are left out, not failing the lookup
+	chain := []string{"folder:5#viewer@user:1"}
+	for i := 0; i < 5; i++ {
+		chain = append(chain, fmt.Sprintf("folder:%d#parent@folder:%d", i, i+1))
+	}
+
+	got, err := NewService(&RelationTupleRepositoryMock{tuples: chain}, definitionRepo, WithMaxDepth(6)).
+		LookupResources(ctx, &domain.User{ID: "1"}, "folder", "view", Page{})
+	if err != nil || !slices.Equal(got.IDs, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("Service.LookupResources() = %+v, %v, want folders 1 to 5", got, err)
+	}
+
+	// the viewers of folders are not viewers of their documents
+	if got, err := s.LookupResources(ctx, &domain.User{ID: "5"}, "document", "viewer", Page{}); err != nil || len(got.IDs) != 0 {
+		t.Errorf("Service.LookupResources(user 5, viewer) = %+v, %v, want none", got, err)
+	}
+
+	if _, err := NewService(
+		&RelationTupleRepositoryMock{hasError: true},
+		definitionRepo,
+	).LookupResources(ctx, &domain.User{ID: "1"}, "document", "view", Page{}); err == nil {
+		t.Errorf("Service.LookupResources() error = nil, want error")
+	}
+}
@@ -2,35 +2,516 @@ package rebac
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/cache"
 )
 
+const (
+	// DefaultMaxDepth is how many relations deep a check follows the
+	// relation graph before giving up.
+	DefaultMaxDepth = 25
+	// DefaultMaxConcurrency is how many branches of a check are checked
+	// concurrently.
+	DefaultMaxConcurrency = 16
+
+	// SubjectTypeUser is the subject type of tuples naming users.
+	SubjectTypeUser = "user"
+
+	// RelationParent links an entity to the entities definitions can grant
+	// its relations from, like document:1#parent@folder:1.
+	RelationParent = "parent"
+)
+
+var ErrMaxDepthExceeded = errors.New("max depth of the relation graph exceeded")
+
 type ReBACCheckResult struct {
-	Allowed bool
-	Depth   int
+	Allowed bool `json:"allowed"`
+	// Depth is how many relations deep the check was decided: the length of
+	// the path to the tuple naming the user when allowed, or else the
+	// deepest relation looked at. It is never above the max depth.
+	Depth int `json:"depth"`
+	// Trace is the path the check evaluated, only set by Explain.
+	Trace *CheckTrace `json:"trace,omitempty"`
+}
+
+type Service interface {
+	// Check tells whether the user has the relation or permission named
+	// action on the resource.
+	Check(
+		ctx context.Context,
+		user *domain.User,
+		resource *domain.Entity,
+		action string,
+	) (*ReBACCheckResult, error)
+	// Explain is Check, also tracing every relation, tuple and rewrite it
+	// evaluated.
+	Explain(
+		ctx context.Context,
+		user *domain.User,
+		resource *domain.Entity,
+		action string,
+	) (*ReBACCheckResult, error)
+	// Expand returns the tree of the subjects having the relation on the
+	// resource.
+	Expand(ctx context.Context, resource *domain.Entity, relation string) (*UsersetTree, error)
+	// LookupResources returns the IDs of the entities of resourceType the
+	// user has the permission on.
+	LookupResources(
+		ctx context.Context,
+		user *domain.User,
+		resourceType, permission string,
+		page Page,
+	) (*LookupResult, error)
+	// LookupSubjects returns the IDs of the subjects of subjectType having
+	// the permission on the resource.
+	LookupSubjects(
+		ctx context.Context,
+		resource *domain.Entity,
+		permission, subjectType string,
+		page Page,
+	) (*LookupResult, error)
+}
+
+type Option func(*rebacService)
+
+// WithMaxDepth sets how many relations deep checks go, DefaultMaxDepth by
+// default.
+func WithMaxDepth(depth int) Option {
+	return func(s *rebacService) {
+		s.maxDepth = depth
+	}
+}
+
+// WithMaxConcurrency sets how many branches of a check are checked
+// concurrently, DefaultMaxConcurrency by default. The others are checked
+// one after the other; zero checks all of them so.
+func WithMaxConcurrency(n int) Option {
+	return func(s *rebacService) {
+		s.maxConcurrency = n
+	}
+}
+
+// WithLookupCacheTTL sets how long the results of a lookup are kept for its
+// following pages, DefaultLookupCacheTTL by default. Zero walks the
+// relation graph for every page.
+func WithLookupCacheTTL(ttl time.Duration) Option {
+	return func(s *rebacService) {
+		s.lookupCacheTTL = ttl
+	}
+}
+
+type rebacService struct {
+	tupleRepo      domain.RelationTupleRepository
+	definitionRepo domain.RelationDefinitionRepository
+	maxDepth       int
+	maxConcurrency int
+	lookups        *cache.LRU[lookupKey, []string]
+	lookupCacheTTL time.Duration
+}
+
+// NewService returns the ReBAC service evaluating checks over the relation
+// tuples and definitions of the repositories.
+func NewService( //nolint: ireturn // it's a factory function
+	tupleRepo domain.RelationTupleRepository,
+	definitionRepo domain.RelationDefinitionRepository,
+	opts ...Option,
+) Service {
+	s := &rebacService{
+		tupleRepo:      tupleRepo,
+		definitionRepo: definitionRepo,
+		maxDepth:       DefaultMaxDepth,
+		maxConcurrency: DefaultMaxConcurrency,
+		lookups:        cache.NewLRU[lookupKey, []string](DefaultLookupCacheSize),
+		lookupCacheTTL: DefaultLookupCacheTTL,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
-func Check(
+// Check walks the relation graph from the relation action of the resource,
+// Zanzibar style. A relation is granted by
+//   - direct tuples naming the user, like document:1#viewer@user:1,
+//   - usersets in tuples, like document:1#viewer@group:1#member, whose
+//     relation is checked in turn,
+//   - definitions computing it from another relation of the entity, like
+//     document#view@owner,
+//   - and arrows, definitions granting it to a relation of the entities
+//     another relation names, like document#view@parent#member.
+//
+// Other definitions only restrict the subjects of tuples, and parents only
+// grant what arrows say.
+//
+// The branches are checked concurrently, up to the max concurrency, and the
+// first one allowing the user cancels the others. Branches going round in a cycle are cut, and checks
+// going deeper than the max depth fail with ErrMaxDepthExceeded, unless
+// another branch allows the user.
+func (s *rebacService) Check(
 	ctx context.Context,
 	user *domain.User,
 	resource *domain.Entity,
 	action string,
 ) (*ReBACCheckResult, error) {
-	depth := 0
-	allowed := false
+	return s.evaluate(ctx, user, resource, action, false)
+}
+
+// Explain checks like Check, but waits for every branch, the ones cut short
+// by a branch allowing the user included, to return the trace of all of
+// them. Branches too deep are traced, not failing the check; other errors
+// are returned with the result so far and its trace.
+func (s *rebacService) Explain(
+	ctx context.Context,
+	user *domain.User,
+	resource *domain.Entity,
+	action string,
+) (*ReBACCheckResult, error) {
+	return s.evaluate(ctx, user, resource, action, true)
+}
+
+func (s *rebacService) evaluate(
+	ctx context.Context,
+	user *domain.User,
+	resource *domain.Entity,
+	action string,
+	explain bool,
+) (*ReBACCheckResult, error) {
+	c := &check{
+		rebacService: s,
+		userID:       user.ID,
+		explain:      explain,
+		slots:        make(chan struct{}, max(s.maxConcurrency, 0)),
+		definitions:  make(map[string][]*domain.RelationDefinition),
+	}
+
+	res, err := c.check(ctx, object{resource.EntityType, resource.EntityID, action}, nil, 1)
+
+	// explaining checks trace the branches too deep and deny
+	if explain && errors.Is(err, ErrMaxDepthExceeded) {
+		err = nil
+	}
+
+	if err != nil && res.trace == nil {
+		return nil, err
+	}
 
 	return &ReBACCheckResult{
-		Allowed: allowed,
-		Depth:   depth,
-	}, nil
+		Allowed: res.allowed,
+		Depth:   res.depth,
+		Trace:   res.trace,
+	}, err
 }
 
-type ReBACService interface {
-	FindRelationTuple(
-		ctx context.Context,
-		user *domain.User,
-		resource *domain.Entity,
-		action string,
-	) (*domain.RelationTuple, error)
+// object is a relation of an entity, like document:1#viewer.
+type object struct {
+	entityType string
+	entityID   string
+	relation   string
+}
+
+func (o object) entity() *domain.Entity {
+	return &domain.Entity{EntityType: o.entityType, EntityID: o.entityID}
+}
+
+// edge is an object a check goes on to, with the rule it goes by and, for
+// usersets, the tuple naming it.
+type edge struct {
+	object object
+	rule   string
+	tuple  *domain.RelationTuple
+}
+
+// path is the chain of objects a check was reached through, to detect
+// cycles.
+type path struct {
+	object object
+	parent *path
+}
+
+func (p *path) contains(o object) bool {
+	for ; p != nil; p = p.parent {
+		if p.object == o {
+			return true
+		}
+	}
+
+	return false
+}
+
+type result struct {
+	allowed bool
+	depth   int
+	trace   *CheckTrace
+}
+
+// check is one evaluation of the relation graph, caching the definitions
+// it reads. Explaining checks trace the relations they evaluate.
+type check struct {
+	*rebacService
+	userID  string
+	explain bool
+	// slots bound the branches checked concurrently
+	slots chan struct{}
+
+	mu          sync.Mutex
+	definitions map[string][]*domain.RelationDefinition
+}
+
+func (c *check) check(ctx context.Context, obj object, from *path, depth int) (result, error) {
+	if err := ctx.Err(); err != nil {
+		return result{}, err //nolint:wrapcheck // the caller's context
+	}
+
+	if depth > c.maxDepth {
+		return result{}, ErrMaxDepthExceeded
+	}
+
+	if from.contains(obj) {
+		return result{depth: depth, trace: c.trace(obj, depth, TraceCycle)}, nil
+	}
+
+	tuples, err := c.tupleRepo.FindRelationTuple(ctx, obj.entity(), obj.relation)
+	if err != nil {
+		return result{}, fmt.Errorf("find relation tuples error %w", err)
+	}
+
+	next := make([]edge, 0, len(tuples))
+
+	for _, tuple := range tuples {
+		switch {
+		case tuple.SubjectRelation != "":
+			next = append(next, usersetEdge(tuple))
+		case tuple.SubjectType == SubjectTypeUser && tuple.SubjectID == c.userID:
+			res := result{allowed: true, depth: depth, trace: c.trace(obj, depth, TraceHit)}
+			if res.trace != nil {
+				res.trace.Rule, res.trace.Tuple = RuleTuple, tupleString(tuple)
+			}
+
+			return res, nil
+		}
+	}
+
+	rewrites, err := c.rewrites(ctx, obj)
+	if err != nil {
+		return result{}, err
+	}
+
+	res, children, err := c.union(ctx, append(next, rewrites...), &path{object: obj, parent: from}, depth)
+	if err != nil && !c.explain {
+		return result{}, err
+	}
+
+	if c.explain {
+		res.trace = c.trace(obj, depth, TraceMiss)
+
+		switch {
+		case res.allowed:
+			res.trace.Result = TraceHit
+		case err != nil:
+			res.trace.Result = traceResult(err)
+		}
+
+		res.trace.Children = children
+	}
+
+	return res, err
+}
+
+func usersetEdge(tuple *domain.RelationTuple) edge {
+	return edge{
+		object: object{tuple.SubjectType, tuple.SubjectID, tuple.SubjectRelation},
+		rule:   RuleUserset,
+		tuple:  tuple,
+	}
+}
+
+// rewrites returns the objects granting the relation of obj according to
+// the definitions of its type.
+func (c *check) rewrites(ctx context.Context, obj object) ([]edge, error) {
+	definitions, err := c.definitionsOf(ctx, obj.entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := definedRelations(definitions)
+	tuplesets := make(map[string][]*domain.RelationTuple)
+
+	var edges []edge
+
+	for _, def := range definitions {
+		// definitions naming a type only restrict tuples
+		if def.RelationType != obj.relation || !relations[def.SubjectType] {
+			continue
+		}
+
+		if def.SubjectRelation == "" {
+			if def.SubjectType != obj.relation {
+				edges = append(edges, edge{object: object{obj.entityType, obj.entityID, def.SubjectType}, rule: RuleComputed})
+			}
+
+			continue
+		}
+
+		tupleset, ok := tuplesets[def.SubjectType]
+		if !ok {
+			tupleset, err = c.tupleRepo.FindRelationTuple(ctx, obj.entity(), def.SubjectType)
+			if err != nil {
+				return nil, fmt.Errorf("find relation tuples error %w", err)
+			}
+
+			tuplesets[def.SubjectType] = tupleset
+		}
+
+		for _, tuple := range tupleset {
+			if tuple.SubjectRelation == "" {
+				edges = append(edges, edge{
+					object: object{tuple.SubjectType, tuple.SubjectID, def.SubjectRelation},
+					rule:   RuleArrow,
+					tuple:  tuple,
+				})
+			}
+		}
+	}
+
+	return edges, nil
+}
+
+// definedRelations returns the set of the relations and permissions the
+// definitions grant.
+func definedRelations(definitions []*domain.RelationDefinition) map[string]bool {
+	relations := make(map[string]bool, len(definitions))
+	for _, def := range definitions {
+		relations[def.RelationType] = true
+	}
+
+	return relations
+}
+
+func (c *check) definitionsOf(ctx context.Context, entityType string) ([]*domain.RelationDefinition, error) {
+	c.mu.Lock()
+	definitions, ok := c.definitions[entityType]
+	c.mu.Unlock()
+
+	if ok {
+		return definitions, nil
+	}
+
+	definitions, err := c.definitionRepo.FindRelationDefinition(ctx, entityType)
+	if err != nil {
+		return nil, fmt.Errorf("find relation definitions error %w", err)
+	}
+
+	c.mu.Lock()
+	c.definitions[entityType] = definitions
+	c.mu.Unlock()
+
+	return definitions, nil
+}
+
+// outcome is the result of the check of the i-th edge of a union.
+type outcome struct {
+	i      int
+	result result
+	err    error
+}
+
+// union checks the objects one level deeper concurrently, as far as the
+// slots of the check go, and the others in turn. It is allowed as soon as
+// one of them is, and fails when none is and one failed. Explaining unions
+// wait for all of them, and return their traces, the failed ones included,
+// with the error.
+func (c *check) union(ctx context.Context, edges []edge, from *path, depth int) (result, []*CheckTrace, error) {
+	res := result{depth: depth}
+
+	if len(edges) == 0 {
+		return res, nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered, so that the checks left behind do not block
+	outcomes := make(chan outcome, len(edges))
+	seen := make(map[object]bool, len(edges))
+	unique := make([]edge, 0, len(edges))
+
+	for _, e := range edges {
+		if seen[e.object] {
+			continue
+		}
+
+		seen[e.object] = true
+		i := len(unique)
+		unique = append(unique, e)
+
+		select {
+		case c.slots <- struct{}{}:
+			go func(obj object) {
+				defer func() { <-c.slots }()
+
+				res, err := c.check(ctx, obj, from, depth+1)
+				outcomes <- outcome{i, res, err}
+			}(e.object)
+
+			continue
+		default:
+		}
+
+		// no slot free, so that checking it here cannot wait for one
+		res, err := c.check(ctx, e.object, from, depth+1)
+		outcomes <- outcome{i, res, err}
+
+		if err == nil && res.allowed {
+			if !c.explain {
+				break
+			}
+
+			// the branches left are skipped
+			cancel()
+		}
+	}
+
+	var (
+		firstErr error
+		done     []outcome
+	)
+
+	for range unique {
+		out := <-outcomes
+		done = append(done, out)
+
+		switch {
+		case out.err != nil:
+			// failures tell more than branches too deep
+			if firstErr == nil || (errors.Is(firstErr, ErrMaxDepthExceeded) && !errors.Is(out.err, ErrMaxDepthExceeded)) {
+				firstErr = out.err
+			}
+		case out.result.allowed:
+			if !c.explain {
+				return out.result, nil, nil
+			}
+
+			if !res.allowed {
+				res = out.result
+				res.trace = nil
+
+				cancel()
+			}
+		case !res.allowed && out.result.depth > res.depth:
+			res.depth = out.result.depth
+		}
+	}
+
+	if !res.allowed && firstErr != nil {
+		return res, c.traces(unique, done, depth+1), firstErr
+	}
+
+	return res, c.traces(unique, done, depth+1), nil
 }
@@ -0,0 +1,359 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.RelationTupleRepository = &RelationTupleRepositoryMock{}
+
+// RelationTupleRepositoryMock holds tuples written like
+// document:1#viewer@group:1#member.
+type RelationTupleRepositoryMock struct {
+	tuples   []string
+	hasError bool
+	// failEntity fails the reads of the tuples of an entity, like group:1
+	failEntity string
+	calls      atomic.Int64
+	// delay holds the reads of tuples, which reading and maxReading count
+	delay      time.Duration
+	reading    atomic.Int64
+	maxReading atomic.Int64
+}
+
+func parseTuple(s string) *domain.RelationTuple {
+	object, subject, _ := strings.Cut(s, "@")
+	entity, relation, _ := strings.Cut(object, "#")
+	entityType, entityID, _ := strings.Cut(entity, ":")
+	subject, subjectRelation, _ := strings.Cut(subject, "#")
+	subjectType, subjectID, _ := strings.Cut(subject, ":")
+
+	return &domain.RelationTuple{
+		EntityType:      entityType,
+		EntityID:        entityID,
+		Relation:        relation,
+		SubjectType:     subjectType,
+		SubjectID:       subjectID,
+		SubjectRelation: subjectRelation,
+	}
+}
+
+func (r *RelationTupleRepositoryMock) FindRelationTuple(
+	_ context.Context,
+	entity *domain.Entity,
+	relation string,
+) ([]*domain.RelationTuple, error) {
+	r.calls.Add(1)
+
+	if r.delay > 0 {
+		reading := r.reading.Add(1)
+		defer r.reading.Add(-1)
+
+		for peak := r.maxReading.Load(); reading > peak && !r.maxReading.CompareAndSwap(peak, reading); {
+			peak = r.maxReading.Load()
+		}
+
+		time.Sleep(r.delay)
+	}
+
+	if r.hasError || entity.EntityType+":"+entity.EntityID == r.failEntity {
+		return nil, errors.New("error")
+	}
+
+	var tuples []*domain.RelationTuple
+
+	for _, s := range r.tuples {
+		tuple := parseTuple(s)
+		if tuple.EntityType == entity.EntityType && tuple.EntityID == entity.EntityID && tuple.Relation == relation {
+			tuples = append(tuples, tuple)
+		}
+	}
+
+	return tuples, nil
+}
+
+func (r *RelationTupleRepositoryMock) FindAll(
+	_ context.Context,
+	filter *domain.RelationTupleFilter,
+) ([]*domain.RelationTuple, error) {
+	if r.hasError {
+		return nil, errors.New("error")
+	}
+
+	var tuples []*domain.RelationTuple
+
+	after, _ := strconv.Atoi(filter.After)
+
+	for i, s := range r.tuples {
+		if i+1 <= after || (filter.Limit > 0 && len(tuples) == filter.Limit) {
+			continue
+		}
+
+		tuple := parseTuple(s)
+		tuple.ID = strconv.Itoa(i + 1)
+
+		if (filter.EntityType == "" || tuple.EntityType == filter.EntityType) &&
+			(filter.EntityID == "" || tuple.EntityID == filter.EntityID) &&
+			(filter.Relation == "" || tuple.Relation == filter.Relation) &&
+			(filter.SubjectType == "" || tuple.SubjectType == filter.SubjectType) &&
+			(filter.SubjectID == "" || tuple.SubjectID == filter.SubjectID) &&
+			(filter.SubjectRelation == "" || tuple.SubjectRelation == filter.SubjectRelation) {
+			tuples = append(tuples, tuple)
+		}
+	}
+
+	return tuples, nil
+}
+
+func (r *RelationTupleRepositoryMock) Write(_ context.Context, tuple *domain.RelationTuple) error {
+	r.tuples = append(r.tuples, tupleString(tuple))
+
+	return nil
+}
+
+func (r *RelationTupleRepositoryMock) Delete(context.Context, *domain.RelationTuple) error {
+	return errors.New("not implemented")
+}
+
+func (r *RelationTupleRepositoryMock) BulkWrite(_ context.Context, writes, _ []*domain.RelationTuple) error {
+	for _, tuple := range writes {
+		r.tuples = append(r.tuples, tupleString(tuple))
+	}
+
+	return nil
+}
+
+var _ domain.RelationDefinitionRepository = &RelationDefinitionRepositoryMock{}
+
+// RelationDefinitionRepositoryMock holds definitions written like
+// document#view@parent#member.
+type RelationDefinitionRepositoryMock struct {
+	definitions []string
+}
+
+func (r *RelationDefinitionRepositoryMock) FindRelationDefinition(
+	_ context.Context,
+	entityType string,
+) ([]*domain.RelationDefinition, error) {
+	var definitions []*domain.RelationDefinition
+
+	for _, s := range r.definitions {
+		object, subject, _ := strings.Cut(s, "@")
+		defType, relation, _ := strings.Cut(object, "#")
+		subjectType, subjectRelation, _ := strings.Cut(subject, "#")
+
+		if defType == entityType {
+			definitions = append(definitions, &domain.RelationDefinition{
+				EntityType:      defType,
+				RelationType:    relation,
+				SubjectType:     subjectType,
+				SubjectRelation: subjectRelation,
+			})
+		}
+	}
+
+	return definitions, nil
+}
+
+func (r *RelationDefinitionRepositoryMock) FindAll(context.Context) ([]*domain.RelationDefinition, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *RelationDefinitionRepositoryMock) Create(
+	context.Context,
+	*domain.RelationDefinition,
+) (*domain.RelationDefinition, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *RelationDefinitionRepositoryMock) Delete(context.Context, *domain.RelationDefinition) error {
+	return errors.New("not implemented")
+}
+
+var definitions = []string{
+	"document#owner@user",
+	"document#viewer@user",
+	"document#viewer@group#member",
+	"document#parent@folder",
+	"document#parent@project",
+	"document#view@owner",
+	"document#view@viewer",
+	"document#view@parent#member",
+	"document#view@parent#view",
+	"document#edit@owner",
+	"folder#viewer@user",
+	"folder#parent@folder",
+	"folder#view@viewer",
+	"folder#view@parent#view",
+	"group#member@user",
+	"group#member@group#member",
+	"project#member@user",
+}
+
+var tuples = []string{
+	"document:1#owner@user:1",
+	"document:1#viewer@group:eng#member",
+	"group:eng#member@user:2",
+	"group:eng#member@group:backend#member",
+	"group:backend#member@user:3",
+	"document:2#parent@project:x",
+	"project:x#member@user:4",
+	"document:3#parent@folder:a",
+	"folder:a#parent@folder:root",
+	"folder:root#viewer@user:5",
+	// a cycle of groups
+	"group:a#member@group:b#member",
+	"group:b#member@group:a#member",
+	"document:4#viewer@group:a#member",
+}
+
+func TestService_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		userID    string
+		resource  string
+		action    string
+		want      bool
+		wantDepth int
+	}{
+		{name: "Direct", userID: "1", resource: "document:1", action: "owner", want: true, wantDepth: 1},
+		{name: "Computed", userID: "1", resource: "document:1", action: "view", want: true, wantDepth: 2},
+		{name: "Computed Other Relation", userID: "2", resource: "document:1", action: "edit", want: false, wantDepth: 2},
+		{name: "Userset", userID: "2", resource: "document:1", action: "viewer", want: true, wantDepth: 2},
+		{name: "Nested Userset", userID: "3", resource: "document:1", action: "view", want: true, wantDepth: 4},
+		{name: "Parent Permission", userID: "4", resource: "document:2", action: "view", want: true, wantDepth: 2},
+		{name: "Parent Permission Other User", userID: "1", resource: "document:2", action: "view", want: false},
+		{name: "Parent Chain", userID: "5", resource: "document:3", action: "view", want: true, wantDepth: 4},
+		{name: "Relation Of Parent", userID: "5", resource: "document:3", action: "viewer", want: false},
+		{name: "Cycle", userID: "9", resource: "document:4", action: "view", want: false},
+		{name: "Unknown", userID: "1", resource: "document:9", action: "view", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewService(
+				&RelationTupleRepositoryMock{tuples: tuples},
+				&RelationDefinitionRepositoryMock{definitions: definitions},
+			)
+
+			entityType, entityID, _ := strings.Cut(tt.resource, ":")
+
+			got, err := s.Check(
+				context.Background(),
+				&domain.User{ID: tt.userID},
+				&domain.Entity{EntityType: entityType, EntityID: entityID},
+				tt.action,
+			)
+			if err != nil {
+				t.Fatalf("Service.Check() error = %v", err)
+			}
+
+			if got.Allowed != tt.want {
+				t.Errorf("Service.Check() allowed = %v, want %v", got.Allowed, tt.want)
+			}
+
+			if tt.wantDepth != 0 && got.Depth != tt.wantDepth {
+				t.Errorf("Service.Check() depth = %v, want %v", got.Depth, tt.wantDepth)
+			}
+		})
+	}
+}
+
+func TestService_Check_maxDepth(t *testing.T) {
+	t.Parallel()
+
+	// folder:0 is in folder:1, which is in folder:2, ...
+	chain := []string{"folder:5#viewer@user:1"}
+	for _, tuple := range []string{"0#parent@folder:1", "1#parent@folder:2", "2#parent@folder:3", "3#parent@folder:4", "4#parent@folder:5"} {
+		chain = append(chain, "folder:"+tuple)
+	}
+
+	check := func(maxDepth int) (*ReBACCheckResult, error) {
+		s := NewService(
+			&RelationTupleRepositoryMock{tuples: chain},
+			&RelationDefinitionRepositoryMock{definitions: definitions},
+			WithMaxDepth(maxDepth),
+		)
+
+		return s.Check(context.Background(), &domain.User{ID: "1"}, &domain.Entity{EntityType: "folder", EntityID: "0"}, "view")
+	}
+
+	got, err := check(7)
+	if err != nil || !got.Allowed || got.Depth != 7 {
+		t.Errorf("Service.Check() = %+v, %v, want allowed at depth 7", got, err)
+	}
+
+	if got, err := check(6); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Service.Check() = %+v, %v, want %v", got, err, ErrMaxDepthExceeded)
+	}
+}
+
+func TestService_Check_maxConcurrency(t *testing.T) {
+	t.Parallel()
+
+	// group:wide has 20 member groups, of which the last has user 1
+	wide := []string{"group:w19#member@user:1"}
+	for i := range 20 {
+		wide = append(wide, "group:wide#member@group:w"+strconv.Itoa(i)+"#member")
+	}
+
+	for _, maxConcurrency := range []int{0, 1, 4} {
+		for userID, want := range map[string]bool{"1": true, "2": false} {
+			tupleRepo := &RelationTupleRepositoryMock{tuples: wide, delay: time.Millisecond}
+			s := NewService(
+				tupleRepo,
+				&RelationDefinitionRepositoryMock{definitions: definitions},
+				WithMaxConcurrency(maxConcurrency),
+			)
+
+			got, err := s.Check(context.Background(), &domain.User{ID: userID}, &domain.Entity{EntityType: "group", EntityID: "wide"}, "member")
+			if err != nil || got.Allowed != want {
+				t.Errorf("Service.Check() with %d concurrent = %+v, %v, want allowed %v", maxConcurrency, got, err, want)
+			}
+
+			// the branches checked in turn are read besides the concurrent ones
+			if peak := tupleRepo.maxReading.Load(); peak > int64(maxConcurrency)+1 {
+				t.Errorf("Service.Check() with %d concurrent read tuples %d times at once", maxConcurrency, peak)
+			}
+		}
+	}
+}
+
+func TestService_Check_errors(t *testing.T) {
+	t.Parallel()
+
+	s := NewService(
+		&RelationTupleRepositoryMock{tuples: tuples, hasError: true},
+		&RelationDefinitionRepositoryMock{definitions: definitions},
+	)
+
+	if _, err := s.Check(context.Background(), &domain.User{ID: "1"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view"); err == nil {
+		t.Errorf("Service.Check() error = nil, want error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tupleRepo := &RelationTupleRepositoryMock{tuples: tuples}
+	s = NewService(tupleRepo, &RelationDefinitionRepositoryMock{definitions: definitions})
+
+	if _, err := s.Check(ctx, &domain.User{ID: "1"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Service.Check() error = %v, want %v", err, context.Canceled)
+	}
+
+	if calls := tupleRepo.calls.Load(); calls != 0 {
+		t.Errorf("Service.Check() read tuples %d times after the context was canceled", calls)
+	}
+}
@@ -0,0 +1,710 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+)
+
+var (
+	ErrInvalidSchema = errors.New("invalid schema")
+	ErrInvalidTuple  = errors.New("relation tuple does not match the schema")
+)
+
+// Schema is an authorization schema, the entity types with the relations
+// tuples are written for and the permissions computed from them:
+//
+//	definition user {}
+//
+//	definition group {
+//		relation member: user | group#member
+//	}
+//
+//	definition document {
+//		relation parent: folder
+//		relation owner: user
+//		relation viewer: user | group#member
+//		permission view = owner + viewer + parent->view
+//	}
+//
+// Permissions are unions of relations and permissions of the entity, and of
+// those of its parents with parent->. Statements may end with ";", and
+// "//" starts a comment.
+type Schema struct {
+	Definitions []*TypeDefinition
+}
+
+// TypeDefinition is an entity type of a schema.
+type TypeDefinition struct {
+	Name        string
+	Relations   []*SchemaRelation
+	Permissions []*SchemaPermission
+}
+
+// SchemaRelation is a relation tuples are written for, with the subjects
+// they can name.
+type SchemaRelation struct {
+	Name     string
+	Subjects []SubjectReference
+}
+
+// SubjectReference is a subject type, like user, or a relation of one,
+// like group#member.
+type SubjectReference struct {
+	Type     string
+	Relation string
+}
+
+// SchemaPermission is the union of its terms.
+type SchemaPermission struct {
+	Name  string
+	Terms []PermissionTerm
+}
+
+// PermissionTerm is a relation or permission of the entity, like owner, or
+// of the entities it names with the relation Via, like parent->view.
+type PermissionTerm struct {
+	Via      string
+	Relation string
+}
+
+// ParseSchema parses and type-checks an authorization schema.
+func ParseSchema(src string) (*Schema, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	schema, err := p.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schema.check(); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// Definition returns the definition of an entity type, or nil.
+func (s *Schema) Definition(entityType string) *TypeDefinition {
+	for _, def := range s.Definitions {
+		if def.Name == entityType {
+			return def
+		}
+	}
+
+	return nil
+}
+
+// Relation returns the relation of the type with name, or nil.
+func (d *TypeDefinition) Relation(name string) *SchemaRelation {
+	for _, rel := range d.Relations {
+		if rel.Name == name {
+			return rel
+		}
+	}
+
+	return nil
+}
+
+// Permission returns the permission of the type with name, or nil.
+func (d *TypeDefinition) Permission(name string) *SchemaPermission {
+	for _, perm := range d.Permissions {
+		if perm.Name == name {
+			return perm
+		}
+	}
+
+	return nil
+}
+
+// has reports whether the type has a relation or permission with name.
+func (d *TypeDefinition) has(name string) bool {
+	return d.Relation(name) != nil || d.Permission(name) != nil
+}
+
+// check type-checks the schema: every type and relation referenced has to
+// be defined, and names are unique.
+func (s *Schema) check() error {
+	seen := make(map[string]bool, len(s.Definitions))
+
+	for _, def := range s.Definitions {
+		if seen[def.Name] {
+			return fmt.Errorf("%w: duplicate definition %s", ErrInvalidSchema, def.Name)
+		}
+
+		seen[def.Name] = true
+	}
+
+	for _, def := range s.Definitions {
+		if err := s.checkDefinition(def, seen); err != nil {
+			return fmt.Errorf("%w: definition %s: %w", ErrInvalidSchema, def.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) checkDefinition(def *TypeDefinition, types map[string]bool) error {
+	names := make(map[string]bool, len(def.Relations)+len(def.Permissions))
+
+	for _, name := range def.names() {
+		if names[name] {
+			return fmt.Errorf("duplicate relation or permission %s", name)
+		}
+
+		// computed relations are told apart from subject types by name
+		if types[name] {
+			return fmt.Errorf("relation or permission %s is named like a type", name)
+		}
+
+		names[name] = true
+	}
+
+	for _, rel := range def.Relations {
+		for _, subject := range rel.Subjects {
+			target := s.Definition(subject.Type)
+
+			switch {
+			case target == nil:
+				return fmt.Errorf("relation %s: unknown type %s", rel.Name, subject.Type)
+			case subject.Relation != "" && !target.has(subject.Relation):
+				return fmt.Errorf("relation %s: type %s has no relation %s", rel.Name, subject.Type, subject.Relation)
+			}
+		}
+	}
+
+	for _, perm := range def.Permissions {
+		for _, term := range perm.Terms {
+			if err := s.checkTerm(def, perm, term); err != nil {
+				return fmt.Errorf("permission %s: %w", perm.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) checkTerm(def *TypeDefinition, perm *SchemaPermission, term PermissionTerm) error {
+	if term.Via == "" {
+		switch {
+		case term.Relation == perm.Name:
+			return errors.New("refers to itself")
+		case !def.has(term.Relation):
+			return fmt.Errorf("unknown relation %s", term.Relation)
+		}
+
+		return nil
+	}
+
+	via := def.Relation(term.Via)
+
+	switch {
+	case via == nil:
+		return fmt.Errorf("unknown relation %s", term.Via)
+	case term.Via != RelationParent:
+		return fmt.Errorf("%s->%s: only the %s relation can be followed", term.Via, term.Relation, RelationParent)
+	}
+
+	found := false
+
+	for _, subject := range via.Subjects {
+		if subject.Relation != "" {
+			return fmt.Errorf("%s->%s: %s names %s#%s", term.Via, term.Relation, term.Via, subject.Type, subject.Relation)
+		}
+
+		found = found || s.Definition(subject.Type).has(term.Relation)
+	}
+
+	if !found {
+		return fmt.Errorf("%s->%s: no type of %s has %s", term.Via, term.Relation, term.Via, term.Relation)
+	}
+
+	return nil
+}
+
+func (d *TypeDefinition) names() []string {
+	names := make([]string, 0, len(d.Relations)+len(d.Permissions))
+
+	for _, rel := range d.Relations {
+		names = append(names, rel.Name)
+	}
+
+	for _, perm := range d.Permissions {
+		names = append(names, perm.Name)
+	}
+
+	return names
+}
+
+// RelationDefinitions returns the relation definitions Check evaluates
+// the schema with: document#viewer@group#member for a relation,
+// document#view@owner for a term and document#view@parent#view for
+// parent->view. Relations are never named like types, so that arrows and
+// computed relations are told apart from the subject types of relations.
+func (s *Schema) RelationDefinitions() []*domain.RelationDefinition {
+	var definitions []*domain.RelationDefinition
+
+	seen := make(map[string]bool)
+	add := func(def *domain.RelationDefinition) {
+		if key := definitionKey(def); !seen[key] {
+			seen[key] = true
+
+			definitions = append(definitions, def)
+		}
+	}
+
+	for _, def := range s.Definitions {
+		for _, rel := range def.Relations {
+			for _, subject := range rel.Subjects {
+				add(&domain.RelationDefinition{
+					EntityType:      def.Name,
+					RelationType:    rel.Name,
+					SubjectType:     subject.Type,
+					SubjectRelation: subject.Relation,
+				})
+			}
+		}
+
+		for _, perm := range def.Permissions {
+			for _, term := range perm.Terms {
+				if term.Via == "" {
+					add(&domain.RelationDefinition{
+						EntityType:   def.Name,
+						RelationType: perm.Name,
+						SubjectType:  term.Relation,
+					})
+
+					continue
+				}
+
+				add(&domain.RelationDefinition{
+					EntityType:      def.Name,
+					RelationType:    perm.Name,
+					SubjectType:     term.Via,
+					SubjectRelation: term.Relation,
+				})
+			}
+		}
+	}
+
+	return definitions
+}
+
+func definitionKey(def *domain.RelationDefinition) string {
+	return def.EntityType + "#" + def.RelationType + "@" + def.SubjectType + "#" + def.SubjectRelation
+}
+
+// ValidateTuple checks that a tuple relates an entity of a defined type by
+// one of its relations, not permissions, to a subject the relation allows.
+func (s *Schema) ValidateTuple(tuple *domain.RelationTuple) error {
+	if tuple.EntityID == "" || tuple.SubjectID == "" {
+		return fmt.Errorf("%w: missing entity or subject ID", ErrInvalidTuple)
+	}
+
+	def := s.Definition(tuple.EntityType)
+	if def == nil {
+		return fmt.Errorf("%w: unknown type %s", ErrInvalidTuple, tuple.EntityType)
+	}
+
+	rel := def.Relation(tuple.Relation)
+	if rel == nil {
+		return fmt.Errorf("%w: type %s has no relation %s", ErrInvalidTuple, tuple.EntityType, tuple.Relation)
+	}
+
+	for _, subject := range rel.Subjects {
+		if subject.Type == tuple.SubjectType && subject.Relation == tuple.SubjectRelation {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"%w: relation %s#%s does not allow %s",
+		ErrInvalidTuple,
+		tuple.EntityType,
+		tuple.Relation,
+		SubjectReference{Type: tuple.SubjectType, Relation: tuple.SubjectRelation},
+	)
+}
+
+// validatingTupleRepo is a tuple repository refusing to write tuples that do
+// not match the schema.
+type validatingTupleRepo struct {
+	domain.RelationTupleRepository
+	schema *Schema
+}
+
+// NewValidatingTupleRepo returns repo checking the tuples it writes against
+// schema, with a ValidationError for those not matching. Without a schema
+// repo is returned as is.
+func NewValidatingTupleRepo( //nolint: ireturn // it's a factory function
+	repo domain.RelationTupleRepository,
+	schema *Schema,
+) domain.RelationTupleRepository {
+	if schema == nil {
+		return repo
+	}
+
+	return &validatingTupleRepo{RelationTupleRepository: repo, schema: schema}
+}
+
+func (r *validatingTupleRepo) Write(ctx context.Context, tuple *domain.RelationTuple) error {
+	if err := r.validate([]*domain.RelationTuple{tuple}, ""); err != nil {
+		return err
+	}
+
+	return r.RelationTupleRepository.Write(ctx, tuple) //nolint:wrapcheck // a decorator
+}
+
+// BulkWrite writes nothing unless all the writes match the schema. Deletes
+// are not checked, so that tuples the schema dropped can be removed.
+func (r *validatingTupleRepo) BulkWrite(ctx context.Context, writes, deletes []*domain.RelationTuple) error {
+	if err := r.validate(writes, "/writes"); err != nil {
+		return err
+	}
+
+	return r.RelationTupleRepository.BulkWrite(ctx, writes, deletes) //nolint:wrapcheck // a decorator
+}
+
+// validate returns a ValidationError with an item for each tuple not
+// matching the schema, pointed at by its index under pointer.
+func (r *validatingTupleRepo) validate(tuples []*domain.RelationTuple, pointer string) error {
+	var violations []httperr.ValidationErrorItem
+
+	for i, tuple := range tuples {
+		if err := r.schema.ValidateTuple(tuple); err != nil {
+			item := httperr.ValidationErrorItem{Detail: err.Error(), Pointer: pointer}
+			if pointer != "" {
+				item.Pointer = fmt.Sprintf("%s/%d", pointer, i)
+			}
+
+			violations = append(violations, item)
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return httperr.NewValidationError("", "The relation tuples do not match the schema", violations)
+}
+
+func (r SubjectReference) String() string {
+	if r.Relation == "" {
+		return r.Type
+	}
+
+	return r.Type + "#" + r.Relation
+}
+
+// SyncRelationDefinitions makes the definitions of the repository those of
+// the schema, creating the missing ones and deleting the others.
+func SyncRelationDefinitions(
+	ctx context.Context,
+	repo domain.RelationDefinitionRepository,
+	schema *Schema,
+) error {
+	existing, err := repo.FindAll(ctx)
+	if err != nil {
+		return fmt.Errorf("find relation definitions error %w", err)
+	}
+
+	stored := make(map[string]bool, len(existing))
+	for _, def := range existing {
+		stored[definitionKey(def)] = true
+	}
+
+	wanted := make(map[string]bool)
+
+	for _, def := range schema.RelationDefinitions() {
+		key := definitionKey(def)
+		wanted[key] = true
+
+		if stored[key] {
+			continue
+		}
+
+		if _, err := repo.Create(ctx, def); err != nil {
+			return fmt.Errorf("create relation definition %s error %w", key, err)
+		}
+	}
+
+	for _, def := range existing {
+		if wanted[definitionKey(def)] {
+			continue
+		}
+
+		if err := repo.Delete(ctx, def); err != nil {
+			return fmt.Errorf("delete relation definition %s error %w", definitionKey(def), err)
+		}
+	}
+
+	return nil
+}
+
+type token struct {
+	text string
+	line int
+	col  int
+}
+
+func (t token) ident() bool {
+	r := []rune(t.text)
+
+	return len(r) > 0 && (unicode.IsLetter(r[0]) || r[0] == '_')
+}
+
+// punctuation of the schema language; "-", "&" and "*" only to tell that
+// exclusions, intersections and wildcards are not supported
+var punctuation = []string{"->", "{", "}", ":", ";", "|", "#", "=", "+", "-", "&", "*"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for lineNo, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		runes := []rune(line)
+
+		for col := 0; col < len(runes); {
+			r := runes[col]
+
+			switch {
+			case unicode.IsSpace(r):
+				col++
+			case unicode.IsLetter(r) || r == '_':
+				start := col
+				for col < len(runes) && (unicode.IsLetter(runes[col]) || unicode.IsDigit(runes[col]) || runes[col] == '_') {
+					col++
+				}
+
+				tokens = append(tokens, token{string(runes[start:col]), lineNo + 1, start + 1})
+			default:
+				punct := ""
+
+				for _, p := range punctuation {
+					if strings.HasPrefix(string(runes[col:]), p) {
+						punct = p
+
+						break
+					}
+				}
+
+				if punct == "" {
+					return nil, fmt.Errorf("%w: %d:%d: unexpected %q", ErrInvalidSchema, lineNo+1, col+1, r)
+				}
+
+				tokens = append(tokens, token{punct, lineNo + 1, col + 1})
+				col += len(punct)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token, which is empty at the end.
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		if len(p.tokens) == 0 {
+			return token{line: 1, col: 1}
+		}
+
+		last := p.tokens[len(p.tokens)-1]
+
+		return token{line: last.line, col: last.col + len(last.text)}
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	p.pos++
+
+	return tok
+}
+
+// accept consumes the next token when it is text.
+func (p *parser) accept(text string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].text == text {
+		p.pos++
+
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.next(); tok.text != text {
+		return p.errorf(tok, "expected %q", text)
+	}
+
+	return nil
+}
+
+func (p *parser) ident(what string) (string, error) {
+	tok := p.next()
+	if !tok.ident() {
+		return "", p.errorf(tok, "expected %s", what)
+	}
+
+	return tok.text, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	found := "end of schema"
+	if tok.text != "" {
+		found = fmt.Sprintf("%q", tok.text)
+	}
+
+	return fmt.Errorf("%w: %d:%d: %s, found %s", ErrInvalidSchema, tok.line, tok.col, fmt.Sprintf(format, args...), found)
+}
+
+func (p *parser) schema() (*Schema, error) {
+	schema := &Schema{}
+
+	for p.pos < len(p.tokens) {
+		def, err := p.definition()
+		if err != nil {
+			return nil, err
+		}
+
+		schema.Definitions = append(schema.Definitions, def)
+	}
+
+	return schema, nil
+}
+
+func (p *parser) definition() (*TypeDefinition, error) {
+	if err := p.expect("definition"); err != nil {
+		return nil, err
+	}
+
+	name, err := p.ident("type name")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	def := &TypeDefinition{Name: name}
+
+	for !p.accept("}") {
+		switch tok := p.next(); tok.text {
+		case "relation":
+			rel, err := p.relation()
+			if err != nil {
+				return nil, err
+			}
+
+			def.Relations = append(def.Relations, rel)
+		case "permission":
+			perm, err := p.permission()
+			if err != nil {
+				return nil, err
+			}
+
+			def.Permissions = append(def.Permissions, perm)
+		default:
+			return nil, p.errorf(tok, "expected relation, permission or %q", "}")
+		}
+
+		p.accept(";")
+	}
+
+	return def, nil
+}
+
+func (p *parser) relation() (*SchemaRelation, error) {
+	name, err := p.ident("relation name")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	rel := &SchemaRelation{Name: name}
+
+	for {
+		subject, err := p.ident("subject type")
+		if err != nil {
+			return nil, err
+		}
+
+		ref := SubjectReference{Type: subject}
+
+		if p.accept("#") {
+			if ref.Relation, err = p.ident("subject relation"); err != nil {
+				return nil, err
+			}
+		}
+
+		rel.Subjects = append(rel.Subjects, ref)
+
+		if !p.accept("|") {
+			return rel, nil
+		}
+	}
+}
+
+func (p *parser) permission() (*SchemaPermission, error) {
+	name, err := p.ident("permission name")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+
+	perm := &SchemaPermission{Name: name}
+
+	for {
+		relation, err := p.ident("relation")
+		if err != nil {
+			return nil, err
+		}
+
+		term := PermissionTerm{Relation: relation}
+
+		if p.accept("->") {
+			term.Via = relation
+
+			if term.Relation, err = p.ident("relation"); err != nil {
+				return nil, err
+			}
+		}
+
+		perm.Terms = append(perm.Terms, term)
+
+		if tok := p.peek(); tok.text == "-" || tok.text == "&" {
+			return nil, p.errorf(tok, "only unions (+) of relations are supported")
+		}
+
+		if !p.accept("+") {
+			return perm, nil
+		}
+	}
+}
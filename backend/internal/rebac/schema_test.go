@@ -0,0 +1,291 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+)
+
+const testSchema = `
+// users and the groups they are in
+definition user {}
+
+definition group {
+	relation member: user | group#member
+}
+
+definition folder {
+	relation parent: folder
+	relation viewer: user
+	permission view = viewer + parent->view
+}
+
+definition project {
+	relation member: user;
+}
+
+definition document {
+	relation parent: folder | project
+	relation owner: user
+	relation viewer: user | group#member | folder#viewer
+	permission view = owner + viewer + parent->view + parent->member
+	permission edit = owner
+}
+`
+
+func TestParseSchema(t *testing.T) {
+	t.Parallel()
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	document := schema.Definition("document")
+	if document == nil || len(document.Relations) != 3 || len(document.Permissions) != 2 {
+		t.Fatalf("ParseSchema() document = %+v, want 3 relations and 2 permissions", document)
+	}
+
+	want := []PermissionTerm{{Relation: "owner"}, {Relation: "viewer"}, {Via: "parent", Relation: "view"}, {Via: "parent", Relation: "member"}}
+	if got := document.Permission("view").Terms; !slices.Equal(got, want) {
+		t.Errorf("ParseSchema() view = %v, want %v", got, want)
+	}
+
+	var got []string
+	for _, def := range schema.RelationDefinitions() {
+		got = append(got, strings.TrimSuffix(definitionKey(def), "#"))
+	}
+
+	for _, def := range []string{
+		"group#member@group#member",
+		"document#viewer@group#member",
+		"document#viewer@folder#viewer",
+		"document#view@owner",
+		"document#view@parent#view",
+		"document#view@parent#member",
+		"document#edit@owner",
+		"folder#view@parent#view",
+	} {
+		if !slices.Contains(got, def) {
+			t.Errorf("Schema.RelationDefinitions() = %v, want %s", got, def)
+		}
+	}
+}
+
+func TestParseSchema_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "Syntax", schema: "definition user { relation }", wantErr: `1:28: expected relation name, found "}"`},
+		{name: "Unterminated", schema: "definition user {\n", wantErr: "expected relation, permission or \"}\", found end of schema"},
+		{name: "Character", schema: "definition user { relation owner: user! }", wantErr: `1:39: unexpected '!'`},
+		{name: "Exclusion", schema: "definition doc { relation a: doc\n permission b = a - a }", wantErr: "2:19: only unions"},
+		{name: "Duplicate Definition", schema: "definition user {} definition user {}", wantErr: "duplicate definition user"},
+		{name: "Duplicate Relation", schema: "definition user { relation a: user relation a: user }", wantErr: "duplicate relation or permission a"},
+		{name: "Unknown Type", schema: "definition doc { relation owner: user }", wantErr: "definition doc: relation owner: unknown type user"},
+		{name: "Unknown Subject Relation", schema: "definition group { relation member: group#admin }", wantErr: "type group has no relation admin"},
+		{name: "Unknown Term", schema: "definition doc { permission view = owner }", wantErr: "permission view: unknown relation owner"},
+		{name: "Self Reference", schema: "definition doc { permission view = view }", wantErr: "refers to itself"},
+		{name: "Arrow Not Parent", schema: "definition doc { relation folder_of: doc permission view = folder_of->view }", wantErr: "only the parent relation"},
+		{name: "Arrow Unknown", schema: "definition doc { relation parent: doc permission view = parent->edit }", wantErr: "no type of parent has edit"},
+		{name: "Named Like Type", schema: "definition user {} definition doc { relation user: user }", wantErr: "user is named like a type"},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseSchema(tt.schema)
+			if !errors.Is(err, ErrInvalidSchema) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchema_ValidateTuple(t *testing.T) {
+	t.Parallel()
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	tests := []struct {
+		tuple   string
+		wantErr bool
+	}{
+		{tuple: "document:1#owner@user:1"},
+		{tuple: "document:1#viewer@group:1#member"},
+		{tuple: "document:1#parent@project:1"},
+		{tuple: "document:1#owner@group:1#member", wantErr: true},
+		{tuple: "document:1#viewer@group:1", wantErr: true},
+		{tuple: "document:1#view@user:1", wantErr: true},
+		{tuple: "page:1#owner@user:1", wantErr: true},
+		{tuple: "document:#owner@user:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := schema.ValidateTuple(parseTuple(tt.tuple)); (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidTuple)) {
+			t.Errorf("Schema.ValidateTuple(%s) error = %v, wantErr %v", tt.tuple, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewValidatingTupleRepo(t *testing.T) {
+	t.Parallel()
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	ctx := context.Background()
+	tuples := &RelationTupleRepositoryMock{}
+	repo := NewValidatingTupleRepo(tuples, schema)
+
+	if err := repo.Write(ctx, parseTuple("document:1#owner@user:1")); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+
+	var validationErr *httperr.ValidationError
+
+	if err := repo.Write(ctx, parseTuple("page:1#owner@user:1")); !errors.As(err, &validationErr) {
+		t.Errorf("Write() error = %v, want a ValidationError", err)
+	}
+
+	err = repo.BulkWrite(ctx, []*domain.RelationTuple{
+		parseTuple("document:2#owner@user:1"),
+		parseTuple("document:2#viewer@group:1"),
+	}, nil)
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Pointer != "/writes/1" {
+		t.Errorf("BulkWrite() error = %v, want a ValidationError of /writes/1", err)
+	}
+
+	if !slices.Equal(tuples.tuples, []string{"document:1#owner@user:1"}) {
+		t.Errorf("tuples = %v, want only the valid tuple written alone", tuples.tuples)
+	}
+
+	if NewValidatingTupleRepo(tuples, nil) != domain.RelationTupleRepository(tuples) {
+		t.Errorf("NewValidatingTupleRepo() without a schema wraps the repository")
+	}
+}
+
+// TestSchema_Check checks a schema evaluates as written.
+func TestSchema_Check(t *testing.T) {
+	t.Parallel()
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	definitionRepo := &schemaDefinitionRepo{}
+	if err := SyncRelationDefinitions(context.Background(), definitionRepo, schema); err != nil {
+		t.Fatalf("SyncRelationDefinitions() error = %v", err)
+	}
+
+	s := NewService(&RelationTupleRepositoryMock{tuples: []string{
+		"document:1#parent@project:x",
+		"project:x#member@user:1",
+		"document:2#parent@folder:a",
+		"folder:a#viewer@user:2",
+		"document:3#viewer@folder:b#viewer",
+		"folder:b#viewer@user:3",
+	}}, definitionRepo)
+
+	for _, tt := range []struct {
+		userID, documentID, action string
+		want                       bool
+	}{
+		{"1", "1", "view", true},
+		{"2", "2", "view", true},
+		// document#viewer@folder#viewer is the type of a userset, not an
+		// arrow over the parent
+		{"2", "2", "viewer", false},
+		{"3", "3", "viewer", true},
+	} {
+		got, err := s.Check(context.Background(), &domain.User{ID: tt.userID}, &domain.Entity{EntityType: "document", EntityID: tt.documentID}, tt.action)
+		if err != nil || got.Allowed != tt.want {
+			t.Errorf("Service.Check(user %s, document %s, %s) = %+v, %v, want %v", tt.userID, tt.documentID, tt.action, got, err, tt.want)
+		}
+
+		lookup, err := s.LookupResources(context.Background(), &domain.User{ID: tt.userID}, "document", tt.action, Page{})
+		if err != nil || slices.Contains(lookup.IDs, tt.documentID) != tt.want {
+			t.Errorf("Service.LookupResources(user %s, %s) = %+v, %v, want it to agree with the check", tt.userID, tt.action, lookup, err)
+		}
+	}
+}
+
+func TestSyncRelationDefinitions(t *testing.T) {
+	t.Parallel()
+
+	stale := &domain.RelationDefinition{EntityType: "document", RelationType: "admin", SubjectType: "user"}
+	kept := &domain.RelationDefinition{EntityType: "document", RelationType: "owner", SubjectType: "user"}
+	repo := &schemaDefinitionRepo{definitions: []*domain.RelationDefinition{stale, kept}}
+
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	if err := SyncRelationDefinitions(context.Background(), repo, schema); err != nil {
+		t.Fatalf("SyncRelationDefinitions() error = %v", err)
+	}
+
+	if len(repo.definitions) != len(schema.RelationDefinitions()) || slices.Contains(repo.definitions, stale) ||
+		!slices.Contains(repo.definitions, kept) {
+		t.Errorf("SyncRelationDefinitions() left %d definitions, want those of the schema", len(repo.definitions))
+	}
+}
+
+// schemaDefinitionRepo keeps definitions in memory.
+type schemaDefinitionRepo struct {
+	RelationDefinitionRepositoryMock
+	definitions []*domain.RelationDefinition
+}
+
+func (r *schemaDefinitionRepo) FindRelationDefinition(
+	_ context.Context,
+	entityType string,
+) ([]*domain.RelationDefinition, error) {
+	var definitions []*domain.RelationDefinition
+
+	for _, def := range r.definitions {
+		if def.EntityType == entityType {
+			definitions = append(definitions, def)
+		}
+	}
+
+	return definitions, nil
+}
+
+func (r *schemaDefinitionRepo) FindAll(context.Context) ([]*domain.RelationDefinition, error) {
+	return slices.Clone(r.definitions), nil
+}
+
+func (r *schemaDefinitionRepo) Create(
+	_ context.Context,
+	definition *domain.RelationDefinition,
+) (*domain.RelationDefinition, error) {
+	r.definitions = append(r.definitions, definition)
+
+	return definition, nil
+}
+
+func (r *schemaDefinitionRepo) Delete(_ context.Context, definition *domain.RelationDefinition) error {
+	r.definitions = slices.DeleteFunc(r.definitions, func(def *domain.RelationDefinition) bool {
+		return definitionKey(def) == definitionKey(definition)
+	})
+
+	return nil
+}
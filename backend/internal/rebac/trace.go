@@ -0,0 +1,149 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"goadmin-backend/internal/domain"
+)
+
+// Rules a check goes from a relation to the next by.
+const (
+	// RuleTuple is a tuple naming the user.
+	RuleTuple = "tuple"
+	// RuleUserset is a tuple naming a userset, like group:1#member.
+	RuleUserset = "userset"
+	// RuleComputed is a definition computing the relation from another
+	// relation of the entity, like document#view@owner.
+	RuleComputed = "computed"
+	// RuleArrow is a definition granting the relation to a relation of the
+	// entities another relation names, like document#view@parent#member.
+	RuleArrow = "arrow"
+)
+
+// Results of the relations of a trace.
+const (
+	TraceHit      = "hit"
+	TraceMiss     = "miss"
+	TraceCycle    = "cycle"
+	TraceMaxDepth = "max_depth"
+	// TraceSkipped relations were cut short as another branch allowed the
+	// user first.
+	TraceSkipped = "skipped"
+	TraceError   = "error"
+)
+
+// CheckTrace is a relation of an entity a check evaluated, with the rule it
+// was reached by, what it found and the relations it went on to.
+type CheckTrace struct {
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Relation   string `json:"relation"`
+	// Rule is empty at the root, and RuleTuple where a tuple names the user.
+	Rule string `json:"rule,omitempty"`
+	// Tuple is the tuple the rule followed, if any: the one naming the
+	// user, the userset or the parent.
+	Tuple    string        `json:"tuple,omitempty"`
+	Result   string        `json:"result"`
+	Depth    int           `json:"depth"`
+	Children []*CheckTrace `json:"children,omitempty"`
+}
+
+// trace returns the trace of obj, nil unless the check explains.
+func (c *check) trace(obj object, depth int, res string) *CheckTrace {
+	if !c.explain {
+		return nil
+	}
+
+	return &CheckTrace{
+		EntityType: obj.entityType,
+		EntityID:   obj.entityID,
+		Relation:   obj.relation,
+		Result:     res,
+		Depth:      depth,
+	}
+}
+
+// traces returns the traces of the edges of a union, in order.
+func (c *check) traces(edges []edge, outcomes []outcome, depth int) []*CheckTrace {
+	if !c.explain {
+		return nil
+	}
+
+	traces := make([]*CheckTrace, len(edges))
+
+	for _, out := range outcomes {
+		e := edges[out.i]
+
+		trace := out.result.trace
+		if trace == nil {
+			trace = c.trace(e.object, depth, traceResult(out.err))
+		}
+
+		// hits keep the tuple naming the user
+		if trace.Rule == "" {
+			trace.Rule = e.rule
+			if e.tuple != nil {
+				trace.Tuple = tupleString(e.tuple)
+			}
+		}
+
+		traces[out.i] = trace
+	}
+
+	return traces
+}
+
+func traceResult(err error) string {
+	switch {
+	case errors.Is(err, ErrMaxDepthExceeded):
+		return TraceMaxDepth
+	case errors.Is(err, context.Canceled):
+		return TraceSkipped
+	default:
+		return TraceError
+	}
+}
+
+// tupleString writes a tuple like document:1#viewer@group:1#member.
+func tupleString(tuple *domain.RelationTuple) string {
+	s := fmt.Sprintf("%s:%s#%s@%s:%s", tuple.EntityType, tuple.EntityID, tuple.Relation, tuple.SubjectType, tuple.SubjectID)
+	if tuple.SubjectRelation != "" {
+		s += "#" + tuple.SubjectRelation
+	}
+
+	return s
+}
+
+// WriteTrace writes a trace as an indented tree, a relation a line.
+func WriteTrace(w io.Writer, trace *CheckTrace) error {
+	return writeTrace(w, trace, 0)
+}
+
+func writeTrace(w io.Writer, trace *CheckTrace, level int) error {
+	line := fmt.Sprintf("%s:%s#%s", trace.EntityType, trace.EntityID, trace.Relation)
+
+	if trace.Rule != "" {
+		line = trace.Rule + " " + line
+	}
+
+	if trace.Tuple != "" {
+		line += " by " + trace.Tuple
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s depth %d: %s\n", strings.Repeat("  ", level), line, trace.Depth, trace.Result)
+	if err != nil {
+		return fmt.Errorf("write trace error %w", err)
+	}
+
+	for _, child := range trace.Children {
+		if err := writeTrace(w, child, level+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+)
+
+// findTrace returns the first relation of the trace matching.
+func findTrace(trace *CheckTrace, match func(*CheckTrace) bool) *CheckTrace {
+	if match(trace) {
+		return trace
+	}
+
+	for _, child := range trace.Children {
+		if found := findTrace(child, match); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func TestService_Explain(t *testing.T) {
+	t.Parallel()
+
+	s := newTestService()
+	ctx := context.Background()
+
+	got, err := s.Explain(ctx, &domain.User{ID: "3"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view")
+	if err != nil || !got.Allowed || got.Depth != 4 {
+		t.Fatalf("Service.Explain() = %+v, %v, want allowed at depth 4", got, err)
+	}
+
+	if got.Trace.Result != TraceHit || got.Trace.Rule != "" || got.Trace.Relation != "view" {
+		t.Errorf("Service.Explain() trace = %+v, want the hit of document:1#view", got.Trace)
+	}
+
+	backend := findTrace(got.Trace, func(trace *CheckTrace) bool { return trace.EntityID == "backend" })
+	if backend == nil || backend.Rule != RuleTuple || backend.Tuple != "group:backend#member@user:3" ||
+		backend.Result != TraceHit || backend.Depth != 4 || len(backend.Children) != 0 {
+		t.Fatalf("Service.Explain() backend = %+v, want the hit of group:backend#member by the tuple naming user 3", backend)
+	}
+
+	got, err = s.Explain(ctx, &domain.User{ID: "9"}, &domain.Entity{EntityType: "document", EntityID: "4"}, "view")
+	if err != nil || got.Allowed || got.Trace.Result != TraceMiss {
+		t.Fatalf("Service.Explain() = %+v, %v, want denied", got, err)
+	}
+
+	if findTrace(got.Trace, func(trace *CheckTrace) bool { return trace.Result == TraceCycle }) == nil {
+		t.Errorf("Service.Explain() trace has no cycle, want the cycle of groups")
+	}
+
+	// checks do not trace
+	if got, err := s.Check(ctx, &domain.User{ID: "3"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view"); err != nil || got.Trace != nil {
+		t.Errorf("Service.Check() = %+v, %v, want no trace", got, err)
+	}
+
+	// explaining checks trace the branches too deep instead of failing
+	got, err = newTestService(WithMaxDepth(2)).Explain(ctx, &domain.User{ID: "3"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view")
+	if err != nil || got.Allowed || got.Trace.Result != TraceMaxDepth {
+		t.Fatalf("Service.Explain() = %+v, %v, want denied at the max depth", got, err)
+	}
+
+	if findTrace(got.Trace, func(trace *CheckTrace) bool { return trace.Depth == 3 && trace.Result == TraceMaxDepth }) == nil {
+		t.Errorf("Service.Explain() trace has no branch past the max depth")
+	}
+
+	if _, err := newTestService(WithMaxDepth(2)).Check(ctx, &domain.User{ID: "3"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view"); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Service.Check() error = %v, want %v", err, ErrMaxDepthExceeded)
+	}
+}
+
+func TestService_Explain_error(t *testing.T) {
+	t.Parallel()
+
+	s := NewService(
+		&RelationTupleRepositoryMock{tuples: tuples, failEntity: "group:backend"},
+		&RelationDefinitionRepositoryMock{definitions: definitions},
+	)
+
+	got, err := s.Explain(context.Background(), &domain.User{ID: "9"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "view")
+	if err == nil || errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("Service.Explain() error = %v, want the error of the repository", err)
+	}
+
+	if got == nil || got.Allowed || got.Trace.Result != TraceError {
+		t.Fatalf("Service.Explain() = %+v, want the trace so far", got)
+	}
+
+	if findTrace(got.Trace, func(trace *CheckTrace) bool { return trace.EntityID == "backend" && trace.Result == TraceError }) == nil {
+		t.Errorf("Service.Explain() trace has no error for group:backend")
+	}
+}
+
+func TestWriteTrace(t *testing.T) {
+	t.Parallel()
+
+	got, err := newTestService().Explain(context.Background(), &domain.User{ID: "1"}, &domain.Entity{EntityType: "document", EntityID: "1"}, "edit")
+	if err != nil {
+		t.Fatalf("Service.Explain() error = %v", err)
+	}
+
+	var b strings.Builder
+	if err := WriteTrace(&b, got.Trace); err != nil {
+		t.Fatalf("WriteTrace() error = %v", err)
+	}
+
+	want := `document:1#edit depth 1: hit
+  tuple document:1#owner by document:1#owner@user:1 depth 2: hit
+`
+	if b.String() != want {
+		t.Errorf("WriteTrace() = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.APIKeyRepository = &APIKeyRepo{}
+
+type APIKeyRepo struct {
+	db Queryer
+}
+
+func NewAPIKeyRepo(db Queryer) *APIKeyRepo {
+	return &APIKeyRepo{
+		db: db,
+	}
+}
+
+// Create stores a new API key
+func (r *APIKeyRepo) Create(ctx context.Context, key *domain.APIKey) (*domain.APIKey, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		user_id, name, prefix, key_hash, scopes, expires_at
+	) VALUES (
+		$1, $2, $3, $4, $5, $6
+	) RETURNING *`, apiKeyTable)
+
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	created, err := queryRow[domain.APIKey](
+		ctx,
+		r.db,
+		createQuery,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		scopes,
+		key.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create api_key error: %w", err)
+	}
+
+	return created, nil
+}
+
+// FindByHash returns the API key with the given hash
+func (r *APIKeyRepo) FindByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE key_hash = $1`, apiKeyTable)
+
+	key, err := queryRow[domain.APIKey](ctx, r.db, findQuery, keyHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("APIKey", "key_hash")
+		}
+
+		return nil, fmt.Errorf("find api_key by hash error: %w", err)
+	}
+
+	return key, nil
+}
+
+// FindByUserID returns the API keys of a user
+func (r *APIKeyRepo) FindByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`, apiKeyTable)
+
+	keys, err := query[domain.APIKey](ctx, r.db, findQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find api_keys by user ID error: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Touch records the last use of an API key
+func (r *APIKeyRepo) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	touchQuery := fmt.Sprintf(`UPDATE %s SET
+		last_used_at = $2
+	WHERE id = $1`, apiKeyTable)
+
+	_, err := exec(ctx, r.db, touchQuery, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("touch api_key error: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an API key of a user
+func (r *APIKeyRepo) Delete(ctx context.Context, userID, id string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE user_id = $1 AND id = $2`, apiKeyTable)
+
+	tag, err := exec(ctx, r.db, deleteQuery, userID, id)
+	if err != nil {
+		return fmt.Errorf("delete api_key error: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return domain.NewResourceNotFoundError("APIKey", "id="+id)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestAPIKeyRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewAPIKeyRepo(conn)
+	ctx := context.Background()
+	userID := testUsers[0].ID
+
+	keyHash := randToken()
+
+	created, err := repo.Create(ctx, &domain.APIKey{
+		UserID:  userID,
+		Name:    "deploy script",
+		Prefix:  "gak_abcdefgh",
+		KeyHash: keyHash,
+		Scopes:  []string{"users:read"},
+	})
+	if err != nil {
+		t.Fatalf("APIKeyRepo.Create() error = %v", err)
+	}
+
+	if created.ID == "" || created.LastUsedAt != nil {
+		t.Errorf("APIKeyRepo.Create() = %+v, want a new unused key", created)
+	}
+
+	found, err := repo.FindByHash(ctx, keyHash)
+	if err != nil {
+		t.Fatalf("APIKeyRepo.FindByHash() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(found.Scopes, []string{"users:read"}) || found.Name != "deploy script" {
+		t.Errorf("APIKeyRepo.FindByHash() = %+v, want the created key", found)
+	}
+
+	usedAt := time.Now().Truncate(time.Second)
+	if err := repo.Touch(ctx, created.ID, usedAt); err != nil {
+		t.Fatalf("APIKeyRepo.Touch() error = %v", err)
+	}
+
+	keys, err := repo.FindByUserID(ctx, userID)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("APIKeyRepo.FindByUserID() = %v, %v, want 1 key", keys, err)
+	}
+
+	if keys[0].LastUsedAt == nil || !keys[0].LastUsedAt.Equal(usedAt) {
+		t.Errorf("APIKeyRepo.FindByUserID() last used at = %v, want %v", keys[0].LastUsedAt, usedAt)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+
+	// a key can only be deleted by its owner
+	if err := repo.Delete(ctx, testUsers[1].ID, created.ID); !errors.As(err, &notFoundErr) {
+		t.Errorf("APIKeyRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if err := repo.Delete(ctx, userID, created.ID); err != nil {
+		t.Fatalf("APIKeyRepo.Delete() error = %v", err)
+	}
+
+	if _, err := repo.FindByHash(ctx, keyHash); !errors.As(err, &notFoundErr) {
+		t.Errorf("APIKeyRepo.FindByHash() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewAPIKeyRepo(&queryerMock{err: errors.New("error")}).FindByUserID(ctx, userID); err == nil {
+		t.Errorf("APIKeyRepo.FindByUserID() error = nil, wantErr true")
+	}
+}
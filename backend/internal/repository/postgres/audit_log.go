@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.AuditLogRepository = &AuditLogRepo{}
+
+type AuditLogRepo struct {
+	db Queryer
+}
+
+func NewAuditLogRepo(db Queryer) *AuditLogRepo {
+	return &AuditLogRepo{
+		db: db,
+	}
+}
+
+// Create appends an event to the audit log
+func (r *AuditLogRepo) Create(
+	ctx context.Context,
+	event *domain.AuditEvent,
+) (*domain.AuditEvent, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		action, actor_id, subject_id, reason, user_agent, ip_address
+	) VALUES (
+		$1, $2, $3, $4, $5, $6
+	) RETURNING *`, auditLogTable)
+
+	created, err := queryRow[domain.AuditEvent](
+		ctx,
+		r.db,
+		createQuery,
+		event.Action,
+		event.ActorID,
+		event.SubjectID,
+		event.Reason,
+		event.UserAgent,
+		event.IPAddress,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create audit_log error: %w", err)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestAuditLogRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewAuditLogRepo(conn)
+	ctx := context.Background()
+
+	event := &domain.AuditEvent{
+		Action:    domain.AuditActionImpersonationStart,
+		ActorID:   testUsers[0].ID,
+		SubjectID: testUsers[1].ID,
+		Reason:    "support ticket 42",
+		UserAgent: "test-agent",
+		IPAddress: "127.0.0.1",
+	}
+
+	created, err := repo.Create(ctx, event)
+	if err != nil {
+		t.Fatalf("AuditLogRepo.Create() error = %v", err)
+	}
+
+	if created.ID == "" || created.CreatedAt.IsZero() {
+		t.Errorf("AuditLogRepo.Create() = %+v, want a new event", created)
+	}
+
+	if created.Action != event.Action || created.ActorID != event.ActorID ||
+		created.SubjectID != event.SubjectID || created.Reason != event.Reason ||
+		created.IPAddress != event.IPAddress {
+		t.Errorf("AuditLogRepo.Create() = %+v, want %+v", created, event)
+	}
+
+	if _, err := NewAuditLogRepo(&queryerMock{err: errors.New("error")}).Create(ctx, event); err == nil {
+		t.Error("AuditLogRepo.Create() error = nil, want error")
+	}
+}
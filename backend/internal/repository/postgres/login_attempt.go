@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.LoginAttemptRepository = &LoginAttemptRepo{}
+
+type LoginAttemptRepo struct {
+	db Queryer
+}
+
+func NewLoginAttemptRepo(db Queryer) *LoginAttemptRepo {
+	return &LoginAttemptRepo{
+		db: db,
+	}
+}
+
+// FindByKey returns the failed sign-ins of a username or client
+func (r *LoginAttemptRepo) FindByKey(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE key = $1`, loginAttemptTable)
+
+	attempt, err := queryRow[domain.LoginAttempt](ctx, r.db, findQuery, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("LoginAttempt", "key="+key)
+		}
+
+		return nil, fmt.Errorf("find login_attempt by key error: %w", err)
+	}
+
+	return attempt, nil
+}
+
+// RecordFailure counts a failed sign-in in a single statement, so that
+// concurrent attempts are all counted
+func (r *LoginAttemptRepo) RecordFailure(
+	ctx context.Context,
+	key string,
+	window time.Duration,
+) (*domain.LoginAttempt, error) {
+	recordQuery := fmt.Sprintf(`INSERT INTO %[1]s (
+		key, failures, last_failure_at
+	) VALUES (
+		$1, 1, NOW()
+	) ON CONFLICT (key) DO UPDATE SET
+		failures = CASE
+			WHEN %[1]s.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+			ELSE %[1]s.failures + 1
+		END,
+		last_failure_at = NOW()
+	RETURNING *`, loginAttemptTable)
+
+	attempt, err := queryRow[domain.LoginAttempt](ctx, r.db, recordQuery, key, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("record login_attempt failure error: %w", err)
+	}
+
+	return attempt, nil
+}
+
+// Lock refuses sign-ins for a username or client until the given time
+func (r *LoginAttemptRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	lockQuery := fmt.Sprintf(`UPDATE %s SET
+		locked_until = $2
+	WHERE key = $1`, loginAttemptTable)
+
+	_, err := exec(ctx, r.db, lockQuery, key, until)
+	if err != nil {
+		return fmt.Errorf("lock login_attempt error: %w", err)
+	}
+
+	return nil
+}
+
+// Delete forgets the failed sign-ins of a username or client
+func (r *LoginAttemptRepo) Delete(ctx context.Context, key string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, loginAttemptTable)
+
+	_, err := exec(ctx, r.db, deleteQuery, key)
+	if err != nil {
+		return fmt.Errorf("delete login_attempt error: %w", err)
+	}
+
+	return nil
+}
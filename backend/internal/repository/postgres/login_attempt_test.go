@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestLoginAttemptRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewLoginAttemptRepo(conn)
+	ctx := context.Background()
+	key := "user:" + randToken()
+
+	for want := 1; want <= 3; want++ {
+		attempt, err := repo.RecordFailure(ctx, key, time.Hour)
+		if err != nil {
+			t.Fatalf("LoginAttemptRepo.RecordFailure() error = %v", err)
+		}
+
+		if attempt.Failures != want {
+			t.Errorf("LoginAttemptRepo.RecordFailure() failures = %v, want %v", attempt.Failures, want)
+		}
+	}
+
+	// failures older than the window are forgotten
+	attempt, err := repo.RecordFailure(ctx, key, 0)
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("LoginAttemptRepo.RecordFailure() = %+v, %v, want 1 failure", attempt, err)
+	}
+
+	until := time.Now().Add(time.Minute)
+	if err := repo.Lock(ctx, key, until); err != nil {
+		t.Fatalf("LoginAttemptRepo.Lock() error = %v", err)
+	}
+
+	attempt, err = repo.FindByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("LoginAttemptRepo.FindByKey() error = %v", err)
+	}
+
+	if !attempt.Locked(time.Now()) {
+		t.Errorf("LoginAttemptRepo.FindByKey() = %+v, want locked", attempt)
+	}
+
+	if err := repo.Delete(ctx, key); err != nil {
+		t.Fatalf("LoginAttemptRepo.Delete() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if _, err := repo.FindByKey(ctx, key); !errors.As(err, &notFoundErr) {
+		t.Errorf("LoginAttemptRepo.FindByKey() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewLoginAttemptRepo(&queryerMock{err: errors.New("error")}).RecordFailure(ctx, key, time.Hour); err == nil {
+		t.Errorf("LoginAttemptRepo.RecordFailure() error = nil, wantErr true")
+	}
+}
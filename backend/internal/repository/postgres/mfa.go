@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.MFARepository = &MFARepo{}
+
+type MFARepo struct {
+	db Queryer
+}
+
+func NewMFARepo(db Queryer) *MFARepo {
+	return &MFARepo{
+		db: db,
+	}
+}
+
+// FindTOTP returns the TOTP factor of a user
+func (r *MFARepo) FindTOTP(ctx context.Context, userID string) (*domain.TOTPFactor, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1`, totpTable)
+
+	factor, err := queryRow[domain.TOTPFactor](ctx, r.db, findQuery, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("TOTPFactor", "user_id="+userID)
+		}
+
+		return nil, fmt.Errorf("find totp by user ID error: %w", err)
+	}
+
+	return factor, nil
+}
+
+// SaveTOTP stores a new unconfirmed factor. A confirmed factor is never
+// overwritten.
+func (r *MFARepo) SaveTOTP(ctx context.Context, factor *domain.TOTPFactor) error {
+	saveQuery := fmt.Sprintf(`INSERT INTO %[1]s (
+		user_id, secret
+	) VALUES (
+		$1, $2
+	) ON CONFLICT (user_id) DO UPDATE SET
+		secret = EXCLUDED.secret,
+		last_used_step = 0,
+		created_at = NOW()
+	WHERE %[1]s.confirmed_at IS NULL`, totpTable)
+
+	_, err := exec(ctx, r.db, saveQuery, factor.UserID, factor.Secret)
+	if err != nil {
+		return fmt.Errorf("save totp error: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmTOTP turns on the factor of a user; step is the time step of the
+// code it was confirmed with
+func (r *MFARepo) ConfirmTOTP(ctx context.Context, userID string, step int64) error {
+	confirmQuery := fmt.Sprintf(`UPDATE %s SET
+		confirmed_at = NOW(),
+		last_used_step = $2
+	WHERE user_id = $1 AND confirmed_at IS NULL`, totpTable)
+
+	_, err := exec(ctx, r.db, confirmQuery, userID, step)
+	if err != nil {
+		return fmt.Errorf("confirm totp error: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records an accepted code. The conditional update makes sure a
+// code is only accepted once, even by concurrent sign-ins.
+func (r *MFARepo) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	useQuery := fmt.Sprintf(`UPDATE %s SET
+		last_used_step = $2
+	WHERE user_id = $1 AND last_used_step < $2`, totpTable)
+
+	result, err := exec(ctx, r.db, useQuery, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("use totp step error: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// DeleteTOTP removes the factor and the recovery codes of a user
+func (r *MFARepo) DeleteTOTP(ctx context.Context, userID string) error {
+	deleteQuery := fmt.Sprintf(`WITH deleted_codes AS (
+		DELETE FROM %s WHERE user_id = $1
+	)
+	DELETE FROM %s WHERE user_id = $1`, recoveryCodeTable, totpTable)
+
+	_, err := exec(ctx, r.db, deleteQuery, userID)
+	if err != nil {
+		return fmt.Errorf("delete totp error: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the recovery codes of a user in one statement,
+// so that the user is never left without codes
+func (r *MFARepo) ReplaceRecoveryCodes(
+	ctx context.Context,
+	userID string,
+	codeHashes []string,
+) error {
+	replaceQuery := fmt.Sprintf(`WITH deleted_codes AS (
+		DELETE FROM %[1]s WHERE user_id = $1
+	)
+	INSERT INTO %[1]s (user_id, code_hash)
+	SELECT $1, code_hash FROM UNNEST($2::TEXT[]) AS code_hash`, recoveryCodeTable)
+
+	_, err := exec(ctx, r.db, replaceQuery, userID, codeHashes)
+	if err != nil {
+		return fmt.Errorf("replace recovery codes error: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used
+func (r *MFARepo) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	useQuery := fmt.Sprintf(`UPDATE %s SET
+		used_at = NOW()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, recoveryCodeTable)
+
+	result, err := exec(ctx, r.db, useQuery, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code error: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestMFARepo_TOTP(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewMFARepo(conn)
+	ctx := context.Background()
+	userID := testUsers[0].ID
+
+	if err := repo.SaveTOTP(ctx, &domain.TOTPFactor{UserID: userID, Secret: "FIRST"}); err != nil {
+		t.Fatalf("MFARepo.SaveTOTP() error = %v", err)
+	}
+
+	// enrolling again replaces an unconfirmed factor
+	if err := repo.SaveTOTP(ctx, &domain.TOTPFactor{UserID: userID, Secret: "SECOND"}); err != nil {
+		t.Fatalf("MFARepo.SaveTOTP() error = %v", err)
+	}
+
+	if err := repo.ConfirmTOTP(ctx, userID, 100); err != nil {
+		t.Fatalf("MFARepo.ConfirmTOTP() error = %v", err)
+	}
+
+	// but never a confirmed one
+	if err := repo.SaveTOTP(ctx, &domain.TOTPFactor{UserID: userID, Secret: "THIRD"}); err != nil {
+		t.Fatalf("MFARepo.SaveTOTP() error = %v", err)
+	}
+
+	factor, err := repo.FindTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("MFARepo.FindTOTP() error = %v", err)
+	}
+
+	if factor.Secret != "SECOND" || !factor.Confirmed() || factor.LastUsedStep != 100 {
+		t.Errorf("MFARepo.FindTOTP() = %+v, want confirmed SECOND at step 100", factor)
+	}
+
+	steps := []struct {
+		step int64
+		want bool
+	}{
+		{step: 100, want: false},
+		{step: 101, want: true},
+		{step: 101, want: false},
+		{step: 99, want: false},
+	}
+	for _, s := range steps {
+		if got, err := repo.UseTOTPStep(ctx, userID, s.step); err != nil || got != s.want {
+			t.Errorf("MFARepo.UseTOTPStep(%v) = %v, %v, want %v", s.step, got, err, s.want)
+		}
+	}
+
+	if err := repo.DeleteTOTP(ctx, userID); err != nil {
+		t.Fatalf("MFARepo.DeleteTOTP() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if _, err := repo.FindTOTP(ctx, userID); !errors.As(err, &notFoundErr) {
+		t.Errorf("MFARepo.FindTOTP() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewMFARepo(&queryerMock{err: errors.New("error")}).FindTOTP(ctx, userID); err == nil {
+		t.Errorf("MFARepo.FindTOTP() error = nil, wantErr true")
+	}
+}
+
+func TestMFARepo_RecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewMFARepo(conn)
+	ctx := context.Background()
+	userID := testUsers[1].ID
+
+	if err := repo.ReplaceRecoveryCodes(ctx, userID, []string{"old-1", "old-2"}); err != nil {
+		t.Fatalf("MFARepo.ReplaceRecoveryCodes() error = %v", err)
+	}
+
+	if err := repo.ReplaceRecoveryCodes(ctx, userID, []string{"new-1", "new-2"}); err != nil {
+		t.Fatalf("MFARepo.ReplaceRecoveryCodes() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		codeHash string
+		want     bool
+	}{
+		{name: "Replaced", codeHash: "old-1", want: false},
+		{name: "Unused", codeHash: "new-1", want: true},
+		{name: "Used", codeHash: "new-1", want: false},
+		{name: "Unknown", codeHash: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		got, err := repo.UseRecoveryCode(ctx, userID, tt.codeHash)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: MFARepo.UseRecoveryCode() = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	_, err := NewMFARepo(&queryerMock{err: errors.New("error")}).UseRecoveryCode(ctx, userID, "new-2")
+	if err == nil {
+		t.Errorf("MFARepo.UseRecoveryCode() error = nil, wantErr true")
+	}
+}
@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.OAuthClientRepository = &OAuthClientRepo{}
+
+type OAuthClientRepo struct {
+	db Queryer
+}
+
+func NewOAuthClientRepo(db Queryer) *OAuthClientRepo {
+	return &OAuthClientRepo{
+		db: db,
+	}
+}
+
+// Create stores a new OAuth client
+func (r *OAuthClientRepo) Create(
+	ctx context.Context,
+	client *domain.OAuthClient,
+) (*domain.OAuthClient, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		client_id, name, client_secret_hash, redirect_uris, scopes
+	) VALUES (
+		$1, $2, $3, $4, $5
+	) RETURNING *`, oauthClientTable)
+
+	scopes := client.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	created, err := queryRow[domain.OAuthClient](
+		ctx,
+		r.db,
+		createQuery,
+		client.ClientID,
+		client.Name,
+		client.ClientSecretHash,
+		client.RedirectURIs,
+		scopes,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create oauth_client error: %w", err)
+	}
+
+	return created, nil
+}
+
+// FindByClientID returns the OAuth client with the given client ID
+func (r *OAuthClientRepo) FindByClientID(
+	ctx context.Context,
+	clientID string,
+) (*domain.OAuthClient, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE client_id = $1`, oauthClientTable)
+
+	client, err := queryRow[domain.OAuthClient](ctx, r.db, findQuery, clientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("OAuthClient", "client_id="+clientID)
+		}
+
+		return nil, fmt.Errorf("find oauth_client by client ID error: %w", err)
+	}
+
+	return client, nil
+}
+
+// FindAll returns every OAuth client
+func (r *OAuthClientRepo) FindAll(ctx context.Context) ([]*domain.OAuthClient, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	ORDER BY created_at DESC, id DESC`, oauthClientTable)
+
+	clients, err := query[domain.OAuthClient](ctx, r.db, findQuery)
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_clients error: %w", err)
+	}
+
+	return clients, nil
+}
+
+// Delete removes an OAuth client; its consents and codes go with it
+func (r *OAuthClientRepo) Delete(ctx context.Context, id string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, oauthClientTable)
+
+	tag, err := exec(ctx, r.db, deleteQuery, id)
+	if err != nil {
+		return fmt.Errorf("delete oauth_client error: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return domain.NewResourceNotFoundError("OAuthClient", "id="+id)
+	}
+
+	return nil
+}
+
+var _ domain.OAuthGrantRepository = &OAuthGrantRepo{}
+
+type OAuthGrantRepo struct {
+	db Queryer
+}
+
+func NewOAuthGrantRepo(db Queryer) *OAuthGrantRepo {
+	return &OAuthGrantRepo{
+		db: db,
+	}
+}
+
+// FindConsent returns the consent of a user for a client
+func (r *OAuthGrantRepo) FindConsent(
+	ctx context.Context,
+	userID, clientID string,
+) (*domain.OAuthConsent, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE user_id = $1 AND client_id = $2`, oauthConsentTable)
+
+	consent, err := queryRow[domain.OAuthConsent](ctx, r.db, findQuery, userID, clientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("OAuthConsent", "client_id="+clientID)
+		}
+
+		return nil, fmt.Errorf("find oauth_consent error: %w", err)
+	}
+
+	return consent, nil
+}
+
+// SaveConsent creates or replaces the consent of a user for a client
+func (r *OAuthGrantRepo) SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	saveQuery := fmt.Sprintf(`INSERT INTO %s (
+		user_id, client_id, scopes
+	) VALUES (
+		$1, $2, $3
+	) ON CONFLICT (user_id, client_id) DO UPDATE SET
+		scopes = EXCLUDED.scopes,
+		updated_at = NOW()`, oauthConsentTable)
+
+	scopes := consent.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	_, err := exec(ctx, r.db, saveQuery, consent.UserID, consent.ClientID, scopes)
+	if err != nil {
+		return fmt.Errorf("save oauth_consent error: %w", err)
+	}
+
+	return nil
+}
+
+// CreateCode stores a new authorization code
+func (r *OAuthGrantRepo) CreateCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		code_hash, client_id, user_id, redirect_uri, scopes, nonce,
+		code_challenge, auth_time, expires_at
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9
+	)`, oauthCodeTable)
+
+	scopes := code.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	_, err := exec(
+		ctx,
+		r.db,
+		createQuery,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		scopes,
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create oauth_authorization_code error: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeCode deletes an authorization code and returns it. Deleting in the
+// same statement makes sure a code is only exchanged once, even by
+// concurrent requests.
+func (r *OAuthGrantRepo) ConsumeCode(
+	ctx context.Context,
+	codeHash string,
+) (*domain.AuthorizationCode, error) {
+	consumeQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE code_hash = $1
+	RETURNING *`, oauthCodeTable)
+
+	code, err := queryRow[domain.AuthorizationCode](ctx, r.db, consumeQuery, codeHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("AuthorizationCode", "code_hash")
+		}
+
+		return nil, fmt.Errorf("consume oauth_authorization_code error: %w", err)
+	}
+
+	return code, nil
+}
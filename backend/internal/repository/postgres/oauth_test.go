@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestOAuthClientRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewOAuthClientRepo(conn)
+	ctx := context.Background()
+	clientID := randToken()
+	secretHash := randToken()
+
+	created, err := repo.Create(ctx, &domain.OAuthClient{
+		ClientID:         clientID,
+		Name:             "wiki",
+		ClientSecretHash: &secretHash,
+		RedirectURIs:     []string{"https://wiki.example.com/callback"},
+		Scopes:           []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatalf("OAuthClientRepo.Create() error = %v", err)
+	}
+
+	found, err := repo.FindByClientID(ctx, clientID)
+	if err != nil {
+		t.Fatalf("OAuthClientRepo.FindByClientID() error = %v", err)
+	}
+
+	if found.ID != created.ID || found.Public() || !reflect.DeepEqual(found.Scopes, []string{"openid", "email"}) {
+		t.Errorf("OAuthClientRepo.FindByClientID() = %+v, want the created client", found)
+	}
+
+	if clients, err := repo.FindAll(ctx); err != nil || len(clients) == 0 {
+		t.Errorf("OAuthClientRepo.FindAll() = %v, %v, want clients", clients, err)
+	}
+
+	if err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("OAuthClientRepo.Delete() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+
+	if _, err := repo.FindByClientID(ctx, clientID); !errors.As(err, &notFoundErr) {
+		t.Errorf("OAuthClientRepo.FindByClientID() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if err := repo.Delete(ctx, created.ID); !errors.As(err, &notFoundErr) {
+		t.Errorf("OAuthClientRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+}
+
+func TestOAuthGrantRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	client, err := NewOAuthClientRepo(conn).Create(context.Background(), &domain.OAuthClient{
+		ClientID:     randToken(),
+		Name:         "spa",
+		RedirectURIs: []string{"http://localhost:5173/callback"},
+	})
+	if err != nil {
+		t.Fatalf("OAuthClientRepo.Create() error = %v", err)
+	}
+
+	repo := NewOAuthGrantRepo(conn)
+	ctx := context.Background()
+	userID := testUsers[0].ID
+
+	var notFoundErr *domain.ResourceNotFoundError
+
+	if _, err := repo.FindConsent(ctx, userID, client.ClientID); !errors.As(err, &notFoundErr) {
+		t.Errorf("OAuthGrantRepo.FindConsent() error = %v, want ResourceNotFoundError", err)
+	}
+
+	for _, scopes := range [][]string{{"openid"}, {"openid", "profile"}} {
+		err := repo.SaveConsent(ctx, &domain.OAuthConsent{UserID: userID, ClientID: client.ClientID, Scopes: scopes})
+		if err != nil {
+			t.Fatalf("OAuthGrantRepo.SaveConsent() error = %v", err)
+		}
+	}
+
+	consent, err := repo.FindConsent(ctx, userID, client.ClientID)
+	if err != nil || !reflect.DeepEqual(consent.Scopes, []string{"openid", "profile"}) {
+		t.Errorf("OAuthGrantRepo.FindConsent() = %+v, %v, want the last scopes", consent, err)
+	}
+
+	codeHash := randToken()
+	now := time.Now().Truncate(time.Second)
+
+	err = repo.CreateCode(ctx, &domain.AuthorizationCode{
+		CodeHash:      codeHash,
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   "http://localhost:5173/callback",
+		Scopes:        []string{"openid"},
+		Nonce:         "nonce",
+		CodeChallenge: "challenge",
+		AuthTime:      now,
+		ExpiresAt:     now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("OAuthGrantRepo.CreateCode() error = %v", err)
+	}
+
+	code, err := repo.ConsumeCode(ctx, codeHash)
+	if err != nil {
+		t.Fatalf("OAuthGrantRepo.ConsumeCode() error = %v", err)
+	}
+
+	if code.UserID != userID || code.Nonce != "nonce" || !code.AuthTime.Equal(now) {
+		t.Errorf("OAuthGrantRepo.ConsumeCode() = %+v, want the created code", code)
+	}
+
+	if _, err := repo.ConsumeCode(ctx, codeHash); !errors.As(err, &notFoundErr) {
+		t.Errorf("OAuthGrantRepo.ConsumeCode() twice error = %v, want ResourceNotFoundError", err)
+	}
+}
@@ -125,6 +125,21 @@ func TestPostgres(t *testing.T) {
 		"role_permission",
 		"user_permission",
 		"revoked_token",
+		"refresh_token",
+		"session",
+		"user_token",
+		"user_totp",
+		"mfa_recovery_code",
+		"login_attempt",
+		"api_key",
+		"service_account",
+		"oauth_client",
+		"oauth_consent",
+		"oauth_authorization_code",
+		"user_identity",
+		"audit_log",
+		"relation_definition",
+		"relation_tuple",
 	}
 
 	if len(tables) != len(expectedTables) {
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.RefreshTokenRepository = &RefreshTokenRepo{}
+
+type RefreshTokenRepo struct {
+	db Queryer
+}
+
+func NewRefreshTokenRepo(db Queryer) *RefreshTokenRepo {
+	return &RefreshTokenRepo{
+		db: db,
+	}
+}
+
+// Create stores a newly issued refresh token
+func (r *RefreshTokenRepo) Create(
+	ctx context.Context,
+	token *domain.RefreshToken,
+) error {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		id, family_id, user_id, expires_at
+	) VALUES (
+		$1, $2, $3, $4
+	)`, refreshTokenTable)
+
+	_, err := exec(
+		ctx,
+		r.db,
+		createQuery,
+		token.ID,
+		token.FamilyID,
+		token.UserID,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create refresh_token error: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns a refresh token by its ID (the "jti" claim)
+func (r *RefreshTokenRepo) FindByID(
+	ctx context.Context,
+	id string,
+) (*domain.RefreshToken, error) {
+	findByIDQuery := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, refreshTokenTable)
+
+	token, err := queryRow[domain.RefreshToken](ctx, r.db, findByIDQuery, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("RefreshToken", "id="+id)
+		}
+
+		return nil, fmt.Errorf("find refresh_token by ID error: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate flags an active refresh token as used and stores the next one in
+// the same statement, so either both happen or neither does. Only the first
+// caller wins, so concurrent reuse of the same token is detected as well.
+func (r *RefreshTokenRepo) Rotate(
+	ctx context.Context,
+	id string,
+	next *domain.RefreshToken,
+) (bool, error) {
+	rotateQuery := fmt.Sprintf(`WITH rotated AS (
+		UPDATE %[1]s SET
+			rotated_at = NOW()
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+		RETURNING id
+	)
+	INSERT INTO %[1]s (
+		id, family_id, user_id, expires_at
+	) SELECT $2, $3, $4, $5 FROM rotated`, refreshTokenTable)
+
+	result, err := exec(ctx, r.db, rotateQuery, id, next.ID, next.FamilyID, next.UserID, next.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("rotate refresh_token error: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// RevokeFamily revokes every refresh token descending from the same login
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	revokeFamilyQuery := fmt.Sprintf(`UPDATE %s SET
+		revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL`, refreshTokenTable)
+
+	_, err := exec(ctx, r.db, revokeFamilyQuery, familyID)
+	if err != nil {
+		return fmt.Errorf("revoke refresh_token family error: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllByUserID revokes every refresh token of a user, e.g. when their
+// password changes
+func (r *RefreshTokenRepo) RevokeAllByUserID(ctx context.Context, userID string) error {
+	revokeAllQuery := fmt.Sprintf(`UPDATE %s SET
+		revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`, refreshTokenTable)
+
+	_, err := exec(ctx, r.db, revokeAllQuery, userID)
+	if err != nil {
+		return fmt.Errorf("revoke refresh_token of user error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func newTestRefreshToken(familyID string) *domain.RefreshToken {
+	id := randToken()
+	if familyID == "" {
+		familyID = id
+	}
+
+	return &domain.RefreshToken{
+		ID:        id,
+		FamilyID:  familyID,
+		UserID:    testUsers[0].ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestNewRefreshTokenRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	type args struct {
+		db Queryer
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want *RefreshTokenRepo
+	}{
+		{
+			name: "Success",
+			args: args{
+				db: conn,
+			},
+			want: &RefreshTokenRepo{
+				db: conn,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := NewRefreshTokenRepo(tt.args.db); !reflect.DeepEqual(
+				got,
+				tt.want,
+			) {
+				t.Errorf("NewRefreshTokenRepo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRepo_FindByID(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRefreshTokenRepo(conn)
+
+	token := newTestRefreshToken("")
+	if err := repo.Create(context.Background(), token); err != nil {
+		t.Fatalf("RefreshTokenRepo.Create() error = %v", err)
+	}
+
+	type args struct {
+		id string
+	}
+
+	tests := []struct {
+		name         string
+		db           Queryer
+		args         args
+		wantFamilyID string
+		wantErr      bool
+	}{
+		{
+			name: "Success",
+			db:   conn,
+			args: args{
+				id: token.ID,
+			},
+			wantFamilyID: token.FamilyID,
+		},
+		{
+			name: "Not Found",
+			db:   conn,
+			args: args{
+				id: "not-exist",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error",
+			db:   &queryerMock{err: errors.New("error")},
+			args: args{
+				id: token.ID,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &RefreshTokenRepo{db: tt.db}
+
+			got, err := repo.FindByID(context.Background(), tt.args.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(
+					"RefreshTokenRepo.FindByID() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+
+				return
+			}
+
+			if got != nil && got.FamilyID != tt.wantFamilyID {
+				t.Errorf(
+					"RefreshTokenRepo.FindByID() = %v, want %v",
+					got.FamilyID,
+					tt.wantFamilyID,
+				)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRepo_Rotate(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRefreshTokenRepo(conn)
+
+	token := newTestRefreshToken("")
+	if err := repo.Create(context.Background(), token); err != nil {
+		t.Fatalf("RefreshTokenRepo.Create() error = %v", err)
+	}
+
+	ctx := context.Background()
+
+	next := newTestRefreshToken(token.FamilyID)
+
+	got, err := repo.Rotate(ctx, token.ID, next)
+	if err != nil || !got {
+		t.Fatalf("RefreshTokenRepo.Rotate() = %v, %v, want true", got, err)
+	}
+
+	if saved, err := repo.FindByID(ctx, next.ID); err != nil || saved.FamilyID != token.FamilyID {
+		t.Errorf("RefreshTokenRepo.FindByID() = %v, %v, want the next token of the family", saved, err)
+	}
+
+	// the second attempt is a reuse, and stores nothing
+	reused := newTestRefreshToken(token.FamilyID)
+
+	got, err = repo.Rotate(ctx, token.ID, reused)
+	if err != nil || got {
+		t.Errorf("RefreshTokenRepo.Rotate() = %v, %v, want false", got, err)
+	}
+
+	if _, err := repo.FindByID(ctx, reused.ID); err == nil {
+		t.Errorf("RefreshTokenRepo.Rotate() stored the token of a reuse")
+	}
+
+	if _, err := (&RefreshTokenRepo{
+		db: &queryerMock{err: errors.New("error")},
+	}).Rotate(ctx, token.ID, next); err == nil {
+		t.Errorf("RefreshTokenRepo.Rotate() error = nil, want error")
+	}
+}
+
+func TestRefreshTokenRepo_RevokeFamily(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRefreshTokenRepo(conn)
+	ctx := context.Background()
+
+	first := newTestRefreshToken("")
+	second := newTestRefreshToken(first.FamilyID)
+
+	for _, token := range []*domain.RefreshToken{first, second} {
+		if err := repo.Create(ctx, token); err != nil {
+			t.Fatalf("RefreshTokenRepo.Create() error = %v", err)
+		}
+	}
+
+	if err := repo.RevokeFamily(ctx, first.FamilyID); err != nil {
+		t.Fatalf("RefreshTokenRepo.RevokeFamily() error = %v", err)
+	}
+
+	for _, token := range []*domain.RefreshToken{first, second} {
+		got, err := repo.FindByID(ctx, token.ID)
+		if err != nil {
+			t.Fatalf("RefreshTokenRepo.FindByID() error = %v", err)
+		}
+
+		if got.RevokedAt == nil {
+			t.Errorf("RefreshTokenRepo.RevokeFamily() token %s not revoked", token.ID)
+		}
+	}
+
+	if err := (&RefreshTokenRepo{
+		db: &queryerMock{err: errors.New("error")},
+	}).RevokeFamily(ctx, first.FamilyID); err == nil {
+		t.Errorf("RefreshTokenRepo.RevokeFamily() error = nil, want error")
+	}
+}
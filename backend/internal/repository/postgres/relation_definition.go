@@ -2,11 +2,16 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
+
 	"goadmin-backend/internal/domain"
 )
 
+var _ domain.RelationDefinitionRepository = &RelationDefinitionRepo{}
+
 type RelationDefinitionRepo struct {
 	db Queryer
 }
@@ -27,6 +32,7 @@ func (rdr *RelationDefinitionRepo) FindRelationDefinition(
 		FROM
 			%s
 		WHERE entity_type = $1
+		ORDER BY id
 	`, relationDefinition)
 
 	rd, err := query[domain.RelationDefinition](ctx, rdr.db, sql, entityType)
@@ -36,3 +42,80 @@ func (rdr *RelationDefinitionRepo) FindRelationDefinition(
 
 	return rd, nil
 }
+
+// FindAll returns the definitions of every entity type
+func (rdr *RelationDefinitionRepo) FindAll(ctx context.Context) ([]*domain.RelationDefinition, error) {
+	findAllQuery := fmt.Sprintf(`SELECT * FROM %s
+	ORDER BY entity_type, id`, relationDefinition)
+
+	definitions, err := query[domain.RelationDefinition](ctx, rdr.db, findAllQuery)
+	if err != nil {
+		return nil, fmt.Errorf("find relation_definitions error: %w", err)
+	}
+
+	return definitions, nil
+}
+
+// Create stores a relation definition
+func (rdr *RelationDefinitionRepo) Create(
+	ctx context.Context,
+	definition *domain.RelationDefinition,
+) (*domain.RelationDefinition, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		entity_type, relation_type, subject_type, subject_relation
+	) VALUES (
+		$1, $2, $3, $4
+	) ON CONFLICT DO NOTHING
+	RETURNING *`, relationDefinition)
+
+	created, err := queryRow[domain.RelationDefinition](
+		ctx,
+		rdr.db,
+		createQuery,
+		definition.EntityType,
+		definition.RelationType,
+		definition.SubjectType,
+		definition.SubjectRelation,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceExistsError(
+				"RelationDefinition",
+				"entity_type,relation_type,subject_type,subject_relation",
+			)
+		}
+
+		return nil, fmt.Errorf("create relation_definition error: %w", err)
+	}
+
+	return created, nil
+}
+
+// Delete removes a relation definition
+func (rdr *RelationDefinitionRepo) Delete(ctx context.Context, definition *domain.RelationDefinition) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE entity_type = $1 AND relation_type = $2
+		AND subject_type = $3 AND subject_relation = $4`, relationDefinition)
+
+	tag, err := exec(
+		ctx,
+		rdr.db,
+		deleteQuery,
+		definition.EntityType,
+		definition.RelationType,
+		definition.SubjectType,
+		definition.SubjectRelation,
+	)
+	if err != nil {
+		return fmt.Errorf("delete relation_definition error: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.NewResourceNotFoundError(
+			"RelationDefinition",
+			definition.EntityType+"#"+definition.RelationType,
+		)
+	}
+
+	return nil
+}
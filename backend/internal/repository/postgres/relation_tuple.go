@@ -3,9 +3,12 @@ package postgres
 import (
 	"context"
 	"fmt"
+
 	"goadmin-backend/internal/domain"
 )
 
+var _ domain.RelationTupleRepository = &RelationTupleRepo{}
+
 type RelationTupleRepo struct {
 	db Queryer
 }
@@ -16,11 +19,11 @@ func NewRelationTupleRepo(db Queryer) *RelationTupleRepo {
 	}
 }
 
+// FindRelationTuple returns the tuples of an entity with a relation.
 func (rtr *RelationTupleRepo) FindRelationTuple(
 	ctx context.Context,
-	user *domain.User,
 	entity *domain.Entity,
-	action string,
+	relation string,
 ) ([]*domain.RelationTuple, error) {
 	sql := fmt.Sprintf(`
 		SELECT
@@ -29,13 +32,188 @@ func (rtr *RelationTupleRepo) FindRelationTuple(
 			%s
 		WHERE entity_type = $1
 			AND entity_id = $2
-			AND action = $3
+			AND relation = $3
+		ORDER BY id
 	`, relationTupleTable)
 
-	rt, err := query[domain.RelationTuple](ctx, rtr.db, sql, entity.EntityType, entity.EntityID, action)
+	rt, err := query[domain.RelationTuple](ctx, rtr.db, sql, entity.EntityType, entity.EntityID, relation)
 	if err != nil {
 		return nil, err
 	}
 
 	return rt, nil
 }
+
+// FindAll returns a page of the tuples matching the filter
+func (rtr *RelationTupleRepo) FindAll(
+	ctx context.Context,
+	filter *domain.RelationTupleFilter,
+) ([]*domain.RelationTuple, error) {
+	where := "1 = 1"
+	args := []interface{}{}
+
+	if filter == nil {
+		filter = &domain.RelationTupleFilter{}
+	}
+
+	for _, field := range []struct {
+		column string
+		value  string
+	}{
+		{"entity_type", filter.EntityType},
+		{"entity_id", filter.EntityID},
+		{"relation", filter.Relation},
+		{"subject_type", filter.SubjectType},
+		{"subject_id", filter.SubjectID},
+		{"subject_relation", filter.SubjectRelation},
+	} {
+		if field.value != "" {
+			args = append(args, field.value)
+			where += fmt.Sprintf(" AND %s = $%d", field.column, len(args))
+		}
+	}
+
+	if filter.After != "" {
+		args = append(args, filter.After)
+		where += fmt.Sprintf(" AND id > $%d", len(args))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.DefaultRelationTupleLimit
+	}
+
+	args = append(args, limit)
+
+	findAllQuery := fmt.Sprintf(
+		`SELECT * FROM %s WHERE %s ORDER BY id LIMIT $%d`,
+		relationTupleTable,
+		where,
+		len(args),
+	)
+
+	tuples, err := query[domain.RelationTuple](ctx, rtr.db, findAllQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find relation_tuples error: %w", err)
+	}
+
+	return tuples, nil
+}
+
+// Write stores a tuple, unless it is stored already
+func (rtr *RelationTupleRepo) Write(ctx context.Context, tuple *domain.RelationTuple) error {
+	return rtr.BulkWrite(ctx, []*domain.RelationTuple{tuple}, nil)
+}
+
+// Delete removes a tuple
+func (rtr *RelationTupleRepo) Delete(ctx context.Context, tuple *domain.RelationTuple) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE entity_type = $1 AND entity_id = $2 AND relation = $3
+		AND subject_type = $4 AND subject_id = $5 AND subject_relation = $6`, relationTupleTable)
+
+	tag, err := exec(
+		ctx,
+		rtr.db,
+		deleteQuery,
+		tuple.EntityType,
+		tuple.EntityID,
+		tuple.Relation,
+		tuple.SubjectType,
+		tuple.SubjectID,
+		tuple.SubjectRelation,
+	)
+	if err != nil {
+		return fmt.Errorf("delete relation_tuple error: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.NewResourceNotFoundError(
+			"RelationTuple",
+			fmt.Sprintf("%s:%s#%s", tuple.EntityType, tuple.EntityID, tuple.Relation),
+		)
+	}
+
+	return nil
+}
+
+// BulkWrite stores and removes tuples in one statement, so that either all
+// of the changes are applied or none. The insert does not see the delete,
+// so tuples both written and deleted are refused.
+func (rtr *RelationTupleRepo) BulkWrite(
+	ctx context.Context,
+	writes, deletes []*domain.RelationTuple,
+) error {
+	deleted := make(map[domain.RelationTuple]bool, len(deletes))
+	for _, tuple := range deletes {
+		deleted[tupleKey(tuple)] = true
+	}
+
+	for _, tuple := range writes {
+		if deleted[tupleKey(tuple)] {
+			return fmt.Errorf(
+				"bulk write relation_tuples error: %s:%s#%s@%s:%s: %w",
+				tuple.EntityType, tuple.EntityID, tuple.Relation, tuple.SubjectType, tuple.SubjectID,
+				domain.ErrTupleWrittenAndDeleted,
+			)
+		}
+	}
+
+	bulkQuery := fmt.Sprintf(`WITH deleted_tuples AS (
+		DELETE FROM %[1]s t
+		USING UNNEST($7::TEXT[], $8::TEXT[], $9::TEXT[], $10::TEXT[], $11::TEXT[], $12::TEXT[])
+			AS d (entity_type, entity_id, relation, subject_type, subject_id, subject_relation)
+		WHERE t.entity_type = d.entity_type AND t.entity_id = d.entity_id AND t.relation = d.relation
+			AND t.subject_type = d.subject_type AND t.subject_id = d.subject_id
+			AND t.subject_relation = d.subject_relation
+	)
+	INSERT INTO %[1]s (
+		entity_type, entity_id, relation, subject_type, subject_id, subject_relation
+	)
+	SELECT * FROM UNNEST($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[], $5::TEXT[], $6::TEXT[])
+	ON CONFLICT DO NOTHING`, relationTupleTable)
+
+	args := append(tupleColumns(writes), tupleColumns(deletes)...)
+
+	_, err := exec(ctx, rtr.db, bulkQuery, args...)
+	if err != nil {
+		return fmt.Errorf("bulk write relation_tuples error: %w", err)
+	}
+
+	return nil
+}
+
+// tupleKey returns the columns identifying a tuple
+func tupleKey(tuple *domain.RelationTuple) domain.RelationTuple {
+	return domain.RelationTuple{
+		EntityType:      tuple.EntityType,
+		EntityID:        tuple.EntityID,
+		Relation:        tuple.Relation,
+		SubjectType:     tuple.SubjectType,
+		SubjectID:       tuple.SubjectID,
+		SubjectRelation: tuple.SubjectRelation,
+	}
+}
+
+// tupleColumns returns the columns of tuples as arrays for UNNEST
+func tupleColumns(tuples []*domain.RelationTuple) []any {
+	columns := make([][]string, 6)
+	for i := range columns {
+		columns[i] = make([]string, 0, len(tuples))
+	}
+
+	for _, tuple := range tuples {
+		columns[0] = append(columns[0], tuple.EntityType)
+		columns[1] = append(columns[1], tuple.EntityID)
+		columns[2] = append(columns[2], tuple.Relation)
+		columns[3] = append(columns[3], tuple.SubjectType)
+		columns[4] = append(columns[4], tuple.SubjectID)
+		columns[5] = append(columns[5], tuple.SubjectRelation)
+	}
+
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		args[i] = column
+	}
+
+	return args
+}
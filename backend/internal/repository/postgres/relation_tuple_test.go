@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestRelationTupleRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRelationTupleRepo(conn)
+	ctx := context.Background()
+
+	// tests share the database
+	docID, groupID, userID, otherUserID := randToken(), randToken(), randToken(), randToken()
+
+	owner := &domain.RelationTuple{
+		EntityType:  "document",
+		EntityID:    docID,
+		Relation:    "owner",
+		SubjectType: "user",
+		SubjectID:   userID,
+	}
+	viewer := &domain.RelationTuple{
+		EntityType:      "document",
+		EntityID:        docID,
+		Relation:        "viewer",
+		SubjectType:     "group",
+		SubjectID:       groupID,
+		SubjectRelation: "member",
+	}
+	member := &domain.RelationTuple{
+		EntityType:  "group",
+		EntityID:    groupID,
+		Relation:    "member",
+		SubjectType: "user",
+		SubjectID:   userID,
+	}
+
+	if err := repo.Write(ctx, owner); err != nil {
+		t.Fatalf("RelationTupleRepo.Write() error = %v", err)
+	}
+
+	// writing a tuple again does nothing
+	if err := repo.Write(ctx, owner); err != nil {
+		t.Fatalf("RelationTupleRepo.Write() error = %v", err)
+	}
+
+	if err := repo.BulkWrite(ctx, []*domain.RelationTuple{viewer, member}, nil); err != nil {
+		t.Fatalf("RelationTupleRepo.BulkWrite() error = %v", err)
+	}
+
+	tuples, err := repo.FindRelationTuple(ctx, &domain.Entity{EntityType: "document", EntityID: docID}, "viewer")
+	if err != nil || len(tuples) != 1 || tuples[0].SubjectRelation != "member" || tuples[0].ID == "" {
+		t.Fatalf("RelationTupleRepo.FindRelationTuple() = %v, %v, want the viewer tuple", tuples, err)
+	}
+
+	// the entities the user is related to
+	tuples, err = repo.FindAll(ctx, &domain.RelationTupleFilter{SubjectType: "user", SubjectID: userID})
+	if err != nil || len(tuples) != 2 {
+		t.Fatalf("RelationTupleRepo.FindAll() = %v, %v, want 2 tuples", tuples, err)
+	}
+
+	page, err := repo.FindAll(ctx, &domain.RelationTupleFilter{EntityType: "document", EntityID: docID, Limit: 1})
+	if err != nil || len(page) != 1 || page[0].Relation != "owner" {
+		t.Fatalf("RelationTupleRepo.FindAll() = %v, %v, want the first page", page, err)
+	}
+
+	page, err = repo.FindAll(ctx, &domain.RelationTupleFilter{
+		EntityType: "document",
+		EntityID:   docID,
+		Limit:      1,
+		After:      page[0].ID,
+	})
+	if err != nil || len(page) != 1 || page[0].Relation != "viewer" {
+		t.Fatalf("RelationTupleRepo.FindAll() = %v, %v, want the second page", page, err)
+	}
+
+	// moving the document from the group to the user at once
+	direct := &domain.RelationTuple{
+		EntityType:  "document",
+		EntityID:    docID,
+		Relation:    "viewer",
+		SubjectType: "user",
+		SubjectID:   otherUserID,
+	}
+
+	if err := repo.BulkWrite(ctx, []*domain.RelationTuple{direct}, []*domain.RelationTuple{viewer}); err != nil {
+		t.Fatalf("RelationTupleRepo.BulkWrite() error = %v", err)
+	}
+
+	tuples, err = repo.FindRelationTuple(ctx, &domain.Entity{EntityType: "document", EntityID: docID}, "viewer")
+	if err != nil || len(tuples) != 1 || tuples[0].SubjectID != otherUserID {
+		t.Fatalf("RelationTupleRepo.FindRelationTuple() = %v, %v, want the direct viewer", tuples, err)
+	}
+
+	// a tuple both written and deleted changes nothing
+	err = repo.BulkWrite(ctx, []*domain.RelationTuple{viewer, direct}, []*domain.RelationTuple{{
+		EntityType:  direct.EntityType,
+		EntityID:    direct.EntityID,
+		Relation:    direct.Relation,
+		SubjectType: direct.SubjectType,
+		SubjectID:   direct.SubjectID,
+	}})
+	if !errors.Is(err, domain.ErrTupleWrittenAndDeleted) {
+		t.Fatalf("RelationTupleRepo.BulkWrite() error = %v, want %v", err, domain.ErrTupleWrittenAndDeleted)
+	}
+
+	tuples, err = repo.FindRelationTuple(ctx, &domain.Entity{EntityType: "document", EntityID: docID}, "viewer")
+	if err != nil || len(tuples) != 1 || tuples[0].SubjectID != otherUserID {
+		t.Fatalf("RelationTupleRepo.FindRelationTuple() = %v, %v, want the direct viewer only", tuples, err)
+	}
+
+	if err := repo.Delete(ctx, owner); err != nil {
+		t.Fatalf("RelationTupleRepo.Delete() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if err := repo.Delete(ctx, owner); !errors.As(err, &notFoundErr) {
+		t.Errorf("RelationTupleRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+
+	failing := NewRelationTupleRepo(&queryerMock{err: errors.New("error")})
+
+	if err := failing.Write(ctx, owner); err == nil {
+		t.Error("RelationTupleRepo.Write() error = nil, want error")
+	}
+
+	if _, err := failing.FindAll(ctx, nil); err == nil {
+		t.Error("RelationTupleRepo.FindAll() error = nil, want error")
+	}
+}
+
+func TestRelationDefinitionRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRelationDefinitionRepo(conn)
+	ctx := context.Background()
+	entityType := "document_" + randToken()
+
+	definition := &domain.RelationDefinition{
+		EntityType:      entityType,
+		RelationType:    "viewer",
+		SubjectType:     "group",
+		SubjectRelation: "member",
+	}
+
+	created, err := repo.Create(ctx, definition)
+	if err != nil || created.ID == "" {
+		t.Fatalf("RelationDefinitionRepo.Create() = %+v, %v, want a new definition", created, err)
+	}
+
+	var existsErr *domain.ResourceExistsError
+	if _, err := repo.Create(ctx, definition); !errors.As(err, &existsErr) {
+		t.Errorf("RelationDefinitionRepo.Create() error = %v, want ResourceExistsError", err)
+	}
+
+	definitions, err := repo.FindRelationDefinition(ctx, entityType)
+	if err != nil || len(definitions) != 1 || definitions[0].SubjectRelation != "member" {
+		t.Fatalf("RelationDefinitionRepo.FindRelationDefinition() = %v, %v, want the definition", definitions, err)
+	}
+
+	definitions, err = repo.FindAll(ctx)
+	if err != nil || !slices.ContainsFunc(definitions, func(d *domain.RelationDefinition) bool {
+		return d.ID == created.ID
+	}) {
+		t.Fatalf("RelationDefinitionRepo.FindAll() = %v, %v, want the definition", definitions, err)
+	}
+
+	if err := repo.Delete(ctx, definition); err != nil {
+		t.Fatalf("RelationDefinitionRepo.Delete() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+	if err := repo.Delete(ctx, definition); !errors.As(err, &notFoundErr) {
+		t.Errorf("RelationDefinitionRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewRelationDefinitionRepo(&queryerMock{err: errors.New("error")}).Create(ctx, definition); err == nil {
+		t.Error("RelationDefinitionRepo.Create() error = nil, want error")
+	}
+}
@@ -3,8 +3,13 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"goadmin-backend/internal/domain"
 )
 
+var _ domain.RevokedTokenRepository = &RevokedTokenRepo{}
+
 type RevokedTokenRepo struct {
 	db Queryer
 }
@@ -15,10 +20,18 @@ func NewRevokedTokenRepo(db Queryer) *RevokedTokenRepo {
 	}
 }
 
-func (rtr *RevokedTokenRepo) AddRevokedToken(ctx context.Context, token string) error {
-	query := fmt.Sprintf(`INSERT INTO %s (token) VALUES ($1)`, revokedTokenTable)
+func (rtr *RevokedTokenRepo) AddRevokedToken(
+	ctx context.Context,
+	tokenID string,
+	expiresAt time.Time,
+) error {
+	// revoking a token twice, like logging out twice, is no error
+	query := fmt.Sprintf(
+		`INSERT INTO %s (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		revokedTokenTable,
+	)
 
-	_, err := rtr.db.Exec(ctx, query, token)
+	_, err := rtr.db.Exec(ctx, query, tokenID, expiresAt)
 	if err != nil {
 		return fmt.Errorf("adding revoked_token error: %w", err)
 	}
@@ -26,15 +39,27 @@ func (rtr *RevokedTokenRepo) AddRevokedToken(ctx context.Context, token string)
 	return nil
 }
 
-func (rtr *RevokedTokenRepo) IsRevoked(ctx context.Context, token string) (bool, error) {
-	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE token = $1)`, revokedTokenTable)
+func (rtr *RevokedTokenRepo) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE jti = $1)`, revokedTokenTable)
 
 	var exists bool
 
-	err := rtr.db.QueryRow(ctx, query, token).Scan(&exists)
+	err := rtr.db.QueryRow(ctx, query, tokenID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("check revoked_token error: %w", err)
 	}
 
 	return exists, nil
 }
+
+// DeleteExpired removes the revocations of tokens that have expired
+func (rtr *RevokedTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < CURRENT_TIMESTAMP`, revokedTokenTable)
+
+	tag, err := rtr.db.Exec(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired revoked_token error: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
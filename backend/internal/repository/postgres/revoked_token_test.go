@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func randToken() string {
@@ -82,7 +83,7 @@ func TestRevokedTokenRepo_AddRevokedToken(t *testing.T) {
 
 	repo := NewRevokedTokenRepo(conn)
 
-	_ = repo.AddRevokedToken(context.Background(), "token1")
+	_ = repo.AddRevokedToken(context.Background(), "token1", time.Now().Add(time.Hour))
 
 	type args struct {
 		token string
@@ -101,11 +102,11 @@ func TestRevokedTokenRepo_AddRevokedToken(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "Fail",
+			name: "Already Revoked",
 			args: args{
 				token: "token1",
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
@@ -114,7 +115,11 @@ func TestRevokedTokenRepo_AddRevokedToken(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			if err := repo.AddRevokedToken(context.Background(), tt.args.token); (err != nil) != tt.wantErr {
+			if err := repo.AddRevokedToken(
+				context.Background(),
+				tt.args.token,
+				time.Now().Add(time.Hour),
+			); (err != nil) != tt.wantErr {
 				t.Errorf(
 					"RevokedTokenRepo.AddRevokedToken() error = %v, wantErr %v",
 					err,
@@ -191,7 +196,7 @@ func TestRevokedTokenRepo_IsRevoked(t *testing.T) {
 			}
 
 			if tt.want {
-				_ = repo.AddRevokedToken(context.Background(), tt.args.token)
+				_ = repo.AddRevokedToken(context.Background(), tt.args.token, time.Now().Add(time.Hour))
 			}
 
 			got, err := repo.IsRevoked(context.Background(), tt.args.token)
@@ -215,3 +220,40 @@ func TestRevokedTokenRepo_IsRevoked(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokedTokenRepo_DeleteExpired(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewRevokedTokenRepo(conn)
+	expired, valid := randToken(), randToken()
+
+	_ = repo.AddRevokedToken(context.Background(), expired, time.Now().Add(-time.Minute))
+	_ = repo.AddRevokedToken(context.Background(), valid, time.Now().Add(time.Hour))
+
+	deleted, err := repo.DeleteExpired(context.Background())
+	if err != nil {
+		t.Fatalf("RevokedTokenRepo.DeleteExpired() error = %v", err)
+	}
+
+	if deleted < 1 {
+		t.Errorf("RevokedTokenRepo.DeleteExpired() = %v, want at least 1", deleted)
+	}
+
+	if revoked, _ := repo.IsRevoked(context.Background(), expired); revoked {
+		t.Errorf("RevokedTokenRepo.DeleteExpired() kept an expired token")
+	}
+
+	if revoked, _ := repo.IsRevoked(context.Background(), valid); !revoked {
+		t.Errorf("RevokedTokenRepo.DeleteExpired() deleted a valid token")
+	}
+
+	errRepo := NewRevokedTokenRepo(&queryerMock{err: errors.New("error")})
+	if _, err := errRepo.DeleteExpired(context.Background()); err == nil {
+		t.Errorf("RevokedTokenRepo.DeleteExpired() error = nil, want error")
+	}
+}
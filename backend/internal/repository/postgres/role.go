@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.RoleRepository = &RoleRepo{}
+
+type RoleRepo struct {
+	db Queryer
+}
+
+func NewRoleRepo(db Queryer) *RoleRepo {
+	return &RoleRepo{
+		db: db,
+	}
+}
+
+// FindByUserID returns the roles granted to a user
+func (r *RoleRepo) FindByUserID(ctx context.Context, userID string) ([]*domain.Role, error) {
+	findQuery := fmt.Sprintf(`SELECT r.* FROM %s r
+	JOIN %s ur ON ur.role_id = r.id
+	WHERE ur.user_id = $1
+	ORDER BY r.name`, roleTable, userRoleTable)
+
+	roles, err := query[domain.Role](ctx, r.db, findQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find roles by user ID error: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GrantRole grants the role with the name to a user
+func (r *RoleRepo) GrantRole(ctx context.Context, userID, roleName string) error {
+	grantQuery := fmt.Sprintf(`INSERT INTO %s (user_id, role_id)
+	SELECT $1, id FROM %s WHERE name = $2
+	ON CONFLICT (user_id, role_id) DO NOTHING`, userRoleTable, roleTable)
+
+	if _, err := exec(ctx, r.db, grantQuery, userID, roleName); err != nil {
+		return fmt.Errorf("grant role error: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeRole revokes the role with the name from a user
+func (r *RoleRepo) RevokeRole(ctx context.Context, userID, roleName string) error {
+	revokeQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE user_id = $1 AND role_id IN (SELECT id FROM %s WHERE name = $2)`, userRoleTable, roleTable)
+
+	if _, err := exec(ctx, r.db, revokeQuery, userID, roleName); err != nil {
+		return fmt.Errorf("revoke role error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRoleRepo_FindByUserID(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	ctx := context.Background()
+
+	_, err := conn.Exec(ctx, `WITH admin AS (
+		INSERT INTO role (name) VALUES ('admin') RETURNING id
+	)
+	INSERT INTO user_role (user_id, role_id) SELECT $1, id FROM admin`, testUsers[0].ID)
+	if err != nil {
+		t.Fatalf("insert role error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		db      Queryer
+		userID  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Admin", db: conn, userID: testUsers[0].ID, want: []string{"admin"}},
+		{name: "No Roles", db: conn, userID: testUsers[1].ID},
+		{name: "Error", db: &queryerMock{err: errors.New("error")}, userID: testUsers[0].ID, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewRoleRepo(tt.db).FindByUserID(ctx, tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoleRepo.FindByUserID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("RoleRepo.FindByUserID() = %v, want %v", got, tt.want)
+			}
+
+			for i, role := range got {
+				if role.Name != tt.want[i] {
+					t.Errorf("RoleRepo.FindByUserID() = %v, want %v", role.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRoleRepo_GrantRole(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	ctx := context.Background()
+
+	if _, err := conn.Exec(ctx, `INSERT INTO role (name) VALUES ('editor')`); err != nil {
+		t.Fatalf("insert role error = %v", err)
+	}
+
+	repo := NewRoleRepo(conn)
+
+	// granting twice, or an unknown role, does nothing
+	for _, role := range []string{"editor", "editor", "unknown"} {
+		if err := repo.GrantRole(ctx, testUsers[1].ID, role); err != nil {
+			t.Fatalf("RoleRepo.GrantRole() error = %v", err)
+		}
+	}
+
+	roles, err := repo.FindByUserID(ctx, testUsers[1].ID)
+	if err != nil || len(roles) != 1 || roles[0].Name != "editor" {
+		t.Fatalf("RoleRepo.FindByUserID() = %v, %v, want editor", roles, err)
+	}
+
+	if err := repo.RevokeRole(ctx, testUsers[1].ID, "editor"); err != nil {
+		t.Fatalf("RoleRepo.RevokeRole() error = %v", err)
+	}
+
+	if roles, _ := repo.FindByUserID(ctx, testUsers[1].ID); len(roles) != 0 {
+		t.Errorf("RoleRepo.RevokeRole() left roles %v", roles)
+	}
+
+	errRepo := NewRoleRepo(&queryerMock{err: errors.New("error")})
+	if err := errRepo.GrantRole(ctx, testUsers[1].ID, "editor"); err == nil {
+		t.Errorf("RoleRepo.GrantRole() error = nil, want error")
+	}
+
+	if err := errRepo.RevokeRole(ctx, testUsers[1].ID, "editor"); err == nil {
+		t.Errorf("RoleRepo.RevokeRole() error = nil, want error")
+	}
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.ServiceAccountRepository = &ServiceAccountRepo{}
+
+type ServiceAccountRepo struct {
+	db Queryer
+}
+
+func NewServiceAccountRepo(db Queryer) *ServiceAccountRepo {
+	return &ServiceAccountRepo{
+		db: db,
+	}
+}
+
+// Create stores a new service account
+func (r *ServiceAccountRepo) Create(
+	ctx context.Context,
+	account *domain.ServiceAccount,
+) (*domain.ServiceAccount, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		name, client_id, client_secret_hash, scopes, created_by
+	) VALUES (
+		$1, $2, $3, $4, $5
+	) RETURNING *`, serviceAccountTable)
+
+	scopes := account.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	created, err := queryRow[domain.ServiceAccount](
+		ctx,
+		r.db,
+		createQuery,
+		account.Name,
+		account.ClientID,
+		account.ClientSecretHash,
+		scopes,
+		account.CreatedBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create service_account error: %w", err)
+	}
+
+	return created, nil
+}
+
+// FindByID returns the service account with the given ID
+func (r *ServiceAccountRepo) FindByID(ctx context.Context, id string) (*domain.ServiceAccount, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, serviceAccountTable)
+
+	account, err := queryRow[domain.ServiceAccount](ctx, r.db, findQuery, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("ServiceAccount", "id="+id)
+		}
+
+		return nil, fmt.Errorf("find service_account by ID error: %w", err)
+	}
+
+	return account, nil
+}
+
+// FindByClientID returns the service account with the given client ID
+func (r *ServiceAccountRepo) FindByClientID(
+	ctx context.Context,
+	clientID string,
+) (*domain.ServiceAccount, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s WHERE client_id = $1`, serviceAccountTable)
+
+	account, err := queryRow[domain.ServiceAccount](ctx, r.db, findQuery, clientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("ServiceAccount", "client_id="+clientID)
+		}
+
+		return nil, fmt.Errorf("find service_account by client ID error: %w", err)
+	}
+
+	return account, nil
+}
+
+// FindAll returns every service account
+func (r *ServiceAccountRepo) FindAll(ctx context.Context) ([]*domain.ServiceAccount, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	ORDER BY created_at DESC, id DESC`, serviceAccountTable)
+
+	accounts, err := query[domain.ServiceAccount](ctx, r.db, findQuery)
+	if err != nil {
+		return nil, fmt.Errorf("find service_accounts error: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// Touch records the last use of a service account
+func (r *ServiceAccountRepo) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	touchQuery := fmt.Sprintf(`UPDATE %s SET
+		last_used_at = $2
+	WHERE id = $1`, serviceAccountTable)
+
+	_, err := exec(ctx, r.db, touchQuery, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("touch service_account error: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a service account
+func (r *ServiceAccountRepo) Delete(ctx context.Context, id string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, serviceAccountTable)
+
+	tag, err := exec(ctx, r.db, deleteQuery, id)
+	if err != nil {
+		return fmt.Errorf("delete service_account error: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return domain.NewResourceNotFoundError("ServiceAccount", "id="+id)
+	}
+
+	return nil
+}
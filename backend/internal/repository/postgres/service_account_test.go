@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestServiceAccountRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewServiceAccountRepo(conn)
+	ctx := context.Background()
+	clientID := randToken()
+
+	created, err := repo.Create(ctx, &domain.ServiceAccount{
+		Name:             "billing sync",
+		ClientID:         clientID,
+		ClientSecretHash: randToken(),
+		Scopes:           []string{"users:read"},
+		CreatedBy:        &testUsers[0].ID,
+	})
+	if err != nil {
+		t.Fatalf("ServiceAccountRepo.Create() error = %v", err)
+	}
+
+	if created.ID == "" || created.LastUsedAt != nil {
+		t.Errorf("ServiceAccountRepo.Create() = %+v, want a new unused account", created)
+	}
+
+	found, err := repo.FindByClientID(ctx, clientID)
+	if err != nil {
+		t.Fatalf("ServiceAccountRepo.FindByClientID() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(found.Scopes, []string{"users:read"}) || found.ID != created.ID {
+		t.Errorf("ServiceAccountRepo.FindByClientID() = %+v, want the created account", found)
+	}
+
+	usedAt := time.Now().Truncate(time.Second)
+	if err := repo.Touch(ctx, created.ID, usedAt); err != nil {
+		t.Fatalf("ServiceAccountRepo.Touch() error = %v", err)
+	}
+
+	found, err = repo.FindByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("ServiceAccountRepo.FindByID() error = %v", err)
+	}
+
+	if found.LastUsedAt == nil || !found.LastUsedAt.Equal(usedAt) {
+		t.Errorf("ServiceAccountRepo.FindByID() last used at = %v, want %v", found.LastUsedAt, usedAt)
+	}
+
+	accounts, err := repo.FindAll(ctx)
+	if err != nil || len(accounts) == 0 {
+		t.Fatalf("ServiceAccountRepo.FindAll() = %v, %v, want accounts", accounts, err)
+	}
+
+	if err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("ServiceAccountRepo.Delete() error = %v", err)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+
+	if err := repo.Delete(ctx, created.ID); !errors.As(err, &notFoundErr) {
+		t.Errorf("ServiceAccountRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := repo.FindByClientID(ctx, clientID); !errors.As(err, &notFoundErr) {
+		t.Errorf("ServiceAccountRepo.FindByClientID() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewServiceAccountRepo(&queryerMock{err: errors.New("error")}).FindAll(ctx); err == nil {
+		t.Errorf("ServiceAccountRepo.FindAll() error = nil, wantErr true")
+	}
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.SessionRepository = &SessionRepo{}
+
+type SessionRepo struct {
+	db Queryer
+}
+
+func NewSessionRepo(db Queryer) *SessionRepo {
+	return &SessionRepo{
+		db: db,
+	}
+}
+
+// Create stores a new session
+func (r *SessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		id, user_id, user_agent, ip_address, expires_at
+	) VALUES (
+		$1, $2, $3, $4, $5
+	)`, sessionTable)
+
+	_, err := exec(
+		ctx,
+		r.db,
+		createQuery,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IPAddress,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create session error: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns a session by its ID (the "sid" claim)
+func (r *SessionRepo) FindByID(ctx context.Context, id string) (*domain.Session, error) {
+	findByIDQuery := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, sessionTable)
+
+	session, err := queryRow[domain.Session](ctx, r.db, findByIDQuery, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("Session", "id="+id)
+		}
+
+		return nil, fmt.Errorf("find session by ID error: %w", err)
+	}
+
+	return session, nil
+}
+
+// FindActiveByUserID returns the sessions of a user that are still in use
+func (r *SessionRepo) FindActiveByUserID(
+	ctx context.Context,
+	userID string,
+) ([]*domain.Session, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	ORDER BY last_used_at DESC`, sessionTable)
+
+	sessions, err := query[domain.Session](ctx, r.db, findQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find sessions by user ID error: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Touch records a use of an active session and extends it
+func (r *SessionRepo) Touch(ctx context.Context, id string, expiresAt time.Time) error {
+	touchQuery := fmt.Sprintf(`UPDATE %s SET
+		last_used_at = NOW(),
+		expires_at = $2
+	WHERE id = $1 AND revoked_at IS NULL`, sessionTable)
+
+	_, err := exec(ctx, r.db, touchQuery, id, expiresAt)
+	if err != nil {
+		return fmt.Errorf("touch session error: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke ends a session
+func (r *SessionRepo) Revoke(ctx context.Context, id string) error {
+	revokeQuery := fmt.Sprintf(`UPDATE %s SET
+		revoked_at = NOW()
+	WHERE id = $1 AND revoked_at IS NULL`, sessionTable)
+
+	_, err := exec(ctx, r.db, revokeQuery, id)
+	if err != nil {
+		return fmt.Errorf("revoke session error: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllByUserID ends every session of a user
+func (r *SessionRepo) RevokeAllByUserID(ctx context.Context, userID string) error {
+	revokeQuery := fmt.Sprintf(`UPDATE %s SET
+		revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`, sessionTable)
+
+	_, err := exec(ctx, r.db, revokeQuery, userID)
+	if err != nil {
+		return fmt.Errorf("revoke sessions by user ID error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func newTestSession(userID string) *domain.Session {
+	return &domain.Session{
+		ID:        randToken(),
+		UserID:    userID,
+		UserAgent: "Mozilla/5.0",
+		IPAddress: "127.0.0.1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestSessionRepo_FindByID(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewSessionRepo(conn)
+
+	session := newTestSession(testUsers[0].ID)
+	if err := repo.Create(context.Background(), session); err != nil {
+		t.Fatalf("SessionRepo.Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		db      Queryer
+		id      string
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			db:   conn,
+			id:   session.ID,
+		},
+		{
+			name:    "Not Found",
+			db:      conn,
+			id:      "not-exist",
+			wantErr: true,
+		},
+		{
+			name:    "Error",
+			db:      &queryerMock{err: errors.New("error")},
+			id:      session.ID,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewSessionRepo(tt.db).FindByID(context.Background(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SessionRepo.FindByID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && (got.UserAgent != session.UserAgent || got.UserID != session.UserID) {
+				t.Errorf("SessionRepo.FindByID() = %v, want %v", got, session)
+			}
+		})
+	}
+}
+
+func TestSessionRepo_Revoke(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewSessionRepo(conn)
+	ctx := context.Background()
+	userID := testUsers[1].ID
+
+	first, second, third := newTestSession(userID), newTestSession(userID), newTestSession(userID)
+	for _, session := range []*domain.Session{first, second, third} {
+		if err := repo.Create(ctx, session); err != nil {
+			t.Fatalf("SessionRepo.Create() error = %v", err)
+		}
+	}
+
+	if err := repo.Touch(ctx, third.ID, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("SessionRepo.Touch() error = %v", err)
+	}
+
+	if err := repo.Revoke(ctx, first.ID); err != nil {
+		t.Fatalf("SessionRepo.Revoke() error = %v", err)
+	}
+
+	active, err := repo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("SessionRepo.FindActiveByUserID() error = %v", err)
+	}
+
+	if len(active) != 2 || active[0].ID != third.ID {
+		t.Errorf("SessionRepo.FindActiveByUserID() = %v, want [%v %v]", active, third.ID, second.ID)
+	}
+
+	if err := repo.RevokeAllByUserID(ctx, userID); err != nil {
+		t.Fatalf("SessionRepo.RevokeAllByUserID() error = %v", err)
+	}
+
+	active, _ = repo.FindActiveByUserID(ctx, userID)
+	if len(active) != 0 {
+		t.Errorf("SessionRepo.FindActiveByUserID() after RevokeAllByUserID() = %v, want none", active)
+	}
+
+	errRepo := NewSessionRepo(&queryerMock{err: errors.New("error")})
+	if err := errRepo.RevokeAllByUserID(ctx, userID); err == nil {
+		t.Errorf("SessionRepo.RevokeAllByUserID() error = nil, want error")
+	}
+}
@@ -1,8 +1,23 @@
 package postgres
 
 const (
-	userTable              = `"user"`
-	revokedTokenTable      = "revoked_token"
-	relationDefinition     = "relation_definition"
-	relationTupleTable     = "relation_tuple"
+	userTable           = `"user"`
+	revokedTokenTable   = "revoked_token"
+	refreshTokenTable   = "refresh_token"
+	sessionTable        = "session"
+	userTokenTable      = "user_token"
+	totpTable           = "user_totp"
+	recoveryCodeTable   = "mfa_recovery_code"
+	loginAttemptTable   = "login_attempt"
+	roleTable           = "role"
+	userRoleTable       = "user_role"
+	apiKeyTable         = "api_key"
+	serviceAccountTable = "service_account"
+	oauthClientTable    = "oauth_client"
+	oauthConsentTable   = "oauth_consent"
+	oauthCodeTable      = "oauth_authorization_code"
+	userIdentityTable   = "user_identity"
+	auditLogTable       = "audit_log"
+	relationDefinition  = "relation_definition"
+	relationTupleTable  = "relation_tuple"
 )
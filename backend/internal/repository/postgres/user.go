@@ -132,6 +132,26 @@ func (r *UserRepo) FindByUsername(
 	return user, nil
 }
 
+// FindByEmail returns a user from the database by email
+func (r *UserRepo) FindByEmail(
+	ctx context.Context,
+	email string,
+) (*domain.User, error) {
+	findByEmailQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE lower(email) = lower($1) AND NOT deleted AND active`, userTable)
+
+	user, err := queryRow[domain.User](ctx, r.db, findByEmailQuery, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("User", "email="+email)
+		}
+
+		return nil, fmt.Errorf("find user by email error: %w", err)
+	}
+
+	return user, nil
+}
+
 // Create a new user in the database
 func (r *UserRepo) Create(
 	ctx context.Context,
@@ -176,7 +196,10 @@ func (r *UserRepo) Update(
 
 	if user.Email != "" {
 		args = append(args, user.Email)
-		fields = append(fields, fmt.Sprintf("email = $%d", len(args)))
+		fields = append(fields,
+			fmt.Sprintf("email = $%d", len(args)),
+			// a new address has to be verified again
+			fmt.Sprintf("email_verified_at = CASE WHEN email = $%d THEN email_verified_at END", len(args)))
 	}
 
 	if user.FirstName != "" {
@@ -213,6 +236,40 @@ func (r *UserRepo) Update(
 	return updatedUsr, nil
 }
 
+// UpdatePassword replaces the password hash of a user
+func (r *UserRepo) UpdatePassword(
+	ctx context.Context,
+	usrID string,
+	passwordHash string,
+) error {
+	updatePasswordQuery := fmt.Sprintf(`UPDATE %s SET
+		password = $2,
+		updated_at = NOW()
+	WHERE id = $1`, userTable)
+
+	_, err := exec(ctx, r.db, updatePasswordQuery, usrID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("update user password error: %w", err)
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that a user proved to own their e-mail address
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, usrID string) error {
+	markEmailVerifiedQuery := fmt.Sprintf(`UPDATE %s SET
+		email_verified_at = NOW(),
+		updated_at = NOW()
+	WHERE id = $1 AND email_verified_at IS NULL`, userTable)
+
+	_, err := exec(ctx, r.db, markEmailVerifiedQuery, usrID)
+	if err != nil {
+		return fmt.Errorf("mark user email verified error: %w", err)
+	}
+
+	return nil
+}
+
 // SoftDelete a user in the database
 func (r *UserRepo) SoftDelete(ctx context.Context, usrID string) error {
 	softDeleteUserQuery := fmt.Sprintf(`UPDATE %s SET
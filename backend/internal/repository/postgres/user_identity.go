@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.UserIdentityRepository = &UserIdentityRepo{}
+
+type UserIdentityRepo struct {
+	db Queryer
+}
+
+func NewUserIdentityRepo(db Queryer) *UserIdentityRepo {
+	return &UserIdentityRepo{
+		db: db,
+	}
+}
+
+// Create links an external identity to a user
+func (r *UserIdentityRepo) Create(
+	ctx context.Context,
+	identity *domain.UserIdentity,
+) (*domain.UserIdentity, error) {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		user_id, provider, subject, email
+	) VALUES (
+		$1, $2, $3, $4
+	) ON CONFLICT (provider, subject) DO NOTHING
+	RETURNING *`, userIdentityTable)
+
+	created, err := queryRow[domain.UserIdentity](
+		ctx,
+		r.db,
+		createQuery,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceExistsError("UserIdentity", "provider,subject")
+		}
+
+		return nil, fmt.Errorf("create user_identity error: %w", err)
+	}
+
+	return created, nil
+}
+
+// Find returns the identity a provider knows by subject
+func (r *UserIdentityRepo) Find(
+	ctx context.Context,
+	provider, subject string,
+) (*domain.UserIdentity, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE provider = $1 AND subject = $2`, userIdentityTable)
+
+	identity, err := queryRow[domain.UserIdentity](ctx, r.db, findQuery, provider, subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("UserIdentity", "provider="+provider)
+		}
+
+		return nil, fmt.Errorf("find user_identity error: %w", err)
+	}
+
+	return identity, nil
+}
+
+// FindByUserID returns the identities of a user
+func (r *UserIdentityRepo) FindByUserID(
+	ctx context.Context,
+	userID string,
+) ([]*domain.UserIdentity, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE user_id = $1
+	ORDER BY created_at, id`, userIdentityTable)
+
+	identities, err := query[domain.UserIdentity](ctx, r.db, findQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find user_identities by user ID error: %w", err)
+	}
+
+	return identities, nil
+}
+
+// Touch records the last sign-in with an identity
+func (r *UserIdentityRepo) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	touchQuery := fmt.Sprintf(`UPDATE %s SET
+		last_used_at = $2
+	WHERE id = $1`, userIdentityTable)
+
+	_, err := exec(ctx, r.db, touchQuery, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("touch user_identity error: %w", err)
+	}
+
+	return nil
+}
+
+// Delete unlinks an identity of a user
+func (r *UserIdentityRepo) Delete(ctx context.Context, userID, id string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE user_id = $1 AND id = $2`, userIdentityTable)
+
+	tag, err := exec(ctx, r.db, deleteQuery, userID, id)
+	if err != nil {
+		return fmt.Errorf("delete user_identity error: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return domain.NewResourceNotFoundError("UserIdentity", "id="+id)
+	}
+
+	return nil
+}
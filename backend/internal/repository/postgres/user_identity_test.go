@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestUserIdentityRepo(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewUserIdentityRepo(conn)
+	ctx := context.Background()
+	userID := testUsers[0].ID
+	subject := randToken()
+
+	created, err := repo.Create(ctx, &domain.UserIdentity{
+		UserID:   userID,
+		Provider: "okta",
+		Subject:  subject,
+		Email:    "user@example.com",
+	})
+	if err != nil {
+		t.Fatalf("UserIdentityRepo.Create() error = %v", err)
+	}
+
+	if created.ID == "" || created.LastUsedAt != nil {
+		t.Errorf("UserIdentityRepo.Create() = %+v, want a new identity", created)
+	}
+
+	// an identity is linked to one user only
+	var existsErr *domain.ResourceExistsError
+	if _, err := repo.Create(ctx, &domain.UserIdentity{
+		UserID:   testUsers[1].ID,
+		Provider: "okta",
+		Subject:  subject,
+	}); !errors.As(err, &existsErr) {
+		t.Errorf("UserIdentityRepo.Create() error = %v, want ResourceExistsError", err)
+	}
+
+	found, err := repo.Find(ctx, "okta", subject)
+	if err != nil || found.UserID != userID || found.Email != "user@example.com" {
+		t.Fatalf("UserIdentityRepo.Find() = %+v, %v, want the created identity", found, err)
+	}
+
+	usedAt := time.Now().Truncate(time.Second)
+	if err := repo.Touch(ctx, created.ID, usedAt); err != nil {
+		t.Fatalf("UserIdentityRepo.Touch() error = %v", err)
+	}
+
+	identities, err := repo.FindByUserID(ctx, userID)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("UserIdentityRepo.FindByUserID() = %v, %v, want 1 identity", identities, err)
+	}
+
+	if identities[0].LastUsedAt == nil || !identities[0].LastUsedAt.Equal(usedAt) {
+		t.Errorf("UserIdentityRepo.FindByUserID() last used at = %v, want %v", identities[0].LastUsedAt, usedAt)
+	}
+
+	var notFoundErr *domain.ResourceNotFoundError
+
+	// an identity can only be unlinked by its user
+	if err := repo.Delete(ctx, testUsers[1].ID, created.ID); !errors.As(err, &notFoundErr) {
+		t.Errorf("UserIdentityRepo.Delete() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if err := repo.Delete(ctx, userID, created.ID); err != nil {
+		t.Fatalf("UserIdentityRepo.Delete() error = %v", err)
+	}
+
+	if _, err := repo.Find(ctx, "okta", subject); !errors.As(err, &notFoundErr) {
+		t.Errorf("UserIdentityRepo.Find() error = %v, want ResourceNotFoundError", err)
+	}
+
+	if _, err := NewUserIdentityRepo(&queryerMock{err: errors.New("error")}).FindByUserID(ctx, userID); err == nil {
+		t.Errorf("UserIdentityRepo.FindByUserID() error = nil, wantErr true")
+	}
+}
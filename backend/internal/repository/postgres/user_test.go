@@ -328,6 +328,137 @@ func Test_UserRepo_FindByUsername(t *testing.T) {
 	}
 }
 
+func Test_UserRepo_FindByEmail(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+
+	userRepo := NewUserRepo(conn)
+
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	type args struct {
+		email string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    *domain.User
+		wantErr bool
+	}{
+		{
+			name: "success",
+			args: args{
+				email: "JaneDoe@goadmin.com",
+			},
+			want: &testUsers[1],
+		},
+		{
+			name: "fail",
+			args: args{
+				email: "nobody@goadmin.com",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := userRepo.FindByEmail(context.Background(), tt.args.email)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(
+					"UserRepo.FindByEmail() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf(
+					"UserRepo.FindByEmail() = %v, want %v",
+					got,
+					tt.want,
+				)
+			}
+		})
+	}
+}
+
+func TestUserRepo_UpdatePassword(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+
+	userRepo := NewUserRepo(conn)
+
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	user, err := userRepo.Create(context.Background(), randomUser())
+	if err != nil {
+		t.Fatalf("UserRepo.Create() error = %v", err)
+	}
+
+	if err := userRepo.UpdatePassword(context.Background(), user.ID, "new-hash"); err != nil {
+		t.Fatalf("UserRepo.UpdatePassword() error = %v", err)
+	}
+
+	got, _ := userRepo.FindByID(context.Background(), user.ID)
+	if got.Password != "new-hash" {
+		t.Errorf("UserRepo.UpdatePassword() password = %v, want %v", got.Password, "new-hash")
+	}
+}
+
+func TestUserRepo_MarkEmailVerified(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+
+	userRepo := NewUserRepo(conn)
+
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	user, err := userRepo.Create(context.Background(), randomUser())
+	if err != nil {
+		t.Fatalf("UserRepo.Create() error = %v", err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		t.Fatalf("UserRepo.Create() email_verified_at = %v, want nil", user.EmailVerifiedAt)
+	}
+
+	if err := userRepo.MarkEmailVerified(context.Background(), user.ID); err != nil {
+		t.Fatalf("UserRepo.MarkEmailVerified() error = %v", err)
+	}
+
+	got, _ := userRepo.FindByID(context.Background(), user.ID)
+	if got.EmailVerifiedAt == nil {
+		t.Errorf("UserRepo.MarkEmailVerified() email_verified_at = nil")
+	}
+
+	// the same address stays verified, a new one does not
+	got, err = userRepo.Update(context.Background(), &domain.User{ID: user.ID, Email: user.Email})
+	if err != nil || got.EmailVerifiedAt == nil {
+		t.Errorf("UserRepo.Update() same email = %+v, %v, want still verified", got, err)
+	}
+
+	got, err = userRepo.Update(context.Background(), &domain.User{ID: user.ID, Email: "new." + user.Email})
+	if err != nil || got.EmailVerifiedAt != nil {
+		t.Errorf("UserRepo.Update() new email = %+v, %v, want unverified", got, err)
+	}
+}
+
 func Test_UserRepo_Create(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"goadmin-backend/internal/domain"
+)
+
+var _ domain.UserTokenRepository = &UserTokenRepo{}
+
+type UserTokenRepo struct {
+	db Queryer
+}
+
+func NewUserTokenRepo(db Queryer) *UserTokenRepo {
+	return &UserTokenRepo{
+		db: db,
+	}
+}
+
+// Create stores a newly issued user token
+func (r *UserTokenRepo) Create(ctx context.Context, token *domain.UserToken) error {
+	createQuery := fmt.Sprintf(`INSERT INTO %s (
+		user_id, purpose, token_hash, expires_at
+	) VALUES (
+		$1, $2, $3, $4
+	)`, userTokenTable)
+
+	_, err := exec(
+		ctx,
+		r.db,
+		createQuery,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create user_token error: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns a valid token, leaving it unused
+func (r *UserTokenRepo) Find(
+	ctx context.Context,
+	purpose string,
+	tokenHash string,
+) (*domain.UserToken, error) {
+	findQuery := fmt.Sprintf(`SELECT * FROM %s
+	WHERE purpose = $1 AND token_hash = $2
+		AND used_at IS NULL AND expires_at > NOW()`, userTokenTable)
+
+	token, err := queryRow[domain.UserToken](ctx, r.db, findQuery, purpose, tokenHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("UserToken", "purpose="+purpose)
+		}
+
+		return nil, fmt.Errorf("find user_token error: %w", err)
+	}
+
+	return token, nil
+}
+
+// Consume marks a valid token as used and returns it. The conditional
+// update makes sure a token can only be used once.
+func (r *UserTokenRepo) Consume(
+	ctx context.Context,
+	purpose string,
+	tokenHash string,
+) (*domain.UserToken, error) {
+	consumeQuery := fmt.Sprintf(`UPDATE %s SET
+		used_at = NOW()
+	WHERE purpose = $1 AND token_hash = $2
+		AND used_at IS NULL AND expires_at > NOW()
+	RETURNING *`, userTokenTable)
+
+	token, err := queryRow[domain.UserToken](ctx, r.db, consumeQuery, purpose, tokenHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewResourceNotFoundError("UserToken", "purpose="+purpose)
+		}
+
+		return nil, fmt.Errorf("consume user_token error: %w", err)
+	}
+
+	return token, nil
+}
+
+// DeleteByUserID removes the tokens of a user for a purpose
+func (r *UserTokenRepo) DeleteByUserID(ctx context.Context, userID, purpose string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s
+	WHERE user_id = $1 AND purpose = $2`, userTokenTable)
+
+	_, err := exec(ctx, r.db, deleteQuery, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("delete user_token error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+)
+
+func TestUserTokenRepo_Consume(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := before(t)
+	t.Cleanup(func() {
+		teardown(t)
+	})
+
+	repo := NewUserTokenRepo(conn)
+	ctx := context.Background()
+
+	valid := &domain.UserToken{
+		UserID:    testUsers[0].ID,
+		Purpose:   domain.UserTokenPasswordReset,
+		TokenHash: randToken(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expired := &domain.UserToken{
+		UserID:    testUsers[0].ID,
+		Purpose:   domain.UserTokenPasswordReset,
+		TokenHash: randToken(),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	for _, token := range []*domain.UserToken{valid, expired} {
+		if err := repo.Create(ctx, token); err != nil {
+			t.Fatalf("UserTokenRepo.Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		db        Queryer
+		purpose   string
+		tokenHash string
+		wantErr   bool
+	}{
+		{name: "Expired", db: conn, purpose: expired.Purpose, tokenHash: expired.TokenHash, wantErr: true},
+		{name: "Wrong Purpose", db: conn, purpose: "other", tokenHash: valid.TokenHash, wantErr: true},
+		{name: "Error", db: &queryerMock{err: errors.New("error")}, purpose: valid.Purpose, tokenHash: valid.TokenHash, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewUserTokenRepo(tt.db).Consume(ctx, tt.purpose, tt.tokenHash); (err != nil) != tt.wantErr {
+				t.Errorf("UserTokenRepo.Consume() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// finding a token does not use it up
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Find(ctx, valid.Purpose, valid.TokenHash); err != nil {
+			t.Fatalf("UserTokenRepo.Find() error = %v", err)
+		}
+	}
+
+	if _, err := repo.Find(ctx, expired.Purpose, expired.TokenHash); err == nil {
+		t.Errorf("UserTokenRepo.Find() found an expired token")
+	}
+
+	// concurrent consumers: exactly one wins
+	var (
+		wg   sync.WaitGroup
+		wins atomic.Int32
+	)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if token, err := repo.Consume(ctx, valid.Purpose, valid.TokenHash); err == nil && token.UserID == valid.UserID {
+				wins.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := wins.Load(); got != 1 {
+		t.Errorf("UserTokenRepo.Consume() succeeded %v times, want 1", got)
+	}
+
+	if err := repo.DeleteByUserID(ctx, testUsers[0].ID, domain.UserTokenPasswordReset); err != nil {
+		t.Errorf("UserTokenRepo.DeleteByUserID() error = %v", err)
+	}
+}
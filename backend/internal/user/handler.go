@@ -1,6 +1,7 @@
 package user
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -54,10 +55,17 @@ func (h *Handler) GetByID(res http.ResponseWriter, req *http.Request) {
 	h.RespondJSON(res, auth.ToUserResponse(user), http.StatusOK)
 }
 
+// updateRequest is a user update, and the current password of users
+// changing their own.
+type updateRequest struct {
+	domain.User
+	CurrentPassword string `json:"current_password"`
+}
+
 func (h *Handler) Update(res http.ResponseWriter, req *http.Request) {
 	id := chi.URLParam(req, "id")
 
-	var updates domain.User
+	var updates updateRequest
 	if err := h.ParseJSON(res, req, &updates); err != nil {
 		h.Logger.Error("error decoding update request", slog.Any("err", err))
 
@@ -66,13 +74,54 @@ func (h *Handler) Update(res http.ResponseWriter, req *http.Request) {
 
 	updates.ID = id
 
-	updated, err := h.userService.Update(req.Context(), &updates)
+	var (
+		updated *domain.User
+		err     error
+	)
+
+	if updates.Password != "" {
+		updated, err = h.changePasswordAndUpdate(req, &updates)
+	} else {
+		updated, err = h.userService.Update(req.Context(), &updates.User)
+	}
+
+	if errors.Is(err, ErrVerificationMailNotSent) {
+		// the address is changed, the user can ask for the e-mail again
+		h.Logger.Warn("error sending verification email", slog.Any("err", err))
+
+		err = nil
+	}
+
 	if err != nil {
 		h.Logger.Error("error updating user", slog.Any("err", err))
-		httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+
+		var validationErr *httperr.ValidationError
+
+		switch {
+		case errors.As(err, &validationErr):
+			httperr.JSONError(res, err, http.StatusUnprocessableEntity, req.URL.Path)
+		case errors.Is(err, ErrPasswordChangeForbidden):
+			httperr.JSONError(res, err, http.StatusForbidden, req.URL.Path)
+		case errors.Is(err, ErrPasswordChangeNotSupported):
+			httperr.JSONError(res, err, http.StatusNotImplemented, req.URL.Path)
+		default:
+			httperr.JSONError(res, err, http.StatusInternalServerError, req.URL.Path)
+		}
 
 		return
 	}
 
 	h.RespondJSON(res, auth.ToUserResponse(updated), http.StatusOK)
 }
+
+// changePasswordAndUpdate changes the password of a user on behalf of the
+// user of the request, and then the other fields. API keys and service
+// accounts cannot change passwords.
+func (h *Handler) changePasswordAndUpdate(req *http.Request, updates *updateRequest) (*domain.User, error) {
+	actor, ok := auth.UserFromContext(req.Context())
+	if _, isAPIKey := auth.APIKeyFromContext(req.Context()); !ok || isAPIKey {
+		return nil, ErrPasswordChangeForbidden
+	}
+
+	return h.userService.ChangePasswordAndUpdate(req.Context(), actor.ID, &updates.User, updates.CurrentPassword)
+}
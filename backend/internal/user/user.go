@@ -2,26 +2,63 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
 )
 
 type Service interface {
 	List(ctx context.Context, filter *domain.UserFilter) ([]domain.User, error)
 	GetByID(ctx context.Context, id string) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) (*domain.User, error)
+	// ChangePassword sets the password of a user on behalf of actorID: the
+	// user, giving their current password, or an admin.
+	ChangePassword(ctx context.Context, actorID, userID, currentPassword, password string) error
+	// ChangePasswordAndUpdate changes the password of a user, as
+	// ChangePassword does, and then the other given fields, as Update does.
+	ChangePasswordAndUpdate(ctx context.Context, actorID string, user *domain.User, currentPassword string) (*domain.User, error)
+}
+
+var (
+	ErrPasswordChangeNotSupported = errors.New("password changes are not enabled")
+	ErrPasswordChangeForbidden    = errors.New("only the user or an admin can change a password")
+	ErrVerificationMailNotSent    = errors.New("verification email could not be sent")
+)
+
+// PasswordChanger checks new passwords against the password policy and
+// stores their hashes, and tells admins apart. auth.Service is one.
+type PasswordChanger interface {
+	ChangePassword(ctx context.Context, userID, currentPassword, password string) error
+	SetPassword(ctx context.Context, userID, password string) error
+	HasRole(ctx context.Context, userID string, roles ...string) (bool, error)
+}
+
+// EmailVerifier e-mails users a link to verify their new address.
+// auth.Service is one.
+type EmailVerifier interface {
+	ResendVerificationEmail(ctx context.Context, email string) error
 }
 
 type userService struct {
-	userRepo domain.UserRepository
+	userRepo        domain.UserRepository
+	passwordChanger PasswordChanger
+	emailVerifier   EmailVerifier
 }
 
+// NewUserService returns the user service. Without a passwordChanger
+// updates cannot change passwords, and without an emailVerifier new e-mail
+// addresses are left unverified without a mail.
 func NewUserService( //nolint: ireturn // it's a factory function
 	userRepo domain.UserRepository,
+	passwordChanger PasswordChanger,
+	emailVerifier EmailVerifier,
 ) Service {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:        userRepo,
+		passwordChanger: passwordChanger,
+		emailVerifier:   emailVerifier,
 	}
 }
 
@@ -49,14 +86,155 @@ func (s *userService) GetByID(
 	return user, nil
 }
 
+// Update changes the given fields of a user, but for the password, which
+// only ChangePassword changes. Usernames and e-mail addresses of other users
+// are refused with a ValidationError. A new e-mail address is unverified,
+// and the user is e-mailed a link to verify it; when that e-mail cannot be
+// sent, the updated user is returned with ErrVerificationMailNotSent.
 func (s *userService) Update(
 	ctx context.Context,
 	user *domain.User,
 ) (*domain.User, error) {
+	if err := s.validateUpdate(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, user)
+}
+
+// ChangePasswordAndUpdate validates the other fields before it changes the
+// password, so that an update refused does not change the password and sign
+// the user out everywhere.
+func (s *userService) ChangePasswordAndUpdate(
+	ctx context.Context,
+	actorID string,
+	user *domain.User,
+	currentPassword string,
+) (*domain.User, error) {
+	if err := s.validateUpdate(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.ChangePassword(ctx, actorID, user.ID, currentPassword, user.Password); err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, user)
+}
+
+// validateUpdate refuses usernames and e-mail addresses of other users.
+func (s *userService) validateUpdate(ctx context.Context, user *domain.User) error {
+	var violations []httperr.ValidationErrorItem
+
+	for _, field := range []struct {
+		value, name string
+		find        func(ctx context.Context, value string) (*domain.User, error)
+	}{
+		{user.Username, "username", s.userRepo.FindByUsername},
+		{user.Email, "email", s.userRepo.FindByEmail},
+	} {
+		if field.value == "" {
+			continue
+		}
+
+		other, err := field.find(ctx, field.value)
+
+		var notFoundErr *domain.ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &notFoundErr):
+		case err != nil:
+			return fmt.Errorf("find user by %s error: %w", field.name, err)
+		case other.ID != user.ID:
+			violations = append(violations, httperr.ValidationErrorItem{
+				Detail:  "The " + field.name + " is taken",
+				Pointer: "/" + field.name,
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return httperr.NewValidationError("", "The user cannot be updated", violations)
+	}
+
+	return nil
+}
+
+func (s *userService) update(
+	ctx context.Context,
+	user *domain.User,
+) (*domain.User, error) {
+	user.Password = ""
+
+	if !hasProfileUpdates(user) {
+		return s.GetByID(ctx, user.ID)
+	}
+
+	var previousEmail string
+
+	if user.Email != "" {
+		current, err := s.GetByID(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		previousEmail = current.Email
+	}
+
 	updated, err := s.userRepo.Update(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("update user error: %w", err)
 	}
 
+	if user.Email == "" || updated.Email == previousEmail || s.emailVerifier == nil {
+		return updated, nil
+	}
+
+	if err := s.emailVerifier.ResendVerificationEmail(ctx, updated.Email); err != nil {
+		return updated, errors.Join(ErrVerificationMailNotSent, err)
+	}
+
 	return updated, nil
 }
+
+// ChangePassword lets users change their own password, giving the current
+// one, and admins set the password of other users. The new password has to
+// meet the password policy.
+func (s *userService) ChangePassword(
+	ctx context.Context,
+	actorID, userID, currentPassword, password string,
+) error {
+	if s.passwordChanger == nil {
+		return ErrPasswordChangeNotSupported
+	}
+
+	if actorID == userID {
+		if err := s.passwordChanger.ChangePassword(ctx, userID, currentPassword, password); err != nil {
+			return fmt.Errorf("change password error: %w", err)
+		}
+
+		return nil
+	}
+
+	admin, err := s.passwordChanger.HasRole(ctx, actorID, domain.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("check role error: %w", err)
+	}
+
+	if !admin {
+		return ErrPasswordChangeForbidden
+	}
+
+	if err := s.passwordChanger.SetPassword(ctx, userID, password); err != nil {
+		return fmt.Errorf("set password error: %w", err)
+	}
+
+	return nil
+}
+
+// hasProfileUpdates reports whether user sets any field UserRepository.Update
+// changes.
+func hasProfileUpdates(user *domain.User) bool {
+	return user.Username != "" || user.Email != "" || user.FirstName != "" ||
+		user.LastName != "" || user.Picture != ""
+}
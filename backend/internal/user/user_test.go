@@ -0,0 +1,237 @@
+package user
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goadmin-backend/internal/domain"
+	"goadmin-backend/internal/platform/httperr"
+)
+
+// passwordChangerMock records the password changes it was asked for.
+type passwordChangerMock struct {
+	changed, set string
+}
+
+func (p *passwordChangerMock) ChangePassword(_ context.Context, userID, _, _ string) error {
+	p.changed = userID
+
+	return nil
+}
+
+func (p *passwordChangerMock) SetPassword(_ context.Context, userID, _ string) error {
+	p.set = userID
+
+	return nil
+}
+
+func (p *passwordChangerMock) HasRole(_ context.Context, userID string, roles ...string) (bool, error) {
+	return userID == "admin" && len(roles) == 1 && roles[0] == domain.RoleAdmin, nil
+}
+
+// userRepoMock is a user the service updates, besides other users.
+type userRepoMock struct {
+	domain.UserRepository
+	user   domain.User
+	others []domain.User
+}
+
+func (u *userRepoMock) find(matches func(user domain.User) bool) (*domain.User, error) {
+	for _, user := range append([]domain.User{u.user}, u.others...) {
+		if matches(user) {
+			return &user, nil
+		}
+	}
+
+	return nil, domain.NewResourceNotFoundError("User", "")
+}
+
+func (u *userRepoMock) FindByUsername(_ context.Context, username string) (*domain.User, error) {
+	return u.find(func(user domain.User) bool { return user.Username == username })
+}
+
+func (u *userRepoMock) FindByEmail(_ context.Context, email string) (*domain.User, error) {
+	return u.find(func(user domain.User) bool { return user.Email == email })
+}
+
+func (u *userRepoMock) FindByID(_ context.Context, _ string) (*domain.User, error) {
+	found := u.user
+
+	return &found, nil
+}
+
+func (u *userRepoMock) Update(_ context.Context, user *domain.User) (*domain.User, error) {
+	if user.Email != "" && user.Email != u.user.Email {
+		u.user.Email, u.user.EmailVerifiedAt = user.Email, nil
+	}
+
+	u.user.FirstName = cmp.Or(user.FirstName, u.user.FirstName)
+
+	return u.FindByID(context.Background(), user.ID)
+}
+
+// emailVerifierMock records the addresses it e-mailed, or fails.
+type emailVerifierMock struct {
+	emails []string
+	err    error
+}
+
+func (e *emailVerifierMock) ResendVerificationEmail(_ context.Context, email string) error {
+	e.emails = append(e.emails, email)
+
+	return e.err
+}
+
+func TestUserService_Update(t *testing.T) {
+	t.Parallel()
+
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		update       domain.User
+		verifierErr  error
+		wantVerified bool
+		wantMails    int
+		wantErr      error
+	}{
+		{name: "New Email", update: domain.User{Email: "new@example.com"}, wantMails: 1},
+		{name: "Same Email", update: domain.User{Email: "user@example.com"}, wantVerified: true},
+		{name: "Other Fields", update: domain.User{FirstName: "New"}, wantVerified: true},
+		{name: "Taken Email", update: domain.User{Email: "other@example.com"}, wantErr: &httperr.ValidationError{}},
+		{
+			name:        "Mail Not Sent",
+			update:      domain.User{Email: "new@example.com"},
+			verifierErr: errors.New("smtp error"),
+			wantMails:   1,
+			wantErr:     ErrVerificationMailNotSent,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &userRepoMock{
+				user:   domain.User{ID: "1", Email: "user@example.com", EmailVerifiedAt: &verifiedAt},
+				others: []domain.User{{ID: "2", Email: "other@example.com"}},
+			}
+			verifier := &emailVerifierMock{err: tt.verifierErr}
+			s := NewUserService(repo, nil, verifier)
+
+			tt.update.ID = "1"
+
+			got, err := s.Update(context.Background(), &tt.update)
+
+			var validationErr *httperr.ValidationError
+			if errors.As(tt.wantErr, &validationErr) {
+				if !errors.As(err, &validationErr) || repo.user.Email != "user@example.com" {
+					t.Errorf("userService.Update() = %+v, %v, want the user unchanged and a ValidationError", repo.user, err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("userService.Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got == nil || (got.EmailVerifiedAt != nil) != tt.wantVerified {
+				t.Errorf("userService.Update() = %+v, want verified %v", got, tt.wantVerified)
+			}
+
+			if len(verifier.emails) != tt.wantMails {
+				t.Errorf("userService.Update() sent %v verification mails, want %v", len(verifier.emails), tt.wantMails)
+			}
+		})
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		actorID     string
+		userID      string
+		wantErr     error
+		wantChanged string
+		wantSet     string
+	}{
+		{name: "Own Password", actorID: "1", userID: "1", wantChanged: "1"},
+		{name: "Admin", actorID: "admin", userID: "1", wantSet: "1"},
+		{name: "Admin Own Password", actorID: "admin", userID: "admin", wantChanged: "admin"},
+		{name: "Other User", actorID: "2", userID: "1", wantErr: ErrPasswordChangeForbidden},
+	}
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			changer := &passwordChangerMock{}
+			s := NewUserService(nil, changer, nil)
+
+			err := s.ChangePassword(context.Background(), tt.actorID, tt.userID, "current", "new password")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("userService.ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if changer.changed != tt.wantChanged || changer.set != tt.wantSet {
+				t.Errorf("userService.ChangePassword() changed %q and set %q, want %q and %q",
+					changer.changed, changer.set, tt.wantChanged, tt.wantSet)
+			}
+		})
+	}
+
+	if err := NewUserService(nil, nil, nil).ChangePassword(context.Background(), "1", "1", "", "p"); !errors.Is(err, ErrPasswordChangeNotSupported) {
+		t.Errorf("userService.ChangePassword() error = %v, want %v", err, ErrPasswordChangeNotSupported)
+	}
+}
+
+func TestUserService_ChangePasswordAndUpdate(t *testing.T) {
+	t.Parallel()
+
+	newRepo := func() *userRepoMock {
+		return &userRepoMock{
+			user:   domain.User{ID: "1", Email: "user@example.com", FirstName: "Old"},
+			others: []domain.User{{ID: "2", Email: "other@example.com"}},
+		}
+	}
+
+	repo, changer := newRepo(), &passwordChangerMock{}
+
+	got, err := NewUserService(repo, changer, nil).ChangePasswordAndUpdate(
+		context.Background(), "1", &domain.User{ID: "1", FirstName: "New", Password: "new password"}, "current")
+	if err != nil || got.FirstName != "New" || changer.changed != "1" {
+		t.Errorf("userService.ChangePasswordAndUpdate() = %+v, %v, changed %q, want both changed", got, err, changer.changed)
+	}
+
+	// an update refused leaves the password unchanged
+	repo, changer = newRepo(), &passwordChangerMock{}
+
+	_, err = NewUserService(repo, changer, nil).ChangePasswordAndUpdate(
+		context.Background(), "1", &domain.User{ID: "1", Email: "other@example.com", FirstName: "New", Password: "new password"}, "current")
+
+	var validationErr *httperr.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("userService.ChangePasswordAndUpdate() error = %v, want a ValidationError", err)
+	}
+
+	if changer.changed != "" || repo.user.FirstName != "Old" {
+		t.Errorf("userService.ChangePasswordAndUpdate() changed %q and the user to %+v, want neither changed", changer.changed, repo.user)
+	}
+
+	// a password change refused leaves the user unchanged
+	repo = newRepo()
+
+	_, err = NewUserService(repo, nil, nil).ChangePasswordAndUpdate(
+		context.Background(), "1", &domain.User{ID: "1", FirstName: "New", Password: "new password"}, "current")
+	if !errors.Is(err, ErrPasswordChangeNotSupported) || repo.user.FirstName != "Old" {
+		t.Errorf("userService.ChangePasswordAndUpdate() = %+v, %v, want the user unchanged and %v", repo.user, err, ErrPasswordChangeNotSupported)
+	}
+}